@@ -5,14 +5,47 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"Contact_App/component/auth"
+	bulkController "Contact_App/component/bulk/controller"
+	bulkService "Contact_App/component/bulk/service"
+	cardLinkController "Contact_App/component/card_link/controller"
+	cardLinkService "Contact_App/component/card_link/service"
+	carddavController "Contact_App/component/carddav/controller"
+	carddavService "Contact_App/component/carddav/service"
 	contactController "Contact_App/component/contact/controller"
 	"Contact_App/component/contact/service"
 	contactDetailController "Contact_App/component/contact_detail/controller"
+	customFieldController "Contact_App/component/custom_field/controller"
+	customFieldService "Contact_App/component/custom_field/service"
+	duplicateController "Contact_App/component/duplicate/controller"
+	duplicateService "Contact_App/component/duplicate/service"
+	groupController "Contact_App/component/group/controller"
+	groupService "Contact_App/component/group/service"
+	historyController "Contact_App/component/history/controller"
+	historyService "Contact_App/component/history/service"
+	dateController "Contact_App/component/important_date/controller"
+	dateService "Contact_App/component/important_date/service"
+	interactionController "Contact_App/component/interaction/controller"
+	interactionService "Contact_App/component/interaction/service"
+	organizationController "Contact_App/component/organization/controller"
+	organizationService "Contact_App/component/organization/service"
+	photoController "Contact_App/component/photo/controller"
+	photoService "Contact_App/component/photo/service"
+	qualityController "Contact_App/component/quality/controller"
+	qualityService "Contact_App/component/quality/service"
+	relationshipController "Contact_App/component/relationship/controller"
+	relationshipService "Contact_App/component/relationship/service"
+	shareController "Contact_App/component/share/controller"
+	shareService "Contact_App/component/share/service"
+	taskController "Contact_App/component/task/controller"
+	taskService "Contact_App/component/task/service"
 	userController "Contact_App/component/user/controller"
+	workspaceController "Contact_App/component/workspace/controller"
+	workspaceService "Contact_App/component/workspace/service"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
@@ -25,6 +58,7 @@ type App struct {
 	DB     *gorm.DB
 	Server *http.Server
 	WG     *sync.WaitGroup
+	Quit   chan struct{}
 }
 
 func NewApp(db *gorm.DB) *App {
@@ -33,20 +67,34 @@ func NewApp(db *gorm.DB) *App {
 		Router: mux.NewRouter().StrictSlash(true),
 		DB:     db,
 		WG:     wg,
+		Quit:   make(chan struct{}),
 	}
 
 	app.registerRoutes()
+	app.startBackgroundJobs()
 	return app
 }
 
 func (app *App) InitServer(port string) {
-	headers := handlers.AllowedHeaders([]string{"Content-Type", "X-Total-Count", "token", "Authorization"})
-	methods := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"})
+	headers := handlers.AllowedHeaders([]string{"Content-Type", "X-Total-Count", "token", "Authorization", "If-Match", "If-None-Match"})
+	methods := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"})
 	origins := handlers.AllowedOrigins([]string{"*"})
+	// browsers only let scripts read the ETag for If-Match when it is exposed
+	exposed := handlers.ExposedHeaders([]string{"ETag"})
+
+	cors := handlers.CORS(headers, methods, origins, exposed)(app.Router)
 
 	app.Server = &http.Server{
-		Addr:    port,
-		Handler: handlers.CORS(headers, methods, origins)(app.Router),
+		Addr: port,
+		// CardDAV clients send OPTIONS without an Origin, which the CORS
+		// handler would answer itself instead of passing it on
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/carddav") || r.URL.Path == "/.well-known/carddav" {
+				app.Router.ServeHTTP(w, r)
+				return
+			}
+			cors.ServeHTTP(w, r)
+		}),
 	}
 }
 
@@ -61,6 +109,9 @@ func (app *App) Stop() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	close(app.Quit)
+	app.WG.Wait()
+
 	sqlDB, err := app.DB.DB()
 	if err == nil {
 		sqlDB.Close()
@@ -83,8 +134,49 @@ func (app *App) registerRoutes() {
 	cService := service.NewContactService()
 	cController := contactController.NewContactController(cService)
 	cdHandler := contactDetailController.NewContactDetailHandler(app.DB)
+	pService := photoService.NewPhotoService()
+	gController := groupController.NewGroupController(groupService.NewGroupService(), pService)
+	dController := duplicateController.NewDuplicateController(duplicateService.NewDuplicateService())
+	hController := historyController.NewHistoryController(historyService.NewHistoryService())
+	bController := bulkController.NewBulkController(bulkService.NewBulkService())
+	pController := photoController.NewPhotoController(pService)
+	idController := dateController.NewDateController(dateService.NewDateService())
+	iController := interactionController.NewInteractionController(interactionService.NewInteractionService())
+	oController := organizationController.NewOrganizationController(organizationService.NewOrganizationService())
+	rController := relationshipController.NewRelationshipController(relationshipService.NewRelationshipService())
+	cfController := customFieldController.NewCustomFieldController(customFieldService.NewCustomFieldService())
+	sController := shareController.NewShareController(shareService.NewShareService())
+	wController := workspaceController.NewWorkspaceController(workspaceService.NewWorkspaceService())
+	davController := carddavController.NewCardDAVController(carddavService.NewCardDAVService(cService))
+	clController := cardLinkController.NewCardLinkController(cardLinkService.NewCardLinkService(cService))
+	tController := taskController.NewTaskController(taskService.NewTaskService())
+	qController := qualityController.NewQualityController(qualityService.NewQualityService(cService))
 
 	uHandler.RegisterRoutes(api)
 	cController.RegisterRoutes(api)
 	cdHandler.RegisterRoutes(api)
+	gController.RegisterRoutes(api)
+	dController.RegisterRoutes(api)
+	hController.RegisterRoutes(api)
+	bController.RegisterRoutes(api)
+	pController.RegisterRoutes(api)
+	idController.RegisterRoutes(api)
+	idController.RegisterPublicRoutes(app.Router)
+	iController.RegisterRoutes(api)
+	oController.RegisterRoutes(api)
+	rController.RegisterRoutes(api)
+	cfController.RegisterRoutes(api)
+	sController.RegisterRoutes(api)
+	wController.RegisterRoutes(api)
+	davController.RegisterRoutes(api)
+	davController.RegisterDAVRoutes(app.Router)
+	clController.RegisterRoutes(api)
+	clController.RegisterPublicRoutes(app.Router)
+	tController.RegisterRoutes(api)
+	qController.RegisterRoutes(api)
+}
+
+func (app *App) startBackgroundJobs() {
+	service.NewTrashPurger(service.NewContactService()).Start(app.WG, app.Quit)
+	taskService.NewReminderScheduler(taskService.NewTaskService()).Start(app.WG, app.Quit)
 }
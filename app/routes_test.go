@@ -0,0 +1,115 @@
+package app
+
+import (
+	"Contact_App/component/auth"
+	"Contact_App/db/dbtest"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	app := &App{Router: mux.NewRouter().StrictSlash(true), DB: dbtest.Open(t)}
+	app.registerRoutes()
+	return app
+}
+
+// serve runs a request through the router, authenticated as userID unless it is 0
+func serve(t *testing.T, app *App, method, target string, userID uint) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, target, nil)
+	if userID != 0 {
+		token, err := auth.GenerateToken(int(userID), false, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	app.Router.ServeHTTP(w, r)
+	return w
+}
+
+// publicPath reads the URL a JSON response hands out and returns its path
+func publicPath(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(body.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Path
+}
+
+// The URL of a card link opens the card without an account.
+func TestPublicCardLinkNeedsNoToken(t *testing.T) {
+	app := newTestApp(t)
+	owner := dbtest.User(t, app.DB, "owner@example.com")
+	c := dbtest.Contact(t, app.DB, owner, nil, "Ada", "Lovelace")
+
+	created := serve(t, app, http.MethodPost,
+		"/api/v1/users/"+strconv.Itoa(int(owner.UserID))+"/contacts/"+strconv.Itoa(int(c.ContactID))+"/links", owner.UserID)
+	if created.Code != http.StatusCreated {
+		t.Fatalf("create link status = %d: %s", created.Code, created.Body)
+	}
+	path := publicPath(t, created)
+
+	w := serve(t, app, http.MethodGet, path, 0)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s status = %d: %s", path, w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), "FN:Ada Lovelace") {
+		t.Errorf("GET %s body = %q, want the vCard", path, w.Body)
+	}
+
+	if w := serve(t, app, http.MethodGet, "/cards/0123456789abcdef.vcf", 0); w.Code != http.StatusNotFound {
+		t.Errorf("unknown card token status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+// Calendar apps subscribe to the feed URL without a token.
+func TestCalendarFeedNeedsNoToken(t *testing.T) {
+	app := newTestApp(t)
+	owner := dbtest.User(t, app.DB, "owner@example.com")
+
+	feed := serve(t, app, http.MethodGet, "/api/v1/users/"+strconv.Itoa(int(owner.UserID))+"/calendar-feed", owner.UserID)
+	if feed.Code != http.StatusOK {
+		t.Fatalf("get feed status = %d: %s", feed.Code, feed.Body)
+	}
+	path := publicPath(t, feed)
+
+	w := serve(t, app, http.MethodGet, path, 0)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s status = %d: %s", path, w.Code, w.Body)
+	}
+	if !strings.HasPrefix(w.Body.String(), "BEGIN:VCALENDAR") {
+		t.Errorf("GET %s body = %q, want a calendar", path, w.Body)
+	}
+}
+
+// Browser clients read the ETag to send it back in If-Match.
+func TestCORSExposesETag(t *testing.T) {
+	app := newTestApp(t)
+	app.InitServer(":0")
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/login", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	w := httptest.NewRecorder()
+	app.Server.Handler.ServeHTTP(w, r)
+
+	if got := w.Header().Get("Access-Control-Expose-Headers"); !strings.EqualFold(got, "ETag") {
+		t.Errorf("Access-Control-Expose-Headers = %q, want ETag", got)
+	}
+}
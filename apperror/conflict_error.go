@@ -0,0 +1,13 @@
+package apperror
+
+import "net/http"
+
+type ConflictError struct{ *BaseAppError }
+
+func NewConflictError(context, message string) *ConflictError {
+	return &ConflictError{&BaseAppError{
+		Code:    http.StatusConflict,
+		Message: message,
+		Context: context,
+	}}
+}
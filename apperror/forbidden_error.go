@@ -0,0 +1,13 @@
+package apperror
+
+import "net/http"
+
+type ForbiddenError struct{ *BaseAppError }
+
+func NewForbiddenError(context, message string) *ForbiddenError {
+	return &ForbiddenError{&BaseAppError{
+		Code:    http.StatusForbidden,
+		Message: message,
+		Context: context,
+	}}
+}
@@ -0,0 +1,13 @@
+package apperror
+
+import "net/http"
+
+type GoneError struct{ *BaseAppError }
+
+func NewGoneError(context, message string) *GoneError {
+	return &GoneError{&BaseAppError{
+		Code:    http.StatusGone,
+		Message: message,
+		Context: context,
+	}}
+}
@@ -0,0 +1,16 @@
+package apperror
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type UnsupportedMediaTypeError struct{ *BaseAppError }
+
+func NewUnsupportedMediaTypeError(got string, supported ...string) *UnsupportedMediaTypeError {
+	return &UnsupportedMediaTypeError{&BaseAppError{
+		Code:    http.StatusUnsupportedMediaType,
+		Message: fmt.Sprintf("unsupported content type %q, expected one of %v", got, supported),
+		Context: "content_type",
+	}}
+}
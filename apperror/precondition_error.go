@@ -0,0 +1,16 @@
+package apperror
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type PreconditionFailedError struct{ *BaseAppError }
+
+func NewPreconditionFailedError(resource string, currentVersion uint) *PreconditionFailedError {
+	return &PreconditionFailedError{&BaseAppError{
+		Code:    http.StatusPreconditionFailed,
+		Message: fmt.Sprintf("%s was modified concurrently; current version is %d", resource, currentVersion),
+		Context: resource,
+	}}
+}
@@ -0,0 +1,59 @@
+package blobstore
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned by Get when no blob is stored under the key
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps opaque binary objects under slash-separated keys. Implementations
+// must be safe for concurrent use.
+type Store interface {
+	Put(key string, r io.Reader) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+var (
+	defaultStore Store
+	defaultOnce  sync.Once
+)
+
+// Default returns the process-wide store: a LocalStore rooted at
+// BLOB_STORE_DIR (default "data/blobs")
+func Default() Store {
+	defaultOnce.Do(func() {
+		root := os.Getenv("BLOB_STORE_DIR")
+		if root == "" {
+			root = "data/blobs"
+		}
+		store, err := NewLocalStore(root)
+		if err != nil {
+			log.Fatalf("blob store init failed: %v", err)
+		}
+		defaultStore = store
+	})
+	return defaultStore
+}
+
+// SetDefault replaces the process-wide store, e.g. with a cloud-backed implementation
+func SetDefault(store Store) {
+	defaultOnce.Do(func() {})
+	defaultStore = store
+}
+
+// cleanKey rejects keys that are empty, absolute or try to escape the store root
+func cleanKey(key string) (string, error) {
+	cleaned := path.Clean(strings.TrimSpace(key))
+	if cleaned == "." || cleaned == "" || strings.HasPrefix(cleaned, "/") || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", errors.New("invalid blob key " + key)
+	}
+	return cleaned, nil
+}
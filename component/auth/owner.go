@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"Contact_App/apperror"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// AuthorizeOwner resolves {userID} and makes sure it matches the caller's
+// token. It writes the error response and reports false when it does not.
+func AuthorizeOwner(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	claims := GetUserClaims(r)
+	if claims == nil {
+		apperror.HandleUnauthorized(w, "missing or invalid token")
+		return 0, false
+	}
+
+	userID64, err := strconv.ParseUint(mux.Vars(r)["userID"], 10, 64)
+	if err != nil {
+		apperror.HandleBadRequest(w, "invalid userID")
+		return 0, false
+	}
+
+	if claims.UserID != int(userID64) {
+		http.Error(w, "Forbidden: cannot access another user's data", http.StatusForbidden)
+		return 0, false
+	}
+	return uint(userID64), true
+}
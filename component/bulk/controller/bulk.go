@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/component/auth"
+	"Contact_App/component/bulk/service"
+	"Contact_App/web"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type BulkController struct {
+	Service *service.BulkService
+}
+
+func NewBulkController(svc *service.BulkService) *BulkController {
+	return &BulkController{Service: svc}
+}
+
+// POST /users/{userID}/contacts/bulk
+// Body: {"mode": "atomic"|"best_effort", "operations": [...]}
+func (c *BulkController) BulkHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Mode       string              `json:"mode"`
+		Operations []service.Operation `json:"operations"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	resp, err := c.Service.Execute(userID, input.Mode, input.Operations)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	// an atomic batch that failed answers with the failing operation's status;
+	// a best-effort batch with some failures answers 207
+	status := http.StatusOK
+	switch {
+	case !resp.Committed:
+		for _, result := range resp.Results {
+			if result.Status != http.StatusFailedDependency {
+				status = result.Status
+				break
+			}
+		}
+	case resp.Failed > 0:
+		status = http.StatusMultiStatus
+	}
+
+	web.RespondJSON(w, status, resp)
+}
+
+func (c *BulkController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/users/{userID}/contacts/bulk", c.BulkHandler).Methods("POST")
+}
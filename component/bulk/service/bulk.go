@@ -0,0 +1,279 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	contactService "Contact_App/component/contact/service"
+	detailService "Contact_App/component/contact_detail/service"
+	groupService "Contact_App/component/group/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/repository"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+const (
+	ModeAtomic     = "atomic"
+	ModeBestEffort = "best_effort"
+)
+
+const (
+	OpCreateContact = "create_contact"
+	OpUpdateContact = "update_contact"
+	OpDeleteContact = "delete_contact"
+	OpCreateDetail  = "create_detail"
+	OpUpdateDetail  = "update_detail"
+	OpDeleteDetail  = "delete_detail"
+	OpMoveToGroup   = "move_to_group"
+)
+
+// Operation is one entry of a bulk request. Data carries the same body the
+// matching single-item endpoint accepts.
+type Operation struct {
+	Op          string          `json:"op"`
+	ContactID   uint            `json:"contact_id,omitempty"`
+	DetailID    uint            `json:"detail_id,omitempty"`
+	GroupID     uint            `json:"group_id,omitempty"`
+	FromGroupID uint            `json:"from_group_id,omitempty"`
+	IfMatch     uint            `json:"if_match,omitempty"`
+	Data        json.RawMessage `json:"data,omitempty"`
+}
+
+// ErrorBody mirrors the JSON written by apperror.HandleError
+type ErrorBody struct {
+	Message string `json:"message"`
+	Context string `json:"context"`
+}
+
+// Result reports the outcome of one operation with the status code and body
+// the single-item endpoint would have returned
+type Result struct {
+	Index  int         `json:"index"`
+	Op     string      `json:"op"`
+	Status int         `json:"status"`
+	Data   interface{} `json:"data,omitempty"`
+	Error  *ErrorBody  `json:"error,omitempty"`
+}
+
+type Response struct {
+	Mode      string    `json:"mode"`
+	Committed bool      `json:"committed"`
+	Succeeded int       `json:"succeeded"`
+	Failed    int       `json:"failed"`
+	Results   []*Result `json:"results"`
+}
+
+type BulkService struct {
+	contacts *contactService.ContactService
+	groups   *groupService.GroupService
+}
+
+func NewBulkService() *BulkService {
+	return &BulkService{
+		contacts: contactService.NewContactService(),
+		groups:   groupService.NewGroupService(),
+	}
+}
+
+// MaxOperations caps how many operations one bulk request may carry (BULK_MAX_OPERATIONS, default 500)
+func MaxOperations() int {
+	if n, err := strconv.Atoi(os.Getenv("BULK_MAX_OPERATIONS")); err == nil && n > 0 {
+		return n
+	}
+	return 500
+}
+
+// Execute runs ops inside one transaction. In atomic mode the first failure
+// rolls everything back; in best-effort mode each operation runs behind its
+// own savepoint so a failure only undoes that operation.
+func (s *BulkService) Execute(userID uint, mode string, ops []Operation) (*Response, error) {
+	if mode == "" {
+		mode = ModeAtomic
+	}
+	if mode != ModeAtomic && mode != ModeBestEffort {
+		return nil, apperror.NewValidationError("mode", "must be atomic or best_effort")
+	}
+	if len(ops) == 0 {
+		return nil, apperror.NewValidationError("operations", "cannot be empty")
+	}
+	if limit := MaxOperations(); len(ops) > limit {
+		return nil, apperror.NewValidationError("operations", fmt.Sprintf("at most %d operations per request", limit))
+	}
+
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	resp := &Response{Mode: mode, Results: make([]*Result, len(ops))}
+	for i, op := range ops {
+		savepoint := fmt.Sprintf("bulk_op_%d", i)
+		if mode == ModeBestEffort {
+			if err := uow.SavePoint(savepoint); err != nil {
+				return nil, err
+			}
+		}
+
+		status, data, err := s.apply(uow, userID, op)
+		if err == nil {
+			resp.Results[i] = &Result{Index: i, Op: op.Op, Status: status, Data: data}
+			resp.Succeeded++
+			continue
+		}
+
+		resp.Results[i] = errorResult(i, op.Op, err)
+		resp.Failed++
+		if mode == ModeAtomic {
+			abortRemaining(resp, ops, i)
+			return resp, nil
+		}
+		if err := uow.RollbackTo(savepoint); err != nil {
+			return nil, err
+		}
+	}
+
+	uow.Commit()
+	resp.Committed = true
+	return resp, nil
+}
+
+func (s *BulkService) apply(uow *repository.UnitOfWork, userID uint, op Operation) (int, interface{}, error) {
+	switch op.Op {
+	case OpCreateContact:
+		var input contactService.ContactInput
+		if err := decodeData(op, &input); err != nil {
+			return 0, nil, err
+		}
+		created, err := s.contacts.CreateContactWithDetailsUOW(uow, userID, input)
+		if err != nil {
+			return 0, nil, err
+		}
+		return http.StatusCreated, created, nil
+
+	case OpUpdateContact:
+		if op.ContactID == 0 {
+			return 0, nil, apperror.NewValidationError("contact_id", "is required")
+		}
+		var updates map[string]interface{}
+		if err := decodeData(op, &updates); err != nil {
+			return 0, nil, err
+		}
+		if _, err := s.contacts.UpdateContactWithUOW(uow, userID, op.ContactID, updates, op.IfMatch); err != nil {
+			return 0, nil, err
+		}
+		return http.StatusOK, map[string]string{"message": "contact updated"}, nil
+
+	case OpDeleteContact:
+		if op.ContactID == 0 {
+			return 0, nil, apperror.NewValidationError("contact_id", "is required")
+		}
+		if err := s.contacts.DeleteContactWithUOW(uow, userID, op.ContactID, op.IfMatch); err != nil {
+			return 0, nil, err
+		}
+		return http.StatusOK, map[string]string{"message": "contact deleted"}, nil
+
+	case OpCreateDetail:
+		if op.ContactID == 0 {
+			return 0, nil, apperror.NewValidationError("contact_id", "is required")
+		}
+		var input struct {
+			Type  string `json:"type"`
+			Value string `json:"value"`
+		}
+		if err := decodeData(op, &input); err != nil {
+			return 0, nil, err
+		}
+		detail, err := detailService.AddDetailWithUOW(uow, int(userID), int(op.ContactID), input.Type, input.Value)
+		if err != nil {
+			return 0, nil, err
+		}
+		return http.StatusCreated, detail, nil
+
+	case OpUpdateDetail:
+		if op.ContactID == 0 || op.DetailID == 0 {
+			return 0, nil, apperror.NewValidationError("contact_id/detail_id", "are required")
+		}
+		var input detailService.UpdateDetailInput
+		if err := decodeData(op, &input); err != nil {
+			return 0, nil, err
+		}
+		if _, err := detailService.UpdateDetailWithUOW(uow, int(userID), int(op.ContactID), int(op.DetailID), input, op.IfMatch); err != nil {
+			return 0, nil, err
+		}
+		return http.StatusOK, map[string]string{"message": "Contact detail updated successfully"}, nil
+
+	case OpDeleteDetail:
+		if op.ContactID == 0 || op.DetailID == 0 {
+			return 0, nil, apperror.NewValidationError("contact_id/detail_id", "are required")
+		}
+		if err := detailService.DeleteDetailWithUOW(uow, int(userID), int(op.ContactID), int(op.DetailID), op.IfMatch); err != nil {
+			return 0, nil, err
+		}
+		return http.StatusOK, map[string]string{"message": "Contact detail deleted successfully"}, nil
+
+	case OpMoveToGroup:
+		if op.ContactID == 0 || op.GroupID == 0 {
+			return 0, nil, apperror.NewValidationError("contact_id/group_id", "are required")
+		}
+		removed := 0
+		if op.FromGroupID != 0 {
+			var err error
+			if removed, err = s.groups.RemoveContactsWithUOW(uow, userID, op.FromGroupID, []uint{op.ContactID}); err != nil {
+				return 0, nil, err
+			}
+		}
+		added, err := s.groups.AddContactsWithUOW(uow, userID, op.GroupID, []uint{op.ContactID})
+		if err != nil {
+			return 0, nil, err
+		}
+		return http.StatusOK, map[string]interface{}{"message": "contact moved to group", "added": added, "removed": removed}, nil
+	}
+	return 0, nil, apperror.NewValidationError("op", fmt.Sprintf("unknown operation %q", op.Op))
+}
+
+func decodeData(op Operation, out interface{}) error {
+	if len(op.Data) == 0 {
+		return apperror.NewValidationError("data", "is required for "+op.Op)
+	}
+	if err := json.Unmarshal(op.Data, out); err != nil {
+		return apperror.NewValidationError("data", "invalid JSON")
+	}
+	return nil
+}
+
+func errorResult(index int, op string, err error) *Result {
+	appErr, ok := err.(apperror.AppError)
+	if !ok {
+		appErr = apperror.NewInternalError("Something went wrong")
+	}
+	return &Result{
+		Index:  index,
+		Op:     op,
+		Status: appErr.StatusCode(),
+		Error:  &ErrorBody{Message: appErr.MessageText(), Context: appErr.ErrorContext()},
+	}
+}
+
+// abortRemaining marks every operation other than the failed one as rolled
+// back or skipped once an atomic batch has failed
+func abortRemaining(resp *Response, ops []Operation, failed int) {
+	for i := range resp.Results {
+		if i == failed {
+			continue
+		}
+		message := "not executed because an earlier operation failed"
+		if i < failed {
+			message = "rolled back because a later operation failed"
+		}
+		resp.Results[i] = &Result{
+			Index:  i,
+			Op:     ops[i].Op,
+			Status: http.StatusFailedDependency,
+			Error:  &ErrorBody{Message: message, Context: "bulk"},
+		}
+	}
+	resp.Succeeded = 0
+}
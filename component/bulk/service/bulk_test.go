@@ -0,0 +1,138 @@
+package service
+
+import (
+	contactService "Contact_App/component/contact/service"
+	"Contact_App/db/dbtest"
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_share"
+	"Contact_App/models/user"
+	"Contact_App/models/workspace"
+	"Contact_App/repository"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func updateOp(contactID uint, firstName string) Operation {
+	data, _ := json.Marshal(map[string]string{"first_name": firstName})
+	return Operation{Op: OpUpdateContact, ContactID: contactID, Data: data}
+}
+
+// Bulk updates and deletes are subject to the same access checks as the
+// single-contact endpoints.
+func TestBulkContactAccess(t *testing.T) {
+	conn := dbtest.Open(t)
+	owner := dbtest.User(t, conn, "owner@example.com")
+	writer := dbtest.User(t, conn, "writer@example.com")
+	reader := dbtest.User(t, conn, "reader@example.com")
+	viewer := dbtest.User(t, conn, "viewer@example.com")
+
+	team := dbtest.Workspace(t, conn, "Team", map[*user.User]string{owner: workspace.RoleAdmin, viewer: workspace.RoleViewer})
+	// created by the viewer before they were demoted
+	teamContact := dbtest.Contact(t, conn, viewer, &team.WorkspaceID, "Team", "Contact")
+
+	writeShared := dbtest.Contact(t, conn, owner, nil, "Write", "Shared")
+	dbtest.ShareContact(t, conn, owner, writer, writeShared.ContactID, contact_share.PermissionWrite)
+	readShared := dbtest.Contact(t, conn, owner, nil, "Read", "Shared")
+	dbtest.ShareContact(t, conn, owner, reader, readShared.ContactID, contact_share.PermissionRead)
+	private := dbtest.Contact(t, conn, owner, nil, "Private", "Contact")
+
+	tests := []struct {
+		name   string
+		userID uint
+		op     Operation
+		status int
+	}{
+		{"viewer cannot update a workspace contact they created", viewer.UserID, updateOp(teamContact.ContactID, "Changed"), http.StatusForbidden},
+		{"viewer cannot delete a workspace contact they created", viewer.UserID, Operation{Op: OpDeleteContact, ContactID: teamContact.ContactID}, http.StatusForbidden},
+		{"write share allows updating", writer.UserID, updateOp(writeShared.ContactID, "Changed"), http.StatusOK},
+		{"write share does not allow deleting", writer.UserID, Operation{Op: OpDeleteContact, ContactID: writeShared.ContactID}, http.StatusForbidden},
+		{"read share does not allow updating", reader.UserID, updateOp(readShared.ContactID, "Changed"), http.StatusForbidden},
+		{"unshared contact is not found", writer.UserID, updateOp(private.ContactID, "Changed"), http.StatusNotFound},
+		{"workspace admin deletes a team contact", owner.UserID, Operation{Op: OpDeleteContact, ContactID: teamContact.ContactID}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := NewBulkService().Execute(tt.userID, ModeAtomic, []Operation{tt.op})
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if got := resp.Results[0].Status; got != tt.status {
+				t.Fatalf("status = %d, want %d (%+v)", got, tt.status, resp.Results[0].Error)
+			}
+		})
+	}
+
+	var updated contact.Contact
+	if err := repository.WithoutTenant(conn).First(&updated, writeShared.ContactID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if updated.FName != "Changed" {
+		t.Errorf("write-shared contact first name = %q, want the bulk update", updated.FName)
+	}
+}
+
+func createOp(firstName string, customFields map[string]interface{}) Operation {
+	data, _ := json.Marshal(contactService.ContactInput{FName: firstName, LName: "Bulk", CustomFields: customFields})
+	return Operation{Op: OpCreateContact, Data: data}
+}
+
+// Atomic requests keep nothing once an operation fails; best-effort requests
+// keep every operation that succeeded and nothing of the ones that failed.
+func TestBulkModes(t *testing.T) {
+	conn := dbtest.Open(t)
+	owner := dbtest.User(t, conn, "owner@example.com")
+	ops := []Operation{
+		createOp("First", nil),
+		// fails after the contact row is written, on the custom field
+		createOp("Partial", map[string]interface{}{"undefined": "x"}),
+		updateOp(999, "Missing"),
+		createOp("Last", nil),
+	}
+
+	tests := []struct {
+		name          string
+		mode          string
+		wantStatuses  []int
+		wantCommitted bool
+		wantContacts  []string
+	}{
+		{"atomic", ModeAtomic, []int{http.StatusFailedDependency, http.StatusBadRequest, http.StatusFailedDependency, http.StatusFailedDependency}, false, nil},
+		{"best effort", ModeBestEffort, []int{http.StatusCreated, http.StatusBadRequest, http.StatusNotFound, http.StatusCreated}, true, []string{"First", "Last"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := repository.WithoutTenant(conn).Unscoped().Where("l_name = ?", "Bulk").Delete(&contact.Contact{}).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := NewBulkService().Execute(owner.UserID, tt.mode, ops)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if resp.Committed != tt.wantCommitted {
+				t.Errorf("committed = %v, want %v", resp.Committed, tt.wantCommitted)
+			}
+			for i, want := range tt.wantStatuses {
+				if got := resp.Results[i].Status; got != want {
+					t.Errorf("operation %d status = %d, want %d (%+v)", i, got, want, resp.Results[i].Error)
+				}
+			}
+
+			var names []string
+			if err := repository.WithoutTenant(conn).Unscoped().Model(&contact.Contact{}).
+				Where("l_name = ?", "Bulk").Order("contact_id").
+				Pluck("f_name", &names).Error; err != nil {
+				t.Fatal(err)
+			}
+			if len(names) != len(tt.wantContacts) {
+				t.Fatalf("stored contacts = %v, want %v", names, tt.wantContacts)
+			}
+			for i := range names {
+				if names[i] != tt.wantContacts[i] {
+					t.Fatalf("stored contacts = %v, want %v", names, tt.wantContacts)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/component/auth"
+	"Contact_App/component/card_link/service"
+	"Contact_App/export"
+	"Contact_App/models/card_link"
+	"Contact_App/models/contact"
+	"Contact_App/web"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type CardLinkController struct {
+	Service *service.CardLinkService
+}
+
+func NewCardLinkController(svc *service.CardLinkService) *CardLinkController {
+	return &CardLinkController{Service: svc}
+}
+
+// GET /users/{userID}/contacts/{contactID}/qr?format=png|svg&content=vcard|mecard|link&size=&link_id=
+func (c *CardLinkController) QRCodeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	opts := service.QROptions{
+		Format:      strings.ToLower(strings.TrimSpace(query.Get("format"))),
+		Content:     strings.ToLower(strings.TrimSpace(query.Get("content"))),
+		LinkBaseURL: linkBaseURL(r),
+	}
+	if opts.Format == "" {
+		opts.Format = export.QRFormatPNG
+	}
+	if opts.Content == "" {
+		opts.Content = service.QRContentVCard
+	}
+	if v := query.Get("size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			apperror.HandleBadRequest(w, "invalid size")
+			return
+		}
+		opts.Size = size
+	}
+	if v := query.Get("link_id"); v != "" {
+		linkID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			apperror.HandleBadRequest(w, "invalid link_id")
+			return
+		}
+		opts.LinkID = uint(linkID)
+	}
+
+	image, err := c.Service.RenderQR(userID, contactID, opts)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", export.QRContentType(opts.Format))
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.WriteHeader(http.StatusOK)
+	w.Write(image)
+}
+
+// GET /users/{userID}/contacts/{contactID}/links
+func (c *CardLinkController) ListLinksHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+
+	links, err := c.Service.ListLinks(userID, contactID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	withURL(r, links...)
+	web.RespondJSON(w, http.StatusOK, links)
+}
+
+// POST /users/{userID}/contacts/{contactID}/links
+func (c *CardLinkController) CreateLinkHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+
+	var input service.LinkInput
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			apperror.HandleBadRequest(w, "invalid JSON")
+			return
+		}
+	}
+
+	link, err := c.Service.CreateLink(userID, contactID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	withURL(r, link)
+	web.RespondJSON(w, http.StatusCreated, link)
+}
+
+// DELETE /users/{userID}/contacts/{contactID}/links/{linkID}
+// Revokes the link; it stays listed so its history is not lost
+func (c *CardLinkController) RevokeLinkHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+	linkID, ok := web.ParseID(w, r, "linkID")
+	if !ok {
+		return
+	}
+
+	link, err := c.Service.RevokeLink(userID, contactID, linkID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	withURL(r, link)
+	web.RespondJSON(w, http.StatusOK, link)
+}
+
+// GET /cards/{token}.vcf
+// Public: the token in the URL is the only credential, so the card can be
+// opened from a QR code or a message without an account
+func (c *CardLinkController) PublicCardHandler(w http.ResponseWriter, r *http.Request) {
+	card, photos, err := c.Service.PublicCard(mux.Vars(r)["token"])
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	filename := strings.TrimSpace(card.FName + " " + card.LName)
+	if filename == "" {
+		filename = fmt.Sprintf("contact-%d", card.ContactID)
+	}
+	_ = export.Respond(w, export.FormatVCard, filename, []*contact.Contact{card}, photos)
+}
+
+func withURL(r *http.Request, links ...*card_link.CardLink) {
+	base := linkBaseURL(r)
+	for _, link := range links {
+		link.URL = base + service.FileName(link)
+	}
+}
+
+// linkBaseURL is the public URL card link file names are appended to
+func linkBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/cards/"
+}
+
+func (c *CardLinkController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/qr", c.QRCodeHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/links", c.ListLinksHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/links", c.CreateLinkHandler).Methods("POST")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/links/{linkID:[0-9]+}", c.RevokeLinkHandler).Methods("DELETE")
+}
+
+// RegisterPublicRoutes serves the shared cards on the root router, outside
+// the authenticated JSON API
+func (c *CardLinkController) RegisterPublicRoutes(router *mux.Router) {
+	router.HandleFunc("/cards/{token:[0-9a-f]+}.vcf", c.PublicCardHandler).Methods("GET")
+}
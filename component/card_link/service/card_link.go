@@ -0,0 +1,294 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	contactService "Contact_App/component/contact/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/db"
+	"Contact_App/export"
+	"Contact_App/models/card_link"
+	"Contact_App/models/contact"
+	"Contact_App/repository"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// QR code sizes in pixels
+const (
+	DefaultQRSize = 256
+	MinQRSize     = 64
+	MaxQRSize     = 2048
+)
+
+// What a contact's QR code encodes: its vCard, its MeCard or the URL of one
+// of its card links
+const (
+	QRContentVCard  = "vcard"
+	QRContentMeCard = "mecard"
+	QRContentLink   = "link"
+)
+
+// QROptions describes the QR code to render. LinkID picks the card link
+// encoded with QRContentLink, whose URL is LinkBaseURL followed by the
+// link's file name.
+type QROptions struct {
+	Format      string
+	Content     string
+	Size        int
+	LinkID      uint
+	LinkBaseURL string
+}
+
+// LinkInput is the body accepted when creating a card link; without
+// expires_at the link works until it is revoked
+type LinkInput struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type CardLinkService struct {
+	repo     repository.Repository
+	contacts *contactService.ContactService
+}
+
+func NewCardLinkService(contacts *contactService.ContactService) *CardLinkService {
+	return &CardLinkService{repo: repository.NewGormRepository(), contacts: contacts}
+}
+
+// RenderQR draws a QR code for a contact the user can see. Cards leave out
+// custom fields and photos, which are the user's own notes or too large for
+// a QR code. A card too large to encode is rejected so the caller can fall
+// back to a MeCard or a card link.
+func (s *CardLinkService) RenderQR(userID, contactID uint, opts QROptions) ([]byte, error) {
+	if !export.IsSupportedQRFormat(opts.Format) {
+		return nil, apperror.NewValidationError("format", "must be png or svg")
+	}
+	if opts.Size == 0 {
+		opts.Size = DefaultQRSize
+	}
+	if opts.Size < MinQRSize || opts.Size > MaxQRSize {
+		return nil, apperror.NewValidationError("size", fmt.Sprintf("must be between %d and %d", MinQRSize, MaxQRSize))
+	}
+
+	var text string
+	switch opts.Content {
+	case QRContentVCard, QRContentMeCard:
+		c, err := s.contacts.GetContactByIDWithDetails(userID, contactID)
+		if err != nil {
+			return nil, err
+		}
+		card := publicCard(c)
+		text = export.VCard(card)
+		if opts.Content == QRContentMeCard {
+			text = export.MeCard(card)
+		}
+	case QRContentLink:
+		if opts.LinkID == 0 {
+			return nil, apperror.NewValidationError("link_id", "is required for link content")
+		}
+		uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+		if err != nil {
+			return nil, err
+		}
+		if err := ensureManageable(uow.DB, userID, contactID); err != nil {
+			return nil, err
+		}
+		link, err := findLink(uow.DB, contactID, opts.LinkID)
+		if err != nil {
+			return nil, err
+		}
+		if !link.IsActive(time.Now()) {
+			return nil, apperror.NewGoneError("card link", "the link has expired or was revoked")
+		}
+		text = opts.LinkBaseURL + FileName(link)
+	default:
+		return nil, apperror.NewValidationError("content", "must be vcard, mecard or link")
+	}
+
+	image, err := export.QRCode(text, opts.Format, opts.Size)
+	if err != nil {
+		return nil, apperror.NewValidationError("content", "too much data for a QR code; use mecard or a card link")
+	}
+	return image, nil
+}
+
+// ListLinks returns the card links of a contact, newest first, including
+// expired and revoked ones
+func (s *CardLinkService) ListLinks(userID, contactID uint) ([]*card_link.CardLink, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureManageable(uow.DB, userID, contactID); err != nil {
+		return nil, err
+	}
+
+	links := []*card_link.CardLink{}
+	if err := uow.DB.Where("contact_id = ?", contactID).
+		Order("created_at DESC, link_id DESC").
+		Find(&links).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load card links")
+	}
+	now := time.Now()
+	for _, link := range links {
+		link.Active = link.IsActive(now)
+	}
+	return links, nil
+}
+
+// CreateLink issues a public link to a contact the user owns or edits in a
+// workspace. Contacts shared with the user cannot be published by them.
+func (s *CardLinkService) CreateLink(userID, contactID uint, input LinkInput) (*card_link.CardLink, error) {
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, apperror.NewValidationError("expires_at", "must be in the future")
+	}
+
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	if err := ensureManageable(uow.DB, userID, contactID); err != nil {
+		return nil, err
+	}
+	token, err := newLinkToken()
+	if err != nil {
+		return nil, err
+	}
+	link := &card_link.CardLink{UserID: userID, ContactID: contactID, Token: token, ExpiresAt: input.ExpiresAt}
+	if err := s.repo.Add(uow, link); err != nil {
+		return nil, err
+	}
+
+	uow.Commit()
+	link.Active = true
+	return link, nil
+}
+
+// RevokeLink stops a card link from serving the card. Revoking a link twice
+// keeps the first revocation time.
+func (s *CardLinkService) RevokeLink(userID, contactID, linkID uint) (*card_link.CardLink, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	if err := ensureManageable(uow.DB, userID, contactID); err != nil {
+		return nil, err
+	}
+	link, err := findLink(uow.DB, contactID, linkID)
+	if err != nil {
+		return nil, err
+	}
+	if link.RevokedAt == nil {
+		now := time.Now()
+		link.RevokedAt = &now
+		if err := uow.DB.Model(link).UpdateColumn("revoked_at", now).Error; err != nil {
+			return nil, apperror.NewInternalError("failed to revoke card link")
+		}
+	}
+
+	uow.Commit()
+	link.Active = false
+	return link, nil
+}
+
+// PublicCard returns the contact behind a card link and its photo for
+// anyone holding the token, and counts the visit. Expired and revoked links
+// answer 410; so does a link whose creator can no longer see the contact.
+func (s *CardLinkService) PublicCard(token string) (*contact.Contact, export.Photos, error) {
+	var link card_link.CardLink
+	if err := db.GetDB().Where("token = ?", token).First(&link).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, apperror.NewNotFoundError("card link", 0)
+		}
+		return nil, nil, apperror.NewInternalError("failed to load card link")
+	}
+	if !link.IsActive(time.Now()) {
+		return nil, nil, apperror.NewGoneError("card link", "the link has expired or was revoked")
+	}
+
+	contacts, photos, err := s.contacts.GetCards(link.UserID, []uint{link.ContactID})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(contacts) == 0 {
+		return nil, nil, apperror.NewGoneError("card link", "the contact is no longer available")
+	}
+
+	// visits are informational, so a failed update still serves the card
+	db.GetDB().Model(&link).UpdateColumns(map[string]interface{}{
+		"access_count":     gorm.Expr("access_count + 1"),
+		"last_accessed_at": time.Now(),
+	})
+	return publicCard(contacts[0]), photos, nil
+}
+
+// FileName is the last path segment of a card link's public URL
+func FileName(link *card_link.CardLink) string {
+	return link.Token + ".vcf"
+}
+
+// publicCard copies a contact without its custom fields, which are the
+// user's own bookkeeping rather than part of the card they hand out
+func publicCard(c *contact.Contact) *contact.Contact {
+	card := *c
+	card.CustomFields = nil
+	return &card
+}
+
+// ensureManageable checks that the user may publish the contact: their own,
+// or one of a workspace where they can edit contacts
+func ensureManageable(conn *gorm.DB, userID, contactID uint) error {
+	var c contact.Contact
+	if err := conn.Select("contact_id", "user_id", "workspace_id").
+		Where("contact_id = ? AND is_active = ?", contactID, true).
+		First(&c).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return apperror.NewNotFoundError("contact", int(contactID))
+		}
+		return apperror.NewInternalError("failed to load contact")
+	}
+
+	if c.WorkspaceID == nil {
+		if c.UserID != userID {
+			return apperror.NewNotFoundError("contact", int(contactID))
+		}
+		return nil
+	}
+	role, err := workspaceService.Role(conn, userID, *c.WorkspaceID)
+	if err != nil {
+		return err
+	}
+	if role == "" {
+		return apperror.NewNotFoundError("contact", int(contactID))
+	}
+	if !workspaceService.CanWrite(role) {
+		return apperror.NewForbiddenError("contact", "your workspace role only allows reading contacts")
+	}
+	return nil
+}
+
+func findLink(conn *gorm.DB, contactID, linkID uint) (*card_link.CardLink, error) {
+	var link card_link.CardLink
+	if err := conn.Where("link_id = ? AND contact_id = ?", linkID, contactID).First(&link).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewNotFoundError("card link", int(linkID))
+		}
+		return nil, apperror.NewInternalError("failed to load card link")
+	}
+	return &link, nil
+}
+
+func newLinkToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", apperror.NewInternalError("failed to generate card link")
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/component/auth"
+	"Contact_App/component/carddav/service"
+	"Contact_App/web"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type CardDAVController struct {
+	Service *service.CardDAVService
+}
+
+func NewCardDAVController(svc *service.CardDAVService) *CardDAVController {
+	return &CardDAVController{Service: svc}
+}
+
+// GET /users/{userID}/app-passwords
+func (c *CardDAVController) ListAppPasswordsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	passwords, err := c.Service.ListAppPasswords(userID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, passwords)
+}
+
+// POST /users/{userID}/app-passwords
+// The secret is only part of this response
+func (c *CardDAVController) CreateAppPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	var input service.AppPasswordInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	password, err := c.Service.CreateAppPassword(userID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusCreated, password)
+}
+
+// DELETE /users/{userID}/app-passwords/{appPasswordID}
+func (c *CardDAVController) DeleteAppPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	appPasswordID, ok := web.ParseID(w, r, "appPasswordID")
+	if !ok {
+		return
+	}
+
+	if err := c.Service.DeleteAppPassword(userID, appPasswordID); err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, map[string]string{"message": "app password revoked"})
+}
+
+func (c *CardDAVController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/users/{userID}/app-passwords", c.ListAppPasswordsHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/app-passwords", c.CreateAppPasswordHandler).Methods("POST")
+	router.HandleFunc("/users/{userID}/app-passwords/{appPasswordID:[0-9]+}", c.DeleteAppPasswordHandler).Methods("DELETE")
+}
+
+// RegisterDAVRoutes serves the CardDAV tree on the root router, outside the
+// JSON API, since clients discover it through /.well-known/carddav
+func (c *CardDAVController) RegisterDAVRoutes(router *mux.Router) {
+	router.Handle("/.well-known/carddav", http.RedirectHandler(davRoot, http.StatusMovedPermanently))
+	router.PathPrefix(strings.TrimSuffix(davRoot, "/")).Handler(c.basicAuth(http.HandlerFunc(c.ServeDAV)))
+}
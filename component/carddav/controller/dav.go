@@ -0,0 +1,580 @@
+package controller
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/component/carddav/service"
+	contactService "Contact_App/component/contact/service"
+	"Contact_App/web"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// davRoot is where the CardDAV tree is mounted
+	davRoot = "/carddav/"
+
+	// addressBookName is the single address book under each user's home
+	addressBookName = "contacts"
+
+	syncTokenPrefix = "urn:contact-app:sync:"
+
+	// maxXMLBytes bounds PROPFIND and REPORT bodies
+	maxXMLBytes = 1 << 20
+)
+
+type davUserKey struct{}
+
+const (
+	targetRoot = iota
+	targetHome
+	targetAddressBook
+	targetCard
+)
+
+// davTarget is the resource a DAV request URL points at
+type davTarget struct {
+	kind   int
+	userID uint
+	name   string
+}
+
+// resourceProps maps each property of a resource to a function rendering
+// its value, so expensive values are only built when asked for
+type resourceProps map[xml.Name]func() string
+
+var (
+	propResourceType     = xml.Name{Space: nsDAV, Local: "resourcetype"}
+	propDisplayName      = xml.Name{Space: nsDAV, Local: "displayname"}
+	propPrincipal        = xml.Name{Space: nsDAV, Local: "current-user-principal"}
+	propPrincipalURL     = xml.Name{Space: nsDAV, Local: "principal-URL"}
+	propPrivileges       = xml.Name{Space: nsDAV, Local: "current-user-privilege-set"}
+	propSupportedReports = xml.Name{Space: nsDAV, Local: "supported-report-set"}
+	propSyncToken        = xml.Name{Space: nsDAV, Local: "sync-token"}
+	propETag             = xml.Name{Space: nsDAV, Local: "getetag"}
+	propContentType      = xml.Name{Space: nsDAV, Local: "getcontenttype"}
+	propCTag             = xml.Name{Space: nsCS, Local: "getctag"}
+	propHomeSet          = xml.Name{Space: nsCardDAV, Local: "addressbook-home-set"}
+	propAddressData      = xml.Name{Space: nsCardDAV, Local: "address-data"}
+	propSupportedData    = xml.Name{Space: nsCardDAV, Local: "supported-address-data"}
+
+	reportMultiget = xml.Name{Space: nsCardDAV, Local: "addressbook-multiget"}
+	reportQuery    = xml.Name{Space: nsCardDAV, Local: "addressbook-query"}
+	reportSync     = xml.Name{Space: nsDAV, Local: "sync-collection"}
+)
+
+// basicAuth signs CardDAV clients in with the account email and an app
+// password. OPTIONS passes unauthenticated because clients probe the
+// server's capabilities before they send credentials.
+func (c *CardDAVController) basicAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if email, secret, ok := r.BasicAuth(); ok {
+			userID, err := c.Service.Authenticate(email, secret)
+			if err == nil {
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), davUserKey{}, userID)))
+				return
+			}
+			if _, unauthorized := err.(*apperror.UnauthorizedError); !unauthorized {
+				apperror.HandleError(w, err)
+				return
+			}
+		}
+
+		w.Header().Set("WWW-Authenticate", `Basic realm="Contact App", charset="UTF-8"`)
+		apperror.HandleUnauthorized(w, "email and app password required")
+	})
+}
+
+// ServeDAV handles every request below /carddav/:
+//
+//	/carddav/                              root, points clients at their principal
+//	/carddav/{userID}/                     principal and address book home
+//	/carddav/{userID}/contacts/            the user's address book
+//	/carddav/{userID}/contacts/{name}.vcf  one contact as a vCard
+func (c *CardDAVController) ServeDAV(w http.ResponseWriter, r *http.Request) {
+	target, ok := parseTarget(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method == http.MethodOptions {
+		w.Header().Set("DAV", "1, 3, addressbook")
+		w.Header().Set("Allow", allowedMethods(target))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	userID := r.Context().Value(davUserKey{}).(uint)
+	if target.kind != targetRoot && target.userID != userID {
+		http.Error(w, "Forbidden: cannot access another user's address book", http.StatusForbidden)
+		return
+	}
+
+	switch {
+	case r.Method == "PROPFIND":
+		c.propfind(w, r, userID, target)
+	case r.Method == "REPORT" && target.kind == targetAddressBook:
+		c.report(w, r, userID)
+	case (r.Method == http.MethodGet || r.Method == http.MethodHead) && target.kind == targetCard:
+		c.getCard(w, r, userID, target.name)
+	case r.Method == http.MethodPut && target.kind == targetCard:
+		c.putCard(w, r, userID, target.name)
+	case r.Method == http.MethodDelete && target.kind == targetCard:
+		c.deleteCard(w, r, userID, target.name)
+	default:
+		w.Header().Set("Allow", allowedMethods(target))
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// PROPFIND
+// Depth 0 describes the resource, any other depth also its members
+func (c *CardDAVController) propfind(w http.ResponseWriter, r *http.Request, userID uint, target davTarget) {
+	var req propfindRequest
+	if ok := readXML(w, r, &req); !ok {
+		return
+	}
+	depthZero := r.Header.Get("Depth") == "0"
+
+	ms := newMultistatus()
+	switch target.kind {
+	case targetRoot:
+		ms.response(davRoot, selectProps(rootProps(userID), &req))
+		if !depthZero {
+			ms.response(homeHref(userID), selectProps(homeProps(userID), &req))
+		}
+	case targetHome:
+		ms.response(homeHref(userID), selectProps(homeProps(userID), &req))
+		if !depthZero {
+			token, err := c.Service.SyncToken(userID)
+			if err != nil {
+				apperror.HandleError(w, err)
+				return
+			}
+			ms.response(addressBookHref(userID), selectProps(addressBookProps(userID, token), &req))
+		}
+	case targetAddressBook:
+		if depthZero {
+			token, err := c.Service.SyncToken(userID)
+			if err != nil {
+				apperror.HandleError(w, err)
+				return
+			}
+			ms.response(addressBookHref(userID), selectProps(addressBookProps(userID, token), &req))
+			break
+		}
+		cards, token, err := c.Service.ListCards(userID)
+		if err != nil {
+			apperror.HandleError(w, err)
+			return
+		}
+		ms.response(addressBookHref(userID), selectProps(addressBookProps(userID, token), &req))
+		for _, card := range cards {
+			ms.response(cardHref(userID, card.Name), selectProps(cardProps(card), &req))
+		}
+	case targetCard:
+		card, err := c.Service.GetCard(userID, target.name)
+		if err != nil {
+			apperror.HandleError(w, err)
+			return
+		}
+		ms.response(cardHref(userID, card.Name), selectProps(cardProps(card), &req))
+	}
+	ms.write(w)
+}
+
+// REPORT on the address book: addressbook-multiget, addressbook-query and sync-collection
+func (c *CardDAVController) report(w http.ResponseWriter, r *http.Request, userID uint) {
+	var req reportRequest
+	if ok := readXML(w, r, &req); !ok {
+		return
+	}
+
+	switch req.XMLName {
+	case reportMultiget:
+		c.multiget(w, userID, &req)
+	case reportQuery:
+		c.query(w, userID, &req)
+	case reportSync:
+		c.syncCollection(w, userID, &req)
+	default:
+		writeDAVError(w, http.StatusForbidden, xml.Name{Space: nsDAV, Local: "supported-report"})
+	}
+}
+
+func (c *CardDAVController) multiget(w http.ResponseWriter, userID uint, req *reportRequest) {
+	names := make([]string, 0, len(req.Hrefs))
+	for _, href := range req.Hrefs {
+		if name, ok := cardName(href, userID); ok {
+			names = append(names, name)
+		}
+	}
+	cards, err := c.Service.GetCards(userID, names)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	ms := newMultistatus()
+	for _, href := range req.Hrefs {
+		name, _ := cardName(href, userID)
+		if card, ok := cards[name]; ok && name != "" {
+			ms.response(strings.TrimSpace(href), selectReportProps(cardProps(card), req))
+		} else {
+			ms.status(strings.TrimSpace(href), http.StatusNotFound)
+		}
+	}
+	ms.write(w)
+}
+
+// addressbook-query
+// A limit that cuts the result short is reported with 507 on the address book
+func (c *CardDAVController) query(w http.ResponseWriter, userID uint, req *reportRequest) {
+	cards, _, err := c.Service.ListCards(userID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	limit := 0
+	if req.Limit != nil && req.Limit.NResults > 0 {
+		limit = req.Limit.NResults
+	}
+
+	ms := newMultistatus()
+	matched := 0
+	for _, card := range cards {
+		if !req.Filter.matches(card.Properties()) {
+			continue
+		}
+		if limit > 0 && matched == limit {
+			ms.status(addressBookHref(userID), http.StatusInsufficientStorage)
+			break
+		}
+		ms.response(cardHref(userID, card.Name), selectReportProps(cardProps(card), req))
+		matched++
+	}
+	ms.write(w)
+}
+
+// sync-collection
+// An empty token returns every card; removed cards come back as 404 entries
+func (c *CardDAVController) syncCollection(w http.ResponseWriter, userID uint, req *reportRequest) {
+	if level := strings.TrimSpace(req.SyncLevel); level != "" && level != "1" {
+		apperror.HandleBadRequest(w, "only sync-level 1 is supported")
+		return
+	}
+	since, ok := parseSyncToken(req.SyncToken)
+	if !ok {
+		writeDAVError(w, http.StatusForbidden, xml.Name{Space: nsDAV, Local: "valid-sync-token"})
+		return
+	}
+
+	changed, removed, token, err := c.Service.Changes(userID, since)
+	if err != nil {
+		if _, forbidden := err.(*apperror.ForbiddenError); forbidden {
+			writeDAVError(w, http.StatusForbidden, xml.Name{Space: nsDAV, Local: "valid-sync-token"})
+			return
+		}
+		apperror.HandleError(w, err)
+		return
+	}
+
+	ms := newMultistatus()
+	for _, card := range changed {
+		ms.response(cardHref(userID, card.Name), selectReportProps(cardProps(card), req))
+	}
+	for _, name := range removed {
+		ms.status(cardHref(userID, name), http.StatusNotFound)
+	}
+	ms.syncToken(formatSyncToken(token))
+	ms.write(w)
+}
+
+// GET /carddav/{userID}/contacts/{name}
+func (c *CardDAVController) getCard(w http.ResponseWriter, r *http.Request, userID uint, name string) {
+	card, err := c.Service.GetCard(userID, name)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	if web.NotModified(w, r, card.Contact.Version) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/vcard; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, card.VCard())
+}
+
+// PUT /carddav/{userID}/contacts/{name}
+// Creates the card, or replaces it; If-None-Match: * only allows creating
+func (c *CardDAVController) putCard(w http.ResponseWriter, r *http.Request, userID uint, name string) {
+	ifMatch, err := web.IfMatchVersion(r)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	ifNoneMatch := strings.TrimSpace(r.Header.Get("If-None-Match")) == "*"
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, contactService.MaxImportBytes()+1))
+	if err != nil {
+		apperror.HandleBadRequest(w, "failed to read vCard")
+		return
+	}
+	if int64(len(data)) > contactService.MaxImportBytes() {
+		apperror.RespondWithError(w, http.StatusRequestEntityTooLarge, "vCard is too large")
+		return
+	}
+
+	card, created, err := c.Service.PutCard(userID, name, data, ifMatch, ifNoneMatch)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.SetETag(w, card.Contact.Version)
+	if created {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /carddav/{userID}/contacts/{name}
+// The contact goes to the trash like any other deleted contact
+func (c *CardDAVController) deleteCard(w http.ResponseWriter, r *http.Request, userID uint, name string) {
+	ifMatch, err := web.IfMatchVersion(r)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	if err := c.Service.DeleteCard(userID, name, ifMatch); err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func rootProps(userID uint) resourceProps {
+	return resourceProps{
+		propResourceType: func() string { return "<d:collection/>" },
+		propPrincipal:    func() string { return hrefValue(homeHref(userID)) },
+	}
+}
+
+func homeProps(userID uint) resourceProps {
+	return resourceProps{
+		propResourceType: func() string { return "<d:collection/><d:principal/>" },
+		propPrincipal:    func() string { return hrefValue(homeHref(userID)) },
+		propPrincipalURL: func() string { return hrefValue(homeHref(userID)) },
+		propHomeSet:      func() string { return hrefValue(homeHref(userID)) },
+	}
+}
+
+func addressBookProps(userID, token uint) resourceProps {
+	return resourceProps{
+		propResourceType: func() string { return "<d:collection/><card:addressbook/>" },
+		propDisplayName:  func() string { return "Contacts" },
+		propPrincipal:    func() string { return hrefValue(homeHref(userID)) },
+		propPrivileges: func() string {
+			return "<d:privilege><d:read/></d:privilege><d:privilege><d:write/></d:privilege>"
+		},
+		propSupportedReports: func() string {
+			var b strings.Builder
+			for _, report := range []xml.Name{reportMultiget, reportQuery, reportSync} {
+				b.WriteString("<d:supported-report><d:report>" + element(report, "") + "</d:report></d:supported-report>")
+			}
+			return b.String()
+		},
+		propSupportedData: func() string { return `<card:address-data-type content-type="text/vcard" version="3.0"/>` },
+		propSyncToken:     func() string { return escapeXML(formatSyncToken(token)) },
+		propCTag:          func() string { return escapeXML(formatSyncToken(token)) },
+	}
+}
+
+func cardProps(card *service.Card) resourceProps {
+	return resourceProps{
+		propResourceType: func() string { return "" },
+		propETag:         func() string { return escapeXML(web.ETag(card.Contact.Version)) },
+		propContentType:  func() string { return "text/vcard; charset=utf-8" },
+		propAddressData:  func() string { return escapeXML(card.VCard()) },
+	}
+}
+
+// selectProps picks the properties a PROPFIND asked for. No body, like
+// allprop, returns every property except address-data.
+func selectProps(props resourceProps, req *propfindRequest) propstats {
+	if req.PropName != nil {
+		var found []prop
+		for _, name := range sortedNames(props) {
+			found = append(found, prop{Name: name})
+		}
+		return propstats{found: found}
+	}
+	if req.AllProp != nil || req.Prop == nil {
+		return propstats{found: allProps(props)}
+	}
+	return requestedProps(props, req.Prop.names())
+}
+
+func selectReportProps(props resourceProps, req *reportRequest) propstats {
+	if req.AllProp != nil || req.Prop == nil {
+		return propstats{found: allProps(props)}
+	}
+	return requestedProps(props, req.Prop.names())
+}
+
+func allProps(props resourceProps) []prop {
+	var found []prop
+	for _, name := range sortedNames(props) {
+		if name != propAddressData {
+			found = append(found, prop{Name: name, Value: props[name]()})
+		}
+	}
+	return found
+}
+
+func requestedProps(props resourceProps, names []xml.Name) propstats {
+	var result propstats
+	for _, name := range names {
+		if value, ok := props[name]; ok {
+			result.found = append(result.found, prop{Name: name, Value: value()})
+		} else {
+			result.missing = append(result.missing, name)
+		}
+	}
+	return result
+}
+
+func sortedNames(props resourceProps) []xml.Name {
+	names := make([]xml.Name, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if names[i].Space != names[j].Space {
+			return names[i].Space < names[j].Space
+		}
+		return names[i].Local < names[j].Local
+	})
+	return names
+}
+
+// readXML decodes a PROPFIND or REPORT body; an empty body leaves out untouched
+func readXML(w http.ResponseWriter, r *http.Request, out interface{}) bool {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxXMLBytes))
+	if err != nil {
+		apperror.HandleBadRequest(w, "failed to read request body")
+		return false
+	}
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return true
+	}
+	if err := xml.Unmarshal(body, out); err != nil {
+		apperror.HandleBadRequest(w, "invalid XML")
+		return false
+	}
+	return true
+}
+
+func parseTarget(path string) (davTarget, bool) {
+	rest := strings.TrimPrefix(path, strings.TrimSuffix(davRoot, "/"))
+	if rest != "" && !strings.HasPrefix(rest, "/") {
+		return davTarget{}, false
+	}
+	rest = strings.Trim(rest, "/")
+	if rest == "" {
+		return davTarget{kind: targetRoot}, true
+	}
+
+	parts := strings.Split(rest, "/")
+	userID, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return davTarget{}, false
+	}
+	target := davTarget{userID: uint(userID)}
+	switch {
+	case len(parts) == 1:
+		target.kind = targetHome
+	case len(parts) == 2 && parts[1] == addressBookName:
+		target.kind = targetAddressBook
+	case len(parts) == 3 && parts[1] == addressBookName && parts[2] != "":
+		target.kind = targetCard
+		target.name = parts[2]
+	default:
+		return davTarget{}, false
+	}
+	return target, true
+}
+
+// cardName extracts the resource name from a multiget href of the user's address book
+func cardName(href string, userID uint) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", false
+	}
+	target, ok := parseTarget(u.Path)
+	if !ok || target.kind != targetCard || target.userID != userID {
+		return "", false
+	}
+	return target.name, true
+}
+
+func allowedMethods(target davTarget) string {
+	switch target.kind {
+	case targetAddressBook:
+		return "OPTIONS, PROPFIND, REPORT"
+	case targetCard:
+		return "OPTIONS, PROPFIND, GET, HEAD, PUT, DELETE"
+	default:
+		return "OPTIONS, PROPFIND"
+	}
+}
+
+func homeHref(userID uint) string {
+	return davRoot + strconv.FormatUint(uint64(userID), 10) + "/"
+}
+
+func addressBookHref(userID uint) string {
+	return homeHref(userID) + addressBookName + "/"
+}
+
+func cardHref(userID uint, name string) string {
+	return addressBookHref(userID) + url.PathEscape(name)
+}
+
+func hrefValue(href string) string {
+	return "<d:href>" + escapeXML(href) + "</d:href>"
+}
+
+func formatSyncToken(token uint) string {
+	return syncTokenPrefix + strconv.FormatUint(uint64(token), 10)
+}
+
+// parseSyncToken accepts the tokens formatSyncToken issues; empty means none
+func parseSyncToken(token string) (uint, bool) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return 0, true
+	}
+	if !strings.HasPrefix(token, syncTokenPrefix) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(strings.TrimPrefix(token, syncTokenPrefix), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(n), true
+}
@@ -0,0 +1,243 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	nsDAV     = "DAV:"
+	nsCardDAV = "urn:ietf:params:xml:ns:carddav"
+	nsCS      = "http://calendarserver.org/ns/"
+)
+
+var prefixes = map[string]string{nsDAV: "d", nsCardDAV: "card", nsCS: "cs"}
+
+// propList collects the property names of a DAV:prop element
+type propList struct {
+	Names []struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+func (p *propList) names() []xml.Name {
+	if p == nil {
+		return nil
+	}
+	names := make([]xml.Name, len(p.Names))
+	for i, n := range p.Names {
+		names[i] = n.XMLName
+	}
+	return names
+}
+
+type propfindRequest struct {
+	XMLName  xml.Name  `xml:"DAV: propfind"`
+	AllProp  *struct{} `xml:"DAV: allprop"`
+	PropName *struct{} `xml:"DAV: propname"`
+	Prop     *propList `xml:"DAV: prop"`
+}
+
+// reportRequest covers the three reports served on the address book; the
+// root element tells them apart
+type reportRequest struct {
+	XMLName xml.Name
+	AllProp *struct{} `xml:"DAV: allprop"`
+	Prop    *propList `xml:"DAV: prop"`
+
+	// addressbook-multiget
+	Hrefs []string `xml:"DAV: href"`
+
+	// addressbook-query
+	Filter *queryFilter `xml:"urn:ietf:params:xml:ns:carddav filter"`
+	Limit  *struct {
+		NResults int `xml:"urn:ietf:params:xml:ns:carddav nresults"`
+	} `xml:"urn:ietf:params:xml:ns:carddav limit"`
+
+	// sync-collection
+	SyncToken string `xml:"DAV: sync-token"`
+	SyncLevel string `xml:"DAV: sync-level"`
+}
+
+type queryFilter struct {
+	Test        string       `xml:"test,attr"`
+	PropFilters []propFilter `xml:"urn:ietf:params:xml:ns:carddav prop-filter"`
+}
+
+type propFilter struct {
+	Name         string      `xml:"name,attr"`
+	Test         string      `xml:"test,attr"`
+	IsNotDefined *struct{}   `xml:"urn:ietf:params:xml:ns:carddav is-not-defined"`
+	TextMatches  []textMatch `xml:"urn:ietf:params:xml:ns:carddav text-match"`
+}
+
+type textMatch struct {
+	Value     string `xml:",chardata"`
+	MatchType string `xml:"match-type,attr"`
+	Negate    string `xml:"negate-condition,attr"`
+}
+
+// matches applies an addressbook-query filter to a card's property values.
+// Text is compared case-insensitively, as the default i;unicode-casemap
+// collation asks; an empty filter matches every card.
+func (f *queryFilter) matches(props map[string][]string) bool {
+	if f == nil || len(f.PropFilters) == 0 {
+		return true
+	}
+	allOf := f.Test == "allof"
+	for _, pf := range f.PropFilters {
+		ok := pf.matches(props[strings.ToUpper(pf.Name)])
+		if allOf && !ok {
+			return false
+		}
+		if !allOf && ok {
+			return true
+		}
+	}
+	return allOf
+}
+
+func (pf *propFilter) matches(values []string) bool {
+	if pf.IsNotDefined != nil {
+		return len(values) == 0
+	}
+	if len(values) == 0 {
+		return false
+	}
+	if len(pf.TextMatches) == 0 {
+		return true
+	}
+
+	allOf := pf.Test == "allof"
+	for _, tm := range pf.TextMatches {
+		ok := false
+		for _, value := range values {
+			if tm.matches(value) {
+				ok = true
+				break
+			}
+		}
+		if allOf && !ok {
+			return false
+		}
+		if !allOf && ok {
+			return true
+		}
+	}
+	return allOf
+}
+
+func (tm *textMatch) matches(value string) bool {
+	value = strings.ToLower(value)
+	want := strings.ToLower(strings.TrimSpace(tm.Value))
+
+	var ok bool
+	switch tm.MatchType {
+	case "equals":
+		ok = value == want
+	case "starts-with":
+		ok = strings.HasPrefix(value, want)
+	case "ends-with":
+		ok = strings.HasSuffix(value, want)
+	default:
+		ok = strings.Contains(value, want)
+	}
+	if tm.Negate == "yes" {
+		return !ok
+	}
+	return ok
+}
+
+// prop is one property value; Value is already XML
+type prop struct {
+	Name  xml.Name
+	Value string
+}
+
+// propstats holds the properties a resource has and those it was asked for but lacks
+type propstats struct {
+	found   []prop
+	missing []xml.Name
+}
+
+// multistatus builds a 207 Multi-Status body
+type multistatus struct {
+	buf bytes.Buffer
+}
+
+func newMultistatus() *multistatus {
+	m := &multistatus{}
+	m.buf.WriteString(xml.Header)
+	m.buf.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:card="` + nsCardDAV + `" xmlns:cs="` + nsCS + `">`)
+	return m
+}
+
+// response adds a resource with the properties found and those it lacks
+func (m *multistatus) response(href string, props propstats) {
+	m.buf.WriteString("<d:response><d:href>" + escapeXML(href) + "</d:href>")
+	if len(props.found) > 0 {
+		m.buf.WriteString("<d:propstat><d:prop>")
+		for _, p := range props.found {
+			m.buf.WriteString(element(p.Name, p.Value))
+		}
+		m.buf.WriteString("</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>")
+	}
+	if len(props.missing) > 0 {
+		m.buf.WriteString("<d:propstat><d:prop>")
+		for _, name := range props.missing {
+			m.buf.WriteString(element(name, ""))
+		}
+		m.buf.WriteString("</d:prop><d:status>HTTP/1.1 404 Not Found</d:status></d:propstat>")
+	}
+	m.buf.WriteString("</d:response>")
+}
+
+// status adds a resource that is reported by status alone, such as a card
+// removed since the last sync
+func (m *multistatus) status(href string, code int) {
+	m.buf.WriteString(fmt.Sprintf("<d:response><d:href>%s</d:href><d:status>HTTP/1.1 %d %s</d:status></d:response>",
+		escapeXML(href), code, http.StatusText(code)))
+}
+
+func (m *multistatus) syncToken(token string) {
+	m.buf.WriteString("<d:sync-token>" + escapeXML(token) + "</d:sync-token>")
+}
+
+func (m *multistatus) write(w http.ResponseWriter) {
+	m.buf.WriteString("</d:multistatus>")
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	w.Write(m.buf.Bytes())
+}
+
+// writeDAVError sends a DAV:error body naming the precondition that failed
+func writeDAVError(w http.ResponseWriter, code int, condition xml.Name) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(code)
+	fmt.Fprintf(w, `%s<d:error xmlns:d="DAV:" xmlns:card="%s">%s</d:error>`, xml.Header, nsCardDAV, element(condition, ""))
+}
+
+// element renders a property with its namespace prefix, declaring the
+// namespace inline when it is not one of ours
+func element(name xml.Name, inner string) string {
+	tag, decl := name.Local, ""
+	if prefix, ok := prefixes[name.Space]; ok {
+		tag = prefix + ":" + name.Local
+	} else if name.Space != "" {
+		tag = "x:" + name.Local
+		decl = ` xmlns:x="` + escapeXML(name.Space) + `"`
+	}
+	if inner == "" {
+		return "<" + tag + decl + "/>"
+	}
+	return "<" + tag + decl + ">" + inner + "</" + tag + ">"
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,314 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/db"
+	"Contact_App/export"
+	"Contact_App/models/carddav"
+	"Contact_App/models/contact"
+	"bytes"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Card is one vCard object of a user's address book
+type Card struct {
+	Name    string
+	UID     string
+	Contact *contact.Contact
+	Photo   *export.Photo
+}
+
+// VCard renders the card as served to clients
+func (c *Card) VCard() string {
+	return export.VCardWithUID(c.Contact, c.Photo, c.UID)
+}
+
+// Properties lists the card's property values for addressbook-query filters
+func (c *Card) Properties() map[string][]string {
+	return export.Properties(c.Contact, c.UID)
+}
+
+// ListCards returns every card of the user's address book and its sync token
+func (s *CardDAVService) ListCards(userID uint) ([]*Card, uint, error) {
+	token, err := s.contacts.SyncToken(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	contacts, photos, err := s.contacts.GetCards(userID, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	cards, err := s.cards(userID, contacts, photos)
+	if err != nil {
+		return nil, 0, err
+	}
+	return cards, token, nil
+}
+
+// SyncToken returns the current sync token of the user's address book
+func (s *CardDAVService) SyncToken(userID uint) (uint, error) {
+	return s.contacts.SyncToken(userID)
+}
+
+// GetCards returns the cards stored under the given resource names. Names
+// that do not resolve to a card of the address book are left out.
+func (s *CardDAVService) GetCards(userID uint, names []string) (map[string]*Card, error) {
+	ids := make([]uint, 0, len(names))
+	for _, name := range names {
+		contactID, err := resolveName(userID, name)
+		if err != nil {
+			return nil, err
+		}
+		if contactID != 0 {
+			ids = append(ids, contactID)
+		}
+	}
+
+	contacts, photos, err := s.contacts.GetCards(userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	cards, err := s.cards(userID, contacts, photos)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*Card, len(cards))
+	for _, card := range cards {
+		byName[card.Name] = card
+	}
+	// a custom name and the default <id>.vcf both reach a contact
+	for _, name := range names {
+		if _, ok := byName[name]; ok {
+			continue
+		}
+		if contactID, ok := defaultContactID(name); ok {
+			for _, card := range cards {
+				if card.Contact.ContactID == contactID {
+					byName[name] = card
+				}
+			}
+		}
+	}
+	return byName, nil
+}
+
+// GetCard returns the card stored under name
+func (s *CardDAVService) GetCard(userID uint, name string) (*Card, error) {
+	cards, err := s.GetCards(userID, []string{name})
+	if err != nil {
+		return nil, err
+	}
+	card, ok := cards[name]
+	if !ok {
+		return nil, apperror.NewNotFoundError("card", 0)
+	}
+	return card, nil
+}
+
+// PutCard stores a vCard under name, updating the contact already there or
+// creating a new one. ifMatch and ifNoneMatch carry the client's
+// preconditions; created reports whether a contact was created.
+func (s *CardDAVService) PutCard(userID uint, name string, data []byte, ifMatch uint, ifNoneMatch bool) (card *Card, created bool, err error) {
+	if !validName(name) {
+		return nil, false, apperror.NewValidationError("name", "must be a .vcf resource name")
+	}
+	parsed, err := export.ParseVCards(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, apperror.NewValidationError("vcard", err.Error())
+	}
+	if len(parsed) != 1 {
+		return nil, false, apperror.NewValidationError("vcard", "exactly one vCard is required")
+	}
+	vcard := parsed[0]
+
+	existing, err := s.GetCard(userID, name)
+	if err != nil {
+		if _, ok := err.(*apperror.NotFoundError); !ok {
+			return nil, false, err
+		}
+		existing = nil
+	}
+
+	var contactID uint
+	switch {
+	case existing != nil && ifNoneMatch:
+		return nil, false, apperror.NewPreconditionFailedError("card", existing.Contact.Version)
+	case existing != nil:
+		contactID = existing.Contact.ContactID
+		if _, err := s.contacts.UpdateFromCard(userID, contactID, vcard, ifMatch); err != nil {
+			return nil, false, err
+		}
+	case ifMatch != 0:
+		return nil, false, apperror.NewPreconditionFailedError("card", 0)
+	default:
+		c, err := s.contacts.CreateFromCard(userID, vcard)
+		if err != nil {
+			return nil, false, err
+		}
+		contactID = c.ContactID
+		created = true
+	}
+
+	uid := strings.TrimSpace(vcard.UID)
+	if created || (uid != "" && uid != effectiveUID(existing)) {
+		if err := rememberName(userID, name, contactID, uid); err != nil {
+			return nil, false, err
+		}
+	}
+
+	card, err = s.GetCard(userID, name)
+	if err != nil {
+		return nil, false, err
+	}
+	return card, created, nil
+}
+
+// DeleteCard moves the contact stored under name to the trash
+func (s *CardDAVService) DeleteCard(userID uint, name string, ifMatch uint) error {
+	card, err := s.GetCard(userID, name)
+	if err != nil {
+		return err
+	}
+	return s.contacts.DeleteContactByID(userID, card.Contact.ContactID, ifMatch)
+}
+
+// Changes returns the cards changed after the sync token since, the names of
+// the cards removed since then and the new sync token
+func (s *CardDAVService) Changes(userID, since uint) (changed []*Card, removed []string, token uint, err error) {
+	changedIDs, removedIDs, token, err := s.contacts.CardChanges(userID, since)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	contacts, photos, err := s.contacts.GetCards(userID, changedIDs)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	changed, err = s.cards(userID, contacts, photos)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	// contacts deleted between the two reads count as removed
+	loaded := make(map[uint]bool, len(contacts))
+	for _, c := range contacts {
+		loaded[c.ContactID] = true
+	}
+	for _, id := range changedIDs {
+		if !loaded[id] && since > 0 {
+			removedIDs = append(removedIDs, id)
+		}
+	}
+
+	resources, err := loadResources(userID, removedIDs)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	removed = make([]string, 0, len(removedIDs))
+	for _, id := range removedIDs {
+		removed = append(removed, resourceName(resources, id))
+	}
+	return changed, removed, token, nil
+}
+
+// cards pairs contacts with the resource names and UIDs clients gave them
+func (s *CardDAVService) cards(userID uint, contacts []*contact.Contact, photos export.Photos) ([]*Card, error) {
+	ids := make([]uint, len(contacts))
+	for i, c := range contacts {
+		ids[i] = c.ContactID
+	}
+	resources, err := loadResources(userID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	cards := make([]*Card, 0, len(contacts))
+	for _, c := range contacts {
+		card := &Card{Name: resourceName(resources, c.ContactID), Contact: c, Photo: photos[c.ContactID]}
+		if r := resources[c.ContactID]; r != nil {
+			card.UID = r.UID
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+func loadResources(userID uint, contactIDs []uint) (map[uint]*carddav.CardResource, error) {
+	resources := make(map[uint]*carddav.CardResource)
+	if len(contactIDs) == 0 {
+		return resources, nil
+	}
+
+	var rows []*carddav.CardResource
+	if err := db.GetDB().Where("user_id = ? AND contact_id IN ?", userID, contactIDs).
+		Order("resource_id").
+		Find(&rows).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load address book entries")
+	}
+	for _, r := range rows {
+		resources[r.ContactID] = r
+	}
+	return resources, nil
+}
+
+func resourceName(resources map[uint]*carddav.CardResource, contactID uint) string {
+	if r := resources[contactID]; r != nil {
+		return r.Name
+	}
+	return strconv.FormatUint(uint64(contactID), 10) + ".vcf"
+}
+
+// resolveName finds the contact stored under a resource name, or 0
+func resolveName(userID uint, name string) (uint, error) {
+	var r carddav.CardResource
+	err := db.GetDB().Where("user_id = ? AND name = ?", userID, name).First(&r).Error
+	if err == nil {
+		return r.ContactID, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return 0, apperror.NewInternalError("failed to load address book entry")
+	}
+	contactID, _ := defaultContactID(name)
+	return contactID, nil
+}
+
+// rememberName points name at the contact, replacing an entry left behind by
+// a deleted contact
+func rememberName(userID uint, name string, contactID uint, uid string) error {
+	if uid == export.DefaultUID(contactID) {
+		uid = ""
+	}
+	r := &carddav.CardResource{UserID: userID, Name: name, ContactID: contactID, UID: uid}
+	if err := db.GetDB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"contact_id", "uid"}),
+	}).Create(r).Error; err != nil {
+		return apperror.NewInternalError("failed to save address book entry")
+	}
+	return nil
+}
+
+func effectiveUID(card *Card) string {
+	if card.UID != "" {
+		return card.UID
+	}
+	return export.DefaultUID(card.Contact.ContactID)
+}
+
+func defaultContactID(name string) (uint, bool) {
+	id, err := strconv.ParseUint(strings.TrimSuffix(name, ".vcf"), 10, 64)
+	if err != nil || id == 0 || !strings.HasSuffix(name, ".vcf") {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func validName(name string) bool {
+	return strings.HasSuffix(name, ".vcf") && len(name) > len(".vcf") && len(name) <= 255 &&
+		!strings.ContainsAny(name, "/\\")
+}
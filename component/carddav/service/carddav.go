@@ -0,0 +1,129 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	contactService "Contact_App/component/contact/service"
+	"Contact_App/db"
+	"Contact_App/models/carddav"
+	"Contact_App/models/user"
+	"Contact_App/repository"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AppPasswordInput is the body accepted when creating an app password
+type AppPasswordInput struct {
+	Name string `json:"name"`
+}
+
+type CardDAVService struct {
+	repo     repository.Repository
+	contacts *contactService.ContactService
+}
+
+func NewCardDAVService(contacts *contactService.ContactService) *CardDAVService {
+	return &CardDAVService{repo: repository.NewGormRepository(), contacts: contacts}
+}
+
+// ListAppPasswords returns the user's app passwords, newest first. Secrets
+// are never included.
+func (s *CardDAVService) ListAppPasswords(userID uint) ([]*carddav.AppPassword, error) {
+	passwords := []*carddav.AppPassword{}
+	if err := db.GetDB().Where("user_id = ?", userID).
+		Order("created_at DESC, app_password_id DESC").
+		Find(&passwords).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load app passwords")
+	}
+	return passwords, nil
+}
+
+// CreateAppPassword issues a new app password. The returned record carries
+// the secret, which cannot be read back later.
+func (s *CardDAVService) CreateAppPassword(userID uint, input AppPasswordInput) (*carddav.AppPassword, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, apperror.NewValidationError("name", "is required")
+	}
+	if len(name) > 100 {
+		return nil, apperror.NewValidationError("name", "must be at most 100 characters")
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	uow := repository.NewUnitOfWork(db.GetDB(), false)
+	defer uow.Rollback()
+
+	password := &carddav.AppPassword{UserID: userID, Name: name, SecretHash: hashSecret(secret)}
+	if err := s.repo.Add(uow, password); err != nil {
+		return nil, err
+	}
+
+	uow.Commit()
+	password.Secret = secret
+	return password, nil
+}
+
+// DeleteAppPassword revokes an app password; clients using it are signed out
+func (s *CardDAVService) DeleteAppPassword(userID, appPasswordID uint) error {
+	result := db.GetDB().Where("app_password_id = ? AND user_id = ?", appPasswordID, userID).
+		Delete(&carddav.AppPassword{})
+	if result.Error != nil {
+		return apperror.NewInternalError("failed to delete app password")
+	}
+	if result.RowsAffected == 0 {
+		return apperror.NewNotFoundError("app password", int(appPasswordID))
+	}
+	return nil
+}
+
+// Authenticate checks the credentials of a CardDAV client: the account email
+// and one of the account's app passwords. It returns the user id.
+func (s *CardDAVService) Authenticate(email, secret string) (uint, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" || secret == "" {
+		return 0, apperror.NewUnauthorized("invalid email or app password")
+	}
+
+	var u user.User
+	if err := db.GetDB().Select("user_id").Where("email = ? AND is_active = ?", email, true).First(&u).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, apperror.NewUnauthorized("invalid email or app password")
+		}
+		return 0, apperror.NewInternalError("failed to load user")
+	}
+
+	var password carddav.AppPassword
+	if err := db.GetDB().Where("user_id = ? AND secret_hash = ?", u.UserID, hashSecret(secret)).First(&password).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, apperror.NewUnauthorized("invalid email or app password")
+		}
+		return 0, apperror.NewInternalError("failed to load app password")
+	}
+
+	// last use is informational, so a failed update does not lock the client out
+	db.GetDB().Model(&password).UpdateColumn("last_used_at", time.Now())
+	return u.UserID, nil
+}
+
+func newSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", apperror.NewInternalError("failed to generate app password")
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// hashSecret needs no salt: secrets are random, so a fast digest is enough
+// and lets Authenticate find the password by its hash
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
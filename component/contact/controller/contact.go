@@ -4,10 +4,13 @@ import (
 	"Contact_App/apperror"
 	"Contact_App/component/auth"
 	"Contact_App/component/contact/service"
-	"Contact_App/db"
-	"Contact_App/repository"
+	"Contact_App/export"
 	"Contact_App/web"
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -15,6 +18,34 @@ import (
 	"github.com/gorilla/mux"
 )
 
+// contactResource whitelists the fields clients may sort, select and filter
+// contacts on. Listings add is_favorite and the user's custom fields as cf.<key>.
+var contactResource = web.ResourceSpec{
+	PrimaryKey: "contact_id",
+	Fields: map[string]web.FieldSpec{
+		"contact_id":      {JSONName: "contact_id", Column: "contacts.contact_id", Type: web.FieldNumber},
+		"user_id":         {JSONName: "user_id", Column: "contacts.user_id", Type: web.FieldNumber},
+		"first_name":      {JSONName: "first_name", Column: "contacts.f_name", Type: web.FieldString},
+		"f_name":          {JSONName: "first_name", Column: "contacts.f_name", Type: web.FieldString},
+		"last_name":       {JSONName: "last_name", Column: "contacts.l_name", Type: web.FieldString},
+		"l_name":          {JSONName: "last_name", Column: "contacts.l_name", Type: web.FieldString},
+		"is_active":       {JSONName: "is_active", Column: "contacts.is_active", Type: web.FieldBool},
+		"created_at":      {JSONName: "created_at", Column: "contacts.created_at", Type: web.FieldTime},
+		"updated_at":      {JSONName: "updated_at", Column: "contacts.updated_at", Type: web.FieldTime},
+		"job_title":       {JSONName: "job_title", Column: "contacts.job_title", Type: web.FieldString},
+		"department":      {JSONName: "department", Column: "contacts.department", Type: web.FieldString},
+		"organization_id": {JSONName: "organization_id", Column: "contacts.organization_id", Type: web.FieldNumber},
+		"workspace_id":    {JSONName: "workspace_id", Column: "contacts.workspace_id", Type: web.FieldNumber},
+		"external_id":     {JSONName: "external_id", Column: "contacts.external_id", Type: web.FieldString},
+		"source":          {JSONName: "source", Column: "contacts.source", Type: web.FieldString},
+		"details":         {JSONName: "details"},
+		"last_contacted":  {JSONName: "last_contacted"},
+		"relationships":   {JSONName: "relationships"},
+		"custom_fields":   {JSONName: "custom_fields"},
+		"completeness":    {JSONName: "completeness"},
+	},
+}
+
 type ContactController struct {
 	Service *service.ContactService
 }
@@ -23,6 +54,9 @@ func NewContactController(svc *service.ContactService) *ContactController {
 	return &ContactController{Service: svc}
 }
 
+// POST /users/{userID}/contacts?on_conflict=keep|overwrite|merge
+// With on_conflict the contact is upserted: matched by external_id and source,
+// then by email or phone, and answered with the outcome
 func (c *ContactController) CreateContactHandler(w http.ResponseWriter, r *http.Request) {
 	claims := auth.GetUserClaims(r)
 	if claims == nil {
@@ -42,14 +76,7 @@ func (c *ContactController) CreateContactHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
-	var input struct {
-		FName   string `json:"first_name"`
-		LName   string `json:"last_name"`
-		Details []struct {
-			Type  string `json:"type"`
-			Value string `json:"value"`
-		} `json:"details"`
-	}
+	var input service.ContactInput
 
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		apperror.HandleBadRequest(w, "invalid JSON")
@@ -63,26 +90,25 @@ func (c *ContactController) CreateContactHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
-	uow := repository.NewUnitOfWork(db.GetDB(), false)
-	defer uow.Rollback()
-
-	contactObj, err := c.Service.CreateContactWithUOW(uow, userID, input.FName, input.LName)
-	if err != nil {
-		apperror.HandleError(w, err)
-		return
-	}
-
-	for _, d := range input.Details {
-		if d.Type == "" || d.Value == "" {
-			continue
-		}
-		if err := c.Service.AddOrUpdateContactDetail(uow, userID, contactObj.ContactID, d.Type, d.Value); err != nil {
+	if policy := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("on_conflict"))); policy != "" {
+		result, err := c.Service.UpsertContact(userID, input, policy)
+		if err != nil {
 			apperror.HandleError(w, err)
 			return
 		}
+		status := http.StatusOK
+		if result.Outcome == service.OutcomeCreated {
+			status = http.StatusCreated
+		}
+		web.RespondJSON(w, status, result)
+		return
 	}
 
-	uow.Commit()
+	contactObj, err := c.Service.CreateContactWithDetails(userID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
 
 	web.RespondJSON(w, http.StatusCreated, contactObj)
 }
@@ -106,19 +132,45 @@ func (c *ContactController) GetContactsHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	resource, err := c.Service.ListingResource(userID, contactResource)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	spec, err := web.ParseQuerySpec(r.URL.Query(), resource)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	if deleted, _ := strconv.ParseBool(r.URL.Query().Get("deleted")); deleted {
+		page, err := c.Service.GetDeletedContactsPage(r, userID, spec)
+		if err != nil {
+			apperror.HandleError(w, err)
+			return
+		}
+		web.RespondJSON(w, http.StatusOK, page.Project(spec))
+		return
+	}
+
 	filters := map[string]string{
-		"f_name": r.URL.Query().Get("f_name"),
-		"l_name": r.URL.Query().Get("l_name"),
-		"phone":  r.URL.Query().Get("phone"),
+		"f_name":             r.URL.Query().Get("f_name"),
+		"l_name":             r.URL.Query().Get("l_name"),
+		"phone":              r.URL.Query().Get("phone"),
+		"group":              r.URL.Query().Get("group"),
+		"q":                  r.URL.Query().Get("q"),
+		"sort":               r.URL.Query().Get("sort"),
+		"not_contacted_days": r.URL.Query().Get("not_contacted_days"),
+		"completeness_below": r.URL.Query().Get("completeness_below"),
 	}
 
-	contacts, err := c.Service.GetContactsWithDetails(userID, filters)
+	page, err := c.Service.GetContactsPage(r, userID, filters, spec)
 	if err != nil {
 		apperror.HandleError(w, err)
 		return
 	}
 
-	web.RespondJSON(w, http.StatusOK, contacts)
+	web.RespondJSON(w, http.StatusOK, page.Project(spec))
 }
 
 // GET /users/{userID}/contacts/{contactID}
@@ -144,7 +196,14 @@ func (c *ContactController) GetContactByIDHandler(w http.ResponseWriter, r *http
 		apperror.HandleError(w, err)
 		return
 	}
+	// a failed view record must not fail the read
+	if err := c.Service.RecordContactView(userID, contactID); err != nil {
+		log.Printf("failed to record view of contact %d by user %d: %v", contactID, userID, err)
+	}
 
+	if web.NotModifiedTag(w, r, service.ContactETag(contactObj)) {
+		return
+	}
 	web.RespondJSON(w, http.StatusOK, contactObj)
 }
 
@@ -171,14 +230,62 @@ func (c *ContactController) UpdateContactHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
-	if err := c.Service.UpdateContactByID(userID, contactID, updates); err != nil {
+	ifMatch, err := web.IfMatchVersion(r)
+	if err != nil {
 		apperror.HandleError(w, err)
 		return
 	}
 
+	version, err := c.Service.UpdateContactByID(userID, contactID, updates, ifMatch)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.SetETag(w, version)
 	web.RespondJSON(w, http.StatusOK, map[string]string{"message": "contact updated"})
 }
 
+// PATCH /users/{userID}/contacts/{contactID}
+// Accepts application/merge-patch+json or application/json-patch+json
+func (c *ContactController) PatchContactHandler(w http.ResponseWriter, r *http.Request) {
+	claims := auth.GetUserClaims(r)
+	if claims == nil {
+		apperror.HandleUnauthorized(w, "missing or invalid token")
+		return
+	}
+
+	userID64, _ := strconv.ParseUint(mux.Vars(r)["userID"], 10, 64)
+	contactID64, _ := strconv.ParseUint(mux.Vars(r)["contactID"], 10, 64)
+	userID := uint(userID64)
+	contactID := uint(contactID64)
+
+	if claims.UserID != int(userID) {
+		http.Error(w, "Forbidden: cannot update another user's contact", http.StatusForbidden)
+		return
+	}
+
+	ifMatch, err := web.IfMatchVersion(r)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	body, err := web.ReadPatchBody(w, r)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	contactObj, err := c.Service.PatchContact(userID, contactID, r.Header.Get("Content-Type"), body, ifMatch)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.SetNewHeader(w, "ETag", service.ContactETag(contactObj))
+	web.RespondJSON(w, http.StatusOK, contactObj)
+}
+
 // DELETE /users/{userID}/contacts/{contactID}
 func (c *ContactController) DeleteContactHandler(w http.ResponseWriter, r *http.Request) {
 	claims := auth.GetUserClaims(r)
@@ -197,7 +304,13 @@ func (c *ContactController) DeleteContactHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
-	if err := c.Service.DeleteContactByID(userID, contactID); err != nil {
+	ifMatch, err := web.IfMatchVersion(r)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	if err := c.Service.DeleteContactByID(userID, contactID, ifMatch); err != nil {
 		apperror.HandleError(w, err)
 		return
 	}
@@ -205,10 +318,173 @@ func (c *ContactController) DeleteContactHandler(w http.ResponseWriter, r *http.
 	web.RespondJSON(w, http.StatusOK, map[string]string{"message": "contact deleted"})
 }
 
+// POST /users/{userID}/contacts/{contactID}/restore
+func (c *ContactController) RestoreContactHandler(w http.ResponseWriter, r *http.Request) {
+	claims := auth.GetUserClaims(r)
+	if claims == nil {
+		apperror.HandleUnauthorized(w, "missing or invalid token")
+		return
+	}
+
+	userID64, _ := strconv.ParseUint(mux.Vars(r)["userID"], 10, 64)
+	contactID64, _ := strconv.ParseUint(mux.Vars(r)["contactID"], 10, 64)
+	userID := uint(userID64)
+	contactID := uint(contactID64)
+
+	if claims.UserID != int(userID) {
+		http.Error(w, "Forbidden: cannot restore another user's contact", http.StatusForbidden)
+		return
+	}
+
+	contactObj, err := c.Service.RestoreContactByID(userID, contactID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.RespondJSON(w, http.StatusOK, contactObj)
+}
+
+// DELETE /users/{userID}/contacts/{contactID}/permanent
+func (c *ContactController) PermanentDeleteContactHandler(w http.ResponseWriter, r *http.Request) {
+	claims := auth.GetUserClaims(r)
+	if claims == nil {
+		apperror.HandleUnauthorized(w, "missing or invalid token")
+		return
+	}
+
+	userID64, _ := strconv.ParseUint(mux.Vars(r)["userID"], 10, 64)
+	contactID64, _ := strconv.ParseUint(mux.Vars(r)["contactID"], 10, 64)
+	userID := uint(userID64)
+	contactID := uint(contactID64)
+
+	if claims.UserID != int(userID) {
+		http.Error(w, "Forbidden: cannot delete another user's contact", http.StatusForbidden)
+		return
+	}
+
+	if err := c.Service.PermanentlyDeleteContactByID(userID, contactID); err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.RespondJSON(w, http.StatusOK, map[string]string{"message": "contact permanently deleted"})
+}
+
+// POST /users/{userID}/contacts/import?on_conflict=keep|overwrite|merge&source=
+// Accepts a multipart form with a "file" field or the vCard text as the body.
+// Card UIDs are kept as external ids within source; with on_conflict cards
+// matching existing contacts update them instead of creating duplicates.
+func (c *ContactController) ImportContactsHandler(w http.ResponseWriter, r *http.Request) {
+	claims := auth.GetUserClaims(r)
+	if claims == nil {
+		apperror.HandleUnauthorized(w, "missing or invalid token")
+		return
+	}
+
+	userID64, err := strconv.ParseUint(mux.Vars(r)["userID"], 10, 64)
+	if err != nil {
+		apperror.HandleBadRequest(w, "invalid userID")
+		return
+	}
+	userID := uint(userID64)
+
+	if claims.UserID != int(userID) {
+		http.Error(w, "Forbidden: cannot import contacts for another user", http.StatusForbidden)
+		return
+	}
+
+	limit := service.MaxImportBytes()
+	r.Body = http.MaxBytesReader(w, r.Body, limit+64*1024)
+
+	var src io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			apperror.HandleBadRequest(w, "multipart body must contain a vCard file")
+			return
+		}
+		defer file.Close()
+		src = file
+	}
+
+	data, err := io.ReadAll(io.LimitReader(src, limit+1))
+	if err != nil || int64(len(data)) > limit {
+		apperror.HandleError(w, apperror.NewValidationError("file", fmt.Sprintf("must be at most %d bytes", limit)))
+		return
+	}
+
+	opts := service.ImportOptions{
+		Policy: strings.ToLower(strings.TrimSpace(r.URL.Query().Get("on_conflict"))),
+		Source: r.URL.Query().Get("source"),
+	}
+	result, err := c.Service.ImportVCards(userID, bytes.NewReader(data), opts)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	status := http.StatusCreated
+	if result.Created == 0 {
+		status = http.StatusOK
+	}
+	web.RespondJSON(w, status, result)
+}
+
+// GET /users/{userID}/contacts/export?format=csv|vcard
+func (c *ContactController) ExportContactsHandler(w http.ResponseWriter, r *http.Request) {
+	claims := auth.GetUserClaims(r)
+	if claims == nil {
+		apperror.HandleUnauthorized(w, "missing or invalid token")
+		return
+	}
+
+	userID64, err := strconv.ParseUint(mux.Vars(r)["userID"], 10, 64)
+	if err != nil {
+		apperror.HandleBadRequest(w, "invalid userID")
+		return
+	}
+	userID := uint(userID64)
+
+	if claims.UserID != int(userID) {
+		http.Error(w, "Forbidden: cannot export contacts of another user", http.StatusForbidden)
+		return
+	}
+
+	format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+	if format == "" {
+		format = export.FormatCSV
+	}
+	if !export.IsSupportedFormat(format) {
+		apperror.HandleBadRequest(w, "format must be csv or vcard")
+		return
+	}
+
+	contacts, photos, err := c.Service.ExportContacts(userID, format)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	_ = export.Respond(w, format, fmt.Sprintf("contacts-%d", userID), contacts, photos)
+}
+
 func (c *ContactController) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/users/{userID}/contacts", c.CreateContactHandler).Methods("POST")
 	router.HandleFunc("/users/{userID}/contacts", c.GetContactsHandler).Methods("GET")
-	router.HandleFunc("/users/{userID}/contacts/{contactID}", c.GetContactByIDHandler).Methods("GET")
-	router.HandleFunc("/users/{userID}/contacts/{contactID}", c.UpdateContactHandler).Methods("PUT")
-	router.HandleFunc("/users/{userID}/contacts/{contactID}", c.DeleteContactHandler).Methods("DELETE")
+	router.HandleFunc("/users/{userID}/contacts/import", c.ImportContactsHandler).Methods("POST")
+	router.HandleFunc("/users/{userID}/contacts/export", c.ExportContactsHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}", c.GetContactByIDHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}", c.UpdateContactHandler).Methods("PUT")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}", c.PatchContactHandler).Methods("PATCH")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}", c.DeleteContactHandler).Methods("DELETE")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/restore", c.RestoreContactHandler).Methods("POST")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/permanent", c.PermanentDeleteContactHandler).Methods("DELETE")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/favorite", c.StarContactHandler).Methods("PUT")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/favorite", c.UnstarContactHandler).Methods("DELETE")
+	router.HandleFunc("/me/contacts/changes", c.GetChangesHandler).Methods("GET")
+	router.HandleFunc("/me/contacts/recent", c.GetRecentContactsHandler).Methods("GET")
+	router.HandleFunc("/me/contacts/recent", c.ClearRecentContactsHandler).Methods("DELETE")
+	router.HandleFunc("/me/contacts/recent/settings", c.GetRecentSettingsHandler).Methods("GET")
+	router.HandleFunc("/me/contacts/recent/settings", c.UpdateRecentSettingsHandler).Methods("PUT")
 }
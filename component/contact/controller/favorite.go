@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/component/auth"
+	"Contact_App/component/contact/service"
+	"Contact_App/web"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// PUT /users/{userID}/contacts/{contactID}/favorite
+func (c *ContactController) StarContactHandler(w http.ResponseWriter, r *http.Request) {
+	userID, contactID, ok := favoriteTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.Service.StarContact(userID, contactID); err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, map[string]interface{}{"contact_id": contactID, "is_favorite": true})
+}
+
+// DELETE /users/{userID}/contacts/{contactID}/favorite
+func (c *ContactController) UnstarContactHandler(w http.ResponseWriter, r *http.Request) {
+	userID, contactID, ok := favoriteTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.Service.UnstarContact(userID, contactID); err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, map[string]interface{}{"contact_id": contactID, "is_favorite": false})
+}
+
+// GET /me/contacts/recent
+func (c *ContactController) GetRecentContactsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	contacts, err := c.Service.GetRecentContacts(userID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, contacts)
+}
+
+// DELETE /me/contacts/recent
+func (c *ContactController) ClearRecentContactsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.Service.ClearRecentContacts(userID); err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, map[string]string{"message": "recently viewed contacts cleared"})
+}
+
+// GET /me/contacts/recent/settings
+func (c *ContactController) GetRecentSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	settings, err := c.Service.GetRecentSettings(userID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, settings)
+}
+
+// PUT /me/contacts/recent/settings
+// {"enabled": false} opts out of tracking and clears the history
+func (c *ContactController) UpdateRecentSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var input service.RecentSettingsInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	settings, err := c.Service.UpdateRecentSettings(userID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, settings)
+}
+
+// GET /me/contacts/changes?since=<token>&limit=<n>
+// Without since every visible contact comes back as created, with the token
+// to pass next time; 410 means the token expired and a full sync is needed
+func (c *ContactController) GetChangesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			apperror.HandleBadRequest(w, "limit must be a positive number")
+			return
+		}
+		limit = n
+	}
+
+	changes, err := c.Service.GetChanges(userID, r.URL.Query().Get("since"), limit)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, changes)
+}
+
+// currentUser returns the caller of a /me route
+func currentUser(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	claims := auth.GetUserClaims(r)
+	if claims == nil {
+		apperror.HandleUnauthorized(w, "missing or invalid token")
+		return 0, false
+	}
+	return uint(claims.UserID), true
+}
+
+func favoriteTarget(w http.ResponseWriter, r *http.Request) (uint, uint, bool) {
+	claims := auth.GetUserClaims(r)
+	if claims == nil {
+		apperror.HandleUnauthorized(w, "missing or invalid token")
+		return 0, 0, false
+	}
+
+	userID64, err := strconv.ParseUint(mux.Vars(r)["userID"], 10, 64)
+	if err != nil {
+		apperror.HandleBadRequest(w, "invalid userID")
+		return 0, 0, false
+	}
+	if claims.UserID != int(userID64) {
+		http.Error(w, "Forbidden: cannot access another user's contacts", http.StatusForbidden)
+		return 0, 0, false
+	}
+	contactID64, err := strconv.ParseUint(mux.Vars(r)["contactID"], 10, 64)
+	if err != nil {
+		apperror.HandleBadRequest(w, "invalid contactID")
+		return 0, 0, false
+	}
+	return uint(userID64), uint(contactID64), true
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	customFieldService "Contact_App/component/custom_field/service"
+	shareService "Contact_App/component/share/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/export"
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_detail"
+	"Contact_App/models/contact_version"
+	"Contact_App/patch"
+	"Contact_App/repository"
+	"encoding/json"
+	"strings"
+)
+
+// GetCards loads the contacts of the user's address book, or only the listed
+// ones when contactIDs is not nil, with details and export photos. The
+// address book holds the same contacts as the contacts listing.
+func (s *ContactService) GetCards(userID uint, contactIDs []uint) ([]*contact.Contact, export.Photos, error) {
+	var processors []repository.QueryProcessor
+	if contactIDs != nil {
+		if len(contactIDs) == 0 {
+			return []*contact.Contact{}, export.Photos{}, nil
+		}
+		processors = append(processors, repository.Filter("contacts.contact_id IN ?", contactIDs))
+	}
+
+	contacts, err := s.GetContactsWithDetails(userID, nil, processors...)
+	if err != nil {
+		return nil, nil, err
+	}
+	photos, err := s.loadPhotos(contacts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return contacts, photos, nil
+}
+
+// CreateFromCard stores a vCard uploaded by a sync client as a new contact
+// of the user, the same way ImportVCards does for every card of a file
+func (s *ContactService) CreateFromCard(userID uint, card *export.Card) (*contact.Contact, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	defined, err := definedCustomFields(uow, userID)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.importCard(uow, userID, card, "", defined)
+	if err != nil {
+		return nil, err
+	}
+
+	uow.Commit()
+	return c, nil
+}
+
+// UpdateFromCard replaces the name, details and custom fields of a contact
+// with those of a vCard, as a merge patch so history, ETags and access checks
+// work as for PATCH. Details that are still on the card keep their ids.
+// Properties the contact model has no place for are left alone, and a PHOTO
+// on the card replaces the contact's photo.
+func (s *ContactService) UpdateFromCard(userID, contactID uint, card *export.Card, ifMatch uint) (*contact.Contact, error) {
+	current, err := s.GetContactByIDWithDetails(userID, contactID)
+	if err != nil {
+		return nil, err
+	}
+
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+	defined, err := definedCustomFields(uow, current.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	unused := make(map[string][]*contact_detail.ContactDetail)
+	for _, d := range current.Details {
+		if d != nil && d.IsActive {
+			key := d.Type + "\x00" + d.Value
+			unused[key] = append(unused[key], d)
+		}
+	}
+	details := make([]map[string]interface{}, 0, len(card.Details))
+	for _, d := range card.Details {
+		detail := map[string]interface{}{"type": strings.ToLower(d.Type), "value": d.Value}
+		key := strings.ToLower(d.Type) + "\x00" + d.Value
+		if kept := unused[key]; len(kept) > 0 {
+			detail["contact_details_id"] = kept[0].ContactDetailsID
+			if kept[0].ExternalID != "" {
+				detail["external_id"] = kept[0].ExternalID
+				detail["source"] = kept[0].Source
+			}
+			unused[key] = kept[1:]
+		}
+		details = append(details, detail)
+	}
+
+	customFields := make(map[string]interface{})
+	for key := range current.CustomFields {
+		customFields[key] = nil
+	}
+	for key, value := range card.CustomFields {
+		if defined[key] {
+			customFields[key] = value
+		}
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"first_name":    strings.TrimSpace(card.FName),
+		"last_name":     strings.TrimSpace(card.LName),
+		"details":       details,
+		"custom_fields": customFields,
+	})
+	if err != nil {
+		return nil, apperror.NewInternalError("failed to encode contact patch")
+	}
+	updated, err := s.PatchContact(userID, contactID, patch.MediaTypeMergePatch, body, ifMatch)
+	if err != nil {
+		return nil, err
+	}
+
+	if card.Photo != nil && len(card.Photo.Data) > 0 {
+		if _, err := s.photos.UploadPhoto(updated.UserID, contactID, card.Photo.Data); err != nil {
+			return nil, err
+		}
+	}
+	return updated, nil
+}
+
+// CardChanges reports which contacts of the user's address book changed
+// after the sync token since and which left it, together with the current
+// token. Tokens are contact version ids, so they only move forward; 0 asks
+// for the whole address book. A token whose version has been purged with
+// its contact can no longer prove what was removed and is rejected. Contacts
+// that leave the address book because a share is revoked are not reported.
+func (s *ContactService) CardChanges(userID, since uint) (changed, removed []uint, token uint, err error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	token, err = syncToken(uow, userID)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	if since > 0 {
+		var count int64
+		if err := uow.DB.Model(&contact_version.ContactVersion{}).
+			Where("version_id = ?", since).
+			Count(&count).Error; err != nil {
+			return nil, nil, 0, apperror.NewInternalError("failed to check sync token")
+		}
+		if count == 0 || since > token {
+			return nil, nil, 0, apperror.NewForbiddenError("sync-token", "sync token is no longer valid")
+		}
+	}
+
+	var rows []struct {
+		ContactID uint
+		Live      bool
+	}
+	versions := uow.DB.Model(&contact_version.ContactVersion{}).
+		Select("contact_id").
+		Where("version_id > ?", since)
+	if err := uow.DB.Unscoped().Model(&contact.Contact{}).
+		Select("contacts.contact_id, contacts.deleted_at IS NULL AND contacts.is_active AS live").
+		Where(shareService.AccessibleContacts(uow.DB, userID)).
+		Where("contacts.contact_id IN (?)", versions).
+		Order("contacts.contact_id").
+		Scan(&rows).Error; err != nil {
+		return nil, nil, 0, apperror.NewInternalError("failed to load changed contacts")
+	}
+
+	changed, removed = []uint{}, []uint{}
+	for _, row := range rows {
+		if row.Live {
+			changed = append(changed, row.ContactID)
+		} else if since > 0 {
+			removed = append(removed, row.ContactID)
+		}
+	}
+	return changed, removed, token, nil
+}
+
+// SyncToken returns the current sync token of the user's address book
+func (s *ContactService) SyncToken(userID uint) (uint, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return 0, err
+	}
+	return syncToken(uow, userID)
+}
+
+// syncToken is the newest version id of any contact the user can see,
+// including deleted ones so that a deletion moves the token too
+func syncToken(uow *repository.UnitOfWork, userID uint) (uint, error) {
+	accessible := uow.DB.Unscoped().Model(&contact.Contact{}).
+		Select("contacts.contact_id").
+		Where(shareService.AccessibleContacts(uow.DB, userID))
+
+	var token uint
+	if err := uow.DB.Model(&contact_version.ContactVersion{}).
+		Select("COALESCE(MAX(version_id), 0)").
+		Where("contact_id IN (?)", accessible).
+		Scan(&token).Error; err != nil {
+		return 0, apperror.NewInternalError("failed to read sync token")
+	}
+	return token, nil
+}
+
+// loadPhotos reads the export photos of contacts
+func (s *ContactService) loadPhotos(contacts []*contact.Contact) (export.Photos, error) {
+	ids := make([]uint, 0, len(contacts))
+	for _, c := range contacts {
+		ids = append(ids, c.ContactID)
+	}
+	return s.photos.LoadExportPhotos(ids)
+}
+
+// definedCustomFields returns the keys of the custom fields the user has defined
+func definedCustomFields(uow *repository.UnitOfWork, userID uint) (map[string]bool, error) {
+	fields, err := customFieldService.LoadDefinitions(uow.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	defined := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		defined[f.Key] = true
+	}
+	return defined, nil
+}
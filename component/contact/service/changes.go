@@ -0,0 +1,306 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	shareService "Contact_App/component/share/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_change"
+	"Contact_App/models/contact_detail"
+	"Contact_App/repository"
+	"Contact_App/web"
+	"encoding/json"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultChangesLimit = 500
+	MaxChangesLimit     = 1000
+
+	// syncTokenSort tells sync tokens apart from listing cursors, which share their signing
+	syncTokenSort = "changes"
+
+	// changeSettleTime is how long a write may take to commit. Sequence
+	// numbers are handed out at insert, so a change younger than this may
+	// still be joined by a smaller, uncommitted one; tokens never move past it.
+	changeSettleTime = 5 * time.Second
+
+	defaultChangeLogRetentionDays = 30
+)
+
+// ChangeLogRetention is how long the change sequence is kept for delta sync,
+// read from CHANGE_LOG_RETENTION_DAYS
+func ChangeLogRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("CHANGE_LOG_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		days = defaultChangeLogRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// ChangeSet is one page of delta sync: the contacts and details created,
+// updated and deleted since the request's token, and the token to send next.
+// Without a token every contact the user can see is listed as created.
+type ChangeSet struct {
+	Created   []*contact.Contact                 `json:"created"`
+	Updated   []*contact.Contact                 `json:"updated"`
+	Deleted   []*contact_change.ContactTombstone `json:"deleted"`
+	Details   DetailChangeSet                    `json:"details"`
+	SyncToken string                             `json:"sync_token"`
+	HasMore   bool                               `json:"has_more"`
+}
+
+// DetailChangeSet lists the detail changes of contacts the client keeps;
+// details of deleted contacts are covered by the contact's tombstone
+type DetailChangeSet struct {
+	Created []*contact_detail.ContactDetail   `json:"created"`
+	Updated []*contact_detail.ContactDetail   `json:"updated"`
+	Deleted []*contact_change.DetailTombstone `json:"deleted"`
+}
+
+// GetChanges returns what changed in the user's contacts after token, reading
+// at most limit entries of the change sequence. Changes are reported by the
+// current state of each contact and detail, so a page is safe to apply more
+// than once. Tokens whose change is older than the change log retention are
+// rejected with 410, since the changes after it may have been purged; the
+// client then starts over without a token.
+func (s *ContactService) GetChanges(userID uint, token string, limit int) (*ChangeSet, error) {
+	if limit <= 0 {
+		limit = DefaultChangesLimit
+	}
+	if limit > MaxChangesLimit {
+		limit = MaxChangesLimit
+	}
+	since, sinceAt, err := decodeSyncToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &ChangeSet{
+		Created: []*contact.Contact{},
+		Updated: []*contact.Contact{},
+		Deleted: []*contact_change.ContactTombstone{},
+		Details: DetailChangeSet{
+			Created: []*contact_detail.ContactDetail{},
+			Updated: []*contact_detail.ContactDetail{},
+			Deleted: []*contact_change.DetailTombstone{},
+		},
+	}
+	if since == 0 {
+		return s.fullSync(uow, userID, set)
+	}
+
+	var changes []*contact_change.ContactChange
+	if err := visibleChanges(uow, userID).
+		Where("seq > ?", since).
+		Order("seq").
+		Limit(limit + 1).
+		Find(&changes).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load contact changes")
+	}
+	if len(changes) > limit {
+		changes = changes[:limit]
+		set.HasMore = true
+	}
+
+	next, nextAt := since, sinceAt
+	settled := time.Now().Add(-changeSettleTime)
+	if len(changes) == 0 && settled.After(nextAt) {
+		// nothing happened since the token, so it stays good as if just taken
+		nextAt = settled
+	}
+	for _, change := range changes {
+		if change.CreatedAt.After(settled) {
+			set.HasMore = false
+			break
+		}
+		next, nextAt = change.Seq, change.CreatedAt
+	}
+	set.SyncToken = encodeSyncToken(next, nextAt)
+
+	if err := s.applyChanges(userID, changes, set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// fullSync lists every contact the user can see, with a token taken before
+// reading them so nothing written meanwhile is skipped. Without any settled
+// change the token starts at the settle horizon.
+func (s *ContactService) fullSync(uow *repository.UnitOfWork, userID uint, set *ChangeSet) (*ChangeSet, error) {
+	settled := time.Now().Add(-changeSettleTime)
+	var newest []*contact_change.ContactChange
+	if err := visibleChanges(uow, userID).
+		Where("created_at <= ?", settled).
+		Order("seq DESC").
+		Limit(1).
+		Find(&newest).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to read change sequence")
+	}
+	latest := &contact_change.ContactChange{CreatedAt: settled}
+	if len(newest) > 0 {
+		latest = newest[0]
+	}
+
+	contacts, err := s.GetContactsWithDetails(userID, nil)
+	if err != nil {
+		return nil, err
+	}
+	set.Created = contacts
+	for _, c := range contacts {
+		for _, d := range c.Details {
+			if d != nil && d.IsActive {
+				set.Details.Created = append(set.Details.Created, d)
+			}
+		}
+	}
+	set.SyncToken = encodeSyncToken(latest.Seq, latest.CreatedAt)
+	return set, nil
+}
+
+// applyChanges sorts the contacts and details touched by changes into the
+// set by how they stand now
+func (s *ContactService) applyChanges(userID uint, changes []*contact_change.ContactChange, set *ChangeSet) error {
+	type touched struct {
+		contactID uint
+		created   bool
+		changedAt time.Time
+	}
+	var contactOrder, detailOrder []uint
+	contacts := make(map[uint]*touched)
+	details := make(map[uint]*touched)
+	for _, change := range changes {
+		id, seen := change.ContactID, contacts
+		if change.DetailID != nil {
+			id, seen = *change.DetailID, details
+		}
+		t := seen[id]
+		if t == nil {
+			t = &touched{contactID: change.ContactID}
+			seen[id] = t
+			if change.DetailID != nil {
+				detailOrder = append(detailOrder, id)
+			} else {
+				contactOrder = append(contactOrder, id)
+			}
+		}
+		t.created = t.created || change.Action == contact_change.ActionCreate
+		t.changedAt = change.CreatedAt
+	}
+
+	ids := make([]uint, 0, len(contacts))
+	for id := range contacts {
+		ids = append(ids, id)
+	}
+	for _, t := range details {
+		ids = append(ids, t.contactID)
+	}
+	live := make(map[uint]*contact.Contact)
+	if len(ids) > 0 {
+		current, err := s.GetContactsWithDetails(userID, nil, repository.Filter("contacts.contact_id IN ?", ids))
+		if err != nil {
+			return err
+		}
+		for _, c := range current {
+			live[c.ContactID] = c
+		}
+	}
+
+	for _, id := range contactOrder {
+		t := contacts[id]
+		c, ok := live[id]
+		switch {
+		case ok && t.created:
+			set.Created = append(set.Created, c)
+		case ok:
+			set.Updated = append(set.Updated, c)
+		case !t.created:
+			// a contact created and removed within the page was never on the client
+			set.Deleted = append(set.Deleted, &contact_change.ContactTombstone{ContactID: id, DeletedAt: t.changedAt})
+		}
+	}
+
+	for _, id := range detailOrder {
+		t := details[id]
+		c, ok := live[t.contactID]
+		if !ok {
+			continue
+		}
+		var detail *contact_detail.ContactDetail
+		for _, d := range c.Details {
+			if d != nil && d.IsActive && d.ContactDetailsID == id {
+				detail = d
+			}
+		}
+		switch {
+		case detail != nil && t.created:
+			set.Details.Created = append(set.Details.Created, detail)
+		case detail != nil:
+			set.Details.Updated = append(set.Details.Updated, detail)
+		case !t.created:
+			set.Details.Deleted = append(set.Details.Deleted, &contact_change.DetailTombstone{
+				ContactDetailsID: id, ContactID: t.contactID, DeletedAt: t.changedAt,
+			})
+		}
+	}
+	return nil
+}
+
+// visibleChanges selects the changes of contacts the user can see, and of
+// their own and their workspaces' contacts that have since been purged.
+// Revokes only concern the user who lost access.
+func visibleChanges(uow *repository.UnitOfWork, userID uint) *gorm.DB {
+	accessible := uow.DB.Unscoped().Model(&contact.Contact{}).
+		Select("contacts.contact_id").
+		Where(shareService.AccessibleContacts(uow.DB, userID))
+	conn := uow.DB.Session(&gorm.Session{NewDB: true})
+	return uow.DB.Where(conn.Where("contact_changes.contact_id IN (?)", accessible).
+		Or("contact_changes.user_id = ? AND contact_changes.workspace_id IS NULL", userID).
+		Or("contact_changes.workspace_id IN (?)", workspaceService.WorkspaceIDs(conn, userID))).
+		Where("contact_changes.action <> ? OR contact_changes.user_id = ?", contact_change.ActionRevoke, userID)
+}
+
+// encodeSyncToken signs a change sequence number together with the time that
+// change was recorded
+func encodeSyncToken(seq uint, changedAt time.Time) string {
+	return web.EncodeCursor(web.Cursor{Values: []interface{}{seq, changedAt.Unix()}, Sort: syncTokenSort})
+}
+
+// decodeSyncToken returns the sequence number in token and the time its change
+// was recorded, 0 for no token. The purge drops changes older than the change
+// log retention, so a token whose change is older may have lost the changes after
+// it (seq order may run ahead of created_at by the settle time) and is gone.
+func decodeSyncToken(token string) (uint, time.Time, error) {
+	if token == "" {
+		return 0, time.Time{}, nil
+	}
+	cursor, err := web.DecodeCursor(token)
+	if err != nil || cursor.Sort != syncTokenSort || len(cursor.Values) != 2 {
+		return 0, time.Time{}, apperror.NewValidationError("since", "not a sync token")
+	}
+	seqValue, okSeq := cursor.Values[0].(json.Number)
+	changedValue, okChanged := cursor.Values[1].(json.Number)
+	if !okSeq || !okChanged {
+		return 0, time.Time{}, apperror.NewValidationError("since", "not a sync token")
+	}
+	seq, errSeq := seqValue.Int64()
+	changed, errChanged := changedValue.Int64()
+	if errSeq != nil || errChanged != nil || seq < 0 {
+		return 0, time.Time{}, apperror.NewValidationError("since", "not a sync token")
+	}
+	changedAt := time.Unix(changed, 0)
+	cutoff := time.Now().Add(-ChangeLogRetention())
+	if changedAt.Add(-changeSettleTime).Before(cutoff) {
+		return 0, time.Time{}, apperror.NewGoneError("since", "sync token has expired; sync again without since")
+	}
+	return uint(seq), changedAt, nil
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	groupService "Contact_App/component/group/service"
+	shareService "Contact_App/component/share/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/db/dbtest"
+	"Contact_App/models/contact_change"
+	"Contact_App/models/contact_share"
+	"Contact_App/models/user"
+	"Contact_App/models/workspace"
+	"Contact_App/repository"
+	"Contact_App/web"
+	"net/http"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestDecodeSyncToken(t *testing.T) {
+	t.Setenv("CURSOR_SECRET", "test-secret")
+	t.Setenv("TRASH_RETENTION_DAYS", "1")
+	t.Setenv("CHANGE_LOG_RETENTION_DAYS", "30")
+	if err := web.InitCursorSecret(); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	retention := 30 * 24 * time.Hour
+	tests := []struct {
+		name       string
+		token      string
+		wantSeq    uint
+		wantAt     time.Time
+		wantStatus int
+	}{
+		{name: "no token", token: ""},
+		{name: "recent change", token: encodeSyncToken(42, now), wantSeq: 42, wantAt: now},
+		{name: "change within retention", token: encodeSyncToken(7, now.Add(-retention+time.Hour)), wantSeq: 7, wantAt: now.Add(-retention + time.Hour)},
+		{name: "change within settle time of the cutoff", token: encodeSyncToken(7, now.Add(-retention+changeSettleTime/2)), wantStatus: http.StatusGone},
+		{name: "change older than retention", token: encodeSyncToken(7, now.Add(-retention-time.Hour)), wantStatus: http.StatusGone},
+		{name: "garbage", token: "not-a-token", wantStatus: http.StatusBadRequest},
+		{name: "cursor of another sort", token: web.EncodeCursor(web.Cursor{Values: []interface{}{1, now.Unix()}, Sort: "last_name"}), wantStatus: http.StatusBadRequest},
+		{name: "legacy token without time", token: web.EncodeCursor(web.Cursor{Values: []interface{}{1}, Sort: syncTokenSort}), wantStatus: http.StatusBadRequest},
+		{name: "non-numeric seq", token: web.EncodeCursor(web.Cursor{Values: []interface{}{"1", now.Unix()}, Sort: syncTokenSort}), wantStatus: http.StatusBadRequest},
+		{name: "negative seq", token: web.EncodeCursor(web.Cursor{Values: []interface{}{-1, now.Unix()}, Sort: syncTokenSort}), wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seq, at, err := decodeSyncToken(tt.token)
+			if tt.wantStatus != 0 {
+				appErr, ok := err.(apperror.AppError)
+				if !ok || appErr.StatusCode() != tt.wantStatus {
+					t.Fatalf("decodeSyncToken() error = %v, want status %d", err, tt.wantStatus)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeSyncToken() error = %v", err)
+			}
+			if seq != tt.wantSeq || !at.Equal(tt.wantAt) {
+				t.Errorf("decodeSyncToken() = %d, %v, want %d, %v", seq, at, tt.wantSeq, tt.wantAt)
+			}
+		})
+	}
+}
+
+// latestSyncToken is the token a client holds after syncing everything recorded so far
+func latestSyncToken(t *testing.T, conn *gorm.DB) string {
+	t.Helper()
+	var seq uint
+	if err := repository.WithoutTenant(conn).Model(&contact_change.ContactChange{}).
+		Select("COALESCE(MAX(seq), 0)").Scan(&seq).Error; err != nil {
+		t.Fatal(err)
+	}
+	return encodeSyncToken(seq, time.Now())
+}
+
+// Losing access to a contact reaches delta sync as a delete for the user who
+// lost it, and leaves everyone else's sync alone.
+func TestGetChangesReportsRevokedAccess(t *testing.T) {
+	t.Setenv("CURSOR_SECRET", "test-secret")
+	if err := web.InitCursorSecret(); err != nil {
+		t.Fatal(err)
+	}
+	conn := dbtest.Open(t)
+	owner := dbtest.User(t, conn, "owner@example.com")
+	recipient := dbtest.User(t, conn, "recipient@example.com")
+	member := dbtest.User(t, conn, "member@example.com")
+
+	svc := NewContactService()
+	shared, err := svc.CreateContact(owner.UserID, "Shared", "Contact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grouped, err := svc.CreateContact(owner.UserID, "Grouped", "Contact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	share := dbtest.ShareContact(t, conn, owner, recipient, shared.ContactID, contact_share.PermissionRead)
+	friends := dbtest.Group(t, conn, owner, "Friends", grouped)
+	dbtest.ShareGroup(t, conn, owner, recipient, friends.GroupID, contact_share.PermissionRead)
+	team := dbtest.Workspace(t, conn, "Team", map[*user.User]string{owner: workspace.RoleAdmin, member: workspace.RoleEditor})
+	teamContact := dbtest.Contact(t, conn, owner, &team.WorkspaceID, "Team", "Contact")
+
+	token := latestSyncToken(t, conn)
+
+	if err := shareService.NewShareService().DeleteShare(owner.UserID, share.ShareID); err != nil {
+		t.Fatalf("DeleteShare() error = %v", err)
+	}
+	if _, err := groupService.NewGroupService().RemoveContacts(owner.UserID, friends.GroupID, []uint{grouped.ContactID}); err != nil {
+		t.Fatalf("RemoveContacts() error = %v", err)
+	}
+	if err := workspaceService.NewWorkspaceService().RemoveMember(owner.UserID, team.WorkspaceID, member.UserID); err != nil {
+		t.Fatalf("RemoveMember() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		userID      uint
+		wantDeleted []uint
+	}{
+		{"share recipient drops the unshared and ungrouped contacts", recipient.UserID, []uint{shared.ContactID, grouped.ContactID}},
+		{"removed member drops the workspace's contacts", member.UserID, []uint{teamContact.ContactID}},
+		{"owner sees no change", owner.UserID, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := svc.GetChanges(tt.userID, token, 0)
+			if err != nil {
+				t.Fatalf("GetChanges() error = %v", err)
+			}
+			var deleted []uint
+			for _, tomb := range set.Deleted {
+				deleted = append(deleted, tomb.ContactID)
+			}
+			if len(deleted) != len(tt.wantDeleted) {
+				t.Fatalf("deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+			for i := range deleted {
+				if deleted[i] != tt.wantDeleted[i] {
+					t.Fatalf("deleted = %v, want %v", deleted, tt.wantDeleted)
+				}
+			}
+			if len(set.Created) != 0 || len(set.Updated) != 0 {
+				t.Errorf("created %d, updated %d contacts, want none", len(set.Created), len(set.Updated))
+			}
+		})
+	}
+}
+
+// The trash purge leaves the change sequence to its own retention.
+func TestPurgeKeepsChangesForTheirOwnRetention(t *testing.T) {
+	conn := dbtest.Open(t)
+	owner := dbtest.User(t, conn, "owner@example.com")
+	svc := NewContactService()
+	if _, err := svc.CreateContact(owner.UserID, "Kept", "Contact"); err != nil {
+		t.Fatal(err)
+	}
+
+	countChanges := func() int64 {
+		var count int64
+		if err := repository.WithoutTenant(conn).Model(&contact_change.ContactChange{}).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	if _, err := svc.PurgeDeletedContacts(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("PurgeDeletedContacts() error = %v", err)
+	}
+	if countChanges() == 0 {
+		t.Fatal("trash purge removed the change sequence")
+	}
+	if _, err := svc.PurgeContactChanges(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("PurgeContactChanges() error = %v", err)
+	}
+	if got := countChanges(); got != 0 {
+		t.Errorf("%d changes left after the change log purge, want 0", got)
+	}
+}
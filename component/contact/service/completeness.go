@@ -0,0 +1,224 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/helper"
+	"Contact_App/models/contact"
+	"Contact_App/web"
+	"strconv"
+	"strings"
+)
+
+// Points each part of a contact adds to its completeness score, out of 100.
+// Valid values only count once the contact has details to check.
+const (
+	namePoints    = 10
+	emailPoints   = 25
+	phonePoints   = 25
+	addressPoints = 15
+	companyPoints = 15
+	validPoints   = 10
+)
+
+// Fields a contact can be missing
+const (
+	FieldFirstName = "first_name"
+	FieldLastName  = "last_name"
+	FieldEmail     = "email"
+	FieldPhone     = "phone"
+	FieldAddress   = "address"
+	FieldCompany   = "company"
+)
+
+// CompletenessFields lists the fields a completeness score checks, in report order
+var CompletenessFields = []string{FieldFirstName, FieldLastName, FieldEmail, FieldPhone, FieldAddress, FieldCompany}
+
+// Completeness is how well filled in a contact is: a score from 0 to 100,
+// the fields it lacks and the detail values that fail validation
+type Completeness struct {
+	ContactID uint            `json:"contact_id"`
+	Score     int             `json:"score"`
+	Missing   []string        `json:"missing"`
+	Invalid   []*InvalidValue `json:"invalid"`
+}
+
+// InvalidValue is a detail whose value is empty, malformed or a repeat of
+// another value of the same contact once normalized
+type InvalidValue struct {
+	DetailID uint   `json:"contact_details_id"`
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+	Reason   string `json:"reason"`
+}
+
+// ScoreCompleteness scores a contact from its names, organization and active
+// details. An email or phone only counts when its value is valid.
+func ScoreCompleteness(c *contact.Contact) *Completeness {
+	result := &Completeness{ContactID: c.ContactID, Missing: []string{}, Invalid: []*InvalidValue{}}
+
+	var hasEmail, hasPhone, hasAddress, hasCompany, hasDetails bool
+	seen := make(map[string]bool)
+	for _, d := range c.Details {
+		if d == nil || !d.IsActive {
+			continue
+		}
+		hasDetails = true
+
+		kind := detailKind(d.Type)
+		reason := invalidReason(kind, d.Value)
+		if reason == "" {
+			key := strings.ToLower(d.Type) + ":" + helper.NormalizeDetailValue(d.Type, d.Value)
+			if seen[key] {
+				reason = "repeats another value"
+			}
+			seen[key] = true
+		}
+		if reason != "" {
+			result.Invalid = append(result.Invalid, &InvalidValue{
+				DetailID: d.ContactDetailsID,
+				Type:     d.Type,
+				Value:    d.Value,
+				Reason:   reason,
+			})
+			continue
+		}
+
+		switch kind {
+		case FieldEmail:
+			hasEmail = true
+		case FieldPhone:
+			hasPhone = true
+		case FieldAddress:
+			hasAddress = true
+		case FieldCompany:
+			hasCompany = true
+		}
+	}
+	if c.OrganizationID != nil {
+		hasCompany = true
+	}
+
+	score := 0
+	firstName, lastName := strings.TrimSpace(c.FName) != "", strings.TrimSpace(c.LName) != ""
+	if firstName && lastName {
+		score += namePoints
+	} else if firstName || lastName {
+		score += namePoints / 2
+	}
+	checks := []struct {
+		field  string
+		ok     bool
+		points int
+	}{
+		{FieldFirstName, firstName, 0},
+		{FieldLastName, lastName, 0},
+		{FieldEmail, hasEmail, emailPoints},
+		{FieldPhone, hasPhone, phonePoints},
+		{FieldAddress, hasAddress, addressPoints},
+		{FieldCompany, hasCompany, companyPoints},
+	}
+	for _, check := range checks {
+		if check.ok {
+			score += check.points
+		} else {
+			result.Missing = append(result.Missing, check.field)
+		}
+	}
+	if hasDetails && len(result.Invalid) == 0 {
+		score += validPoints
+	}
+
+	result.Score = score
+	return result
+}
+
+// applyCompleteness fills the computed completeness score of each contact
+func applyCompleteness(contacts []*contact.Contact) {
+	for _, c := range contacts {
+		c.Completeness = ScoreCompleteness(c).Score
+	}
+}
+
+// belowCompleteness keeps the contacts scoring under threshold
+func belowCompleteness(contacts []*contact.Contact, threshold int) []*contact.Contact {
+	kept := contacts[:0]
+	for _, c := range contacts {
+		if c.Completeness < threshold {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// detailKind maps a detail type onto the completeness field it fills, or ""
+func detailKind(detailType string) string {
+	switch strings.ToLower(strings.TrimSpace(detailType)) {
+	case "email":
+		return FieldEmail
+	case "phone", "mobile", "tel":
+		return FieldPhone
+	case "address", "adr":
+		return FieldAddress
+	case "company", "org", "organization":
+		return FieldCompany
+	}
+	return ""
+}
+
+func invalidReason(kind, value string) string {
+	if strings.TrimSpace(value) == "" {
+		return "empty value"
+	}
+	switch kind {
+	case FieldEmail:
+		if !helper.IsValidEmail(helper.NormalizeEmail(value)) {
+			return "not a valid email address"
+		}
+	case FieldPhone:
+		if !helper.IsValidPhone(value) {
+			return "not a valid phone number"
+		}
+	}
+	return ""
+}
+
+// completenessFilter reads filters["completeness_below"], the score listed
+// contacts must stay under
+func completenessFilter(filters map[string]string) (int, bool, error) {
+	param := strings.TrimSpace(filters["completeness_below"])
+	if param == "" {
+		return 0, false, nil
+	}
+	threshold, err := strconv.Atoi(param)
+	if err != nil || threshold < 1 || threshold > 100 {
+		return 0, false, apperror.NewValidationError("completeness_below", "must be a number between 1 and 100")
+	}
+	return threshold, true, nil
+}
+
+// loadCompletenessColumns adds the columns the score reads to a field
+// selection that lists or filters on completeness
+func loadCompletenessColumns(spec *web.QuerySpec, filtered bool) {
+	if len(spec.Columns) == 0 {
+		return
+	}
+	wanted := filtered
+	for _, field := range spec.Fields {
+		if field == "completeness" {
+			wanted = true
+		}
+	}
+	if !wanted {
+		return
+	}
+
+	loaded := make(map[string]bool, len(spec.Columns))
+	for _, column := range spec.Columns {
+		loaded[column] = true
+	}
+	for _, column := range []string{"contacts.f_name", "contacts.l_name", "contacts.organization_id"} {
+		if !loaded[column] {
+			spec.Columns = append(spec.Columns, column)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_detail"
+	"reflect"
+	"testing"
+)
+
+func TestScoreCompleteness(t *testing.T) {
+	orgID := uint(3)
+	detail := func(detailType, value string) *contact_detail.ContactDetail {
+		return &contact_detail.ContactDetail{Type: detailType, Value: value, IsActive: true}
+	}
+	tests := []struct {
+		name        string
+		contact     *contact.Contact
+		wantScore   int
+		wantMissing []string
+		wantInvalid []string
+	}{
+		{
+			name:        "empty",
+			contact:     &contact.Contact{},
+			wantScore:   0,
+			wantMissing: CompletenessFields,
+			wantInvalid: []string{},
+		},
+		{
+			name:        "first name only",
+			contact:     &contact.Contact{FName: "Ada"},
+			wantScore:   namePoints / 2,
+			wantMissing: []string{FieldLastName, FieldEmail, FieldPhone, FieldAddress, FieldCompany},
+			wantInvalid: []string{},
+		},
+		{
+			name: "complete",
+			contact: &contact.Contact{
+				FName:          "Ada",
+				LName:          "Lovelace",
+				OrganizationID: &orgID,
+				Details: []*contact_detail.ContactDetail{
+					detail("email", "ada@example.com"),
+					detail("mobile", "+442079460000"),
+					detail("address", "12 St James's Square, London"),
+				},
+			},
+			wantScore:   100,
+			wantMissing: []string{},
+			wantInvalid: []string{},
+		},
+		{
+			name: "invalid values do not count",
+			contact: &contact.Contact{
+				FName: "Ada",
+				LName: "Lovelace",
+				Details: []*contact_detail.ContactDetail{
+					detail("email", "not-an-email"),
+					detail("phone", "call me"),
+					detail("company", "Babbage & Co"),
+				},
+			},
+			wantScore:   namePoints + companyPoints,
+			wantMissing: []string{FieldEmail, FieldPhone, FieldAddress},
+			wantInvalid: []string{"not a valid email address", "not a valid phone number"},
+		},
+		{
+			name: "repeated value is invalid",
+			contact: &contact.Contact{
+				FName: "Ada",
+				LName: "Lovelace",
+				Details: []*contact_detail.ContactDetail{
+					detail("email", "ada@example.com"),
+					detail("email", "ADA@example.com"),
+				},
+			},
+			wantScore:   namePoints + emailPoints,
+			wantMissing: []string{FieldPhone, FieldAddress, FieldCompany},
+			wantInvalid: []string{"repeats another value"},
+		},
+		{
+			name: "inactive details are ignored",
+			contact: &contact.Contact{
+				FName: "Ada",
+				LName: "Lovelace",
+				Details: []*contact_detail.ContactDetail{
+					{Type: "email", Value: "bad", IsActive: false},
+				},
+			},
+			wantScore:   namePoints,
+			wantMissing: []string{FieldEmail, FieldPhone, FieldAddress, FieldCompany},
+			wantInvalid: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ScoreCompleteness(tt.contact)
+			if got.Score != tt.wantScore {
+				t.Errorf("Score = %d, want %d", got.Score, tt.wantScore)
+			}
+			if !reflect.DeepEqual(got.Missing, tt.wantMissing) {
+				t.Errorf("Missing = %v, want %v", got.Missing, tt.wantMissing)
+			}
+			reasons := []string{}
+			for _, invalid := range got.Invalid {
+				reasons = append(reasons, invalid.Reason)
+			}
+			if !reflect.DeepEqual(reasons, tt.wantInvalid) {
+				t.Errorf("Invalid reasons = %v, want %v", reasons, tt.wantInvalid)
+			}
+		})
+	}
+}
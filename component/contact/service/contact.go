@@ -2,24 +2,70 @@ package service
 
 import (
 	"Contact_App/apperror"
+	customFieldService "Contact_App/component/custom_field/service"
+	history "Contact_App/component/history/service"
+	interactionService "Contact_App/component/interaction/service"
+	orgService "Contact_App/component/organization/service"
+	photoService "Contact_App/component/photo/service"
+	relationshipService "Contact_App/component/relationship/service"
+	shareService "Contact_App/component/share/service"
+	workspaceService "Contact_App/component/workspace/service"
 	"Contact_App/db"
 	"Contact_App/models/contact"
 	"Contact_App/models/contact_detail"
+	"Contact_App/models/contact_version"
+	"Contact_App/models/group"
 	"Contact_App/repository"
+	"Contact_App/search"
+	"Contact_App/web"
+	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// DetailInput is a type/value pair supplied when creating or updating a
+// contact, optionally with the detail's id in an external system
+type DetailInput struct {
+	Type       string `json:"type"`
+	Value      string `json:"value"`
+	ExternalID string `json:"external_id"`
+	Source     string `json:"source"`
+}
+
+// ContactInput is the body accepted when creating a contact. CustomFields is
+// keyed by custom field key; WorkspaceID files the contact in a workspace the
+// user edits instead of their own address book. ExternalID and Source link the
+// contact to its record in another system for upserts.
+type ContactInput struct {
+	FName          string                 `json:"first_name"`
+	LName          string                 `json:"last_name"`
+	OrganizationID *uint                  `json:"organization_id"`
+	JobTitle       string                 `json:"job_title"`
+	Department     string                 `json:"department"`
+	Details        []DetailInput          `json:"details"`
+	CustomFields   map[string]interface{} `json:"custom_fields"`
+	WorkspaceID    *uint                  `json:"workspace_id"`
+	ExternalID     string                 `json:"external_id"`
+	Source         string                 `json:"source"`
+}
+
 type ContactService struct {
 	contactRepo       repository.Repository
 	contactDetailRepo repository.Repository
+	searchIndex       search.Index
+	photos            *photoService.PhotoService
 }
 
 func NewContactService() *ContactService {
 	return &ContactService{
 		contactRepo:       repository.NewGormRepository(),
 		contactDetailRepo: repository.NewGormRepository(),
+		searchIndex:       search.DefaultIndex,
+		photos:            photoService.NewPhotoService(),
 	}
 }
 
@@ -41,45 +87,325 @@ func (s *ContactService) CreateContact(userID uint, fname, lname string) (*conta
 		IsActive: true,
 	}
 
-	uow := repository.NewUnitOfWork(db.GetDB(), false)
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
 	defer uow.Rollback()
 
 	if err := s.contactRepo.Add(uow, newContact); err != nil {
 		return nil, err
 	}
+	if err := history.RecordVersion(uow, userID, newContact.ContactID, userID, contact_version.ActionCreate); err != nil {
+		return nil, err
+	}
+	search.InvalidateOnCommit(uow, userID)
 
 	uow.Commit()
 	return newContact, nil
 }
 
-// GetContactsWithDetails retrieves all contacts belonging to the given user
-func (s *ContactService) GetContactsWithDetails(userID uint, filters map[string]string) ([]*contact.Contact, error) {
-	var contacts []*contact.Contact
-	uow := repository.NewUnitOfWork(db.GetDB(), true)
+// CreateContactWithDetails creates a contact and its details in one transaction
+func (s *ContactService) CreateContactWithDetails(userID uint, input ContactInput) (*contact.Contact, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
 
-	query := uow.DB.Preload("Details").Where("user_id = ? AND is_active = ?", userID, true)
+	newContact, err := s.CreateContactWithDetailsUOW(uow, userID, input)
+	if err != nil {
+		return nil, err
+	}
 
-	if name := strings.TrimSpace(filters["f_name"]); name != "" {
-		query = query.Where("f_name LIKE ? OR l_name LIKE ?", "%"+name+"%", "%"+name+"%")
+	uow.Commit()
+	return newContact, nil
+}
+
+// CreateContactWithDetailsUOW creates a contact and its details using the provided transaction
+func (s *ContactService) CreateContactWithDetailsUOW(uow *repository.UnitOfWork, userID uint, input ContactInput) (*contact.Contact, error) {
+	fname := strings.TrimSpace(input.FName)
+	lname := strings.TrimSpace(input.LName)
+	if fname == "" || lname == "" {
+		return nil, apperror.NewValidationError("name", "first_name and last_name required")
 	}
-	if phone := strings.TrimSpace(filters["phone"]); phone != "" {
-		query = query.Joins("JOIN contact_details ON contacts.contact_id = contact_details.contact_id").
-			Where("contacts.user_id = ? AND contact_details.type = 'phone' AND contact_details.value LIKE ?", userID, "%"+phone+"%")
+
+	jobTitle, err := optionalText("job_title", input.JobTitle)
+	if err != nil {
+		return nil, err
+	}
+	department, err := optionalText("department", input.Department)
+	if err != nil {
+		return nil, err
+	}
+	if input.OrganizationID != nil {
+		if err := orgService.EnsureOrganization(uow, userID, *input.OrganizationID); err != nil {
+			return nil, err
+		}
+	}
+
+	externalID, source, err := externalLink(input.ExternalID, input.Source)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureWorkspaceWrite(uow, userID, input.WorkspaceID); err != nil {
+		return nil, err
+	}
+
+	newContact := &contact.Contact{
+		UserID:         userID,
+		FName:          fname,
+		LName:          lname,
+		IsActive:       true,
+		OrganizationID: input.OrganizationID,
+		JobTitle:       jobTitle,
+		Department:     department,
+		WorkspaceID:    input.WorkspaceID,
+		ExternalID:     externalID,
+		Source:         source,
+	}
+	if err := s.contactRepo.Add(uow, newContact); err != nil {
+		return nil, err
+	}
+	search.InvalidateOnCommit(uow, userID)
+
+	for _, d := range input.Details {
+		if d.Type == "" || d.Value == "" {
+			continue
+		}
+		if err := s.saveDetail(uow, userID, newContact.ContactID, d); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := customFieldService.SetValuesWithUOW(uow, userID, newContact.ContactID, input.CustomFields); err != nil {
+		return nil, err
+	}
+
+	if err := history.RecordVersion(uow, userID, newContact.ContactID, userID, contact_version.ActionCreate); err != nil {
+		return nil, err
+	}
+	return newContact, nil
+}
+
+// GetContactsWithDetails retrieves all contacts belonging to the given user.
+// When filters["q"] is set the contacts carry the matched highlights and,
+// unless filters["sort"] asks otherwise, are ranked by search relevance.
+func (s *ContactService) GetContactsWithDetails(userID uint, filters map[string]string, processors ...repository.QueryProcessor) ([]*contact.Contact, error) {
+	var contacts []*contact.Contact
+	threshold, below, err := completenessFilter(filters)
+	if err != nil {
+		return nil, err
+	}
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	query, hits, err := s.contactsQuery(uow, userID, filters, processors...)
+	if err != nil {
+		return nil, err
+	}
+	if hits != nil && len(hits) == 0 {
+		return contacts, nil
 	}
 
 	if err := query.Find(&contacts).Error; err != nil {
 		return nil, err
 	}
+	if err := applyComputedFields(uow, userID, contacts); err != nil {
+		return nil, err
+	}
+	if below {
+		contacts = belowCompleteness(contacts, threshold)
+	}
+
+	if hits != nil {
+		applySearchHits(contacts, hits)
+		if strings.TrimSpace(filters["sort"]) == "" {
+			sortByRelevance(contacts)
+		}
+	}
 	return contacts, nil
 }
 
-// GetContactByIDWithDetails retrieves a single contact by ID ensuring ownership
+// GetContactsPage returns one cursor page of the user's contacts. Search
+// results without an explicit sort are paged in relevance order.
+func (s *ContactService) GetContactsPage(r *http.Request, userID uint, filters map[string]string, spec *web.QuerySpec) (*web.Page, error) {
+	contacts := []*contact.Contact{}
+	threshold, below, err := completenessFilter(filters)
+	if err != nil {
+		return nil, err
+	}
+	loadCompletenessColumns(spec, below)
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	query, hits, err := s.contactsQuery(uow, userID, filters, spec.FilterProcessors()...)
+	if err != nil {
+		return nil, err
+	}
+	if hits != nil && len(hits) == 0 {
+		return web.PaginateSlice(r, contacts, relevanceOrder)
+	}
+
+	if hits != nil && !spec.HasSort() {
+		if err := query.Find(&contacts).Error; err != nil {
+			return nil, err
+		}
+		if err := applyComputedFields(uow, userID, contacts); err != nil {
+			return nil, err
+		}
+		if below {
+			contacts = belowCompleteness(contacts, threshold)
+		}
+		applySearchHits(contacts, hits)
+		sortByRelevance(contacts)
+		return web.PaginateSlice(r, contacts, relevanceOrder)
+	}
+
+	// favorites and custom field values live outside the contacts table, so
+	// pages sorted by them are ordered in memory; so are pages filtered on
+	// completeness, which is only known once the contacts are loaded
+	if sortsInMemory(spec) || below {
+		if err := query.Find(&contacts).Error; err != nil {
+			return nil, err
+		}
+		if err := applyComputedFields(uow, userID, contacts); err != nil {
+			return nil, err
+		}
+		if below {
+			contacts = belowCompleteness(contacts, threshold)
+		}
+		applySearchHits(contacts, hits)
+		order := spec.KeysetOrder()
+		if err := web.SortSlice(contacts, order); err != nil {
+			return nil, err
+		}
+		return web.PaginateSlice(r, contacts, order)
+	}
+
+	page, err := web.PaginateQuery(r, query, &contacts, spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyComputedFields(uow, userID, contacts); err != nil {
+		return nil, err
+	}
+	applySearchHits(contacts, hits)
+	return page, nil
+}
+
+// ListingResource extends base with the fields that depend on the user:
+// is_favorite and a cf.<key> field for each of their custom fields, so
+// listings can filter and sort on them
+func (s *ContactService) ListingResource(userID uint, base web.ResourceSpec) (web.ResourceSpec, error) {
+	fields, err := customFieldService.LoadDefinitions(db.GetDB(), userID)
+	if err != nil {
+		return base, err
+	}
+
+	resource := web.ResourceSpec{PrimaryKey: base.PrimaryKey, Fields: make(map[string]web.FieldSpec, len(base.Fields)+len(fields)+1)}
+	for name, field := range base.Fields {
+		resource.Fields[name] = field
+	}
+	resource.Fields["is_favorite"] = favoriteField(userID)
+	for name, field := range customFieldService.QueryFields(fields) {
+		resource.Fields[name] = field
+	}
+	return resource, nil
+}
+
+// sortsInMemory reports whether the spec sorts on a field that is filled in
+// after the query, which keyset pagination cannot build cursors from
+func sortsInMemory(spec *web.QuerySpec) bool {
+	for _, s := range spec.Sort {
+		if s.JSONName == "is_favorite" || strings.HasPrefix(s.JSONName, "custom_fields.") {
+			return true
+		}
+	}
+	return false
+}
+
+// contactsQuery builds the listing query shared by the paged and unpaged
+// listings. hits is nil unless filters["q"] is set.
+func (s *ContactService) contactsQuery(uow *repository.UnitOfWork, userID uint, filters map[string]string, processors ...repository.QueryProcessor) (*gorm.DB, map[uint]search.Hit, error) {
+	query := uow.DB.Model(&contact.Contact{}).Preload("Details").
+		Where(shareService.AccessibleContacts(uow.DB, userID)).
+		Where("contacts.is_active = ?", true)
+
+	if name := strings.TrimSpace(filters["f_name"]); name != "" {
+		query = query.Where("contacts.f_name LIKE ? OR contacts.l_name LIKE ?", "%"+name+"%", "%"+name+"%")
+	}
+	if lname := strings.TrimSpace(filters["l_name"]); lname != "" {
+		query = query.Where("contacts.l_name LIKE ?", "%"+lname+"%")
+	}
+	if phone := strings.TrimSpace(filters["phone"]); phone != "" {
+		// the outer query already limits the contacts to those the user can see
+		phones := uow.DB.Model(&contact_detail.ContactDetail{}).Select("contact_id").
+			Where("type = 'phone' AND value LIKE ?", "%"+phone+"%")
+		query = query.Where("contacts.contact_id IN (?)", phones)
+	}
+	if groupParam := strings.TrimSpace(filters["group"]); groupParam != "" {
+		groupID, err := strconv.ParseUint(groupParam, 10, 64)
+		if err != nil {
+			return nil, nil, apperror.NewValidationError("group", "must be a group ID")
+		}
+		members := uow.DB.Model(&group.GroupContact{}).Select("contact_id").
+			Where("group_id = ?", groupID).
+			Where("user_id = ? OR group_id IN (?)", userID, shareService.SharedGroupIDs(uow.DB, userID))
+		query = query.Where("contacts.contact_id IN (?)", members)
+	}
+	if daysParam := strings.TrimSpace(filters["not_contacted_days"]); daysParam != "" {
+		days, err := strconv.Atoi(daysParam)
+		if err != nil || days <= 0 {
+			return nil, nil, apperror.NewValidationError("not_contacted_days", "must be a positive number")
+		}
+		contacted := interactionService.ContactedSince(uow.DB, time.Now().AddDate(0, 0, -days))
+		query = query.Where("contacts.contact_id NOT IN (?)", contacted)
+	}
+
+	var hits map[uint]search.Hit
+	if q := strings.TrimSpace(filters["q"]); q != "" {
+		var err error
+		hits, err = s.searchContacts(uow, userID, q)
+		if err != nil {
+			return nil, nil, err
+		}
+		ids := make([]uint, 0, len(hits))
+		for id := range hits {
+			ids = append(ids, id)
+		}
+		if len(ids) > 0 {
+			query = query.Where("contacts.contact_id IN ?", ids)
+		}
+	}
+
+	for _, process := range processors {
+		var err error
+		if query, err = process(query, &contact.Contact{}); err != nil {
+			return nil, nil, apperror.NewValidationError("query", err.Error())
+		}
+	}
+	return query, hits, nil
+}
+
+// GetContactByIDWithDetails retrieves a single contact the user owns or has been shared
 func (s *ContactService) GetContactByIDWithDetails(userID, contactID uint) (*contact.Contact, error) {
 	var c contact.Contact
-	uow := repository.NewUnitOfWork(db.GetDB(), true)
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
 
-	err := uow.DB.Preload("Details").
-		Where("contact_id = ? AND user_id = ? AND is_active = ?", contactID, userID, true).
+	resolved, err := shareService.ResolveContact(uow.DB, userID, contactID, false)
+	if err != nil {
+		return nil, err
+	}
+	err = uow.DB.Preload("Details").Preload("Organization").
+		Where("contact_id = ? AND user_id = ? AND is_active = ?", contactID, resolved.UserID, true).
 		First(&c).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -87,51 +413,236 @@ func (s *ContactService) GetContactByIDWithDetails(userID, contactID uint) (*con
 		}
 		return nil, err
 	}
+	if err := applyComputedFields(uow, userID, []*contact.Contact{&c}); err != nil {
+		return nil, err
+	}
 	return &c, nil
 }
 
+// optionalText trims a free-text contact field that may be left empty
+func optionalText(field, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if len([]rune(value)) > 255 {
+		return "", apperror.NewValidationError(field, "must be at most 255 characters")
+	}
+	return value, nil
+}
+
+// externalLink validates an external id and the source it belongs to. A
+// source without an external id links nothing and is dropped.
+func externalLink(externalID, source string) (string, string, error) {
+	externalID = strings.TrimSpace(externalID)
+	source = strings.ToLower(strings.TrimSpace(source))
+	if externalID == "" {
+		return "", "", nil
+	}
+	if len(externalID) > 255 {
+		return "", "", apperror.NewValidationError("external_id", "must be at most 255 characters")
+	}
+	if len(source) > 100 {
+		return "", "", apperror.NewValidationError("source", "must be at most 100 characters")
+	}
+	return externalID, source, nil
+}
+
+// ensureWorkspaceWrite checks that the user may add contacts to the
+// workspace; a nil workspace is the user's own address book
+func ensureWorkspaceWrite(uow *repository.UnitOfWork, userID uint, workspaceID *uint) error {
+	if workspaceID == nil {
+		return nil
+	}
+	role, err := workspaceService.Role(uow.DB, userID, *workspaceID)
+	if err != nil {
+		return err
+	}
+	if role == "" {
+		return apperror.NewNotFoundError("workspace", int(*workspaceID))
+	}
+	if !workspaceService.CanWrite(role) {
+		return apperror.NewForbiddenError("workspace", "your workspace role only allows reading contacts")
+	}
+	return nil
+}
+
+// organizationIDValue reads organization_id from a decoded JSON body; null unlinks
+func organizationIDValue(v interface{}) (*uint, error) {
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		if val >= 1 && val == float64(uint(val)) {
+			id := uint(val)
+			return &id, nil
+		}
+	case string:
+		if id, err := strconv.ParseUint(strings.TrimSpace(val), 10, 64); err == nil && id > 0 {
+			orgID := uint(id)
+			return &orgID, nil
+		}
+	}
+	return nil, apperror.NewValidationError("organization_id", "must be an organization ID or null")
+}
+
+// applyComputedFields fills the response-only fields that live outside the
+// contacts table. Shared contacts take them from their owner's data and are
+// marked with shared_by for the viewer.
+func applyComputedFields(uow *repository.UnitOfWork, viewerID uint, contacts []*contact.Contact) error {
+	byOwner := make(map[uint][]*contact.Contact)
+	for _, c := range contacts {
+		byOwner[c.UserID] = append(byOwner[c.UserID], c)
+	}
+	for ownerID, owned := range byOwner {
+		if err := applyLastContacted(uow, ownerID, owned); err != nil {
+			return err
+		}
+		if err := customFieldService.ApplyValues(uow.DB, ownerID, owned); err != nil {
+			return err
+		}
+	}
+	if err := applyRelationships(uow, viewerID, contacts); err != nil {
+		return err
+	}
+	applyCompleteness(contacts)
+	if err := applyFavorites(uow, viewerID, contacts); err != nil {
+		return err
+	}
+	return shareService.ApplySharedBy(uow.DB, viewerID, contacts)
+}
+
+// ContactETag is the entity tag of a contact as the viewer it was loaded for
+// sees it: its version plus the computed fields that change without one, such
+// as the viewer's star and when the contact was last contacted
+func ContactETag(c *contact.Contact) string {
+	return web.ViewETag(c.Version, []interface{}{
+		c.IsFavorite, c.LastContacted, c.Relationships, c.CustomFields, c.SharedBy, c.Completeness,
+	})
+}
+
+// applyRelationships attaches each contact's relationships as it sees them,
+// leaving out related contacts the viewer cannot see
+func applyRelationships(uow *repository.UnitOfWork, viewerID uint, contacts []*contact.Contact) error {
+	ids := make([]uint, len(contacts))
+	for i, c := range contacts {
+		ids[i] = c.ContactID
+	}
+	related, err := relationshipService.LoadRelated(uow.DB, viewerID, ids)
+	if err != nil {
+		return err
+	}
+	for _, c := range contacts {
+		c.Relationships = related[c.ContactID]
+	}
+	return nil
+}
+
+// applyLastContacted fills the computed last_contacted field from the interaction log
+func applyLastContacted(uow *repository.UnitOfWork, userID uint, contacts []*contact.Contact) error {
+	ids := make([]uint, len(contacts))
+	for i, c := range contacts {
+		ids[i] = c.ContactID
+	}
+	last, err := interactionService.LastContacted(uow.DB, userID, ids)
+	if err != nil {
+		return err
+	}
+	for _, c := range contacts {
+		if t, ok := last[c.ContactID]; ok {
+			c.LastContacted = &t
+		}
+	}
+	return nil
+}
+
 // AddOrUpdateContactDetail adds or updates a contact detail using the provided transaction
 func (s *ContactService) AddOrUpdateContactDetail(uow *repository.UnitOfWork, userID, contactID uint, detailType, value string) error {
-	detailType = strings.ToLower(strings.TrimSpace(detailType))
-	value = strings.TrimSpace(value)
+	return s.saveDetail(uow, userID, contactID, DetailInput{Type: detailType, Value: value})
+}
+
+// saveDetail adds a detail or updates the one it replaces: the detail with
+// the same external id when one is given, otherwise the first of its type
+func (s *ContactService) saveDetail(uow *repository.UnitOfWork, userID, contactID uint, input DetailInput) error {
+	detailType := strings.ToLower(strings.TrimSpace(input.Type))
+	value := strings.TrimSpace(input.Value)
 	if detailType == "" || value == "" {
 		return apperror.NewValidationError("detail", "type and value cannot be empty")
 	}
+	externalID, source, err := externalLink(input.ExternalID, input.Source)
+	if err != nil {
+		return err
+	}
 
+	query := uow.DB.Where("contact_id = ? AND type = ?", contactID, detailType)
+	if externalID != "" {
+		query = uow.DB.Where("contact_id = ? AND source = ? AND external_id = ?", contactID, source, externalID)
+	}
 	var detail contact_detail.ContactDetail
-	err := uow.DB.Where("contact_id = ? AND type = ?", contactID, detailType).First(&detail).Error
+	err = query.First(&detail).Error
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return err
 	}
 
 	if err == gorm.ErrRecordNotFound {
 		newDetail := &contact_detail.ContactDetail{
-			ContactID: contactID,
-			UserID:    userID,
-			Type:      detailType,
-			Value:     value,
-			IsActive:  true,
+			ContactID:  contactID,
+			UserID:     userID,
+			Type:       detailType,
+			Value:      value,
+			IsActive:   true,
+			ExternalID: externalID,
+			Source:     source,
 		}
 		if err := s.contactDetailRepo.Add(uow, newDetail); err != nil {
 			return err
 		}
 	} else {
+		detail.Type = detailType
 		detail.Value = value
+		if externalID != "" {
+			detail.ExternalID = externalID
+			detail.Source = source
+		}
+		detail.Version++
 		if err := s.contactDetailRepo.Update(uow, &detail); err != nil {
 			return err
 		}
 	}
-
-	return nil
+	return search.InvalidateContactsOnCommit(uow, contactID)
 }
 
-func (s *ContactService) UpdateContactByID(userID, contactID uint, updates map[string]interface{}) error {
-	uow := repository.NewUnitOfWork(db.GetDB(), false)
+// UpdateContactByID applies updates to a contact the user owns, edits through
+// a workspace or holds a write share on. A non-zero ifMatch must equal the contact's current version
+// or the update is rejected.
+func (s *ContactService) UpdateContactByID(userID, contactID uint, updates map[string]interface{}, ifMatch uint) (uint, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return 0, err
+	}
 	defer uow.Rollback()
 
-	c, err := s.GetContactByIDWithDetails(userID, contactID)
+	version, err := s.UpdateContactWithUOW(uow, userID, contactID, updates, ifMatch)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	uow.Commit()
+	return version, nil
+}
+
+// UpdateContactWithUOW is UpdateContactByID using the provided transaction,
+// with the same access checks; it returns the contact's new version
+func (s *ContactService) UpdateContactWithUOW(uow *repository.UnitOfWork, userID, contactID uint, updates map[string]interface{}, ifMatch uint) (uint, error) {
+	resolved, err := shareService.ResolveContact(uow.DB, userID, contactID, true)
+	if err != nil {
+		return 0, err
+	}
+	return s.updateContact(uow, resolved.UserID, userID, contactID, updates, ifMatch)
+}
+
+// updateContact applies updates to a contact of ownerID on behalf of actorID
+func (s *ContactService) updateContact(uow *repository.UnitOfWork, ownerID, actorID, contactID uint, updates map[string]interface{}, ifMatch uint) (uint, error) {
+	c, err := lockContact(uow, ownerID, contactID, ifMatch)
+	if err != nil {
+		return 0, err
 	}
 
 	updateMap := make(map[string]interface{})
@@ -140,7 +651,7 @@ func (s *ContactService) UpdateContactByID(userID, contactID uint, updates map[s
 		strVal, _ := v.(string)
 		strVal = strings.TrimSpace(strVal)
 		if strVal == "" {
-			return apperror.NewValidationError("first_name", "cannot be empty")
+			return 0, apperror.NewValidationError("first_name", "cannot be empty")
 		}
 		updateMap["f_name"] = strVal
 	}
@@ -149,7 +660,7 @@ func (s *ContactService) UpdateContactByID(userID, contactID uint, updates map[s
 		strVal, _ := v.(string)
 		strVal = strings.TrimSpace(strVal)
 		if strVal == "" {
-			return apperror.NewValidationError("last_name", "cannot be empty")
+			return 0, apperror.NewValidationError("last_name", "cannot be empty")
 		}
 		updateMap["l_name"] = strVal
 	}
@@ -165,16 +676,85 @@ func (s *ContactService) UpdateContactByID(userID, contactID uint, updates map[s
 			} else if lower == "false" {
 				updateMap["is_active"] = false
 			} else {
-				return apperror.NewValidationError("is_active", "must be a boolean")
+				return 0, apperror.NewValidationError("is_active", "must be a boolean")
 			}
 		default:
-			return apperror.NewValidationError("is_active", "must be a boolean")
+			return 0, apperror.NewValidationError("is_active", "must be a boolean")
+		}
+	}
+
+	for _, field := range []string{"job_title", "department"} {
+		v, ok := updates[field]
+		if !ok {
+			continue
+		}
+		var strVal string
+		if v != nil {
+			if strVal, ok = v.(string); !ok {
+				return 0, apperror.NewValidationError(field, "must be a string")
+			}
+		}
+		text, err := optionalText(field, strVal)
+		if err != nil {
+			return 0, err
+		}
+		updateMap[field] = text
+	}
+
+	_, hasExternalID := updates["external_id"]
+	_, hasSource := updates["source"]
+	if hasExternalID || hasSource {
+		externalID, source := c.ExternalID, c.Source
+		if v, ok := updates["external_id"]; ok && v != nil {
+			if externalID, ok = v.(string); !ok {
+				return 0, apperror.NewValidationError("external_id", "must be a string")
+			}
+		} else if ok {
+			externalID = ""
+		}
+		if v, ok := updates["source"]; ok && v != nil {
+			if source, ok = v.(string); !ok {
+				return 0, apperror.NewValidationError("source", "must be a string")
+			}
+		} else if ok {
+			source = ""
+		}
+		if externalID, source, err = externalLink(externalID, source); err != nil {
+			return 0, err
+		}
+		updateMap["external_id"] = externalID
+		updateMap["source"] = source
+	}
+
+	if v, ok := updates["organization_id"]; ok {
+		orgID, err := organizationIDValue(v)
+		if err != nil {
+			return 0, err
+		}
+		if orgID == nil {
+			updateMap["organization_id"] = nil
+		} else {
+			if err := orgService.EnsureOrganization(uow, ownerID, *orgID); err != nil {
+				return 0, err
+			}
+			updateMap["organization_id"] = *orgID
 		}
 	}
 
 	if len(updateMap) > 0 {
-		if err := s.contactRepo.UpdateWithMap(uow, c, updateMap, repository.Filter("contact_id = ? AND user_id = ?", contactID, userID)); err != nil {
-			return err
+		if err := s.contactRepo.UpdateWithMap(uow, c, updateMap, repository.Filter("contact_id = ? AND user_id = ?", contactID, ownerID)); err != nil {
+			return 0, err
+		}
+	}
+	search.InvalidateOnCommit(uow, ownerID)
+
+	if v, ok := updates["custom_fields"]; ok {
+		values, ok := v.(map[string]interface{})
+		if !ok {
+			return 0, apperror.NewValidationError("custom_fields", "must be an object keyed by custom field key")
+		}
+		if _, err := customFieldService.SetValuesWithUOW(uow, ownerID, contactID, values); err != nil {
+			return 0, err
 		}
 	}
 
@@ -184,11 +764,14 @@ func (s *ContactService) UpdateContactByID(userID, contactID uint, updates map[s
 				if detailMap, ok3 := d.(map[string]interface{}); ok3 {
 					dType, _ := detailMap["type"].(string)
 					dVal, _ := detailMap["value"].(string)
+					dExternalID, _ := detailMap["external_id"].(string)
+					dSource, _ := detailMap["source"].(string)
 					dType = strings.TrimSpace(dType)
 					dVal = strings.TrimSpace(dVal)
 					if dType != "" && dVal != "" {
-						if err := s.AddOrUpdateContactDetail(uow, userID, contactID, dType, dVal); err != nil {
-							return err
+						detail := DetailInput{Type: dType, Value: dVal, ExternalID: dExternalID, Source: dSource}
+						if err := s.saveDetail(uow, ownerID, contactID, detail); err != nil {
+							return 0, err
 						}
 					}
 				}
@@ -196,44 +779,119 @@ func (s *ContactService) UpdateContactByID(userID, contactID uint, updates map[s
 		}
 	}
 
+	if err := history.BumpVersions(uow, contactID); err != nil {
+		return 0, err
+	}
+	if err := history.RecordVersion(uow, ownerID, contactID, actorID, contact_version.ActionUpdate); err != nil {
+		return 0, err
+	}
+	return c.Version + 1, nil
+}
+
+// DeleteContactByID soft deletes a contact and its details, honouring ifMatch
+// like UpdateContactByID. Shared contacts can only be deleted by their owner;
+// workspace contacts by any editor of the workspace.
+func (s *ContactService) DeleteContactByID(userID, contactID, ifMatch uint) error {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return err
+	}
+	defer uow.Rollback()
+
+	if err := s.DeleteContactWithUOW(uow, userID, contactID, ifMatch); err != nil {
+		return err
+	}
+
 	uow.Commit()
 	return nil
 }
 
-func (s *ContactService) DeleteContactByID(userID, contactID uint) error {
-	uow := repository.NewUnitOfWork(db.GetDB(), false)
-	defer uow.Rollback()
+// DeleteContactWithUOW is DeleteContactByID using the provided transaction,
+// with the same access checks
+func (s *ContactService) DeleteContactWithUOW(uow *repository.UnitOfWork, userID, contactID, ifMatch uint) error {
+	resolved, err := shareService.ResolveContact(uow.DB, userID, contactID, true)
+	if err != nil {
+		return err
+	}
+	if resolved.WorkspaceID == nil && resolved.UserID != userID {
+		return apperror.NewForbiddenError("contact", "only the owner can delete a shared contact")
+	}
+	return s.deleteContact(uow, resolved.UserID, userID, contactID, ifMatch)
+}
+
+// deleteContact soft deletes a contact of ownerID on behalf of actorID
+func (s *ContactService) deleteContact(uow *repository.UnitOfWork, ownerID, actorID, contactID, ifMatch uint) error {
+	if _, err := lockContact(uow, ownerID, contactID, ifMatch); err != nil {
+		return err
+	}
+
+	// the contact and the details deleted with it share a batch, which is
+	// what RestoreContactByID brings back
+	batch, err := newDeleteBatch()
+	if err != nil {
+		return err
+	}
 
 	// Step 1: set is_active=false for contact
 	if err := uow.DB.Model(&contact.Contact{}).
-		Where("contact_id = ? AND user_id = ?", contactID, userID).
-		Update("is_active", false).Error; err != nil {
+		Where("contact_id = ? AND user_id = ?", contactID, ownerID).
+		Updates(map[string]interface{}{"is_active": false, "delete_batch": batch}).Error; err != nil {
 		return apperror.NewInternalError("failed to set contact inactive")
 	}
 
 	// Step 2: soft delete contact (gorm sets deleted_at)
-	if err := uow.DB.Where("contact_id = ? AND user_id = ?", contactID, userID).
+	if err := uow.DB.Where("contact_id = ? AND user_id = ?", contactID, ownerID).
 		Delete(&contact.Contact{}).Error; err != nil {
 		return apperror.NewInternalError("failed to soft delete contact")
 	}
 
-	// Step 3: set is_active=false for related contact_details
+	// Step 3: set is_active=false for related contact_details; details
+	// deleted earlier on their own keep their own batch
 	if err := uow.DB.Model(&contact_detail.ContactDetail{}).
-		Where("contact_id = ? AND user_id = ?", contactID, userID).
-		Update("is_active", false).Error; err != nil {
+		Where("contact_id = ? AND user_id = ?", contactID, ownerID).
+		Updates(map[string]interface{}{"is_active": false, "delete_batch": batch}).Error; err != nil {
 		return apperror.NewInternalError("failed to set details inactive")
 	}
 
 	// Step 4: soft delete related details
-	if err := uow.DB.Where("contact_id = ? AND user_id = ?", contactID, userID).
+	if err := uow.DB.Where("contact_id = ? AND user_id = ?", contactID, ownerID).
 		Delete(&contact_detail.ContactDetail{}).Error; err != nil {
 		return apperror.NewInternalError("failed to soft delete details")
 	}
 
-	uow.Commit()
+	// Step 5: drop relationships on either side
+	if err := relationshipService.DeleteForContactsWithUOW(uow, []uint{contactID}); err != nil {
+		return err
+	}
+
+	if err := history.BumpVersions(uow, contactID); err != nil {
+		return err
+	}
+	if err := history.RecordVersion(uow, ownerID, contactID, actorID, contact_version.ActionDelete); err != nil {
+		return err
+	}
+	search.InvalidateOnCommit(uow, ownerID)
 	return nil
 }
 
+// lockContact loads a live contact FOR UPDATE and checks it against the
+// version the client last saw, so concurrent writers cannot both pass
+func lockContact(uow *repository.UnitOfWork, userID, contactID, ifMatch uint) (*contact.Contact, error) {
+	var c contact.Contact
+	if err := uow.DB.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("contact_id = ? AND user_id = ?", contactID, userID).
+		First(&c).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewNotFoundError("contact", int(contactID))
+		}
+		return nil, apperror.NewInternalError("failed to load contact")
+	}
+	if ifMatch != 0 && c.Version != ifMatch {
+		return nil, apperror.NewPreconditionFailedError("contact", c.Version)
+	}
+	return &c, nil
+}
+
 func (s *ContactService) CreateContactWithUOW(uow *repository.UnitOfWork, userID uint, fname, lname string) (*contact.Contact, error) {
 	newContact := &contact.Contact{
 		UserID:   userID,
@@ -244,5 +902,6 @@ func (s *ContactService) CreateContactWithUOW(uow *repository.UnitOfWork, userID
 	if err := s.contactRepo.Add(uow, newContact); err != nil {
 		return nil, err
 	}
+	search.InvalidateOnCommit(uow, userID)
 	return newContact, nil
 }
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	shareService "Contact_App/component/share/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_favorite"
+	"Contact_App/repository"
+	"Contact_App/web"
+	"fmt"
+)
+
+// StarContact adds a contact the user can see to their favorites. Starring a
+// favorite again is a no-op.
+func (s *ContactService) StarContact(userID, contactID uint) error {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return err
+	}
+	defer uow.Rollback()
+
+	if _, err := shareService.ResolveContact(uow.DB, userID, contactID, false); err != nil {
+		return err
+	}
+
+	var count int64
+	if err := uow.DB.Model(&contact_favorite.ContactFavorite{}).
+		Where("user_id = ? AND contact_id = ?", userID, contactID).
+		Count(&count).Error; err != nil {
+		return apperror.NewInternalError("failed to load favorites")
+	}
+	if count == 0 {
+		if err := s.contactRepo.Add(uow, &contact_favorite.ContactFavorite{UserID: userID, ContactID: contactID}); err != nil {
+			return err
+		}
+	}
+
+	uow.Commit()
+	return nil
+}
+
+// UnstarContact removes a contact from the user's favorites
+func (s *ContactService) UnstarContact(userID, contactID uint) error {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return err
+	}
+	defer uow.Rollback()
+
+	if _, err := shareService.ResolveContact(uow.DB, userID, contactID, false); err != nil {
+		return err
+	}
+	if err := uow.DB.Where("user_id = ? AND contact_id = ?", userID, contactID).
+		Delete(&contact_favorite.ContactFavorite{}).Error; err != nil {
+		return apperror.NewInternalError("failed to remove favorite")
+	}
+
+	uow.Commit()
+	return nil
+}
+
+// applyFavorites sets is_favorite on the contacts the viewer has starred
+func applyFavorites(uow *repository.UnitOfWork, viewerID uint, contacts []*contact.Contact) error {
+	if len(contacts) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(contacts))
+	for i, c := range contacts {
+		ids[i] = c.ContactID
+	}
+
+	var starred []uint
+	if err := uow.DB.Model(&contact_favorite.ContactFavorite{}).
+		Where("user_id = ? AND contact_id IN ?", viewerID, ids).
+		Pluck("contact_id", &starred).Error; err != nil {
+		return apperror.NewInternalError("failed to load favorites")
+	}
+	favorites := make(map[uint]bool, len(starred))
+	for _, id := range starred {
+		favorites[id] = true
+	}
+	for _, c := range contacts {
+		c.IsFavorite = favorites[c.ContactID]
+	}
+	return nil
+}
+
+// favoriteField exposes is_favorite to the listing query spec, so
+// is_favorite[eq]=true lists favorites alone and sort=-is_favorite lists
+// them first
+func favoriteField(userID uint) web.FieldSpec {
+	return web.FieldSpec{
+		JSONName: "is_favorite",
+		Column:   fmt.Sprintf("(contacts.contact_id IN (SELECT contact_id FROM contact_favorites WHERE user_id = %d))", userID),
+		Type:     web.FieldBool,
+	}
+}
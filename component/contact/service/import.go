@@ -0,0 +1,256 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	customFieldService "Contact_App/component/custom_field/service"
+	history "Contact_App/component/history/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/export"
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_detail"
+	"Contact_App/models/contact_version"
+	"Contact_App/repository"
+	"Contact_App/search"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MaxImportBytes caps the size of an uploaded vCard file (IMPORT_MAX_BYTES, default 10 MiB)
+func MaxImportBytes() int64 {
+	if n, err := strconv.ParseInt(os.Getenv("IMPORT_MAX_BYTES"), 10, 64); err == nil && n > 0 {
+		return n
+	}
+	return 10 << 20
+}
+
+// DefaultImportSource is the source imported card UIDs are recorded under
+// unless the import names another
+const DefaultImportSource = "vcard"
+
+// ImportOptions controls how imported cards meet the contacts already in the
+// address book. Without a Policy every card creates a contact; with one,
+// cards are upserted. Card UIDs become external ids within Source.
+type ImportOptions struct {
+	Policy string
+	Source string
+}
+
+// ImportResult tells what an import did with each card, in file order
+type ImportResult struct {
+	Imported  int                `json:"imported"`
+	Created   int                `json:"created"`
+	Updated   int                `json:"updated"`
+	Unchanged int                `json:"unchanged"`
+	Contacts  []*contact.Contact `json:"contacts"`
+	Outcomes  []*CardOutcome     `json:"outcomes"`
+}
+
+// CardOutcome is what an import did with one card
+type CardOutcome struct {
+	ContactID uint   `json:"contact_id"`
+	Outcome   string `json:"outcome"`
+	MatchedBy string `json:"matched_by,omitempty"`
+}
+
+// ImportVCards stores one contact per vCard in r, including its details,
+// custom fields and inline PHOTO, in a single transaction. With a conflict
+// policy a card matching an existing contact updates it instead, see
+// UpsertContact. A card that cannot be imported rejects the whole file.
+// X-CUSTOM- properties for fields the user has not defined are ignored.
+func (s *ContactService) ImportVCards(userID uint, r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	if opts.Policy != "" && !IsConflictPolicy(opts.Policy) {
+		return nil, apperror.NewValidationError("on_conflict", "must be keep, overwrite or merge")
+	}
+	source := strings.ToLower(strings.TrimSpace(opts.Source))
+	if source == "" {
+		source = DefaultImportSource
+	}
+	if len(source) > 100 {
+		return nil, apperror.NewValidationError("source", "must be at most 100 characters")
+	}
+
+	cards, err := export.ParseVCards(r)
+	if err != nil {
+		return nil, apperror.NewValidationError("vcard", err.Error())
+	}
+	if len(cards) == 0 {
+		return nil, apperror.NewValidationError("vcard", "no vCards found")
+	}
+
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	defined, err := definedCustomFields(uow, userID)
+	if err != nil {
+		return nil, err
+	}
+	var matcher *contactMatcher
+	if opts.Policy != "" {
+		if matcher, err = loadMatcher(uow, userID, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &ImportResult{
+		Contacts: make([]*contact.Contact, 0, len(cards)),
+		Outcomes: make([]*CardOutcome, 0, len(cards)),
+	}
+	for i, card := range cards {
+		upserted, err := s.importOne(uow, userID, card, source, defined, matcher, opts.Policy)
+		if err != nil {
+			if appErr, ok := err.(apperror.AppError); ok && appErr.StatusCode() < 500 {
+				return nil, apperror.NewValidationError(fmt.Sprintf("vcard[%d]", i), appErr.MessageText())
+			}
+			return nil, err
+		}
+		switch upserted.Outcome {
+		case OutcomeCreated:
+			result.Created++
+		case OutcomeUpdated:
+			result.Updated++
+		default:
+			result.Unchanged++
+		}
+		result.Contacts = append(result.Contacts, upserted.Contact)
+		result.Outcomes = append(result.Outcomes, &CardOutcome{
+			ContactID: upserted.Contact.ContactID,
+			Outcome:   upserted.Outcome,
+			MatchedBy: upserted.MatchedBy,
+		})
+	}
+	result.Imported = len(result.Contacts)
+
+	uow.Commit()
+	return result, nil
+}
+
+// importOne creates a contact from card, or upserts it when matcher is set
+func (s *ContactService) importOne(uow *repository.UnitOfWork, userID uint, card *export.Card, source string, customFields map[string]bool,
+	matcher *contactMatcher, policy string) (*UpsertResult, error) {
+	create := func() (*contact.Contact, error) {
+		return s.importCard(uow, userID, card, source, customFields)
+	}
+	if matcher == nil {
+		c, err := create()
+		if err != nil {
+			return nil, err
+		}
+		return &UpsertResult{Contact: c, Outcome: OutcomeCreated}, nil
+	}
+
+	input := cardInput(card, source, customFields)
+	if input.FName == "" || input.LName == "" {
+		return nil, apperror.NewValidationError("name", "first_name and last_name required")
+	}
+	var photo []byte
+	if card.Photo != nil {
+		photo = card.Photo.Data
+	}
+	return s.upsert(uow, userID, matcher, input, photo, policy, create)
+}
+
+// cardInput reads a vCard as contact input. The card's UID becomes the
+// external id within source unless source is empty; only custom fields the
+// user has defined are kept.
+func cardInput(card *export.Card, source string, customFields map[string]bool) ContactInput {
+	input := ContactInput{
+		FName:        strings.TrimSpace(card.FName),
+		LName:        strings.TrimSpace(card.LName),
+		Details:      make([]DetailInput, 0, len(card.Details)),
+		CustomFields: make(map[string]interface{}),
+	}
+	if source != "" {
+		input.ExternalID = strings.TrimSpace(card.UID)
+		input.Source = source
+	}
+	for _, d := range card.Details {
+		input.Details = append(input.Details, DetailInput{Type: strings.ToLower(d.Type), Value: d.Value})
+	}
+	for key, value := range card.CustomFields {
+		if customFields[key] {
+			input.CustomFields[key] = value
+		}
+	}
+	return input
+}
+
+// importCard creates a contact from card. Its UID is recorded as the
+// contact's external id within source; an empty source records none.
+func (s *ContactService) importCard(uow *repository.UnitOfWork, userID uint, card *export.Card, source string, customFields map[string]bool) (*contact.Contact, error) {
+	input := cardInput(card, source, customFields)
+	if input.FName == "" || input.LName == "" {
+		return nil, apperror.NewValidationError("name", "first_name and last_name required")
+	}
+	externalID, source, err := externalLink(input.ExternalID, input.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	newContact := &contact.Contact{
+		UserID:     userID,
+		FName:      input.FName,
+		LName:      input.LName,
+		IsActive:   true,
+		ExternalID: externalID,
+		Source:     source,
+	}
+	if err := s.contactRepo.Add(uow, newContact); err != nil {
+		return nil, err
+	}
+	search.InvalidateOnCommit(uow, userID)
+
+	// details are added as-is rather than upserted by type so a card with
+	// several emails or phones keeps all of them
+	for _, d := range input.Details {
+		detail := &contact_detail.ContactDetail{
+			ContactID: newContact.ContactID,
+			UserID:    userID,
+			Type:      d.Type,
+			Value:     d.Value,
+			IsActive:  true,
+		}
+		if err := s.contactDetailRepo.Add(uow, detail); err != nil {
+			return nil, err
+		}
+		newContact.Details = append(newContact.Details, detail)
+	}
+
+	if _, err := customFieldService.SetValuesWithUOW(uow, userID, newContact.ContactID, input.CustomFields); err != nil {
+		return nil, err
+	}
+
+	if card.Photo != nil && len(card.Photo.Data) > 0 {
+		if _, err := s.photos.SavePhotoWithUOW(uow, userID, newContact.ContactID, card.Photo.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := history.RecordVersion(uow, userID, newContact.ContactID, userID, contact_version.ActionCreate); err != nil {
+		return nil, err
+	}
+	return newContact, nil
+}
+
+// ExportContacts loads every active contact of the user with details and,
+// for vCard, their photos
+func (s *ContactService) ExportContacts(userID uint, format string) ([]*contact.Contact, export.Photos, error) {
+	contacts, err := s.GetContactsWithDetails(userID, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if format != export.FormatVCard || len(contacts) == 0 {
+		return contacts, nil, nil
+	}
+
+	photos, err := s.loadPhotos(contacts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return contacts, photos, nil
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	customFieldService "Contact_App/component/custom_field/service"
+	history "Contact_App/component/history/service"
+	orgService "Contact_App/component/organization/service"
+	shareService "Contact_App/component/share/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_detail"
+	"Contact_App/models/contact_version"
+	"Contact_App/patch"
+	"Contact_App/repository"
+	"Contact_App/search"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// contactDocument is the view of a contact that PATCH requests operate on
+type contactDocument struct {
+	FName          string                 `json:"first_name"`
+	LName          string                 `json:"last_name"`
+	IsActive       bool                   `json:"is_active"`
+	OrganizationID *uint                  `json:"organization_id"`
+	JobTitle       string                 `json:"job_title"`
+	Department     string                 `json:"department"`
+	ExternalID     string                 `json:"external_id"`
+	Source         string                 `json:"source"`
+	Details        []detailDocument       `json:"details"`
+	CustomFields   map[string]interface{} `json:"custom_fields"`
+}
+
+type detailDocument struct {
+	ID         uint   `json:"contact_details_id,omitempty"`
+	Type       string `json:"type"`
+	Value      string `json:"value"`
+	ExternalID string `json:"external_id,omitempty"`
+	Source     string `json:"source,omitempty"`
+}
+
+var contactPatchSchema = patch.Schema{
+	"first_name":      {Kind: patch.KindString, Required: true, MaxLength: 255},
+	"last_name":       {Kind: patch.KindString, Required: true, MaxLength: 255},
+	"is_active":       {Kind: patch.KindBool, Required: true},
+	"organization_id": {Kind: patch.KindNumber, Nullable: true},
+	"job_title":       {Kind: patch.KindString, Nullable: true, MaxLength: 255},
+	"department":      {Kind: patch.KindString, Nullable: true, MaxLength: 255},
+	"external_id":     {Kind: patch.KindString, Nullable: true, MaxLength: 255},
+	"source":          {Kind: patch.KindString, Nullable: true, MaxLength: 100},
+	"custom_fields":   {Kind: patch.KindObject, Nullable: true},
+	"details": {Kind: patch.KindArray, Nullable: true, Items: patch.Schema{
+		"contact_details_id": {Kind: patch.KindNumber},
+		"type":               {Kind: patch.KindString, Required: true, MaxLength: 255},
+		"value":              {Kind: patch.KindString, Required: true, MaxLength: 255},
+		"external_id":        {Kind: patch.KindString, MaxLength: 255},
+		"source":             {Kind: patch.KindString, MaxLength: 100},
+	}},
+}
+
+// PatchContact applies a merge patch or JSON Patch to a contact and its details
+// in one transaction. Details kept by id are updated in place, details without
+// an id are created and details left out of the result are soft deleted.
+// Workspace editors and recipients of a write share may patch the contact.
+func (s *ContactService) PatchContact(userID, contactID uint, contentType string, body []byte, ifMatch uint) (*contact.Contact, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	resolved, err := shareService.ResolveContact(uow.DB, userID, contactID, true)
+	if err != nil {
+		return nil, err
+	}
+	ownerID := resolved.UserID
+	c, err := lockContact(uow, ownerID, contactID, ifMatch)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing []*contact_detail.ContactDetail
+	if err := uow.DB.Where("contact_id = ? AND user_id = ? AND is_active = ?", contactID, ownerID, true).
+		Order("contact_details_id").
+		Find(&existing).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load contact details")
+	}
+
+	current := contactDocument{
+		FName:          c.FName,
+		LName:          c.LName,
+		IsActive:       c.IsActive,
+		OrganizationID: c.OrganizationID,
+		JobTitle:       c.JobTitle,
+		Department:     c.Department,
+		ExternalID:     c.ExternalID,
+		Source:         c.Source,
+		Details:        []detailDocument{},
+	}
+	for _, d := range existing {
+		current.Details = append(current.Details, detailDocument{
+			ID: d.ContactDetailsID, Type: d.Type, Value: d.Value, ExternalID: d.ExternalID, Source: d.Source,
+		})
+	}
+	values, err := customFieldService.LoadValues(uow.DB, ownerID, []uint{contactID})
+	if err != nil {
+		return nil, err
+	}
+	current.CustomFields = values[contactID]
+	if current.CustomFields == nil {
+		current.CustomFields = map[string]interface{}{}
+	}
+
+	patched, err := patch.Apply(contentType, current, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := contactPatchSchema.Validate(patched); err != nil {
+		return nil, err
+	}
+	var target contactDocument
+	if err := patch.Decode(patched, &target); err != nil {
+		return nil, err
+	}
+
+	changed := false
+	updateMap := map[string]interface{}{}
+	if name := strings.TrimSpace(target.FName); name != c.FName {
+		updateMap["f_name"] = name
+	}
+	if name := strings.TrimSpace(target.LName); name != c.LName {
+		updateMap["l_name"] = name
+	}
+	if target.IsActive != c.IsActive {
+		updateMap["is_active"] = target.IsActive
+	}
+	if text := strings.TrimSpace(target.JobTitle); text != c.JobTitle {
+		updateMap["job_title"] = text
+	}
+	if text := strings.TrimSpace(target.Department); text != c.Department {
+		updateMap["department"] = text
+	}
+	externalID, source, err := externalLink(target.ExternalID, target.Source)
+	if err != nil {
+		return nil, err
+	}
+	if externalID != c.ExternalID || source != c.Source {
+		updateMap["external_id"] = externalID
+		updateMap["source"] = source
+	}
+	if !sameOrganization(target.OrganizationID, c.OrganizationID) {
+		if target.OrganizationID == nil {
+			updateMap["organization_id"] = nil
+		} else {
+			if err := orgService.EnsureOrganization(uow, ownerID, *target.OrganizationID); err != nil {
+				return nil, err
+			}
+			updateMap["organization_id"] = *target.OrganizationID
+		}
+	}
+	if len(updateMap) > 0 {
+		if err := s.contactRepo.UpdateWithMap(uow, &contact.Contact{}, updateMap,
+			repository.Filter("contact_id = ? AND user_id = ?", contactID, ownerID)); err != nil {
+			return nil, err
+		}
+		changed = true
+	}
+
+	detailsChanged, err := s.syncDetails(uow, ownerID, contactID, existing, target.Details)
+	if err != nil {
+		return nil, err
+	}
+	changed = changed || detailsChanged
+
+	// members removed from custom_fields clear the value
+	customValues := make(map[string]interface{}, len(current.CustomFields)+len(target.CustomFields))
+	for key := range current.CustomFields {
+		customValues[key] = nil
+	}
+	for key, value := range target.CustomFields {
+		customValues[key] = value
+	}
+	customChanged, err := customFieldService.SetValuesWithUOW(uow, ownerID, contactID, customValues)
+	if err != nil {
+		return nil, err
+	}
+	changed = changed || customChanged
+
+	if changed {
+		if err := history.BumpVersions(uow, contactID); err != nil {
+			return nil, err
+		}
+		if err := history.RecordVersion(uow, ownerID, contactID, userID, contact_version.ActionUpdate); err != nil {
+			return nil, err
+		}
+		search.InvalidateOnCommit(uow, ownerID)
+	}
+
+	var result contact.Contact
+	if err := uow.DB.Preload("Details", "is_active = ?", true).Preload("Organization").
+		Where("contact_id = ? AND user_id = ?", contactID, ownerID).
+		First(&result).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to reload contact")
+	}
+	if err := applyComputedFields(uow, userID, []*contact.Contact{&result}); err != nil {
+		return nil, err
+	}
+
+	uow.Commit()
+	return &result, nil
+}
+
+func sameOrganization(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// syncDetails makes the contact's active details match target and reports whether anything changed
+func (s *ContactService) syncDetails(uow *repository.UnitOfWork, userID, contactID uint, existing []*contact_detail.ContactDetail, target []detailDocument) (bool, error) {
+	byID := make(map[uint]*contact_detail.ContactDetail, len(existing))
+	for _, d := range existing {
+		byID[d.ContactDetailsID] = d
+	}
+
+	changed := false
+	kept := make(map[uint]bool, len(target))
+	for i, want := range target {
+		detailType := strings.ToLower(strings.TrimSpace(want.Type))
+		value := strings.TrimSpace(want.Value)
+		externalID, source, err := externalLink(want.ExternalID, want.Source)
+		if err != nil {
+			return false, err
+		}
+
+		if want.ID == 0 {
+			newDetail := &contact_detail.ContactDetail{
+				ContactID:  contactID,
+				UserID:     userID,
+				Type:       detailType,
+				Value:      value,
+				IsActive:   true,
+				ExternalID: externalID,
+				Source:     source,
+			}
+			if err := s.contactDetailRepo.Add(uow, newDetail); err != nil {
+				return false, err
+			}
+			changed = true
+			continue
+		}
+
+		have, ok := byID[want.ID]
+		if !ok {
+			return false, apperror.NewValidationError(fmt.Sprintf("details[%d].contact_details_id", i), "does not belong to this contact")
+		}
+		if kept[want.ID] {
+			return false, apperror.NewValidationError(fmt.Sprintf("details[%d].contact_details_id", i), "appears more than once")
+		}
+		kept[want.ID] = true
+
+		if have.Type == detailType && have.Value == value && have.ExternalID == externalID && have.Source == source {
+			continue
+		}
+		if err := uow.DB.Model(&contact_detail.ContactDetail{}).
+			Where("contact_details_id = ?", have.ContactDetailsID).
+			Updates(map[string]interface{}{
+				"type": detailType, "value": value, "external_id": externalID, "source": source,
+				"version": gorm.Expr("version + 1"),
+			}).Error; err != nil {
+			return false, apperror.NewInternalError("failed to update contact detail")
+		}
+		changed = true
+	}
+
+	for _, d := range existing {
+		if kept[d.ContactDetailsID] {
+			continue
+		}
+		if err := uow.DB.Model(d).Update("is_active", false).Error; err != nil {
+			return false, apperror.NewInternalError("failed to set detail inactive")
+		}
+		if err := uow.DB.Delete(d).Error; err != nil {
+			return false, apperror.NewInternalError("failed to soft delete detail")
+		}
+		changed = true
+	}
+	return changed, nil
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	shareService "Contact_App/component/share/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_view"
+	"Contact_App/repository"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultRecentHistoryLength = 20
+	maxRecentHistoryLength     = 100
+)
+
+// RecentSettingsInput is the body accepted when changing the recently viewed
+// preferences; fields left out keep their value
+type RecentSettingsInput struct {
+	Enabled       *bool `json:"enabled"`
+	HistoryLength *int  `json:"history_length"`
+}
+
+// RecentHistoryLength is how many recently viewed contacts users keep unless
+// they choose otherwise, read from RECENT_CONTACTS_LIMIT
+func RecentHistoryLength() int {
+	n, err := strconv.Atoi(os.Getenv("RECENT_CONTACTS_LIMIT"))
+	if err != nil || n <= 0 {
+		return defaultRecentHistoryLength
+	}
+	if n > maxRecentHistoryLength {
+		return maxRecentHistoryLength
+	}
+	return n
+}
+
+// RecordContactView notes that the user opened a contact, unless they opted
+// out, and drops views beyond their history length
+func (s *ContactService) RecordContactView(userID, contactID uint) error {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return err
+	}
+	defer uow.Rollback()
+
+	settings, err := loadRecentSettings(uow, userID)
+	if err != nil {
+		return err
+	}
+	if !settings.Enabled {
+		return nil
+	}
+
+	view := &contact_view.ContactView{UserID: userID, ContactID: contactID, ViewedAt: time.Now()}
+	if err := uow.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "contact_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"viewed_at"}),
+	}).Create(view).Error; err != nil {
+		return apperror.NewInternalError("failed to record contact view")
+	}
+	if err := trimViews(uow, userID, settings.HistoryLength); err != nil {
+		return err
+	}
+
+	uow.Commit()
+	return nil
+}
+
+// GetRecentContacts returns the contacts the user viewed most recently, newest
+// first. Contacts deleted or no longer shared since are left out.
+func (s *ContactService) GetRecentContacts(userID uint) ([]*contact.Contact, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := loadRecentSettings(uow, userID)
+	if err != nil {
+		return nil, err
+	}
+	contacts := []*contact.Contact{}
+	if !settings.Enabled {
+		return contacts, nil
+	}
+
+	var views []*contact_view.ContactView
+	if err := uow.DB.Where("user_id = ?", userID).
+		Order("viewed_at DESC, view_id DESC").
+		Limit(settings.HistoryLength).
+		Find(&views).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load recently viewed contacts")
+	}
+	if len(views) == 0 {
+		return contacts, nil
+	}
+	ids := make([]uint, len(views))
+	for i, v := range views {
+		ids[i] = v.ContactID
+	}
+
+	var found []*contact.Contact
+	if err := uow.DB.Model(&contact.Contact{}).Preload("Details").
+		Where(shareService.AccessibleContacts(uow.DB, userID)).
+		Where("contacts.is_active = ? AND contacts.contact_id IN ?", true, ids).
+		Find(&found).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load recently viewed contacts")
+	}
+	if err := applyComputedFields(uow, userID, found); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]*contact.Contact, len(found))
+	for _, c := range found {
+		byID[c.ContactID] = c
+	}
+	for _, v := range views {
+		if c := byID[v.ContactID]; c != nil {
+			viewedAt := v.ViewedAt
+			c.ViewedAt = &viewedAt
+			contacts = append(contacts, c)
+		}
+	}
+	return contacts, nil
+}
+
+// ClearRecentContacts forgets every contact the user viewed
+func (s *ContactService) ClearRecentContacts(userID uint) error {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return err
+	}
+	defer uow.Rollback()
+
+	if err := uow.DB.Where("user_id = ?", userID).Delete(&contact_view.ContactView{}).Error; err != nil {
+		return apperror.NewInternalError("failed to clear recently viewed contacts")
+	}
+
+	uow.Commit()
+	return nil
+}
+
+// GetRecentSettings returns the user's recently viewed preferences
+func (s *ContactService) GetRecentSettings(userID uint) (*contact_view.RecentSettings, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+	return loadRecentSettings(uow, userID)
+}
+
+// UpdateRecentSettings changes the user's recently viewed preferences.
+// Opting out clears the history; a shorter length trims it.
+func (s *ContactService) UpdateRecentSettings(userID uint, input RecentSettingsInput) (*contact_view.RecentSettings, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	settings, err := loadRecentSettings(uow, userID)
+	if err != nil {
+		return nil, err
+	}
+	if input.Enabled != nil {
+		settings.Enabled = *input.Enabled
+	}
+	if input.HistoryLength != nil {
+		if *input.HistoryLength < 1 || *input.HistoryLength > maxRecentHistoryLength {
+			return nil, apperror.NewValidationError("history_length", fmt.Sprintf("must be between 1 and %d", maxRecentHistoryLength))
+		}
+		settings.HistoryLength = *input.HistoryLength
+	}
+
+	if err := uow.DB.Save(settings).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to save recently viewed settings")
+	}
+	keep := settings.HistoryLength
+	if !settings.Enabled {
+		keep = 0
+	}
+	if err := trimViews(uow, userID, keep); err != nil {
+		return nil, err
+	}
+
+	uow.Commit()
+	return settings, nil
+}
+
+func loadRecentSettings(uow *repository.UnitOfWork, userID uint) (*contact_view.RecentSettings, error) {
+	var settings contact_view.RecentSettings
+	if err := uow.DB.Where("user_id = ?", userID).First(&settings).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return &contact_view.RecentSettings{UserID: userID, Enabled: true, HistoryLength: RecentHistoryLength()}, nil
+		}
+		return nil, apperror.NewInternalError("failed to load recently viewed settings")
+	}
+	return &settings, nil
+}
+
+// trimViews deletes the user's views beyond the newest keep. MySQL needs a
+// LIMIT with OFFSET; nobody keeps more than maxRecentHistoryLength views, so
+// one pass removes them all.
+func trimViews(uow *repository.UnitOfWork, userID uint, keep int) error {
+	var stale []uint
+	if err := uow.DB.Model(&contact_view.ContactView{}).
+		Where("user_id = ?", userID).
+		Order("viewed_at DESC, view_id DESC").
+		Offset(keep).Limit(maxRecentHistoryLength).
+		Pluck("view_id", &stale).Error; err != nil {
+		return apperror.NewInternalError("failed to trim recently viewed contacts")
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	if err := uow.DB.Where("view_id IN ?", stale).Delete(&contact_view.ContactView{}).Error; err != nil {
+		return apperror.NewInternalError("failed to trim recently viewed contacts")
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	interactionService "Contact_App/component/interaction/service"
+	shareService "Contact_App/component/share/service"
+	"Contact_App/models/contact"
+	"Contact_App/repository"
+	"Contact_App/search"
+	"Contact_App/web"
+	"sort"
+	"strings"
+)
+
+// relevanceOrder is the keyset order of search results: best score first,
+// ties broken by contact ID
+var relevanceOrder = []web.SortField{
+	{JSONName: "search_score", Type: web.FieldNumber, Descending: true},
+	{JSONName: "contact_id", Column: "contacts.contact_id", Type: web.FieldNumber},
+}
+
+// ContactDocument builds the search document for a contact: its names, job
+// title, department and organization, every active detail value keyed by
+// detail type (email, phone, company, ...) and the bodies of its notes
+func ContactDocument(c *contact.Contact, notes []string) search.Document {
+	fields := map[string][]string{
+		"first_name": {c.FName},
+		"last_name":  {c.LName},
+	}
+	if c.JobTitle != "" {
+		fields["job_title"] = []string{c.JobTitle}
+	}
+	if c.Department != "" {
+		fields["department"] = []string{c.Department}
+	}
+	if c.Organization != nil {
+		fields["organization"] = []string{c.Organization.Name}
+	}
+	for _, d := range c.Details {
+		if d == nil || !d.IsActive {
+			continue
+		}
+		field := strings.ToLower(strings.TrimSpace(d.Type))
+		fields[field] = append(fields[field], d.Value)
+	}
+	if len(notes) > 0 {
+		fields["notes"] = append(fields["notes"], notes...)
+	}
+	return search.Document{ID: c.ContactID, OwnerID: c.UserID, Fields: fields}
+}
+
+// ensureSearchIndex loads the user's contacts into the search index the first
+// time they are searched, or after a write invalidated them, and returns the
+// index to search. A write that commits while the contacts are read moves the
+// index generation: the read then only answers this search, from an index of
+// its own, and the next search loads the contacts again.
+func (s *ContactService) ensureSearchIndex(uow *repository.UnitOfWork, userID uint) (search.Index, error) {
+	if s.searchIndex.Indexed(userID) {
+		return s.searchIndex, nil
+	}
+	generation := s.searchIndex.Generation(userID)
+
+	var contacts []*contact.Contact
+	// the index is shared by every viewer of the owner's contacts, so it is
+	// built without the caller's tenant scope
+	conn := repository.WithoutTenant(uow.DB)
+	if err := conn.Preload("Details", "is_active = ?", true).Preload("Organization").
+		Where("user_id = ? AND is_active = ?", userID, true).
+		Find(&contacts).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load contacts for search")
+	}
+	notes, err := interactionService.Notes(conn, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]search.Document, 0, len(contacts))
+	for _, c := range contacts {
+		docs = append(docs, ContactDocument(c, notes[c.ContactID]))
+	}
+	if s.searchIndex.Replace(userID, generation, docs) {
+		return s.searchIndex, nil
+	}
+	own := search.NewMemoryIndex()
+	own.Replace(userID, 0, docs)
+	return own, nil
+}
+
+// searchContacts runs q against the user's index and the indexes of users
+// sharing with them, and returns the hits keyed by contact ID. Hits on
+// contacts that were not shared are dropped by the listing query.
+func (s *ContactService) searchContacts(uow *repository.UnitOfWork, userID uint, q string) (map[uint]search.Hit, error) {
+	owners, err := shareService.SharingOwners(uow.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make(map[uint]search.Hit)
+	for _, ownerID := range append([]uint{userID}, owners...) {
+		index, err := s.ensureSearchIndex(uow, ownerID)
+		if err != nil {
+			return nil, err
+		}
+		for _, hit := range index.Search(ownerID, q) {
+			hits[hit.ID] = hit
+		}
+	}
+	return hits, nil
+}
+
+func applySearchHits(contacts []*contact.Contact, hits map[uint]search.Hit) {
+	if hits == nil {
+		return
+	}
+	for _, c := range contacts {
+		c.SearchScore = hits[c.ContactID].Score
+		c.Highlights = hits[c.ContactID].Highlights
+	}
+}
+
+func sortByRelevance(contacts []*contact.Contact) {
+	sort.SliceStable(contacts, func(i, j int) bool {
+		if contacts[i].SearchScore != contacts[j].SearchScore {
+			return contacts[i].SearchScore > contacts[j].SearchScore
+		}
+		return contacts[i].ContactID < contacts[j].ContactID
+	})
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_detail"
+	"Contact_App/models/organization"
+	"reflect"
+	"testing"
+)
+
+func TestContactDocument(t *testing.T) {
+	tests := []struct {
+		name    string
+		contact *contact.Contact
+		notes   []string
+		want    map[string][]string
+	}{
+		{
+			name:    "names only",
+			contact: &contact.Contact{ContactID: 1, UserID: 2, FName: "Ada", LName: "Lovelace"},
+			want:    map[string][]string{"first_name": {"Ada"}, "last_name": {"Lovelace"}},
+		},
+		{
+			name: "work fields and notes",
+			contact: &contact.Contact{
+				ContactID:    1,
+				UserID:       2,
+				FName:        "Ada",
+				LName:        "Lovelace",
+				JobTitle:     "Analyst",
+				Department:   "Engines",
+				Organization: &organization.Organization{Name: "Babbage & Co"},
+			},
+			notes: []string{"met at the salon", "likes poetry"},
+			want: map[string][]string{
+				"first_name":   {"Ada"},
+				"last_name":    {"Lovelace"},
+				"job_title":    {"Analyst"},
+				"department":   {"Engines"},
+				"organization": {"Babbage & Co"},
+				"notes":        {"met at the salon", "likes poetry"},
+			},
+		},
+		{
+			name: "active details grouped by normalized type",
+			contact: &contact.Contact{
+				ContactID: 1,
+				UserID:    2,
+				FName:     "Ada",
+				Details: []*contact_detail.ContactDetail{
+					{Type: "Email", Value: "ada@example.com", IsActive: true},
+					{Type: " email ", Value: "ada@work.example", IsActive: true},
+					{Type: "phone", Value: "+44 20 7946 0000", IsActive: true},
+					{Type: "phone", Value: "+44 20 0000 0000", IsActive: false},
+					nil,
+				},
+			},
+			want: map[string][]string{
+				"first_name": {"Ada"},
+				"last_name":  {""},
+				"email":      {"ada@example.com", "ada@work.example"},
+				"phone":      {"+44 20 7946 0000"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := ContactDocument(tt.contact, tt.notes)
+			if doc.ID != tt.contact.ContactID || doc.OwnerID != tt.contact.UserID {
+				t.Errorf("ContactDocument() id, owner = %d, %d, want %d, %d", doc.ID, doc.OwnerID, tt.contact.ContactID, tt.contact.UserID)
+			}
+			if !reflect.DeepEqual(doc.Fields, tt.want) {
+				t.Errorf("ContactDocument() fields = %v, want %v", doc.Fields, tt.want)
+			}
+		})
+	}
+}
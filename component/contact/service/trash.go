@@ -0,0 +1,381 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	customFieldService "Contact_App/component/custom_field/service"
+	history "Contact_App/component/history/service"
+	dateService "Contact_App/component/important_date/service"
+	interactionService "Contact_App/component/interaction/service"
+	relationshipService "Contact_App/component/relationship/service"
+	shareService "Contact_App/component/share/service"
+	taskService "Contact_App/component/task/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/db"
+	"Contact_App/models/card_link"
+	"Contact_App/models/carddav"
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_change"
+	"Contact_App/models/contact_detail"
+	"Contact_App/models/contact_favorite"
+	"Contact_App/models/contact_merge"
+	"Contact_App/models/contact_version"
+	"Contact_App/models/contact_view"
+	"Contact_App/models/group"
+	"Contact_App/repository"
+	"Contact_App/search"
+	"Contact_App/web"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTrashRetentionDays   = 30
+	defaultPurgeIntervalMinutes = 60
+)
+
+// TrashRetention is how long soft-deleted contacts stay restorable, read from TRASH_RETENTION_DAYS
+func TrashRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		days = defaultTrashRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// trashOrder lists the most recently deleted contacts first when the client
+// asks for no other order; deleting a contact touches its updated_at
+var trashOrder = web.SortField{JSONName: "updated_at", Column: "contacts.updated_at", Type: web.FieldTime, Descending: true}
+
+// GetDeletedContactsPage returns one cursor page of the soft-deleted contacts
+// the user can see, with the details deleted alongside them
+func (s *ContactService) GetDeletedContactsPage(r *http.Request, userID uint, spec *web.QuerySpec) (*web.Page, error) {
+	contacts := []*contact.Contact{}
+	if sortsInMemory(spec) {
+		return nil, apperror.NewValidationError("sort", "the trash cannot be sorted by favorites or custom fields")
+	}
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	query := uow.DB.Unscoped().Model(&contact.Contact{}).
+		Preload("Details", func(tx *gorm.DB) *gorm.DB { return tx.Unscoped() }).
+		Where(shareService.AccessibleContacts(uow.DB, userID)).
+		Where("contacts.deleted_at IS NOT NULL")
+	for _, process := range spec.FilterProcessors() {
+		if query, err = process(query, &contact.Contact{}); err != nil {
+			return nil, apperror.NewValidationError("query", err.Error())
+		}
+	}
+	if !spec.HasSort() {
+		spec.Sort = append(spec.Sort, trashOrder)
+	}
+	return web.PaginateQuery(r, query, &contacts, spec)
+}
+
+// RestoreContactByID revives a soft-deleted contact together with the details
+// that were deleted with it; details removed individually earlier stay deleted.
+// A contact merged into another comes back by undoing the merge while it can.
+func (s *ContactService) RestoreContactByID(userID, contactID uint) (*contact.Contact, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	deleted, err := getDeletedContact(uow, userID, contactID)
+	if err != nil {
+		return nil, err
+	}
+	ownerID := deleted.UserID
+
+	var pending int64
+	if err := pendingMergeSecondaries(uow.DB).
+		Where("secondary_id = ?", contactID).
+		Count(&pending).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to check pending merges")
+	}
+	if pending > 0 {
+		return nil, apperror.NewConflictError("contact", "the contact was merged; undo the merge to restore it")
+	}
+
+	if err := uow.DB.Unscoped().Model(&contact.Contact{}).
+		Where("contact_id = ? AND user_id = ?", contactID, ownerID).
+		Updates(map[string]interface{}{"is_active": true, "deleted_at": nil, "delete_batch": ""}).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to restore contact")
+	}
+
+	details := uow.DB.Unscoped().Model(&contact_detail.ContactDetail{}).
+		Where("contact_id = ? AND user_id = ? AND deleted_at IS NOT NULL", contactID, ownerID)
+	if deleted.DeleteBatch != "" {
+		details = details.Where("delete_batch = ?", deleted.DeleteBatch)
+	} else {
+		// contacts deleted before batches were recorded only have the
+		// timestamp their details were deleted at
+		details = details.Where("deleted_at >= ?", deleted.DeletedAt.Time.Add(-time.Second))
+	}
+	if err := details.Updates(map[string]interface{}{"is_active": true, "deleted_at": nil, "delete_batch": ""}).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to restore contact details")
+	}
+
+	if err := history.BumpVersions(uow, contactID); err != nil {
+		return nil, err
+	}
+	if err := history.RecordVersion(uow, ownerID, contactID, userID, contact_version.ActionRestore); err != nil {
+		return nil, err
+	}
+	search.InvalidateOnCommit(uow, ownerID)
+
+	uow.Commit()
+	return s.GetContactByIDWithDetails(userID, contactID)
+}
+
+// PermanentlyDeleteContactByID hard deletes a contact in the trash, its details
+// and group memberships. Live contacts must be deleted first, and contacts
+// still needed to undo a merge are kept until the merge expires.
+func (s *ContactService) PermanentlyDeleteContactByID(userID, contactID uint) error {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return err
+	}
+	defer uow.Rollback()
+
+	deleted, err := getDeletedContact(uow, userID, contactID)
+	if err != nil {
+		return err
+	}
+
+	var pending int64
+	if err := pendingMergeSecondaries(uow.DB).
+		Where("secondary_id = ?", contactID).
+		Count(&pending).Error; err != nil {
+		return apperror.NewInternalError("failed to check pending merges")
+	}
+	if pending > 0 {
+		return apperror.NewConflictError("contact", "the contact was merged and can still be restored by undoing the merge")
+	}
+
+	if err := s.hardDeleteContacts(uow, []uint{contactID}); err != nil {
+		return err
+	}
+	search.InvalidateOnCommit(uow, deleted.UserID)
+
+	uow.Commit()
+	return nil
+}
+
+// PurgeDeletedContacts hard deletes contacts and details that were soft deleted
+// before cutoff. Contacts that are still needed to undo a merge are kept.
+func (s *ContactService) PurgeDeletedContacts(cutoff time.Time) (int, error) {
+	// the purge runs for every user, so it opts out of tenant scoping
+	uow := repository.NewUnitOfWork(repository.WithoutTenant(db.GetDB()), false)
+	defer uow.Rollback()
+
+	pendingMerges := pendingMergeSecondaries(uow.DB).Select("secondary_id")
+
+	var contactIDs []uint
+	if err := uow.DB.Unscoped().Model(&contact.Contact{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Where("contact_id NOT IN (?)", pendingMerges).
+		Pluck("contact_id", &contactIDs).Error; err != nil {
+		return 0, apperror.NewInternalError("failed to find expired contacts")
+	}
+
+	if len(contactIDs) > 0 {
+		if err := s.hardDeleteContacts(uow, contactIDs); err != nil {
+			return 0, err
+		}
+	}
+
+	// details deleted on their own while the contact stayed active
+	if err := uow.DB.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Where("contact_id NOT IN (?)", pendingMerges).
+		Delete(&contact_detail.ContactDetail{}).Error; err != nil {
+		return 0, apperror.NewInternalError("failed to purge expired contact details")
+	}
+
+	uow.Commit()
+	return len(contactIDs), nil
+}
+
+// PurgeContactChanges drops the change sequence recorded before cutoff; sync
+// tokens that could still need those changes have expired by then
+func (s *ContactService) PurgeContactChanges(cutoff time.Time) (int64, error) {
+	result := repository.WithoutTenant(db.GetDB()).
+		Where("created_at < ?", cutoff).
+		Delete(&contact_change.ContactChange{})
+	if result.Error != nil {
+		return 0, apperror.NewInternalError("failed to purge expired contact changes")
+	}
+	return result.RowsAffected, nil
+}
+
+// newDeleteBatch returns a random value identifying one contact deletion
+func newDeleteBatch() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", apperror.NewInternalError("failed to delete contact")
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// pendingMergeSecondaries selects the merges that can still be undone, whose
+// secondary contacts must survive until then
+func pendingMergeSecondaries(conn *gorm.DB) *gorm.DB {
+	return conn.Session(&gorm.Session{NewDB: true}).Model(&contact_merge.ContactMerge{}).
+		Where("undone_at IS NULL AND expires_at > ?", time.Now())
+}
+
+// getDeletedContact loads a soft-deleted contact the user may restore or
+// purge: their own, or one of a workspace where they can edit contacts.
+// Contacts shared with the user stay in the owner's hands, as with deleting.
+func getDeletedContact(uow *repository.UnitOfWork, userID, contactID uint) (*contact.Contact, error) {
+	var c contact.Contact
+	err := uow.DB.Unscoped().
+		Where(shareService.AccessibleContacts(uow.DB, userID)).
+		Where("contacts.contact_id = ? AND contacts.deleted_at IS NOT NULL", contactID).
+		First(&c).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewNotFoundError("deleted contact", int(contactID))
+		}
+		return nil, apperror.NewInternalError("failed to fetch deleted contact")
+	}
+
+	if c.WorkspaceID != nil {
+		role, err := workspaceService.Role(uow.DB, userID, *c.WorkspaceID)
+		if err != nil {
+			return nil, err
+		}
+		if !workspaceService.CanWrite(role) {
+			return nil, apperror.NewForbiddenError("contact", "your workspace role only allows reading contacts")
+		}
+		return &c, nil
+	}
+	if c.UserID != userID {
+		return nil, apperror.NewForbiddenError("contact", "only the owner can restore or delete a shared contact")
+	}
+	return &c, nil
+}
+
+func (s *ContactService) hardDeleteContacts(uow *repository.UnitOfWork, contactIDs []uint) error {
+	if err := history.DeleteVersions(uow, contactIDs); err != nil {
+		return err
+	}
+	if err := s.photos.DeletePhotosWithUOW(uow, contactIDs); err != nil {
+		return err
+	}
+	if err := dateService.DeleteDatesWithUOW(uow, contactIDs); err != nil {
+		return err
+	}
+	if err := interactionService.DeleteInteractionsWithUOW(uow, contactIDs); err != nil {
+		return err
+	}
+	if err := relationshipService.DeleteForContactsWithUOW(uow, contactIDs); err != nil {
+		return err
+	}
+	if err := customFieldService.DeleteValuesWithUOW(uow, contactIDs); err != nil {
+		return err
+	}
+	if err := shareService.DeleteForContactsWithUOW(uow, contactIDs); err != nil {
+		return err
+	}
+	if err := taskService.DeleteTasksWithUOW(uow, contactIDs); err != nil {
+		return err
+	}
+	if err := uow.DB.Where("contact_id IN ?", contactIDs).
+		Delete(&group.GroupContact{}).Error; err != nil {
+		return apperror.NewInternalError("failed to remove group memberships")
+	}
+	if err := uow.DB.Where("contact_id IN ?", contactIDs).
+		Delete(&contact_favorite.ContactFavorite{}).Error; err != nil {
+		return apperror.NewInternalError("failed to remove favorites")
+	}
+	if err := uow.DB.Where("contact_id IN ?", contactIDs).
+		Delete(&contact_view.ContactView{}).Error; err != nil {
+		return apperror.NewInternalError("failed to remove recently viewed entries")
+	}
+	if err := uow.DB.Where("contact_id IN ?", contactIDs).
+		Delete(&carddav.CardResource{}).Error; err != nil {
+		return apperror.NewInternalError("failed to remove address book entries")
+	}
+	if err := uow.DB.Where("contact_id IN ?", contactIDs).
+		Delete(&card_link.CardLink{}).Error; err != nil {
+		return apperror.NewInternalError("failed to remove card links")
+	}
+	if err := uow.DB.Unscoped().Where("contact_id IN ?", contactIDs).
+		Delete(&contact_detail.ContactDetail{}).Error; err != nil {
+		return apperror.NewInternalError("failed to permanently delete contact details")
+	}
+	if err := uow.DB.Unscoped().Where("contact_id IN ?", contactIDs).
+		Delete(&contact.Contact{}).Error; err != nil {
+		return apperror.NewInternalError("failed to permanently delete contacts")
+	}
+	return nil
+}
+
+// TrashPurger periodically hard deletes contacts that outlived the trash
+// retention and changes that outlived the change log retention
+type TrashPurger struct {
+	Service         *ContactService
+	Interval        time.Duration
+	Retention       time.Duration
+	ChangeRetention time.Duration
+}
+
+func NewTrashPurger(svc *ContactService) *TrashPurger {
+	minutes, err := strconv.Atoi(os.Getenv("TRASH_PURGE_INTERVAL_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = defaultPurgeIntervalMinutes
+	}
+	return &TrashPurger{
+		Service:         svc,
+		Interval:        time.Duration(minutes) * time.Minute,
+		Retention:       TrashRetention(),
+		ChangeRetention: ChangeLogRetention(),
+	}
+}
+
+// Start runs the purge loop in a goroutine until stop is closed
+func (p *TrashPurger) Start(wg *sync.WaitGroup, stop <-chan struct{}) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(p.Interval)
+		defer ticker.Stop()
+
+		for {
+			p.runOnce()
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (p *TrashPurger) runOnce() {
+	purged, err := p.Service.PurgeDeletedContacts(time.Now().Add(-p.Retention))
+	if err != nil {
+		log.Println("Trash purge failed:", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("Trash purge removed %d contacts\n", purged)
+	}
+
+	if _, err := p.Service.PurgeContactChanges(time.Now().Add(-p.ChangeRetention)); err != nil {
+		log.Println("Change log purge failed:", err)
+	}
+}
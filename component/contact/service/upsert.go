@@ -0,0 +1,487 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	customFieldService "Contact_App/component/custom_field/service"
+	history "Contact_App/component/history/service"
+	orgService "Contact_App/component/organization/service"
+	shareService "Contact_App/component/share/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/helper"
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_detail"
+	"Contact_App/models/contact_photo"
+	"Contact_App/models/contact_version"
+	"Contact_App/repository"
+	"Contact_App/search"
+	"strings"
+)
+
+// Conflict policies decide what an upsert does with a contact that matches
+// the incoming one
+const (
+	ConflictKeep      = "keep"
+	ConflictOverwrite = "overwrite"
+	ConflictMerge     = "merge"
+)
+
+// Outcomes of an upsert
+const (
+	OutcomeCreated   = "created"
+	OutcomeUpdated   = "updated"
+	OutcomeUnchanged = "unchanged"
+)
+
+// What an upsert matched an existing contact on
+const (
+	MatchExternalID = "external_id"
+	MatchEmail      = "email"
+	MatchPhone      = "phone"
+)
+
+// IsConflictPolicy reports whether policy names a conflict policy
+func IsConflictPolicy(policy string) bool {
+	switch policy {
+	case ConflictKeep, ConflictOverwrite, ConflictMerge:
+		return true
+	}
+	return false
+}
+
+// UpsertResult is the contact an upsert created or matched and what it did
+type UpsertResult struct {
+	Contact   *contact.Contact `json:"contact"`
+	Outcome   string           `json:"outcome"`
+	MatchedBy string           `json:"matched_by,omitempty"`
+}
+
+// UpsertContact creates a contact like CreateContactWithDetails unless one
+// already in the address book it is filed in matches it: first by external
+// id and source, then by a normalized email or phone. A match is left alone,
+// overwritten or merged with the input according to policy.
+func (s *ContactService) UpsertContact(userID uint, input ContactInput, policy string) (*UpsertResult, error) {
+	if !IsConflictPolicy(policy) {
+		return nil, apperror.NewValidationError("on_conflict", "must be keep, overwrite or merge")
+	}
+
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	if err := ensureWorkspaceWrite(uow, userID, input.WorkspaceID); err != nil {
+		return nil, err
+	}
+	matcher, err := loadMatcher(uow, userID, input.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.upsert(uow, userID, matcher, input, nil, policy, func() (*contact.Contact, error) {
+		return s.CreateContactWithDetailsUOW(uow, userID, input)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	uow.Commit()
+	return result, nil
+}
+
+// upsert stores input through create unless the matcher finds the contact
+// it duplicates. photo, when set, is the incoming contact's picture.
+func (s *ContactService) upsert(uow *repository.UnitOfWork, userID uint, matcher *contactMatcher, input ContactInput, photo []byte,
+	policy string, create func() (*contact.Contact, error)) (*UpsertResult, error) {
+	externalID, source, err := externalLink(input.ExternalID, input.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	contactID, matchedBy := matcher.match(externalID, source, input.Details)
+	if contactID == 0 {
+		created, err := create()
+		if err != nil {
+			return nil, err
+		}
+		matcher.add(created.ContactID, externalID, source, input.Details)
+		c, err := loadUpserted(uow, userID, created.UserID, created.ContactID)
+		if err != nil {
+			return nil, err
+		}
+		return &UpsertResult{Contact: c, Outcome: OutcomeCreated}, nil
+	}
+
+	resolved, err := shareService.ResolveContact(uow.DB, userID, contactID, policy != ConflictKeep)
+	if err != nil {
+		return nil, err
+	}
+	outcome := OutcomeUnchanged
+	if policy != ConflictKeep {
+		changed, err := s.applyIncoming(uow, resolved.UserID, userID, contactID, input, photo, policy == ConflictOverwrite)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			outcome = OutcomeUpdated
+		}
+	}
+	matcher.add(contactID, externalID, source, input.Details)
+
+	c, err := loadUpserted(uow, userID, resolved.UserID, contactID)
+	if err != nil {
+		return nil, err
+	}
+	return &UpsertResult{Contact: c, Outcome: outcome, MatchedBy: matchedBy}, nil
+}
+
+// applyIncoming writes input over a matched contact of ownerID on behalf of
+// actorID. Overwriting replaces the contact's fields, details and custom
+// fields with the input's; merging only fills in what the contact lacks and
+// adds the details it does not have yet. It reports whether anything changed.
+func (s *ContactService) applyIncoming(uow *repository.UnitOfWork, ownerID, actorID, contactID uint, input ContactInput, photo []byte, overwrite bool) (bool, error) {
+	c, err := lockContact(uow, ownerID, contactID, 0)
+	if err != nil {
+		return false, err
+	}
+	jobTitle, err := optionalText("job_title", input.JobTitle)
+	if err != nil {
+		return false, err
+	}
+	department, err := optionalText("department", input.Department)
+	if err != nil {
+		return false, err
+	}
+	externalID, source, err := externalLink(input.ExternalID, input.Source)
+	if err != nil {
+		return false, err
+	}
+
+	updateMap := map[string]interface{}{}
+	if overwrite {
+		if name := strings.TrimSpace(input.FName); name != "" && name != c.FName {
+			updateMap["f_name"] = name
+		}
+		if name := strings.TrimSpace(input.LName); name != "" && name != c.LName {
+			updateMap["l_name"] = name
+		}
+		if jobTitle != c.JobTitle {
+			updateMap["job_title"] = jobTitle
+		}
+		if department != c.Department {
+			updateMap["department"] = department
+		}
+	} else {
+		if c.JobTitle == "" && jobTitle != "" {
+			updateMap["job_title"] = jobTitle
+		}
+		if c.Department == "" && department != "" {
+			updateMap["department"] = department
+		}
+	}
+	if (overwrite || c.OrganizationID == nil) && !sameOrganization(input.OrganizationID, c.OrganizationID) {
+		if input.OrganizationID == nil {
+			if overwrite {
+				updateMap["organization_id"] = nil
+			}
+		} else {
+			if err := orgService.EnsureOrganization(uow, ownerID, *input.OrganizationID); err != nil {
+				return false, err
+			}
+			updateMap["organization_id"] = *input.OrganizationID
+		}
+	}
+	if externalID != "" && (overwrite || c.ExternalID == "") && (externalID != c.ExternalID || source != c.Source) {
+		updateMap["external_id"] = externalID
+		updateMap["source"] = source
+	}
+
+	changed := false
+	if len(updateMap) > 0 {
+		if err := s.contactRepo.UpdateWithMap(uow, &contact.Contact{}, updateMap,
+			repository.Filter("contact_id = ? AND user_id = ?", contactID, ownerID)); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+
+	var existing []*contact_detail.ContactDetail
+	if err := uow.DB.Where("contact_id = ? AND user_id = ? AND is_active = ?", contactID, ownerID, true).
+		Order("contact_details_id").
+		Find(&existing).Error; err != nil {
+		return false, apperror.NewInternalError("failed to load contact details")
+	}
+	detailsChanged, err := s.syncDetails(uow, ownerID, contactID, existing, incomingDetails(existing, input.Details, overwrite))
+	if err != nil {
+		return false, err
+	}
+	changed = changed || detailsChanged
+
+	current, err := customFieldService.LoadValues(uow.DB, ownerID, []uint{contactID})
+	if err != nil {
+		return false, err
+	}
+	values := make(map[string]interface{})
+	if overwrite {
+		for key := range current[contactID] {
+			values[key] = nil
+		}
+	}
+	for key, value := range input.CustomFields {
+		if _, set := current[contactID][key]; overwrite || !set {
+			values[key] = value
+		}
+	}
+	customChanged, err := customFieldService.SetValuesWithUOW(uow, ownerID, contactID, values)
+	if err != nil {
+		return false, err
+	}
+	changed = changed || customChanged
+
+	if len(photo) > 0 {
+		var photos int64
+		if !overwrite {
+			if err := uow.DB.Model(&contact_photo.ContactPhoto{}).Where("contact_id = ?", contactID).Count(&photos).Error; err != nil {
+				return false, apperror.NewInternalError("failed to check contact photo")
+			}
+		}
+		if photos == 0 {
+			if _, err := s.photos.SavePhotoWithUOW(uow, ownerID, contactID, photo); err != nil {
+				return false, err
+			}
+			changed = true
+		}
+	}
+
+	if changed {
+		if err := history.BumpVersions(uow, contactID); err != nil {
+			return false, err
+		}
+		if err := history.RecordVersion(uow, ownerID, contactID, actorID, contact_version.ActionUpdate); err != nil {
+			return false, err
+		}
+		search.InvalidateOnCommit(uow, ownerID)
+	}
+	return changed, nil
+}
+
+// incomingDetails lists the details a matched contact should end up with.
+// An incoming detail takes the place of the existing one with its external
+// id, or else the one with the same type and normalized value, so kept
+// details keep their ids. Overwriting drops the existing details the input
+// lacks; merging keeps them and adds the new ones.
+func incomingDetails(existing []*contact_detail.ContactDetail, incoming []DetailInput, overwrite bool) []detailDocument {
+	byLink := make(map[string]*contact_detail.ContactDetail)
+	byValue := make(map[string]*contact_detail.ContactDetail)
+	for _, d := range existing {
+		if d.ExternalID != "" {
+			byLink[d.Source+"\x00"+d.ExternalID] = d
+		}
+		if key := valueKey(d.Type, d.Value); byValue[key] == nil {
+			byValue[key] = d
+		}
+	}
+
+	target := make([]detailDocument, 0, len(existing)+len(incoming))
+	placed := make(map[uint]int)
+	seen := make(map[string]bool)
+	for _, in := range incoming {
+		detailType := strings.ToLower(strings.TrimSpace(in.Type))
+		value := strings.TrimSpace(in.Value)
+		if detailType == "" || value == "" {
+			continue
+		}
+		externalID, source, _ := externalLink(in.ExternalID, in.Source)
+		key := valueKey(detailType, value)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		have := byValue[key]
+		if externalID != "" && byLink[source+"\x00"+externalID] != nil {
+			have = byLink[source+"\x00"+externalID]
+		}
+		if have == nil {
+			target = append(target, detailDocument{Type: detailType, Value: value, ExternalID: externalID, Source: source})
+			continue
+		}
+		if _, ok := placed[have.ContactDetailsID]; ok {
+			continue
+		}
+
+		doc := detailDocument{ID: have.ContactDetailsID, Type: have.Type, Value: have.Value, ExternalID: have.ExternalID, Source: have.Source}
+		if overwrite {
+			doc.Type, doc.Value = detailType, value
+		}
+		if externalID != "" && (overwrite || have.ExternalID == "") {
+			doc.ExternalID, doc.Source = externalID, source
+		}
+		placed[have.ContactDetailsID] = len(target)
+		target = append(target, doc)
+	}
+
+	if overwrite {
+		return target
+	}
+	merged := make([]detailDocument, 0, len(existing)+len(target))
+	for _, d := range existing {
+		if i, ok := placed[d.ContactDetailsID]; ok {
+			merged = append(merged, target[i])
+		} else {
+			merged = append(merged, detailDocument{ID: d.ContactDetailsID, Type: d.Type, Value: d.Value, ExternalID: d.ExternalID, Source: d.Source})
+		}
+	}
+	for _, doc := range target {
+		if doc.ID == 0 {
+			merged = append(merged, doc)
+		}
+	}
+	return merged
+}
+
+// loadUpserted reloads a contact with its details for the upsert's response
+func loadUpserted(uow *repository.UnitOfWork, viewerID, ownerID, contactID uint) (*contact.Contact, error) {
+	var c contact.Contact
+	if err := uow.DB.Preload("Details", "is_active = ?", true).Preload("Organization").
+		Where("contact_id = ? AND user_id = ?", contactID, ownerID).
+		First(&c).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to reload contact")
+	}
+	if err := applyComputedFields(uow, viewerID, []*contact.Contact{&c}); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// contactMatcher finds the contact an incoming one duplicates within one
+// address book. Contacts written by the upsert are added as it goes, so a
+// record repeated within one import lands on the same contact.
+type contactMatcher struct {
+	external map[string]uint
+	emails   map[string]uint
+	phones   map[string]uint
+	links    map[uint]externalRef
+}
+
+type externalRef struct {
+	id, source string
+}
+
+// loadMatcher indexes the user's own contacts, or those of the workspace
+func loadMatcher(uow *repository.UnitOfWork, userID uint, workspaceID *uint) (*contactMatcher, error) {
+	m := &contactMatcher{
+		external: make(map[string]uint),
+		emails:   make(map[string]uint),
+		phones:   make(map[string]uint),
+		links:    make(map[uint]externalRef),
+	}
+
+	query := uow.DB.Model(&contact.Contact{}).Select("contact_id", "external_id", "source")
+	if workspaceID != nil {
+		query = query.Where("workspace_id = ?", *workspaceID)
+	} else {
+		query = query.Where("user_id = ? AND workspace_id IS NULL", userID)
+	}
+	var contacts []*contact.Contact
+	if err := query.Order("contact_id").Find(&contacts).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load contacts")
+	}
+	if len(contacts) == 0 {
+		return m, nil
+	}
+
+	ids := make([]uint, len(contacts))
+	for i, c := range contacts {
+		ids[i] = c.ContactID
+		m.add(c.ContactID, c.ExternalID, c.Source, nil)
+	}
+	var details []*contact_detail.ContactDetail
+	if err := uow.DB.Select("contact_id", "type", "value").
+		Where("contact_id IN ? AND is_active = ?", ids, true).
+		Order("contact_details_id").
+		Find(&details).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load contact details")
+	}
+	for _, d := range details {
+		m.add(d.ContactID, "", "", []DetailInput{{Type: d.Type, Value: d.Value}})
+	}
+	return m, nil
+}
+
+// match returns the contact the incoming one duplicates and what matched,
+// or 0. A contact linked to another record of the same source is never
+// matched by email or phone, since it is a different record there.
+func (m *contactMatcher) match(externalID, source string, details []DetailInput) (uint, string) {
+	if externalID != "" {
+		if id := m.external[source+"\x00"+externalID]; id != 0 {
+			return id, MatchExternalID
+		}
+	}
+	compatible := func(id uint) bool {
+		link, ok := m.links[id]
+		return externalID == "" || !ok || link.source != source || link.id == externalID
+	}
+	for _, kind := range []string{MatchEmail, MatchPhone} {
+		index := m.emails
+		if kind == MatchPhone {
+			index = m.phones
+		}
+		for _, d := range details {
+			if k, key := matchKey(d.Type, d.Value); k == kind {
+				if id := index[key]; id != 0 && compatible(id) {
+					return id, kind
+				}
+			}
+		}
+	}
+	return 0, ""
+}
+
+// add indexes a contact under its external id and its emails and phones;
+// keys already taken stay with the first contact
+func (m *contactMatcher) add(contactID uint, externalID, source string, details []DetailInput) {
+	if externalID != "" {
+		if _, ok := m.links[contactID]; !ok {
+			m.links[contactID] = externalRef{id: externalID, source: source}
+		}
+		if m.external[source+"\x00"+externalID] == 0 {
+			m.external[source+"\x00"+externalID] = contactID
+		}
+	}
+	for _, d := range details {
+		kind, key := matchKey(d.Type, d.Value)
+		index := m.emails
+		switch kind {
+		case MatchEmail:
+		case MatchPhone:
+			index = m.phones
+		default:
+			continue
+		}
+		if index[key] == 0 {
+			index[key] = contactID
+		}
+	}
+}
+
+// matchKey normalizes an email or phone detail for matching; other details
+// and values too short to identify anyone have no key
+func matchKey(detailType, value string) (string, string) {
+	switch strings.ToLower(strings.TrimSpace(detailType)) {
+	case "email":
+		if email := helper.NormalizeEmail(value); strings.Contains(email, "@") {
+			return MatchEmail, email
+		}
+	case "phone", "mobile", "tel":
+		if phone := helper.NormalizePhone(value); len(phone) >= 7 {
+			return MatchPhone, phone
+		}
+	}
+	return "", ""
+}
+
+func valueKey(detailType, value string) string {
+	detailType = strings.ToLower(strings.TrimSpace(detailType))
+	return detailType + "\x00" + helper.NormalizeDetailValue(detailType, value)
+}
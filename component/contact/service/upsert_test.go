@@ -0,0 +1,71 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/db/dbtest"
+	"net/http"
+	"testing"
+)
+
+// Each step upserts into the same address book, so later steps match what
+// earlier ones stored.
+func TestUpsertContact(t *testing.T) {
+	conn := dbtest.Open(t)
+	owner := dbtest.User(t, conn, "owner@example.com")
+	other := dbtest.User(t, conn, "other@example.com")
+	stranger := dbtest.Contact(t, conn, other, nil, "Sam", "Stone")
+	dbtest.Detail(t, conn, stranger, "email", "sam@example.com")
+
+	svc := NewContactService()
+	email := func(value string) DetailInput { return DetailInput{Type: "email", Value: value} }
+	phone := func(value string) DetailInput { return DetailInput{Type: "phone", Value: value} }
+
+	steps := []struct {
+		name          string
+		input         ContactInput
+		policy        string
+		wantOutcome   string
+		wantMatchedBy string
+		wantLName     string
+		wantJobTitle  string
+		wantDetails   int
+	}{
+		{"new external id creates", ContactInput{FName: "Ada", LName: "King", ExternalID: "crm-1", Source: "crm", Details: []DetailInput{email("ada@example.com")}},
+			ConflictKeep, OutcomeCreated, "", "King", "", 1},
+		{"keep leaves a match alone", ContactInput{FName: "Ada", LName: "Lovelace", ExternalID: "crm-1", Source: "crm"},
+			ConflictKeep, OutcomeUnchanged, MatchExternalID, "King", "", 1},
+		{"overwrite replaces the match's fields", ContactInput{FName: "Ada", LName: "Lovelace", ExternalID: "crm-1", Source: "crm", Details: []DetailInput{email("ada@example.com")}},
+			ConflictOverwrite, OutcomeUpdated, MatchExternalID, "Lovelace", "", 1},
+		{"merge by email fills gaps and adds details", ContactInput{FName: "A", LName: "L", JobTitle: "Analyst", Details: []DetailInput{email("ADA@Example.com"), phone("+1 555 0100")}},
+			ConflictMerge, OutcomeUpdated, MatchEmail, "Lovelace", "Analyst", 2},
+		{"merge keeps fields already set", ContactInput{FName: "A", LName: "L", JobTitle: "Engineer", Details: []DetailInput{phone("+1 555 0100")}},
+			ConflictMerge, OutcomeUnchanged, MatchPhone, "Lovelace", "Analyst", 2},
+		{"another record of the same source is not matched by email", ContactInput{FName: "Ada", LName: "Twin", ExternalID: "crm-2", Source: "crm", Details: []DetailInput{email("ada@example.com")}},
+			ConflictMerge, OutcomeCreated, "", "Twin", "", 1},
+		{"another user's contacts are never matched", ContactInput{FName: "Sam", LName: "Mine", Details: []DetailInput{email("sam@example.com")}},
+			ConflictOverwrite, OutcomeCreated, "", "Mine", "", 1},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			result, err := svc.UpsertContact(owner.UserID, step.input, step.policy)
+			if err != nil {
+				t.Fatalf("UpsertContact() error = %v", err)
+			}
+			if result.Outcome != step.wantOutcome || result.MatchedBy != step.wantMatchedBy {
+				t.Errorf("outcome = %q matched by %q, want %q matched by %q", result.Outcome, result.MatchedBy, step.wantOutcome, step.wantMatchedBy)
+			}
+			c := result.Contact
+			if c.UserID != owner.UserID || c.LName != step.wantLName || c.JobTitle != step.wantJobTitle {
+				t.Errorf("contact = owner %d, %q, job %q; want owner %d, %q, job %q", c.UserID, c.LName, c.JobTitle, owner.UserID, step.wantLName, step.wantJobTitle)
+			}
+			if len(c.Details) != step.wantDetails {
+				t.Errorf("%d details, want %d", len(c.Details), step.wantDetails)
+			}
+		})
+	}
+
+	_, err := svc.UpsertContact(owner.UserID, ContactInput{FName: "Ada", LName: "King"}, "replace")
+	if appErr, ok := err.(apperror.AppError); !ok || appErr.StatusCode() != http.StatusBadRequest {
+		t.Errorf("unknown policy error = %v, want bad request", err)
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"Contact_App/component/auth"
 
 	"Contact_App/component/contact_detail/service"
+	orgService "Contact_App/component/organization/service"
 	"Contact_App/models/contact_detail"
+	"Contact_App/models/organization"
 	"Contact_App/repository"
 	"Contact_App/web"
 	"context"
@@ -17,6 +19,22 @@ import (
 	"gorm.io/gorm"
 )
 
+// detailResource whitelists the fields clients may sort, select and filter contact details on
+var detailResource = web.ResourceSpec{
+	PrimaryKey: "contact_details_id",
+	Fields: map[string]web.FieldSpec{
+		"contact_details_id": {JSONName: "contact_details_id", Column: "contact_details_id", Type: web.FieldNumber},
+		"id":                 {JSONName: "contact_details_id", Column: "contact_details_id", Type: web.FieldNumber},
+		"contact_id":         {JSONName: "contact_id", Column: "contact_id", Type: web.FieldNumber},
+		"user_id":            {JSONName: "user_id", Column: "user_id", Type: web.FieldNumber},
+		"type":               {JSONName: "type", Column: "type", Type: web.FieldString},
+		"value":              {JSONName: "value", Column: "value", Type: web.FieldString},
+		"is_active":          {JSONName: "is_active", Column: "is_active", Type: web.FieldBool},
+		"created_at":         {JSONName: "created_at", Column: "created_at", Type: web.FieldTime},
+		"updated_at":         {JSONName: "updated_at", Column: "updated_at", Type: web.FieldTime},
+	},
+}
+
 type ContactDetailHandler struct {
 	DB *gorm.DB
 }
@@ -59,7 +77,17 @@ func (h *ContactDetailHandler) AddContactDetail(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	web.RespondJSON(w, http.StatusCreated, detail)
+	// a new email may point at one of the user's organizations; suggest it
+	// when the contact is not linked to one yet
+	var suggested *organization.Organization
+	if detail.Type == "email" {
+		suggested, _ = orgService.NewOrganizationService().SuggestForContact(uint(userID), uint(contactID), detail.Value)
+	}
+
+	web.RespondJSON(w, http.StatusCreated, struct {
+		*contact_detail.ContactDetail
+		SuggestedOrganization *organization.Organization `json:"suggested_organization,omitempty"`
+	}{detail, suggested})
 }
 
 func (h *ContactDetailHandler) UpdateContactDetail(w http.ResponseWriter, r *http.Request) {
@@ -84,21 +112,68 @@ func (h *ContactDetailHandler) UpdateContactDetail(w http.ResponseWriter, r *htt
 		Phone: req.Phone,
 	}
 
-	if err := service.UpdateDetailByID(h.DB, userID, contactID, detailID, input); err != nil {
+	ifMatch, err := web.IfMatchVersion(r)
+	if err != nil {
 		web.RespondError(w, err)
 		return
 	}
 
+	version, err := service.UpdateDetailByID(h.DB, userID, contactID, detailID, input, ifMatch)
+	if err != nil {
+		web.RespondError(w, err)
+		return
+	}
+
+	web.SetETag(w, version)
 	web.RespondJSON(w, http.StatusOK, map[string]string{"message": "Contact detail updated successfully"})
 }
 
+// PatchContactDetail accepts application/merge-patch+json or application/json-patch+json
+func (h *ContactDetailHandler) PatchContactDetail(w http.ResponseWriter, r *http.Request) {
+	userID, err := extractUserID(r.Context())
+	if err != nil {
+		web.RespondError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	contactID, _ := strconv.Atoi(vars["contact_id"])
+	detailID, _ := strconv.Atoi(vars["detail_id"])
+
+	ifMatch, err := web.IfMatchVersion(r)
+	if err != nil {
+		web.RespondError(w, err)
+		return
+	}
+	body, err := web.ReadPatchBody(w, r)
+	if err != nil {
+		web.RespondError(w, err)
+		return
+	}
+
+	detail, err := service.PatchDetailByID(h.DB, userID, contactID, detailID, r.Header.Get("Content-Type"), body, ifMatch)
+	if err != nil {
+		web.RespondError(w, err)
+		return
+	}
+
+	web.SetETag(w, detail.Version)
+	web.RespondJSON(w, http.StatusOK, detail)
+}
+
 func (h *ContactDetailHandler) DeleteContactDetail(w http.ResponseWriter, r *http.Request) {
 	userID, _ := extractUserID(r.Context())
 	vars := mux.Vars(r)
 	contactID, _ := strconv.Atoi(vars["contact_id"])
 	detailID, _ := strconv.Atoi(vars["detail_id"])
 
-	if err := service.DeleteDetailByID(h.DB, userID, contactID, detailID); err != nil {
+	ifMatch, err := web.IfMatchVersion(r)
+	if err != nil {
+		web.RespondError(w, err)
+		return
+	}
+
+	if err := service.DeleteDetailByID(h.DB, userID, contactID, detailID, ifMatch); err != nil {
 		web.RespondError(w, err)
 		return
 	}
@@ -113,11 +188,20 @@ func (h *ContactDetailHandler) GetContactDetails(w http.ResponseWriter, r *http.
 	detailType := r.URL.Query().Get("type")
 	value := r.URL.Query().Get("value")
 
-	uow := repository.NewUnitOfWork(h.DB, true)
+	spec, err := web.ParseQuerySpec(r.URL.Query(), detailResource)
+	if err != nil {
+		web.RespondError(w, err)
+		return
+	}
+
+	uow, err := service.OpenContact(h.DB, userID, contactID)
+	if err != nil {
+		web.RespondError(w, err)
+		return
+	}
 	defer uow.Rollback()
 
 	baseQuery := uow.DB.Model(&contact_detail.ContactDetail{}).
-		Where("user_id = ?", uint(userID)).
 		Where("contact_id = ?", uint(contactID))
 
 	if strings.TrimSpace(detailType) != "" {
@@ -127,8 +211,15 @@ func (h *ContactDetailHandler) GetContactDetails(w http.ResponseWriter, r *http.
 		baseQuery = baseQuery.Where("value LIKE ?", "%"+value+"%")
 	}
 
+	for _, process := range spec.FilterProcessors() {
+		if baseQuery, err = process(baseQuery, &contact_detail.ContactDetail{}); err != nil {
+			web.RespondError(w, apperror.NewValidationError("query", err.Error()))
+			return
+		}
+	}
+
 	var details []*contact_detail.ContactDetail
-	web.Paginate(w, r, uow.DB, &details, baseQuery)
+	web.Paginate(w, r, &details, baseQuery, spec)
 }
 
 func (h *ContactDetailHandler) GetContactDetailByID(w http.ResponseWriter, r *http.Request) {
@@ -138,18 +229,20 @@ func (h *ContactDetailHandler) GetContactDetailByID(w http.ResponseWriter, r *ht
 	detailID, _ := strconv.Atoi(vars["detail_id"])
 
 	detailRepo := repository.NewGormRepository()
-	uow := repository.NewUnitOfWork(h.DB, true)
+	uow, err := service.OpenContact(h.DB, userID, contactID)
+	if err != nil {
+		web.RespondError(w, err)
+		return
+	}
 	defer uow.Rollback()
 
 	var detail contact_detail.ContactDetail
 	filters := []repository.QueryProcessor{
-		repository.Filter("user_id = ?", uint(userID)),
 		repository.Filter("contact_id = ?", uint(contactID)),
 		repository.Filter("contact_details_id = ?", uint(detailID)),
 	}
 
-	err := detailRepo.GetAll(uow, &detail, filters...)
-	if err != nil {
+	if err := detailRepo.GetAll(uow, &detail, filters...); err != nil {
 		web.RespondError(w, err)
 		return
 	}
@@ -159,6 +252,9 @@ func (h *ContactDetailHandler) GetContactDetailByID(w http.ResponseWriter, r *ht
 		return
 	}
 
+	if web.NotModified(w, r, detail.Version) {
+		return
+	}
 	web.RespondJSON(w, http.StatusOK, detail)
 }
 
@@ -167,5 +263,6 @@ func (h *ContactDetailHandler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/users/{user_id}/contacts/{contact_id}/details", h.AddContactDetail).Methods("POST")
 	router.HandleFunc("/users/{user_id}/contacts/{contact_id}/details/{detail_id}", h.GetContactDetailByID).Methods("GET")
 	router.HandleFunc("/users/{user_id}/contacts/{contact_id}/details/{detail_id}", h.UpdateContactDetail).Methods("PUT")
+	router.HandleFunc("/users/{user_id}/contacts/{contact_id}/details/{detail_id}", h.PatchContactDetail).Methods("PATCH")
 	router.HandleFunc("/users/{user_id}/contacts/{contact_id}/details/{detail_id}", h.DeleteContactDetail).Methods("DELETE")
 }
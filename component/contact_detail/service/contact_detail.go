@@ -2,11 +2,18 @@ package service
 
 import (
 	"Contact_App/apperror"
+	history "Contact_App/component/history/service"
+	shareService "Contact_App/component/share/service"
+	workspaceService "Contact_App/component/workspace/service"
 	"Contact_App/models/contact_detail"
+	"Contact_App/models/contact_version"
+	"Contact_App/patch"
 	"Contact_App/repository"
+	"Contact_App/search"
 	"strings"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var contactDetailRepo = repository.NewGormRepository()
@@ -17,37 +24,80 @@ type UpdateDetailInput struct {
 }
 
 func AddDetailToContact(db *gorm.DB, userID, contactID int, detailType, value string) (*contact_detail.ContactDetail, error) {
+	uow, err := scopedUnitOfWork(db, userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	detail, err := AddDetailWithUOW(uow, userID, contactID, detailType, value)
+	if err != nil {
+		return nil, err
+	}
+
+	uow.Commit()
+	return detail, nil
+}
+
+// AddDetailWithUOW adds a detail to a contact the user may change using the
+// provided transaction. The detail belongs to the contact's owner.
+func AddDetailWithUOW(uow *repository.UnitOfWork, userID, contactID int, detailType, value string) (*contact_detail.ContactDetail, error) {
 	if strings.TrimSpace(detailType) == "" || strings.TrimSpace(value) == "" {
 		return nil, apperror.NewValidationError("type/value", "type and value cannot be empty")
 	}
+	ownerID, err := contactOwner(uow, userID, contactID)
+	if err != nil {
+		return nil, err
+	}
 
 	detail := &contact_detail.ContactDetail{
-		UserID:    uint(userID),
+		UserID:    ownerID,
 		ContactID: uint(contactID),
 		Type:      strings.ToLower(detailType),
 		Value:     value,
 	}
 
-	if err := db.Create(detail).Error; err != nil {
+	if err := uow.DB.Create(detail).Error; err != nil {
 		return nil, apperror.NewInternalError("failed to save detail to database")
 	}
+	if err := history.BumpVersions(uow, uint(contactID)); err != nil {
+		return nil, err
+	}
+	if err := history.RecordVersion(uow, ownerID, uint(contactID), uint(userID), contact_version.ActionUpdate); err != nil {
+		return nil, err
+	}
+	search.InvalidateOnCommit(uow, ownerID)
 
 	return detail, nil
 }
 
-func UpdateDetailByID(db *gorm.DB, userID, contactID, detailID int, input UpdateDetailInput) error {
-	uow := repository.NewUnitOfWork(db, false)
+// UpdateDetailByID rewrites a detail's type and value. A non-zero ifMatch must
+// equal the detail's current version or the update is rejected.
+func UpdateDetailByID(db *gorm.DB, userID, contactID, detailID int, input UpdateDetailInput, ifMatch uint) (uint, error) {
+	uow, err := scopedUnitOfWork(db, userID, false)
+	if err != nil {
+		return 0, err
+	}
 	defer uow.Rollback()
 
-	var details []*contact_detail.ContactDetail
-	err := contactDetailRepo.GetAll(uow, &details,
-		repository.Filter("contact_details_id = ? AND contact_id = ? AND user_id = ?", detailID, contactID, userID),
-	)
+	version, err := UpdateDetailWithUOW(uow, userID, contactID, detailID, input, ifMatch)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	if len(details) == 0 {
-		return apperror.NewNotFoundError("contact_detail", detailID)
+
+	uow.Commit()
+	return version, nil
+}
+
+// UpdateDetailWithUOW updates a detail using the provided transaction and returns its new version
+func UpdateDetailWithUOW(uow *repository.UnitOfWork, userID, contactID, detailID int, input UpdateDetailInput, ifMatch uint) (uint, error) {
+	ownerID, err := contactOwner(uow, userID, contactID)
+	if err != nil {
+		return 0, err
+	}
+	detail, err := lockDetail(uow, contactID, detailID, ifMatch)
+	if err != nil {
+		return 0, err
 	}
 
 	updates := make(map[string]interface{})
@@ -59,16 +109,39 @@ func UpdateDetailByID(db *gorm.DB, userID, contactID, detailID int, input Update
 		updates["type"] = "phone"
 		updates["value"] = strings.TrimSpace(input.Phone)
 	} else {
-		return apperror.NewValidationError("body", "must contain either 'email' or 'phone'")
+		return 0, apperror.NewValidationError("body", "must contain either 'email' or 'phone'")
 	}
 
 	if updates["value"] == "" {
-		return apperror.NewValidationError("value", "cannot be empty")
+		return 0, apperror.NewValidationError("value", "cannot be empty")
 	}
+	updates["version"] = gorm.Expr("version + 1")
 
 	if err := contactDetailRepo.UpdateWithMap(uow, &contact_detail.ContactDetail{}, updates,
-		repository.Filter("contact_details_id = ? AND contact_id = ? AND user_id = ?", detailID, contactID, userID),
+		repository.Filter("contact_details_id = ? AND contact_id = ?", detailID, contactID),
 	); err != nil {
+		return 0, err
+	}
+	if err := history.BumpVersions(uow, uint(contactID)); err != nil {
+		return 0, err
+	}
+	if err := history.RecordVersion(uow, ownerID, uint(contactID), uint(userID), contact_version.ActionUpdate); err != nil {
+		return 0, err
+	}
+	search.InvalidateOnCommit(uow, ownerID)
+
+	return detail.Version + 1, nil
+}
+
+// DeleteDetailByID soft deletes a detail, honouring ifMatch like UpdateDetailByID
+func DeleteDetailByID(db *gorm.DB, userID, contactID, detailID int, ifMatch uint) error {
+	uow, err := scopedUnitOfWork(db, userID, false)
+	if err != nil {
+		return err
+	}
+	defer uow.Rollback()
+
+	if err := DeleteDetailWithUOW(uow, userID, contactID, detailID, ifMatch); err != nil {
 		return err
 	}
 
@@ -76,23 +149,17 @@ func UpdateDetailByID(db *gorm.DB, userID, contactID, detailID int, input Update
 	return nil
 }
 
-func DeleteDetailByID(db *gorm.DB, userID, contactID, detailID int) error {
-	uow := repository.NewUnitOfWork(db, false)
-	defer uow.Rollback()
-
-	var details []*contact_detail.ContactDetail
-	err := contactDetailRepo.GetAll(uow, &details,
-		repository.Filter("contact_details_id = ? AND contact_id = ? AND user_id = ?", detailID, contactID, userID),
-	)
+// DeleteDetailWithUOW soft deletes a detail using the provided transaction
+func DeleteDetailWithUOW(uow *repository.UnitOfWork, userID, contactID, detailID int, ifMatch uint) error {
+	ownerID, err := contactOwner(uow, userID, contactID)
 	if err != nil {
 		return err
 	}
-	if len(details) == 0 {
-		return apperror.NewNotFoundError("contact_detail", detailID)
+	detail, err := lockDetail(uow, contactID, detailID, ifMatch)
+	if err != nil {
+		return err
 	}
 
-	detail := details[0]
-
 	// Step 1: mark is_active = false
 	if err := uow.DB.Model(detail).Update("is_active", false).Error; err != nil {
 		return apperror.NewInternalError("failed to set is_active=false")
@@ -102,17 +169,25 @@ func DeleteDetailByID(db *gorm.DB, userID, contactID, detailID int) error {
 	if err := uow.DB.Delete(detail).Error; err != nil {
 		return apperror.NewInternalError("failed to soft delete contact detail")
 	}
+	if err := history.BumpVersions(uow, uint(contactID)); err != nil {
+		return err
+	}
+	if err := history.RecordVersion(uow, ownerID, uint(contactID), uint(userID), contact_version.ActionUpdate); err != nil {
+		return err
+	}
+	search.InvalidateOnCommit(uow, ownerID)
 
-	uow.Commit()
 	return nil
 }
 
 func GetContactDetails(db *gorm.DB, userID, contactID int, detailType, value string) ([]*contact_detail.ContactDetail, error) {
-	uow := repository.NewUnitOfWork(db, true)
+	uow, err := OpenContact(db, userID, contactID)
+	if err != nil {
+		return nil, err
+	}
 	defer uow.Rollback()
 
 	filters := []repository.QueryProcessor{
-		repository.Filter("user_id = ?", userID),
 		repository.Filter("contact_id = ?", contactID),
 	}
 
@@ -132,12 +207,15 @@ func GetContactDetails(db *gorm.DB, userID, contactID int, detailType, value str
 }
 
 func GetContactDetailByID(db *gorm.DB, userID, contactID, detailID int) (*contact_detail.ContactDetail, error) {
-	uow := repository.NewUnitOfWork(db, true)
+	uow, err := OpenContact(db, userID, contactID)
+	if err != nil {
+		return nil, err
+	}
 	defer uow.Rollback()
 
 	var details []*contact_detail.ContactDetail
 	if err := contactDetailRepo.GetAll(uow, &details,
-		repository.Filter("contact_details_id = ? AND contact_id = ? AND user_id = ?", detailID, contactID, userID),
+		repository.Filter("contact_details_id = ? AND contact_id = ?", detailID, contactID),
 	); err != nil {
 		return nil, err
 	}
@@ -148,3 +226,122 @@ func GetContactDetailByID(db *gorm.DB, userID, contactID, detailID int) (*contac
 
 	return details[0], nil
 }
+
+// scopedUnitOfWork opens a unit of work on db limited to the user's tenant
+func scopedUnitOfWork(db *gorm.DB, userID int, readonly bool) (*repository.UnitOfWork, error) {
+	tenant, err := workspaceService.TenantFor(db, uint(userID))
+	if err != nil {
+		return nil, err
+	}
+	return repository.NewUnitOfWork(db, readonly).ScopeTo(tenant), nil
+}
+
+// OpenContact opens a read-only unit of work limited to the user's tenant
+// after checking that the user can see the contact
+func OpenContact(db *gorm.DB, userID, contactID int) (*repository.UnitOfWork, error) {
+	uow, err := scopedUnitOfWork(db, userID, true)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := shareService.ResolveContact(uow.DB, uint(userID), uint(contactID), false); err != nil {
+		uow.Rollback()
+		return nil, err
+	}
+	return uow, nil
+}
+
+// contactOwner returns the owner of a contact the user may change, whose
+// details are stored under the owner
+func contactOwner(uow *repository.UnitOfWork, userID, contactID int) (uint, error) {
+	c, err := shareService.ResolveContact(uow.DB, uint(userID), uint(contactID), true)
+	if err != nil {
+		return 0, err
+	}
+	return c.UserID, nil
+}
+
+// lockDetail loads a live detail FOR UPDATE and checks it against the version
+// the client last saw
+func lockDetail(uow *repository.UnitOfWork, contactID, detailID int, ifMatch uint) (*contact_detail.ContactDetail, error) {
+	var detail contact_detail.ContactDetail
+	if err := uow.DB.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("contact_details_id = ? AND contact_id = ?", detailID, contactID).
+		First(&detail).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewNotFoundError("contact_detail", detailID)
+		}
+		return nil, apperror.NewInternalError("failed to load contact detail")
+	}
+	if ifMatch != 0 && detail.Version != ifMatch {
+		return nil, apperror.NewPreconditionFailedError("contact_detail", detail.Version)
+	}
+	return &detail, nil
+}
+
+var detailPatchSchema = patch.Schema{
+	"type":  {Kind: patch.KindString, Required: true, MaxLength: 255},
+	"value": {Kind: patch.KindString, Required: true, MaxLength: 255},
+}
+
+// PatchDetailByID applies a merge patch or JSON Patch to a detail's type and value
+func PatchDetailByID(db *gorm.DB, userID, contactID, detailID int, contentType string, body []byte, ifMatch uint) (*contact_detail.ContactDetail, error) {
+	uow, err := scopedUnitOfWork(db, userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	ownerID, err := contactOwner(uow, userID, contactID)
+	if err != nil {
+		return nil, err
+	}
+	detail, err := lockDetail(uow, contactID, detailID, ifMatch)
+	if err != nil {
+		return nil, err
+	}
+
+	current := map[string]string{"type": detail.Type, "value": detail.Value}
+	patched, err := patch.Apply(contentType, current, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := detailPatchSchema.Validate(patched); err != nil {
+		return nil, err
+	}
+	var target struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	}
+	if err := patch.Decode(patched, &target); err != nil {
+		return nil, err
+	}
+
+	detailType := strings.ToLower(strings.TrimSpace(target.Type))
+	value := strings.TrimSpace(target.Value)
+	if detailType == detail.Type && value == detail.Value {
+		uow.Commit()
+		return detail, nil
+	}
+
+	if err := contactDetailRepo.UpdateWithMap(uow, &contact_detail.ContactDetail{}, map[string]interface{}{
+		"type":    detailType,
+		"value":   value,
+		"version": gorm.Expr("version + 1"),
+	}, repository.Filter("contact_details_id = ?", detail.ContactDetailsID)); err != nil {
+		return nil, err
+	}
+	if err := history.BumpVersions(uow, uint(contactID)); err != nil {
+		return nil, err
+	}
+	if err := history.RecordVersion(uow, ownerID, uint(contactID), uint(userID), contact_version.ActionUpdate); err != nil {
+		return nil, err
+	}
+	search.InvalidateOnCommit(uow, ownerID)
+
+	detail.Type = detailType
+	detail.Value = value
+	detail.Version++
+
+	uow.Commit()
+	return detail, nil
+}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/component/auth"
+	"Contact_App/component/custom_field/service"
+	"Contact_App/web"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type CustomFieldController struct {
+	Service *service.CustomFieldService
+}
+
+func NewCustomFieldController(svc *service.CustomFieldService) *CustomFieldController {
+	return &CustomFieldController{Service: svc}
+}
+
+// GET /users/{userID}/custom-fields
+func (c *CustomFieldController) ListFieldsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	fields, err := c.Service.ListFields(userID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, fields)
+}
+
+// POST /users/{userID}/custom-fields
+func (c *CustomFieldController) CreateFieldHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	var input service.FieldInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	field, err := c.Service.CreateField(userID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusCreated, field)
+}
+
+// GET /users/{userID}/custom-fields/{fieldID}
+func (c *CustomFieldController) GetFieldHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	fieldID, ok := web.ParseID(w, r, "fieldID")
+	if !ok {
+		return
+	}
+
+	field, err := c.Service.GetField(userID, fieldID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, field)
+}
+
+// PUT /users/{userID}/custom-fields/{fieldID}
+func (c *CustomFieldController) UpdateFieldHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	fieldID, ok := web.ParseID(w, r, "fieldID")
+	if !ok {
+		return
+	}
+
+	var input service.FieldInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	field, err := c.Service.UpdateField(userID, fieldID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, field)
+}
+
+// DELETE /users/{userID}/custom-fields/{fieldID}
+// Every contact's value for the field is deleted with it
+func (c *CustomFieldController) DeleteFieldHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	fieldID, ok := web.ParseID(w, r, "fieldID")
+	if !ok {
+		return
+	}
+
+	if err := c.Service.DeleteField(userID, fieldID); err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, map[string]string{"message": "custom field deleted"})
+}
+
+func (c *CustomFieldController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/users/{userID}/custom-fields", c.ListFieldsHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/custom-fields", c.CreateFieldHandler).Methods("POST")
+	router.HandleFunc("/users/{userID}/custom-fields/{fieldID:[0-9]+}", c.GetFieldHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/custom-fields/{fieldID:[0-9]+}", c.UpdateFieldHandler).Methods("PUT")
+	router.HandleFunc("/users/{userID}/custom-fields/{fieldID:[0-9]+}", c.DeleteFieldHandler).Methods("DELETE")
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	history "Contact_App/component/history/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/db"
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_version"
+	"Contact_App/models/custom_field"
+	"Contact_App/repository"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var keyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+var types = map[string]bool{
+	custom_field.TypeText:    true,
+	custom_field.TypeNumber:  true,
+	custom_field.TypeDate:    true,
+	custom_field.TypeBoolean: true,
+	custom_field.TypeEnum:    true,
+}
+
+// FieldInput is the body accepted when creating or replacing a custom field.
+// Key and type cannot change once the field exists.
+type FieldInput struct {
+	Key     string   `json:"key"`
+	Label   string   `json:"label"`
+	Type    string   `json:"type"`
+	Options []string `json:"options"`
+}
+
+type CustomFieldService struct {
+	repo repository.Repository
+}
+
+func NewCustomFieldService() *CustomFieldService {
+	return &CustomFieldService{repo: repository.NewGormRepository()}
+}
+
+func (s *CustomFieldService) ListFields(userID uint) ([]*custom_field.CustomFieldDefinition, error) {
+	return LoadDefinitions(db.GetDB(), userID)
+}
+
+func (s *CustomFieldService) GetField(userID, fieldID uint) (*custom_field.CustomFieldDefinition, error) {
+	uow := repository.NewUnitOfWork(db.GetDB(), true)
+	return findField(uow, userID, fieldID)
+}
+
+func (s *CustomFieldService) CreateField(userID uint, input FieldInput) (*custom_field.CustomFieldDefinition, error) {
+	uow := repository.NewUnitOfWork(db.GetDB(), false)
+	defer uow.Rollback()
+
+	key := strings.ToLower(strings.TrimSpace(input.Key))
+	if !keyPattern.MatchString(key) {
+		return nil, apperror.NewValidationError("key", "must start with a letter and contain only a-z, 0-9 and _ (at most 64 characters)")
+	}
+	fieldType := strings.ToLower(strings.TrimSpace(input.Type))
+	if !types[fieldType] {
+		return nil, apperror.NewValidationError("type", "must be text, number, date, boolean or enum")
+	}
+
+	field := &custom_field.CustomFieldDefinition{UserID: userID, Key: key, Type: fieldType}
+	if err := applyInput(field, input); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := uow.DB.Model(&custom_field.CustomFieldDefinition{}).
+		Where("user_id = ? AND field_key = ?", userID, key).
+		Count(&count).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to check custom field key")
+	}
+	if count > 0 {
+		return nil, apperror.NewConflictError("custom field", "a custom field with this key already exists")
+	}
+
+	if err := s.repo.Add(uow, field); err != nil {
+		return nil, err
+	}
+
+	uow.Commit()
+	return field, nil
+}
+
+// UpdateField replaces a field's label and, for enums, its options. Options
+// still used by a contact cannot be removed.
+func (s *CustomFieldService) UpdateField(userID, fieldID uint, input FieldInput) (*custom_field.CustomFieldDefinition, error) {
+	uow := repository.NewUnitOfWork(db.GetDB(), false)
+	defer uow.Rollback()
+
+	field, err := findField(uow, userID, fieldID)
+	if err != nil {
+		return nil, err
+	}
+	if key := strings.ToLower(strings.TrimSpace(input.Key)); key != "" && key != field.Key {
+		return nil, apperror.NewValidationError("key", "cannot be changed")
+	}
+	if fieldType := strings.ToLower(strings.TrimSpace(input.Type)); fieldType != "" && fieldType != field.Type {
+		return nil, apperror.NewValidationError("type", "cannot be changed")
+	}
+
+	previous := field.Options
+	if err := applyInput(field, input); err != nil {
+		return nil, err
+	}
+
+	if field.Type == custom_field.TypeEnum {
+		kept := make(map[string]bool, len(field.Options))
+		for _, o := range field.Options {
+			kept[o] = true
+		}
+		for _, o := range previous {
+			if kept[o] {
+				continue
+			}
+			var used int64
+			if err := uow.DB.Model(&custom_field.CustomFieldValue{}).
+				Where("field_id = ? AND value = ?", field.FieldID, o).
+				Count(&used).Error; err != nil {
+				return nil, apperror.NewInternalError("failed to check custom field values")
+			}
+			if used > 0 {
+				return nil, apperror.NewConflictError("custom field", fmt.Sprintf("option %q is still used by %d contact(s)", o, used))
+			}
+		}
+	}
+
+	if err := uow.DB.Select("label", "options").Save(field).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to update custom field")
+	}
+
+	uow.Commit()
+	return field, nil
+}
+
+// DeleteField removes a field together with every contact's value for it
+func (s *CustomFieldService) DeleteField(userID, fieldID uint) error {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return err
+	}
+	defer uow.Rollback()
+
+	field, err := findField(uow, userID, fieldID)
+	if err != nil {
+		return err
+	}
+
+	var contactIDs []uint
+	if err := uow.DB.Model(&contact.Contact{}).
+		Where("user_id = ? AND contact_id IN (?)", userID,
+			uow.DB.Model(&custom_field.CustomFieldValue{}).Select("contact_id").Where("field_id = ?", field.FieldID)).
+		Pluck("contact_id", &contactIDs).Error; err != nil {
+		return apperror.NewInternalError("failed to load custom field contacts")
+	}
+
+	if err := uow.DB.Where("field_id = ?", field.FieldID).Delete(&custom_field.CustomFieldValue{}).Error; err != nil {
+		return apperror.NewInternalError("failed to delete custom field values")
+	}
+	if err := uow.DB.Delete(field).Error; err != nil {
+		return apperror.NewInternalError("failed to delete custom field")
+	}
+	if err := history.BumpVersions(uow, contactIDs...); err != nil {
+		return err
+	}
+	for _, id := range contactIDs {
+		if err := history.RecordVersion(uow, userID, id, userID, contact_version.ActionUpdate); err != nil {
+			return err
+		}
+	}
+
+	uow.Commit()
+	return nil
+}
+
+func applyInput(field *custom_field.CustomFieldDefinition, input FieldInput) error {
+	label := strings.TrimSpace(input.Label)
+	if label == "" {
+		label = field.Key
+	}
+	if len([]rune(label)) > 255 {
+		return apperror.NewValidationError("label", "must be at most 255 characters")
+	}
+
+	var options []string
+	if field.Type == custom_field.TypeEnum {
+		seen := make(map[string]bool, len(input.Options))
+		for i, o := range input.Options {
+			o = strings.TrimSpace(o)
+			if o == "" || len([]rune(o)) > 255 {
+				return apperror.NewValidationError(fmt.Sprintf("options[%d]", i), "must be between 1 and 255 characters")
+			}
+			if seen[strings.ToLower(o)] {
+				return apperror.NewValidationError(fmt.Sprintf("options[%d]", i), "appears more than once")
+			}
+			seen[strings.ToLower(o)] = true
+			options = append(options, o)
+		}
+		if len(options) == 0 {
+			return apperror.NewValidationError("options", "enum fields need at least one option")
+		}
+	} else if len(input.Options) > 0 {
+		return apperror.NewValidationError("options", "are only allowed on enum fields")
+	}
+
+	field.Label = label
+	field.Options = options
+	return nil
+}
+
+func findField(uow *repository.UnitOfWork, userID, fieldID uint) (*custom_field.CustomFieldDefinition, error) {
+	var field custom_field.CustomFieldDefinition
+	if err := uow.DB.Where("field_id = ? AND user_id = ?", fieldID, userID).First(&field).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewNotFoundError("custom field", int(fieldID))
+		}
+		return nil, apperror.NewInternalError("failed to load custom field")
+	}
+	return &field, nil
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/models/contact"
+	"Contact_App/models/custom_field"
+	"Contact_App/repository"
+	"Contact_App/web"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LoadDefinitions returns the user's custom fields ordered by key
+func LoadDefinitions(conn *gorm.DB, userID uint) ([]*custom_field.CustomFieldDefinition, error) {
+	fields := []*custom_field.CustomFieldDefinition{}
+	if err := conn.Where("user_id = ?", userID).Order("field_key").Find(&fields).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load custom fields")
+	}
+	return fields, nil
+}
+
+// LoadValues returns each contact's custom field values keyed by field key,
+// typed as the field is (string, float64 or bool). Contacts without values are
+// absent from the map.
+func LoadValues(conn *gorm.DB, userID uint, contactIDs []uint) (map[uint]map[string]interface{}, error) {
+	values := make(map[uint]map[string]interface{})
+	if len(contactIDs) == 0 {
+		return values, nil
+	}
+
+	var rows []struct {
+		ContactID uint
+		FieldKey  string
+		Type      string
+		Value     string
+	}
+	if err := conn.Table("custom_field_values AS v").
+		Select("v.contact_id, d.field_key, d.type, v.value").
+		Joins("JOIN custom_field_definitions d ON d.field_id = v.field_id").
+		Where("v.user_id = ? AND v.contact_id IN ?", userID, contactIDs).
+		Scan(&rows).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load custom field values")
+	}
+	for _, row := range rows {
+		if values[row.ContactID] == nil {
+			values[row.ContactID] = make(map[string]interface{})
+		}
+		values[row.ContactID][row.FieldKey] = TypedValue(row.Type, row.Value)
+	}
+	return values, nil
+}
+
+// ApplyValues fills the custom_fields of each contact
+func ApplyValues(conn *gorm.DB, userID uint, contacts []*contact.Contact) error {
+	ids := make([]uint, len(contacts))
+	for i, c := range contacts {
+		ids[i] = c.ContactID
+	}
+	values, err := LoadValues(conn, userID, ids)
+	if err != nil {
+		return err
+	}
+	for _, c := range contacts {
+		c.CustomFields = values[c.ContactID]
+	}
+	return nil
+}
+
+// SetValuesWithUOW validates values against the user's custom fields and
+// stores them on the contact. A nil or empty value clears the field; fields
+// left out are not touched. It reports whether anything changed.
+func SetValuesWithUOW(uow *repository.UnitOfWork, userID, contactID uint, values map[string]interface{}) (bool, error) {
+	if len(values) == 0 {
+		return false, nil
+	}
+
+	fields, err := LoadDefinitions(uow.DB, userID)
+	if err != nil {
+		return false, err
+	}
+	byKey := make(map[string]*custom_field.CustomFieldDefinition, len(fields))
+	for _, f := range fields {
+		byKey[f.Key] = f
+	}
+
+	var existing []*custom_field.CustomFieldValue
+	if err := uow.DB.Where("contact_id = ? AND user_id = ?", contactID, userID).Find(&existing).Error; err != nil {
+		return false, apperror.NewInternalError("failed to load custom field values")
+	}
+	current := make(map[uint]*custom_field.CustomFieldValue, len(existing))
+	for _, v := range existing {
+		current[v.FieldID] = v
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	changed := false
+	for _, key := range keys {
+		field, ok := byKey[key]
+		if !ok {
+			return false, apperror.NewValidationError("custom_fields."+key, "is not a defined custom field")
+		}
+		have := current[field.FieldID]
+
+		text, number, err := Canonicalize(field, values[key])
+		if err != nil {
+			return false, err
+		}
+		if text == "" {
+			if have != nil {
+				if err := uow.DB.Delete(have).Error; err != nil {
+					return false, apperror.NewInternalError("failed to clear custom field value")
+				}
+				changed = true
+			}
+			continue
+		}
+		if have != nil {
+			if have.Value == text {
+				continue
+			}
+			if err := uow.DB.Model(have).Updates(map[string]interface{}{"value": text, "number_value": number}).Error; err != nil {
+				return false, apperror.NewInternalError("failed to update custom field value")
+			}
+		} else {
+			value := &custom_field.CustomFieldValue{UserID: userID, ContactID: contactID, FieldID: field.FieldID, Value: text, NumberValue: number}
+			if err := uow.DB.Create(value).Error; err != nil {
+				return false, apperror.NewInternalError("failed to save custom field value")
+			}
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// DeleteValuesWithUOW drops the custom field values of contacts being permanently deleted
+func DeleteValuesWithUOW(uow *repository.UnitOfWork, contactIDs []uint) error {
+	if err := uow.DB.Where("contact_id IN ?", contactIDs).Delete(&custom_field.CustomFieldValue{}).Error; err != nil {
+		return apperror.NewInternalError("failed to delete custom field values")
+	}
+	return nil
+}
+
+// Canonicalize checks raw against the field's type and returns the stored
+// text form, plus the number for number fields. nil and "" give "".
+func Canonicalize(field *custom_field.CustomFieldDefinition, raw interface{}) (string, *float64, error) {
+	name := "custom_fields." + field.Key
+	if raw == nil {
+		return "", nil, nil
+	}
+	if s, ok := raw.(string); ok {
+		if raw = strings.TrimSpace(s); raw == "" {
+			return "", nil, nil
+		}
+	}
+
+	switch field.Type {
+	case custom_field.TypeNumber:
+		var n float64
+		var err error
+		switch v := raw.(type) {
+		case float64:
+			n = v
+		case json.Number:
+			n, err = v.Float64()
+		case string:
+			n, err = strconv.ParseFloat(v, 64)
+		default:
+			err = fmt.Errorf("not a number")
+		}
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return "", nil, apperror.NewValidationError(name, "must be a number")
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), &n, nil
+	case custom_field.TypeBoolean:
+		switch v := raw.(type) {
+		case bool:
+			return strconv.FormatBool(v), nil, nil
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return strconv.FormatBool(b), nil, nil
+			}
+		}
+		return "", nil, apperror.NewValidationError(name, "must be a boolean")
+	case custom_field.TypeDate:
+		s, _ := raw.(string)
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return "", nil, apperror.NewValidationError(name, "must be a date (YYYY-MM-DD)")
+		}
+		return t.Format("2006-01-02"), nil, nil
+	case custom_field.TypeEnum:
+		s, _ := raw.(string)
+		for _, o := range field.Options {
+			if strings.EqualFold(o, s) {
+				return o, nil, nil
+			}
+		}
+		return "", nil, apperror.NewValidationError(name, "must be one of "+strings.Join(field.Options, ", "))
+	default:
+		s, ok := raw.(string)
+		if !ok {
+			return "", nil, apperror.NewValidationError(name, "must be a string")
+		}
+		if len([]rune(s)) > 1024 {
+			return "", nil, apperror.NewValidationError(name, "must be at most 1024 characters")
+		}
+		return s, nil, nil
+	}
+}
+
+// TypedValue converts a stored value back to its JSON type
+func TypedValue(fieldType, value string) interface{} {
+	switch fieldType {
+	case custom_field.TypeNumber:
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	case custom_field.TypeBoolean:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// QueryFields exposes every custom field to the listing query spec as
+// cf.<key>, filtering on the stored value through a correlated subquery.
+// The JSON name points into custom_fields so sorted pages get cursors.
+func QueryFields(fields []*custom_field.CustomFieldDefinition) map[string]web.FieldSpec {
+	specs := make(map[string]web.FieldSpec, len(fields))
+	for _, f := range fields {
+		column, fieldType := "v.value", web.FieldString
+		switch f.Type {
+		case custom_field.TypeNumber:
+			column, fieldType = "v.number_value", web.FieldNumber
+		case custom_field.TypeBoolean:
+			column, fieldType = "v.value = 'true'", web.FieldBool
+		}
+		specs["cf."+f.Key] = web.FieldSpec{
+			JSONName: "custom_fields." + f.Key,
+			Column: fmt.Sprintf("(SELECT %s FROM custom_field_values v WHERE v.contact_id = contacts.contact_id AND v.field_id = %d)",
+				column, f.FieldID),
+			Type: fieldType,
+		}
+	}
+	return specs
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/component/auth"
+	"Contact_App/component/duplicate/service"
+	"Contact_App/web"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type DuplicateController struct {
+	Service *service.DuplicateService
+}
+
+func NewDuplicateController(svc *service.DuplicateService) *DuplicateController {
+	return &DuplicateController{Service: svc}
+}
+
+// GET /users/{userID}/contacts/duplicates?threshold=0.6
+func (c *DuplicateController) GetDuplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	threshold := service.DefaultThreshold
+	if t := r.URL.Query().Get("threshold"); t != "" {
+		parsed, err := strconv.ParseFloat(t, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			apperror.HandleBadRequest(w, "threshold must be a number between 0 and 1")
+			return
+		}
+		threshold = parsed
+	}
+
+	candidates, err := c.Service.FindDuplicates(userID, threshold)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.RespondJSON(w, http.StatusOK, candidates)
+}
+
+// POST /users/{userID}/contacts/merge
+func (c *DuplicateController) MergeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		PrimaryID   uint   `json:"primary_id"`
+		SecondaryID uint   `json:"secondary_id"`
+		FName       string `json:"first_name"`
+		LName       string `json:"last_name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+	if input.PrimaryID == 0 || input.SecondaryID == 0 {
+		apperror.HandleBadRequest(w, "primary_id and secondary_id required")
+		return
+	}
+
+	record, err := c.Service.Merge(userID, input.PrimaryID, input.SecondaryID, input.FName, input.LName)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.RespondJSON(w, http.StatusOK, record)
+}
+
+// GET /users/{userID}/contacts/merges
+func (c *DuplicateController) GetMergesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	merges, err := c.Service.GetMerges(userID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.RespondJSON(w, http.StatusOK, merges)
+}
+
+// POST /users/{userID}/contacts/merges/{mergeID}/undo
+func (c *DuplicateController) UndoMergeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	mergeID64, err := strconv.ParseUint(mux.Vars(r)["mergeID"], 10, 64)
+	if err != nil {
+		apperror.HandleBadRequest(w, "invalid mergeID")
+		return
+	}
+
+	record, err := c.Service.UndoMerge(userID, uint(mergeID64))
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.RespondJSON(w, http.StatusOK, record)
+}
+
+func (c *DuplicateController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/users/{userID}/contacts/duplicates", c.GetDuplicatesHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/contacts/merge", c.MergeHandler).Methods("POST")
+	router.HandleFunc("/users/{userID}/contacts/merges", c.GetMergesHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/contacts/merges/{mergeID}/undo", c.UndoMergeHandler).Methods("POST")
+}
@@ -0,0 +1,538 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	history "Contact_App/component/history/service"
+	shareService "Contact_App/component/share/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/helper"
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_detail"
+	"Contact_App/models/contact_merge"
+	"Contact_App/models/contact_version"
+	"Contact_App/models/group"
+	"Contact_App/repository"
+	"Contact_App/search"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultThreshold      = 0.6
+	defaultRetentionHours = 720
+
+	nameWeight  = 0.7
+	emailWeight = 0.9
+	phoneWeight = 0.85
+)
+
+// Candidate is a pair of contacts that probably describe the same person
+type Candidate struct {
+	Contact   *contact.Contact `json:"contact"`
+	Duplicate *contact.Contact `json:"duplicate"`
+	Score     float64          `json:"score"`
+	Reasons   []string         `json:"reasons"`
+}
+
+type DuplicateService struct {
+	contactRepo repository.Repository
+	mergeRepo   repository.Repository
+}
+
+func NewDuplicateService() *DuplicateService {
+	return &DuplicateService{
+		contactRepo: repository.NewGormRepository(),
+		mergeRepo:   repository.NewGormRepository(),
+	}
+}
+
+// MergeRetention is how long a merge can still be undone, read from MERGE_RETENTION_HOURS
+func MergeRetention() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("MERGE_RETENTION_HOURS"))
+	if err != nil || hours <= 0 {
+		hours = defaultRetentionHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// FindDuplicates scores candidate pairs among the active contacts the user
+// can see and returns those at or above threshold, best matches first. Only
+// contacts with the same owner and workspace are paired, since only those can
+// be merged.
+func (s *DuplicateService) FindDuplicates(userID uint, threshold float64) ([]*Candidate, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var contacts []*contact.Contact
+	if err := uow.DB.Preload("Details", "is_active = ?", true).
+		Where(shareService.AccessibleContacts(uow.DB, userID)).
+		Where("is_active = ?", true).
+		Find(&contacts).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to fetch contacts")
+	}
+
+	candidates := []*Candidate{}
+	for _, candidate := range FindCandidates(contacts, threshold) {
+		if candidate.Contact.UserID == candidate.Duplicate.UserID && sameWorkspace(candidate.Contact, candidate.Duplicate) {
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates, nil
+}
+
+// FindCandidates compares contacts that share at least one blocking key
+// (email, phone or a name prefix) so large address books avoid a full
+// pairwise comparison
+func FindCandidates(contacts []*contact.Contact, threshold float64) []*Candidate {
+	blocks := make(map[string][]int)
+	for i, c := range contacts {
+		for _, key := range blockingKeys(c) {
+			blocks[key] = append(blocks[key], i)
+		}
+	}
+
+	seen := make(map[[2]int]bool)
+	var candidates []*Candidate
+	for _, members := range blocks {
+		for x := 0; x < len(members); x++ {
+			for y := x + 1; y < len(members); y++ {
+				pair := [2]int{members[x], members[y]}
+				if seen[pair] {
+					continue
+				}
+				seen[pair] = true
+
+				a, b := contacts[pair[0]], contacts[pair[1]]
+				score, reasons := ScoreContacts(a, b)
+				if score < threshold {
+					continue
+				}
+				candidates = append(candidates, &Candidate{Contact: a, Duplicate: b, Score: score, Reasons: reasons})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].Contact.ContactID < candidates[j].Contact.ContactID
+	})
+	return candidates
+}
+
+// ScoreContacts combines name similarity and shared emails/phones into a
+// probability-like score between 0 and 1
+func ScoreContacts(a, b *contact.Contact) (float64, []string) {
+	var reasons []string
+	miss := 1.0
+
+	nameSim := NameSimilarity(a, b)
+	if nameSim > 0.8 {
+		miss *= 1 - nameWeight*nameSim*nameSim
+		reasons = append(reasons, fmt.Sprintf("name similarity %.2f", nameSim))
+	}
+
+	emailsA, phonesA := contactKeys(a)
+	emailsB, phonesB := contactKeys(b)
+	for email := range emailsA {
+		if emailsB[email] {
+			miss *= 1 - emailWeight
+			reasons = append(reasons, "same email "+email)
+			break
+		}
+	}
+	for phone := range phonesA {
+		if phonesB[phone] {
+			miss *= 1 - phoneWeight
+			reasons = append(reasons, "same phone "+phone)
+			break
+		}
+	}
+
+	return round2(1 - miss), reasons
+}
+
+// NameSimilarity compares normalized full names, also trying the swapped
+// order to catch first/last name mix-ups
+func NameSimilarity(a, b *contact.Contact) float64 {
+	fa, la := helper.NormalizeName(a.FName), helper.NormalizeName(a.LName)
+	fb, lb := helper.NormalizeName(b.FName), helper.NormalizeName(b.LName)
+
+	direct := helper.JaroWinkler(fa+" "+la, fb+" "+lb)
+	swapped := helper.JaroWinkler(fa+" "+la, lb+" "+fb)
+	return max(direct, swapped)
+}
+
+// Merge folds the secondary contact into the primary one inside a single
+// UnitOfWork: details are moved (or dropped when the primary already has the
+// same normalized value), group memberships are carried over and the
+// secondary contact is soft deleted. A merge record is kept for undo. Both
+// contacts must be ones the user may change, with the same owner and in the
+// same workspace.
+func (s *DuplicateService) Merge(userID, primaryID, secondaryID uint, fname, lname string) (*contact_merge.ContactMerge, error) {
+	if primaryID == secondaryID {
+		return nil, apperror.NewValidationError("secondary_id", "cannot merge a contact into itself")
+	}
+
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	primary, err := loadContact(uow, userID, primaryID)
+	if err != nil {
+		return nil, err
+	}
+	secondary, err := loadContact(uow, userID, secondaryID)
+	if err != nil {
+		return nil, err
+	}
+	if primary.UserID != secondary.UserID {
+		return nil, apperror.NewValidationError("secondary_id", "contacts with different owners cannot be merged")
+	}
+	if !sameWorkspace(primary, secondary) {
+		return nil, apperror.NewValidationError("secondary_id", "contacts in different workspaces cannot be merged")
+	}
+	actorID := userID
+	userID = primary.UserID
+
+	snapshot := contact_merge.Snapshot{
+		PrimaryFName: primary.FName,
+		PrimaryLName: primary.LName,
+	}
+
+	nameUpdates := make(map[string]interface{})
+	if fname = strings.TrimSpace(fname); fname != "" && fname != primary.FName {
+		nameUpdates["f_name"] = fname
+	}
+	if lname = strings.TrimSpace(lname); lname != "" && lname != primary.LName {
+		nameUpdates["l_name"] = lname
+	}
+	if len(nameUpdates) > 0 {
+		if err := s.contactRepo.UpdateWithMap(uow, &contact.Contact{}, nameUpdates,
+			repository.Filter("contact_id = ? AND user_id = ?", primaryID, userID),
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	existing := make(map[string]bool)
+	for _, d := range primary.Details {
+		existing[detailKey(d)] = true
+	}
+	for _, d := range secondary.Details {
+		key := detailKey(d)
+		if existing[key] {
+			snapshot.DroppedDetailIDs = append(snapshot.DroppedDetailIDs, d.ContactDetailsID)
+			continue
+		}
+		existing[key] = true
+		snapshot.MovedDetailIDs = append(snapshot.MovedDetailIDs, d.ContactDetailsID)
+	}
+
+	if len(snapshot.MovedDetailIDs) > 0 {
+		if err := uow.DB.Model(&contact_detail.ContactDetail{}).
+			Where("contact_details_id IN ? AND user_id = ?", snapshot.MovedDetailIDs, userID).
+			Update("contact_id", primaryID).Error; err != nil {
+			return nil, apperror.NewInternalError("failed to move contact details")
+		}
+	}
+	if len(snapshot.DroppedDetailIDs) > 0 {
+		if err := uow.DB.Model(&contact_detail.ContactDetail{}).
+			Where("contact_details_id IN ? AND user_id = ?", snapshot.DroppedDetailIDs, userID).
+			Update("is_active", false).Error; err != nil {
+			return nil, apperror.NewInternalError("failed to set duplicate details inactive")
+		}
+		if err := uow.DB.Where("contact_details_id IN ? AND user_id = ?", snapshot.DroppedDetailIDs, userID).
+			Delete(&contact_detail.ContactDetail{}).Error; err != nil {
+			return nil, apperror.NewInternalError("failed to soft delete duplicate details")
+		}
+	}
+
+	addedGroups, err := copyMemberships(uow, secondaryID, primaryID)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.AddedGroupIDs = addedGroups
+
+	// the batch matches none of the secondary's details: the moved ones now
+	// belong to the primary and the dropped ones duplicate its own
+	batch, err := newDeleteBatch()
+	if err != nil {
+		return nil, err
+	}
+	if err := uow.DB.Model(&contact.Contact{}).
+		Where("contact_id = ? AND user_id = ?", secondaryID, userID).
+		Updates(map[string]interface{}{"is_active": false, "delete_batch": batch}).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to set merged contact inactive")
+	}
+	if err := uow.DB.Where("contact_id = ? AND user_id = ?", secondaryID, userID).
+		Delete(&contact.Contact{}).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to soft delete merged contact")
+	}
+
+	encoded, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, apperror.NewInternalError("failed to encode merge snapshot")
+	}
+
+	now := time.Now()
+	record := &contact_merge.ContactMerge{
+		UserID:      userID,
+		PrimaryID:   primaryID,
+		SecondaryID: secondaryID,
+		Snapshot:    string(encoded),
+		MergedAt:    now,
+		ExpiresAt:   now.Add(MergeRetention()),
+	}
+	if err := s.mergeRepo.Add(uow, record); err != nil {
+		return nil, err
+	}
+	if err := history.BumpVersions(uow, primaryID, secondaryID); err != nil {
+		return nil, err
+	}
+	if err := history.RecordVersion(uow, userID, primaryID, actorID, contact_version.ActionMerge); err != nil {
+		return nil, err
+	}
+	if err := history.RecordVersion(uow, userID, secondaryID, actorID, contact_version.ActionDelete); err != nil {
+		return nil, err
+	}
+	search.InvalidateOnCommit(uow, userID)
+
+	uow.Commit()
+	return record, nil
+}
+
+// UndoMerge reverts a merge that is still inside its retention window. The
+// user must be able to change the merged contact.
+func (s *DuplicateService) UndoMerge(userID, mergeID uint) (*contact_merge.ContactMerge, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	var record contact_merge.ContactMerge
+	if err := uow.DB.Where("merge_id = ?", mergeID).First(&record).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewNotFoundError("merge", int(mergeID))
+		}
+		return nil, apperror.NewInternalError("failed to fetch merge")
+	}
+	if _, err := shareService.ResolveContact(uow.DB, userID, record.PrimaryID, true); err != nil {
+		if _, missing := err.(*apperror.NotFoundError); missing {
+			return nil, apperror.NewNotFoundError("merge", int(mergeID))
+		}
+		return nil, err
+	}
+	actorID := userID
+	userID = record.UserID
+
+	now := time.Now()
+	if !record.CanUndo(now) {
+		return nil, apperror.NewValidationError("merge", "can no longer be undone")
+	}
+
+	if _, err := loadContact(uow, actorID, record.PrimaryID); err != nil {
+		return nil, err
+	}
+
+	var snapshot contact_merge.Snapshot
+	if err := json.Unmarshal([]byte(record.Snapshot), &snapshot); err != nil {
+		return nil, apperror.NewInternalError("failed to decode merge snapshot")
+	}
+
+	if err := s.contactRepo.UpdateWithMap(uow, &contact.Contact{}, map[string]interface{}{
+		"f_name": snapshot.PrimaryFName,
+		"l_name": snapshot.PrimaryLName,
+	}, repository.Filter("contact_id = ? AND user_id = ?", record.PrimaryID, userID)); err != nil {
+		return nil, err
+	}
+
+	if len(snapshot.MovedDetailIDs) > 0 {
+		if err := uow.DB.Unscoped().Model(&contact_detail.ContactDetail{}).
+			Where("contact_details_id IN ? AND user_id = ?", snapshot.MovedDetailIDs, userID).
+			Update("contact_id", record.SecondaryID).Error; err != nil {
+			return nil, apperror.NewInternalError("failed to move contact details back")
+		}
+	}
+	if len(snapshot.DroppedDetailIDs) > 0 {
+		if err := uow.DB.Unscoped().Model(&contact_detail.ContactDetail{}).
+			Where("contact_details_id IN ? AND user_id = ?", snapshot.DroppedDetailIDs, userID).
+			Updates(map[string]interface{}{"is_active": true, "deleted_at": nil}).Error; err != nil {
+			return nil, apperror.NewInternalError("failed to restore contact details")
+		}
+	}
+	if len(snapshot.AddedGroupIDs) > 0 {
+		if err := uow.DB.Where("contact_id = ? AND group_id IN ?", record.PrimaryID, snapshot.AddedGroupIDs).
+			Delete(&group.GroupContact{}).Error; err != nil {
+			return nil, apperror.NewInternalError("failed to restore group memberships")
+		}
+	}
+
+	if err := uow.DB.Unscoped().Model(&contact.Contact{}).
+		Where("contact_id = ? AND user_id = ?", record.SecondaryID, userID).
+		Updates(map[string]interface{}{"is_active": true, "deleted_at": nil, "delete_batch": ""}).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to restore merged contact")
+	}
+
+	record.UndoneAt = &now
+	if err := s.mergeRepo.Save(uow, &record); err != nil {
+		return nil, err
+	}
+	if err := history.BumpVersions(uow, record.PrimaryID, record.SecondaryID); err != nil {
+		return nil, err
+	}
+	if err := history.RecordVersion(uow, userID, record.PrimaryID, actorID, contact_version.ActionUpdate); err != nil {
+		return nil, err
+	}
+	if err := history.RecordVersion(uow, userID, record.SecondaryID, actorID, contact_version.ActionRestore); err != nil {
+		return nil, err
+	}
+	search.InvalidateOnCommit(uow, userID)
+
+	uow.Commit()
+	return &record, nil
+}
+
+// GetMerges lists the merges of contacts the user can see, most recent first
+func (s *DuplicateService) GetMerges(userID uint) ([]*contact_merge.ContactMerge, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var merges []*contact_merge.ContactMerge
+	visible := uow.DB.Unscoped().Model(&contact.Contact{}).Select("contact_id").
+		Where(shareService.AccessibleContacts(uow.DB, userID))
+	if err := uow.DB.Where("primary_id IN (?)", visible).Order("merged_at DESC").Find(&merges).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to fetch merges")
+	}
+	return merges, nil
+}
+
+// sameWorkspace reports whether two contacts are both personal or both in the
+// same workspace
+func sameWorkspace(a, b *contact.Contact) bool {
+	if a.WorkspaceID == nil || b.WorkspaceID == nil {
+		return a.WorkspaceID == nil && b.WorkspaceID == nil
+	}
+	return *a.WorkspaceID == *b.WorkspaceID
+}
+
+// newDeleteBatch returns a random value identifying the deletion of a merged contact
+func newDeleteBatch() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", apperror.NewInternalError("failed to merge contacts")
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// loadContact loads an active contact the user may change, with its details
+func loadContact(uow *repository.UnitOfWork, userID, contactID uint) (*contact.Contact, error) {
+	if _, err := shareService.ResolveContact(uow.DB, userID, contactID, true); err != nil {
+		return nil, err
+	}
+	var c contact.Contact
+	err := uow.DB.Preload("Details", "is_active = ?", true).
+		Where("contact_id = ? AND is_active = ?", contactID, true).
+		First(&c).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewNotFoundError("contact", int(contactID))
+		}
+		return nil, apperror.NewInternalError("failed to fetch contact")
+	}
+	return &c, nil
+}
+
+// copyMemberships adds toID to every group fromID is in, on behalf of each
+// group's owner
+func copyMemberships(uow *repository.UnitOfWork, fromID, toID uint) ([]uint, error) {
+	var fromGroups []*group.GroupContact
+	var toGroups []uint
+	if err := uow.DB.Where("contact_id = ?", fromID).Find(&fromGroups).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load group memberships")
+	}
+	if err := uow.DB.Model(&group.GroupContact{}).Where("contact_id = ?", toID).
+		Pluck("group_id", &toGroups).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load group memberships")
+	}
+
+	has := make(map[uint]bool, len(toGroups))
+	for _, id := range toGroups {
+		has[id] = true
+	}
+
+	var added []uint
+	for _, from := range fromGroups {
+		if has[from.GroupID] {
+			continue
+		}
+		member := &group.GroupContact{GroupID: from.GroupID, ContactID: toID, UserID: from.UserID, CreatedAt: time.Now()}
+		if err := uow.DB.Create(member).Error; err != nil {
+			return nil, apperror.NewInternalError("failed to copy group membership")
+		}
+		added = append(added, from.GroupID)
+	}
+	return added, nil
+}
+
+func contactKeys(c *contact.Contact) (emails, phones map[string]bool) {
+	emails, phones = make(map[string]bool), make(map[string]bool)
+	for _, d := range c.Details {
+		if d == nil || !d.IsActive {
+			continue
+		}
+		switch strings.ToLower(d.Type) {
+		case "email":
+			if v := helper.NormalizeEmail(d.Value); v != "" {
+				emails[v] = true
+			}
+		case "phone", "mobile", "tel":
+			if v := helper.NormalizePhone(d.Value); len(v) >= 7 {
+				phones[v] = true
+			}
+		}
+	}
+	return emails, phones
+}
+
+func blockingKeys(c *contact.Contact) []string {
+	var keys []string
+	emails, phones := contactKeys(c)
+	for email := range emails {
+		keys = append(keys, "e:"+email)
+	}
+	for phone := range phones {
+		keys = append(keys, "p:"+phone)
+	}
+	for _, name := range []string{helper.NormalizeName(c.FName), helper.NormalizeName(c.LName)} {
+		if r := []rune(name); len(r) >= 2 {
+			keys = append(keys, "n:"+string(r[:2]))
+		}
+	}
+	return keys
+}
+
+func detailKey(d *contact_detail.ContactDetail) string {
+	return strings.ToLower(d.Type) + ":" + helper.NormalizeDetailValue(d.Type, d.Value)
+}
+
+func round2(v float64) float64 {
+	return float64(int(v*100+0.5)) / 100
+}
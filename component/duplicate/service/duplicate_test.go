@@ -0,0 +1,123 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	contactService "Contact_App/component/contact/service"
+	"Contact_App/db/dbtest"
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_detail"
+	"Contact_App/models/contact_share"
+	"Contact_App/models/user"
+	"Contact_App/models/workspace"
+	"Contact_App/repository"
+	"net/http"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// load reads a row by primary key, trashed or not
+func load(t *testing.T, conn *gorm.DB, dest interface{}, id uint) {
+	t.Helper()
+	if err := repository.WithoutTenant(conn).Unscoped().First(dest, id).Error; err != nil {
+		t.Fatalf("load %T %d: %v", dest, id, err)
+	}
+}
+
+func wantStatus(t *testing.T, err error, status int) {
+	t.Helper()
+	appErr, ok := err.(apperror.AppError)
+	if !ok || appErr.StatusCode() != status {
+		t.Fatalf("error = %v, want status %d", err, status)
+	}
+}
+
+// A merge moves the secondary's new details to the primary and trashes the
+// secondary, which only undoing the merge brings back as it was.
+func TestMergeAndUndo(t *testing.T) {
+	conn := dbtest.Open(t)
+	owner := dbtest.User(t, conn, "owner@example.com")
+	primary := dbtest.Contact(t, conn, owner, nil, "Ann", "Lee")
+	secondary := dbtest.Contact(t, conn, owner, nil, "Anne", "Lee")
+	dbtest.Detail(t, conn, primary, "email", "ann@example.com")
+	dropped := dbtest.Detail(t, conn, secondary, "email", "ANN@example.com")
+	moved := dbtest.Detail(t, conn, secondary, "phone", "+1 555 0100")
+
+	svc := NewDuplicateService()
+	record, err := svc.Merge(owner.UserID, primary.ContactID, secondary.ContactID, "Annie", "")
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+
+	var merged contact.Contact
+	load(t, conn, &merged, secondary.ContactID)
+	if !merged.DeletedAt.Valid || merged.DeleteBatch == "" {
+		t.Errorf("secondary deleted_at = %v, delete_batch = %q, want trashed with a batch", merged.DeletedAt, merged.DeleteBatch)
+	}
+	var movedNow, droppedNow contact_detail.ContactDetail
+	load(t, conn, &movedNow, moved.ContactDetailsID)
+	load(t, conn, &droppedNow, dropped.ContactDetailsID)
+	if movedNow.ContactID != primary.ContactID {
+		t.Errorf("new phone belongs to contact %d, want the primary %d", movedNow.ContactID, primary.ContactID)
+	}
+	if !droppedNow.DeletedAt.Valid {
+		t.Error("duplicate email was kept, want it dropped")
+	}
+
+	contacts := contactService.NewContactService()
+	_, err = contacts.RestoreContactByID(owner.UserID, secondary.ContactID)
+	wantStatus(t, err, http.StatusConflict)
+
+	if _, err := svc.UndoMerge(owner.UserID, record.MergeID); err != nil {
+		t.Fatalf("UndoMerge() error = %v", err)
+	}
+	var restored, primaryNow contact.Contact
+	load(t, conn, &restored, secondary.ContactID)
+	load(t, conn, &primaryNow, primary.ContactID)
+	if restored.DeletedAt.Valid || !restored.IsActive || restored.DeleteBatch != "" {
+		t.Errorf("secondary after undo = %+v, want it live", restored)
+	}
+	if primaryNow.FName != "Ann" {
+		t.Errorf("primary first name = %q, want %q back", primaryNow.FName, "Ann")
+	}
+	var movedBack, droppedBack contact_detail.ContactDetail
+	load(t, conn, &movedBack, moved.ContactDetailsID)
+	load(t, conn, &droppedBack, dropped.ContactDetailsID)
+	if movedBack.ContactID != secondary.ContactID || droppedBack.DeletedAt.Valid {
+		t.Errorf("details after undo: phone on %d, email deleted %v; want both back on the secondary", movedBack.ContactID, droppedBack.DeletedAt.Valid)
+	}
+
+	_, err = svc.UndoMerge(owner.UserID, record.MergeID)
+	wantStatus(t, err, http.StatusBadRequest)
+}
+
+func TestMergeRequiresSameWorkspaceAndWriteAccess(t *testing.T) {
+	conn := dbtest.Open(t)
+	owner := dbtest.User(t, conn, "owner@example.com")
+	reader := dbtest.User(t, conn, "reader@example.com")
+	team := dbtest.Workspace(t, conn, "Team", map[*user.User]string{owner: workspace.RoleAdmin})
+
+	personal := dbtest.Contact(t, conn, owner, nil, "Ann", "Lee")
+	teamCopy := dbtest.Contact(t, conn, owner, &team.WorkspaceID, "Ann", "Lee")
+	sharedA := dbtest.Contact(t, conn, owner, nil, "Bob", "Ray")
+	sharedB := dbtest.Contact(t, conn, owner, nil, "Bobby", "Ray")
+	dbtest.ShareContact(t, conn, owner, reader, sharedA.ContactID, contact_share.PermissionRead)
+	dbtest.ShareContact(t, conn, owner, reader, sharedB.ContactID, contact_share.PermissionRead)
+
+	tests := []struct {
+		name                   string
+		userID                 uint
+		primaryID, secondaryID uint
+		status                 int
+	}{
+		{"personal into workspace contact", owner.UserID, teamCopy.ContactID, personal.ContactID, http.StatusBadRequest},
+		{"workspace into personal contact", owner.UserID, personal.ContactID, teamCopy.ContactID, http.StatusBadRequest},
+		{"read-only shared contacts", reader.UserID, sharedA.ContactID, sharedB.ContactID, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDuplicateService().Merge(tt.userID, tt.primaryID, tt.secondaryID, "", "")
+			wantStatus(t, err, tt.status)
+		})
+	}
+}
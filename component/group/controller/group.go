@@ -0,0 +1,267 @@
+package controller
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/component/auth"
+	"Contact_App/component/group/service"
+	photoService "Contact_App/component/photo/service"
+	"Contact_App/export"
+	"Contact_App/web"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type GroupController struct {
+	Service *service.GroupService
+	Photos  *photoService.PhotoService
+}
+
+type membershipRequest struct {
+	ContactIDs []uint `json:"contact_ids"`
+}
+
+func NewGroupController(svc *service.GroupService, photos *photoService.PhotoService) *GroupController {
+	return &GroupController{Service: svc, Photos: photos}
+}
+
+// POST /users/{userID}/groups
+func (c *GroupController) CreateGroupHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name        string `json:"name"`
+		Color       string `json:"color"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	g, err := c.Service.CreateGroup(userID, input.Name, input.Color, input.Description)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.RespondJSON(w, http.StatusCreated, g)
+}
+
+// GET /users/{userID}/groups
+func (c *GroupController) GetGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	groups, err := c.Service.GetGroups(userID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.RespondJSON(w, http.StatusOK, groups)
+}
+
+// GET /users/{userID}/groups/{groupID}
+func (c *GroupController) GetGroupByIDHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := web.ParseID(w, r, "groupID")
+	if !ok {
+		return
+	}
+
+	g, err := c.Service.GetGroupByID(userID, groupID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.RespondJSON(w, http.StatusOK, g)
+}
+
+// PUT /users/{userID}/groups/{groupID}
+func (c *GroupController) UpdateGroupHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := web.ParseID(w, r, "groupID")
+	if !ok {
+		return
+	}
+
+	var updates map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	g, err := c.Service.UpdateGroup(userID, groupID, updates)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.RespondJSON(w, http.StatusOK, g)
+}
+
+// DELETE /users/{userID}/groups/{groupID}
+func (c *GroupController) DeleteGroupHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := web.ParseID(w, r, "groupID")
+	if !ok {
+		return
+	}
+
+	if err := c.Service.DeleteGroup(userID, groupID); err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.RespondJSON(w, http.StatusOK, map[string]string{"message": "group deleted"})
+}
+
+// POST /users/{userID}/groups/{groupID}/contacts
+func (c *GroupController) AddContactsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := web.ParseID(w, r, "groupID")
+	if !ok {
+		return
+	}
+
+	var input membershipRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	added, err := c.Service.AddContacts(userID, groupID, input.ContactIDs)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.RespondJSON(w, http.StatusOK, map[string]interface{}{"message": "contacts added to group", "added": added})
+}
+
+// DELETE /users/{userID}/groups/{groupID}/contacts
+func (c *GroupController) RemoveContactsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := web.ParseID(w, r, "groupID")
+	if !ok {
+		return
+	}
+
+	var input membershipRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	removed, err := c.Service.RemoveContacts(userID, groupID, input.ContactIDs)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.RespondJSON(w, http.StatusOK, map[string]interface{}{"message": "contacts removed from group", "removed": removed})
+}
+
+// GET /users/{userID}/groups/{groupID}/contacts
+func (c *GroupController) GetGroupContactsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := web.ParseID(w, r, "groupID")
+	if !ok {
+		return
+	}
+
+	contacts, err := c.Service.GetGroupContacts(userID, groupID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.RespondJSON(w, http.StatusOK, contacts)
+}
+
+// GET /users/{userID}/groups/{groupID}/export?format=csv|vcard
+func (c *GroupController) ExportGroupHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	groupID, ok := web.ParseID(w, r, "groupID")
+	if !ok {
+		return
+	}
+
+	format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+	if format == "" {
+		format = export.FormatCSV
+	}
+	if !export.IsSupportedFormat(format) {
+		apperror.HandleBadRequest(w, "format must be csv or vcard")
+		return
+	}
+
+	g, err := c.Service.GetGroupByID(userID, groupID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	contacts, err := c.Service.GetGroupContacts(userID, groupID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	var photos export.Photos
+	if format == export.FormatVCard {
+		ids := make([]uint, 0, len(contacts))
+		for _, ct := range contacts {
+			ids = append(ids, ct.ContactID)
+		}
+		if photos, err = c.Photos.LoadExportPhotos(ids); err != nil {
+			apperror.HandleError(w, err)
+			return
+		}
+	}
+
+	_ = export.Respond(w, format, fmt.Sprintf("group-%d", g.GroupID), contacts, photos)
+}
+
+func (c *GroupController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/users/{userID}/groups", c.CreateGroupHandler).Methods("POST")
+	router.HandleFunc("/users/{userID}/groups", c.GetGroupsHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/groups/{groupID}", c.GetGroupByIDHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/groups/{groupID}", c.UpdateGroupHandler).Methods("PUT")
+	router.HandleFunc("/users/{userID}/groups/{groupID}", c.DeleteGroupHandler).Methods("DELETE")
+	router.HandleFunc("/users/{userID}/groups/{groupID}/contacts", c.GetGroupContactsHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/groups/{groupID}/contacts", c.AddContactsHandler).Methods("POST")
+	router.HandleFunc("/users/{userID}/groups/{groupID}/contacts", c.RemoveContactsHandler).Methods("DELETE")
+	router.HandleFunc("/users/{userID}/groups/{groupID}/export", c.ExportGroupHandler).Methods("GET")
+}
@@ -0,0 +1,446 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	customFieldService "Contact_App/component/custom_field/service"
+	shareService "Contact_App/component/share/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/models/contact"
+	"Contact_App/models/group"
+	"Contact_App/repository"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var colorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+type GroupService struct {
+	groupRepo  repository.Repository
+	memberRepo repository.Repository
+}
+
+func NewGroupService() *GroupService {
+	return &GroupService{
+		groupRepo:  repository.NewGormRepository(),
+		memberRepo: repository.NewGormRepository(),
+	}
+}
+
+// CreateGroup creates a new group owned by the user
+func (s *GroupService) CreateGroup(userID uint, name, color, description string) (*group.Group, error) {
+	name = strings.TrimSpace(name)
+	color = strings.TrimSpace(color)
+	if err := validateGroupFields(name, color); err != nil {
+		return nil, err
+	}
+
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	if err := s.ensureNameAvailable(uow, userID, 0, name); err != nil {
+		return nil, err
+	}
+
+	newGroup := &group.Group{
+		UserID:      userID,
+		Name:        name,
+		Color:       color,
+		Description: strings.TrimSpace(description),
+		IsActive:    true,
+	}
+	if err := s.groupRepo.Add(uow, newGroup); err != nil {
+		return nil, err
+	}
+
+	uow.Commit()
+	return newGroup, nil
+}
+
+// GetGroups lists the user's groups and the groups shared with them along
+// with their member counts
+func (s *GroupService) GetGroups(userID uint) ([]*group.Group, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []*group.Group
+	if err := s.groupRepo.GetAll(uow, &groups,
+		repository.Filter("user_id = ? OR group_id IN (?)", userID, shareService.SharedGroupIDs(uow.DB, userID)),
+	); err != nil {
+		return nil, err
+	}
+	if err := shareService.ApplyGroupSharedBy(uow.DB, userID, groups); err != nil {
+		return nil, err
+	}
+
+	for _, g := range groups {
+		count, err := s.countMembers(uow, g.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		g.ContactCount = count
+	}
+	return groups, nil
+}
+
+// GetGroupByID retrieves a single group the user owns or has been shared
+func (s *GroupService) GetGroupByID(userID, groupID uint) (*group.Group, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := s.readableGroup(uow, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.countMembers(uow, g.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	g.ContactCount = count
+	return g, nil
+}
+
+// GetGroupWithUOW loads an active group owned by the user using the provided transaction
+func (s *GroupService) GetGroupWithUOW(uow *repository.UnitOfWork, userID, groupID uint) (*group.Group, error) {
+	var groups []*group.Group
+	if err := s.groupRepo.GetAll(uow, &groups,
+		repository.Filter("group_id = ? AND user_id = ?", groupID, userID),
+	); err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, apperror.NewNotFoundError("group", int(groupID))
+	}
+	return groups[0], nil
+}
+
+// readableGroup loads a group the user owns or, through a share, may read.
+// Shared groups carry their shared_by marker.
+func (s *GroupService) readableGroup(uow *repository.UnitOfWork, userID, groupID uint) (*group.Group, error) {
+	permission, err := shareService.GroupPermission(uow.DB, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if permission == "" {
+		return s.GetGroupWithUOW(uow, userID, groupID)
+	}
+
+	var groups []*group.Group
+	if err := s.groupRepo.GetAll(uow, &groups, repository.Filter("group_id = ?", groupID)); err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, apperror.NewNotFoundError("group", int(groupID))
+	}
+	if err := shareService.ApplyGroupSharedBy(uow.DB, userID, groups); err != nil {
+		return nil, err
+	}
+	return groups[0], nil
+}
+
+// UpdateGroup renames a group or changes its color/description
+func (s *GroupService) UpdateGroup(userID, groupID uint, updates map[string]interface{}) (*group.Group, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	g, err := s.GetGroupWithUOW(uow, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	updateMap := make(map[string]interface{})
+
+	if v, ok := updates["name"]; ok {
+		strVal, _ := v.(string)
+		strVal = strings.TrimSpace(strVal)
+		if strVal == "" {
+			return nil, apperror.NewValidationError("name", "cannot be empty")
+		}
+		if err := s.ensureNameAvailable(uow, userID, groupID, strVal); err != nil {
+			return nil, err
+		}
+		updateMap["name"] = strVal
+		g.Name = strVal
+	}
+
+	if v, ok := updates["color"]; ok {
+		strVal, _ := v.(string)
+		strVal = strings.TrimSpace(strVal)
+		if strVal != "" && !colorPattern.MatchString(strVal) {
+			return nil, apperror.NewValidationError("color", "must be a hex color like #1a2b3c")
+		}
+		updateMap["color"] = strVal
+		g.Color = strVal
+	}
+
+	if v, ok := updates["description"]; ok {
+		strVal, _ := v.(string)
+		updateMap["description"] = strings.TrimSpace(strVal)
+		g.Description = strings.TrimSpace(strVal)
+	}
+
+	if len(updateMap) > 0 {
+		if err := s.groupRepo.UpdateWithMap(uow, &group.Group{}, updateMap,
+			repository.Filter("group_id = ? AND user_id = ?", groupID, userID),
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	uow.Commit()
+	return g, nil
+}
+
+// DeleteGroup soft deletes a group and drops its memberships; the contacts themselves are kept
+func (s *GroupService) DeleteGroup(userID, groupID uint) error {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return err
+	}
+	defer uow.Rollback()
+
+	if _, err := s.GetGroupWithUOW(uow, userID, groupID); err != nil {
+		return err
+	}
+
+	if err := uow.DB.Model(&group.Group{}).
+		Where("group_id = ? AND user_id = ?", groupID, userID).
+		Update("is_active", false).Error; err != nil {
+		return apperror.NewInternalError("failed to set group inactive")
+	}
+
+	if err := uow.DB.Where("group_id = ? AND user_id = ?", groupID, userID).
+		Delete(&group.Group{}).Error; err != nil {
+		return apperror.NewInternalError("failed to soft delete group")
+	}
+
+	if err := shareService.DeleteForGroupWithUOW(uow, groupID); err != nil {
+		return err
+	}
+	if err := uow.DB.Where("group_id = ?", groupID).
+		Delete(&group.GroupContact{}).Error; err != nil {
+		return apperror.NewInternalError("failed to remove group memberships")
+	}
+
+	uow.Commit()
+	return nil
+}
+
+// AddContacts adds the given contacts to a group and returns how many were newly added
+func (s *GroupService) AddContacts(userID, groupID uint, contactIDs []uint) (int, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return 0, err
+	}
+	defer uow.Rollback()
+
+	added, err := s.AddContactsWithUOW(uow, userID, groupID, contactIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	uow.Commit()
+	return added, nil
+}
+
+// AddContactsWithUOW adds contacts to a group using the provided transaction
+func (s *GroupService) AddContactsWithUOW(uow *repository.UnitOfWork, userID, groupID uint, contactIDs []uint) (int, error) {
+	if len(contactIDs) == 0 {
+		return 0, apperror.NewValidationError("contact_ids", "cannot be empty")
+	}
+	if _, err := s.GetGroupWithUOW(uow, userID, groupID); err != nil {
+		return 0, err
+	}
+	if err := ensureContactsOwned(uow, userID, contactIDs); err != nil {
+		return 0, err
+	}
+
+	var existing []uint
+	if err := uow.DB.Model(&group.GroupContact{}).
+		Where("group_id = ? AND contact_id IN ?", groupID, contactIDs).
+		Pluck("contact_id", &existing).Error; err != nil {
+		return 0, apperror.NewInternalError("failed to load group memberships")
+	}
+	isMember := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		isMember[id] = true
+	}
+
+	added := 0
+	for _, contactID := range contactIDs {
+		if isMember[contactID] {
+			continue
+		}
+		isMember[contactID] = true
+		member := &group.GroupContact{
+			GroupID:   groupID,
+			ContactID: contactID,
+			UserID:    userID,
+			CreatedAt: time.Now(),
+		}
+		if err := s.memberRepo.Add(uow, member); err != nil {
+			return added, err
+		}
+		added++
+	}
+	return added, nil
+}
+
+// RemoveContacts removes the given contacts from a group and returns how many were removed
+func (s *GroupService) RemoveContacts(userID, groupID uint, contactIDs []uint) (int, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return 0, err
+	}
+	defer uow.Rollback()
+
+	removed, err := s.RemoveContactsWithUOW(uow, userID, groupID, contactIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	uow.Commit()
+	return removed, nil
+}
+
+// RemoveContactsWithUOW removes contacts from a group using the provided transaction
+func (s *GroupService) RemoveContactsWithUOW(uow *repository.UnitOfWork, userID, groupID uint, contactIDs []uint) (int, error) {
+	if len(contactIDs) == 0 {
+		return 0, apperror.NewValidationError("contact_ids", "cannot be empty")
+	}
+	if _, err := s.GetGroupWithUOW(uow, userID, groupID); err != nil {
+		return 0, err
+	}
+
+	var members []uint
+	if err := uow.DB.Model(&group.GroupContact{}).
+		Where("group_id = ? AND user_id = ? AND contact_id IN ?", groupID, userID, contactIDs).
+		Pluck("contact_id", &members).Error; err != nil {
+		return 0, apperror.NewInternalError("failed to load group memberships")
+	}
+	if len(members) == 0 {
+		return 0, nil
+	}
+
+	result := uow.DB.Where("group_id = ? AND user_id = ? AND contact_id IN ?", groupID, userID, members).
+		Delete(&group.GroupContact{})
+	if result.Error != nil {
+		return 0, apperror.NewInternalError("failed to remove contacts from group")
+	}
+	if err := shareService.RevokeGroupContactsWithUOW(uow, groupID, members); err != nil {
+		return 0, err
+	}
+	return int(result.RowsAffected), nil
+}
+
+// GetGroupContacts returns the active contacts in a group that the user can
+// see, with their details
+func (s *GroupService) GetGroupContacts(userID, groupID uint) ([]*contact.Contact, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := s.readableGroup(uow, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	var contacts []*contact.Contact
+	err = uow.DB.Preload("Details", "is_active = ?", true).
+		Where(shareService.AccessibleContacts(uow.DB, userID)).
+		Where("is_active = ?", true).
+		Where("contact_id IN (?)", memberSubQuery(uow, g.GroupID)).
+		Find(&contacts).Error
+	if err != nil {
+		return nil, apperror.NewInternalError("failed to fetch group contacts")
+	}
+	// custom field values belong to each contact's owner
+	byOwner := make(map[uint][]*contact.Contact)
+	for _, c := range contacts {
+		byOwner[c.UserID] = append(byOwner[c.UserID], c)
+	}
+	for ownerID, owned := range byOwner {
+		if err := customFieldService.ApplyValues(uow.DB, ownerID, owned); err != nil {
+			return nil, err
+		}
+	}
+	if err := shareService.ApplySharedBy(uow.DB, userID, contacts); err != nil {
+		return nil, err
+	}
+	return contacts, nil
+}
+
+// memberSubQuery selects the contact IDs belonging to a group, for use in IN clauses
+func memberSubQuery(uow *repository.UnitOfWork, groupID uint) interface{} {
+	return uow.DB.Model(&group.GroupContact{}).Select("contact_id").Where("group_id = ?", groupID)
+}
+
+func (s *GroupService) countMembers(uow *repository.UnitOfWork, groupID uint) (int64, error) {
+	var count int64
+	err := uow.DB.Model(&group.GroupContact{}).
+		Joins("JOIN contacts ON contacts.contact_id = group_contacts.contact_id").
+		Where("group_contacts.group_id = ? AND contacts.is_active = ? AND contacts.deleted_at IS NULL", groupID, true).
+		Count(&count).Error
+	if err != nil {
+		return 0, apperror.NewInternalError("failed to count group members")
+	}
+	return count, nil
+}
+
+func (s *GroupService) ensureNameAvailable(uow *repository.UnitOfWork, userID, groupID uint, name string) error {
+	var count int64
+	if err := uow.DB.Model(&group.Group{}).
+		Where("user_id = ? AND name = ? AND group_id <> ? AND is_active = ?", userID, name, groupID, true).
+		Count(&count).Error; err != nil {
+		return apperror.NewInternalError("failed to check group name")
+	}
+	if count > 0 {
+		return apperror.NewValidationError("name", "a group with this name already exists")
+	}
+	return nil
+}
+
+// ensureContactsOwned checks that every contact is an active personal contact
+// of the user. Sharing a group grants its permission on every member, so a
+// contact the user only sees through a share or a workspace must stay out.
+func ensureContactsOwned(uow *repository.UnitOfWork, userID uint, contactIDs []uint) error {
+	var owned []uint
+	if err := uow.DB.Model(&contact.Contact{}).
+		Where("user_id = ? AND workspace_id IS NULL AND is_active = ? AND contact_id IN ?", userID, true, contactIDs).
+		Pluck("contact_id", &owned).Error; err != nil {
+		return apperror.NewInternalError("failed to load contacts")
+	}
+
+	found := make(map[uint]bool, len(owned))
+	for _, id := range owned {
+		found[id] = true
+	}
+	for _, id := range contactIDs {
+		if !found[id] {
+			return apperror.NewNotFoundError("contact", int(id))
+		}
+	}
+	return nil
+}
+
+func validateGroupFields(name, color string) error {
+	if name == "" {
+		return apperror.NewValidationError("name", "cannot be empty")
+	}
+	if color != "" && !colorPattern.MatchString(color) {
+		return apperror.NewValidationError("color", "must be a hex color like #1a2b3c")
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	shareService "Contact_App/component/share/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/db/dbtest"
+	"Contact_App/models/contact_share"
+	"Contact_App/models/group"
+	"Contact_App/models/user"
+	"Contact_App/models/workspace"
+	"net/http"
+	"testing"
+)
+
+// A contact shared read-only must not become writable for anyone by being
+// put in a group the recipient then shares with write access.
+func TestReadOnlyRecipientCannotEscalateThroughGroupShare(t *testing.T) {
+	conn := dbtest.Open(t)
+	owner := dbtest.User(t, conn, "owner@example.com")
+	recipient := dbtest.User(t, conn, "recipient@example.com")
+	third := dbtest.User(t, conn, "third@example.com")
+
+	shared := dbtest.Contact(t, conn, owner, nil, "Ada", "Lovelace")
+	dbtest.ShareContact(t, conn, owner, recipient, shared.ContactID, contact_share.PermissionRead)
+	own := dbtest.Contact(t, conn, recipient, nil, "Grace", "Hopper")
+
+	svc := NewGroupService()
+	g, err := svc.CreateGroup(recipient.UserID, "Friends", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.AddContacts(recipient.UserID, g.GroupID, []uint{own.ContactID, shared.ContactID})
+	if appErr, ok := err.(apperror.AppError); !ok || appErr.StatusCode() != http.StatusNotFound {
+		t.Fatalf("AddContacts(shared contact) error = %v, want not found", err)
+	}
+	if added, err := svc.AddContacts(recipient.UserID, g.GroupID, []uint{own.ContactID}); err != nil || added != 1 {
+		t.Fatalf("AddContacts(own contact) = %d, %v, want 1, nil", added, err)
+	}
+
+	// a membership written before the check existed still grants nothing
+	if err := conn.Create(&group.GroupContact{GroupID: g.GroupID, ContactID: shared.ContactID, UserID: recipient.UserID}).Error; err != nil {
+		t.Fatal(err)
+	}
+	dbtest.ShareGroup(t, conn, recipient, third, g.GroupID, contact_share.PermissionWrite)
+
+	for _, tt := range []struct {
+		name      string
+		userID    uint
+		contactID uint
+		write     bool
+		status    int
+	}{
+		{"recipient still reads the shared contact", recipient.UserID, shared.ContactID, false, 0},
+		{"recipient still cannot write it", recipient.UserID, shared.ContactID, true, http.StatusForbidden},
+		{"third party cannot read it through the group", third.UserID, shared.ContactID, false, http.StatusNotFound},
+		{"third party cannot write it through the group", third.UserID, shared.ContactID, true, http.StatusNotFound},
+		{"third party writes the sharer's own contact", third.UserID, own.ContactID, true, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			uow, err := workspaceService.ScopedUnitOfWork(tt.userID, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = shareService.ResolveContact(uow.DB, tt.userID, tt.contactID, tt.write)
+			if tt.status == 0 {
+				if err != nil {
+					t.Fatalf("ResolveContact() error = %v", err)
+				}
+				return
+			}
+			if appErr, ok := err.(apperror.AppError); !ok || appErr.StatusCode() != tt.status {
+				t.Fatalf("ResolveContact() error = %v, want status %d", err, tt.status)
+			}
+		})
+	}
+}
+
+// Workspace contacts belong to the team and cannot be put in a personal group.
+func TestAddContactsRejectsWorkspaceContacts(t *testing.T) {
+	conn := dbtest.Open(t)
+	admin := dbtest.User(t, conn, "admin@example.com")
+	team := dbtest.Workspace(t, conn, "Team", map[*user.User]string{admin: workspace.RoleAdmin})
+	teamContact := dbtest.Contact(t, conn, admin, &team.WorkspaceID, "Team", "Contact")
+
+	svc := NewGroupService()
+	g, err := svc.CreateGroup(admin.UserID, "Mine", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.AddContacts(admin.UserID, g.GroupID, []uint{teamContact.ContactID}); err == nil {
+		t.Fatal("AddContacts(workspace contact) succeeded, want an error")
+	}
+}
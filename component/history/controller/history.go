@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/component/auth"
+	"Contact_App/component/history/service"
+	"Contact_App/web"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type HistoryController struct {
+	Service *service.HistoryService
+}
+
+func NewHistoryController(svc *service.HistoryService) *HistoryController {
+	return &HistoryController{Service: svc}
+}
+
+// parseContactVersion reads {contactID} and, when present, {version} from the path
+func parseContactVersion(w http.ResponseWriter, r *http.Request) (uint, int, bool) {
+	vars := mux.Vars(r)
+	contactID64, err := strconv.ParseUint(vars["contactID"], 10, 64)
+	if err != nil {
+		apperror.HandleBadRequest(w, "invalid contactID")
+		return 0, 0, false
+	}
+
+	version := 0
+	if v, ok := vars["version"]; ok {
+		version, err = strconv.Atoi(v)
+		if err != nil || version < 1 {
+			apperror.HandleBadRequest(w, "invalid version")
+			return 0, 0, false
+		}
+	}
+	return uint(contactID64), version, true
+}
+
+// GET /users/{userID}/contacts/{contactID}/history
+func (c *HistoryController) GetHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, _, ok := parseContactVersion(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := c.Service.GetHistory(userID, contactID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.RespondJSON(w, http.StatusOK, entries)
+}
+
+// GET /users/{userID}/contacts/{contactID}/history/{version}
+func (c *HistoryController) GetVersionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, version, ok := parseContactVersion(w, r)
+	if !ok {
+		return
+	}
+
+	entry, err := c.Service.GetVersion(userID, contactID, version)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.RespondJSON(w, http.StatusOK, entry)
+}
+
+// POST /users/{userID}/contacts/{contactID}/history/{version}/revert
+func (c *HistoryController) RevertHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, version, ok := parseContactVersion(w, r)
+	if !ok {
+		return
+	}
+
+	actorID := uint(auth.GetUserClaims(r).UserID)
+	contactObj, err := c.Service.Revert(userID, contactID, actorID, version)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.RespondJSON(w, http.StatusOK, contactObj)
+}
+
+func (c *HistoryController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/history", c.GetHistoryHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/history/{version:[0-9]+}", c.GetVersionHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/history/{version:[0-9]+}/revert", c.RevertHandler).Methods("POST")
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_change"
+	"Contact_App/models/contact_version"
+	"Contact_App/repository"
+	"sort"
+)
+
+// recordChanges appends the contact and the details that differ between the
+// two snapshots to the change sequence. before is nil for a contact's first
+// version, so all its details count as created.
+func recordChanges(uow *repository.UnitOfWork, userID, contactID uint, action string, before, after *contact_version.Snapshot) error {
+	var c contact.Contact
+	if err := uow.DB.Unscoped().Select("workspace_id").
+		Where("contact_id = ?", contactID).
+		First(&c).Error; err != nil {
+		return apperror.NewInternalError("failed to load contact for change log")
+	}
+
+	changeAction := contact_change.ActionUpdate
+	switch action {
+	case contact_version.ActionCreate:
+		changeAction = contact_change.ActionCreate
+	case contact_version.ActionDelete:
+		changeAction = contact_change.ActionDelete
+	}
+	changes := []*contact_change.ContactChange{
+		{UserID: userID, WorkspaceID: c.WorkspaceID, ContactID: contactID, Action: changeAction},
+	}
+	detailChange := func(detailID uint, action string) {
+		id := detailID
+		changes = append(changes, &contact_change.ContactChange{
+			UserID: userID, WorkspaceID: c.WorkspaceID, ContactID: contactID, DetailID: &id, Action: action,
+		})
+	}
+
+	old := make(map[uint]contact_version.DetailSnapshot)
+	if before != nil {
+		for _, d := range before.Details {
+			old[d.ID] = d
+		}
+	}
+	for _, d := range after.Details {
+		prev, ok := old[d.ID]
+		switch {
+		case !ok:
+			detailChange(d.ID, contact_change.ActionCreate)
+		case prev.Type != d.Type || prev.Value != d.Value:
+			detailChange(d.ID, contact_change.ActionUpdate)
+		}
+		delete(old, d.ID)
+	}
+	removed := make([]uint, 0, len(old))
+	for id := range old {
+		removed = append(removed, id)
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i] < removed[j] })
+	for _, id := range removed {
+		detailChange(id, contact_change.ActionDelete)
+	}
+
+	if err := uow.DB.Create(&changes).Error; err != nil {
+		return apperror.NewInternalError("failed to record contact change")
+	}
+	return nil
+}
@@ -0,0 +1,507 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	shareService "Contact_App/component/share/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_detail"
+	"Contact_App/models/contact_version"
+	"Contact_App/models/custom_field"
+	"Contact_App/models/organization"
+	"Contact_App/repository"
+	"Contact_App/search"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Change is a single field-level difference between two versions
+type Change struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// VersionEntry is a stored version together with what changed since the previous one
+type VersionEntry struct {
+	*contact_version.ContactVersion
+	Changes  []Change                  `json:"changes"`
+	Snapshot *contact_version.Snapshot `json:"snapshot,omitempty"`
+}
+
+type HistoryService struct {
+	versionRepo repository.Repository
+}
+
+func NewHistoryService() *HistoryService {
+	return &HistoryService{
+		versionRepo: repository.NewGormRepository(),
+	}
+}
+
+// RecordVersion snapshots the contact as it currently stands inside uow and
+// stores it as the contact's next version. Call it after the change, before
+// commit. It leaves the contact's own version alone; writers move that with
+// BumpVersions as part of the change.
+func RecordVersion(uow *repository.UnitOfWork, userID, contactID, actorID uint, action string) error {
+	snapshot, err := takeSnapshot(uow, userID, contactID)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(snapshot)
+	if err != nil {
+		return apperror.NewInternalError("failed to encode contact snapshot")
+	}
+
+	var previous contact_version.ContactVersion
+	if err := uow.DB.Where("contact_id = ?", contactID).
+		Order("version DESC").
+		Limit(1).
+		Find(&previous).Error; err != nil {
+		return apperror.NewInternalError("failed to read contact version")
+	}
+
+	version := &contact_version.ContactVersion{
+		ContactID: contactID,
+		Version:   previous.Version + 1,
+		UserID:    userID,
+		ActorID:   actorID,
+		Action:    action,
+		Snapshot:  string(encoded),
+		CreatedAt: time.Now(),
+	}
+	if err := uow.DB.Create(version).Error; err != nil {
+		return apperror.NewInternalError("failed to record contact version")
+	}
+
+	var before *contact_version.Snapshot
+	if previous.VersionID != 0 {
+		if before, err = decodeSnapshot(&previous); err != nil {
+			return err
+		}
+	}
+	if err := recordChanges(uow, userID, contactID, action, before, snapshot); err != nil {
+		return err
+	}
+	return nil
+}
+
+// BumpVersions moves the contacts' versions, and so their ETags, forward. Every
+// write to a contact after its creation calls it once.
+func BumpVersions(uow *repository.UnitOfWork, contactIDs ...uint) error {
+	if len(contactIDs) == 0 {
+		return nil
+	}
+	if err := uow.DB.Unscoped().Model(&contact.Contact{}).
+		Where("contact_id IN ?", contactIDs).
+		UpdateColumn("version", gorm.Expr("version + 1")).Error; err != nil {
+		return apperror.NewInternalError("failed to bump contact version")
+	}
+	return nil
+}
+
+// DeleteVersions drops the history of the given contacts, used when they are permanently deleted
+func DeleteVersions(uow *repository.UnitOfWork, contactIDs []uint) error {
+	if err := uow.DB.Where("contact_id IN ?", contactIDs).
+		Delete(&contact_version.ContactVersion{}).Error; err != nil {
+		return apperror.NewInternalError("failed to delete contact history")
+	}
+	return nil
+}
+
+// GetHistory lists the versions of a contact the user can see, newest first,
+// each with its diff to the previous version
+func (s *HistoryService) GetHistory(userID, contactID uint) ([]*VersionEntry, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+	c, err := shareService.ResolveContact(uow.DB.Unscoped(), userID, contactID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []*contact_version.ContactVersion
+	if err := uow.DB.Where("contact_id = ? AND user_id = ?", contactID, c.UserID).
+		Order("version ASC").
+		Find(&versions).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to fetch contact history")
+	}
+
+	entries := make([]*VersionEntry, len(versions))
+	var previous *contact_version.Snapshot
+	for i, v := range versions {
+		snapshot, err := decodeSnapshot(v)
+		if err != nil {
+			return nil, err
+		}
+		entries[len(versions)-1-i] = &VersionEntry{ContactVersion: v, Changes: Diff(previous, snapshot)}
+		previous = snapshot
+	}
+	return entries, nil
+}
+
+// GetVersion returns a single version with its full snapshot
+func (s *HistoryService) GetVersion(userID, contactID uint, version int) (*VersionEntry, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+	c, err := shareService.ResolveContact(uow.DB.Unscoped(), userID, contactID, false)
+	if err != nil {
+		return nil, err
+	}
+	ownerID := c.UserID
+
+	v, err := loadVersion(uow, ownerID, contactID, version)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := decodeSnapshot(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous *contact_version.Snapshot
+	if version > 1 {
+		if pv, err := loadVersion(uow, ownerID, contactID, version-1); err == nil {
+			if previous, err = decodeSnapshot(pv); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &VersionEntry{ContactVersion: v, Changes: Diff(previous, snapshot), Snapshot: snapshot}, nil
+}
+
+// Revert brings a contact the user may change and its details back to the
+// state stored in version and records the result as a new version
+func (s *HistoryService) Revert(userID, contactID, actorID uint, version int) (*contact.Contact, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	c, err := shareService.ResolveContact(uow.DB.Unscoped(), userID, contactID, true)
+	if err != nil {
+		return nil, err
+	}
+	// the contact's rows stay with its owner whoever reverts it
+	userID = c.UserID
+
+	v, err := loadVersion(uow, userID, contactID, version)
+	if err != nil {
+		return nil, err
+	}
+	target, err := decodeSnapshot(v)
+	if err != nil {
+		return nil, err
+	}
+
+	contactUpdates := map[string]interface{}{
+		"f_name":          target.FName,
+		"l_name":          target.LName,
+		"is_active":       target.IsActive,
+		"job_title":       target.JobTitle,
+		"department":      target.Department,
+		"organization_id": nil,
+	}
+	if target.OrganizationID != nil {
+		// the organization may have been deleted since this version was taken
+		var count int64
+		if err := uow.DB.Model(&organization.Organization{}).
+			Where("organization_id = ? AND user_id = ?", *target.OrganizationID, userID).
+			Count(&count).Error; err != nil {
+			return nil, apperror.NewInternalError("failed to load organization")
+		}
+		if count > 0 {
+			contactUpdates["organization_id"] = *target.OrganizationID
+		}
+	}
+	if target.IsActive {
+		contactUpdates["deleted_at"] = nil
+	} else {
+		contactUpdates["deleted_at"] = time.Now()
+	}
+	if err := uow.DB.Unscoped().Model(&contact.Contact{}).
+		Where("contact_id = ? AND user_id = ?", contactID, userID).
+		Updates(contactUpdates).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to revert contact")
+	}
+
+	keep := make(map[uint]bool, len(target.Details))
+	for _, d := range target.Details {
+		keep[d.ID] = true
+		result := uow.DB.Unscoped().Model(&contact_detail.ContactDetail{}).
+			Where("contact_details_id = ? AND contact_id = ? AND user_id = ?", d.ID, contactID, userID).
+			Updates(map[string]interface{}{"type": d.Type, "value": d.Value, "is_active": true, "deleted_at": nil, "version": gorm.Expr("version + 1")})
+		if result.Error != nil {
+			return nil, apperror.NewInternalError("failed to revert contact detail")
+		}
+		if result.RowsAffected == 0 {
+			// the detail row is gone for good; recreate it
+			recreated := &contact_detail.ContactDetail{UserID: userID, ContactID: contactID, Type: d.Type, Value: d.Value, IsActive: true}
+			if err := uow.DB.Create(recreated).Error; err != nil {
+				return nil, apperror.NewInternalError("failed to recreate contact detail")
+			}
+			keep[recreated.ContactDetailsID] = true
+		}
+	}
+
+	var current []*contact_detail.ContactDetail
+	if err := uow.DB.Where("contact_id = ? AND user_id = ?", contactID, userID).Find(&current).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load contact details")
+	}
+	for _, d := range current {
+		if keep[d.ContactDetailsID] {
+			continue
+		}
+		if err := uow.DB.Model(d).Update("is_active", false).Error; err != nil {
+			return nil, apperror.NewInternalError("failed to set detail inactive")
+		}
+		if err := uow.DB.Delete(d).Error; err != nil {
+			return nil, apperror.NewInternalError("failed to soft delete detail")
+		}
+	}
+
+	if err := revertCustomFields(uow, userID, contactID, target.CustomFields); err != nil {
+		return nil, err
+	}
+
+	if err := BumpVersions(uow, contactID); err != nil {
+		return nil, err
+	}
+	if err := RecordVersion(uow, userID, contactID, actorID, contact_version.ActionRevert); err != nil {
+		return nil, err
+	}
+	search.InvalidateOnCommit(uow, userID)
+
+	var reverted contact.Contact
+	if err := uow.DB.Unscoped().Preload("Details").
+		Where("contact_id = ? AND user_id = ?", contactID, userID).
+		First(&reverted).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to reload contact")
+	}
+
+	uow.Commit()
+	return &reverted, nil
+}
+
+// Diff compares two snapshots field by field. A nil old snapshot means the
+// contact was just created, so every field shows up as new.
+func Diff(old, new *contact_version.Snapshot) []Change {
+	changes := []Change{}
+	if old == nil {
+		old = &contact_version.Snapshot{}
+	}
+
+	if old.FName != new.FName {
+		changes = append(changes, Change{Field: "first_name", Old: old.FName, New: new.FName})
+	}
+	if old.LName != new.LName {
+		changes = append(changes, Change{Field: "last_name", Old: old.LName, New: new.LName})
+	}
+	if old.IsActive != new.IsActive {
+		changes = append(changes, Change{Field: "is_active", Old: old.IsActive, New: new.IsActive})
+	}
+	if !sameID(old.OrganizationID, new.OrganizationID) {
+		changes = append(changes, Change{Field: "organization_id", Old: old.OrganizationID, New: new.OrganizationID})
+	}
+	if old.JobTitle != new.JobTitle {
+		changes = append(changes, Change{Field: "job_title", Old: old.JobTitle, New: new.JobTitle})
+	}
+	if old.Department != new.Department {
+		changes = append(changes, Change{Field: "department", Old: old.Department, New: new.Department})
+	}
+
+	oldDetails := make(map[uint]contact_version.DetailSnapshot, len(old.Details))
+	for _, d := range old.Details {
+		oldDetails[d.ID] = d
+	}
+	newDetails := make(map[uint]bool, len(new.Details))
+	for _, d := range new.Details {
+		newDetails[d.ID] = true
+		field := fmt.Sprintf("details.%d", d.ID)
+		prev, existed := oldDetails[d.ID]
+		if !existed {
+			changes = append(changes, Change{Field: field, Old: nil, New: d})
+			continue
+		}
+		if prev.Type != d.Type {
+			changes = append(changes, Change{Field: field + ".type", Old: prev.Type, New: d.Type})
+		}
+		if prev.Value != d.Value {
+			changes = append(changes, Change{Field: field + ".value", Old: prev.Value, New: d.Value})
+		}
+	}
+	for _, d := range old.Details {
+		if !newDetails[d.ID] {
+			changes = append(changes, Change{Field: fmt.Sprintf("details.%d", d.ID), Old: d, New: nil})
+		}
+	}
+
+	keys := make([]string, 0, len(old.CustomFields)+len(new.CustomFields))
+	for key := range old.CustomFields {
+		keys = append(keys, key)
+	}
+	for key := range new.CustomFields {
+		if _, ok := old.CustomFields[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		prev, hadPrev := old.CustomFields[key]
+		next, hasNext := new.CustomFields[key]
+		if hadPrev == hasNext && prev == next {
+			continue
+		}
+		change := Change{Field: "custom_fields." + key}
+		if hadPrev {
+			change.Old = prev
+		}
+		if hasNext {
+			change.New = next
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func takeSnapshot(uow *repository.UnitOfWork, userID, contactID uint) (*contact_version.Snapshot, error) {
+	var c contact.Contact
+	if err := uow.DB.Unscoped().
+		Where("contact_id = ? AND user_id = ?", contactID, userID).
+		First(&c).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewNotFoundError("contact", int(contactID))
+		}
+		return nil, apperror.NewInternalError("failed to load contact for history")
+	}
+
+	snapshot := &contact_version.Snapshot{
+		FName:    c.FName,
+		LName:    c.LName,
+		IsActive: c.IsActive && !c.DeletedAt.Valid,
+		Details:  []contact_version.DetailSnapshot{},
+
+		OrganizationID: c.OrganizationID,
+		JobTitle:       c.JobTitle,
+		Department:     c.Department,
+	}
+	if !snapshot.IsActive {
+		return snapshot, nil
+	}
+
+	var details []*contact_detail.ContactDetail
+	if err := uow.DB.Where("contact_id = ? AND user_id = ? AND is_active = ?", contactID, userID, true).
+		Order("contact_details_id").
+		Find(&details).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load contact details for history")
+	}
+	for _, d := range details {
+		snapshot.Details = append(snapshot.Details, contact_version.DetailSnapshot{ID: d.ContactDetailsID, Type: d.Type, Value: d.Value})
+	}
+
+	var values []struct {
+		FieldKey string
+		Value    string
+	}
+	if err := uow.DB.Table("custom_field_values AS v").
+		Select("d.field_key, v.value").
+		Joins("JOIN custom_field_definitions d ON d.field_id = v.field_id").
+		Where("v.contact_id = ? AND v.user_id = ?", contactID, userID).
+		Scan(&values).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load custom fields for history")
+	}
+	if len(values) > 0 {
+		snapshot.CustomFields = make(map[string]string, len(values))
+		for _, v := range values {
+			snapshot.CustomFields[v.FieldKey] = v.Value
+		}
+	}
+	return snapshot, nil
+}
+
+// revertCustomFields restores the custom field values of a snapshot. Fields
+// deleted since then are skipped.
+func revertCustomFields(uow *repository.UnitOfWork, userID, contactID uint, target map[string]string) error {
+	var fields []*custom_field.CustomFieldDefinition
+	if err := uow.DB.Where("user_id = ?", userID).Find(&fields).Error; err != nil {
+		return apperror.NewInternalError("failed to load custom fields")
+	}
+	var current []*custom_field.CustomFieldValue
+	if err := uow.DB.Where("contact_id = ? AND user_id = ?", contactID, userID).Find(&current).Error; err != nil {
+		return apperror.NewInternalError("failed to load custom field values")
+	}
+	byField := make(map[uint]*custom_field.CustomFieldValue, len(current))
+	for _, v := range current {
+		byField[v.FieldID] = v
+	}
+
+	for _, f := range fields {
+		want, keep := target[f.Key]
+		have := byField[f.FieldID]
+		switch {
+		case !keep && have != nil:
+			if err := uow.DB.Delete(have).Error; err != nil {
+				return apperror.NewInternalError("failed to revert custom field value")
+			}
+		case keep && have == nil:
+			value := &custom_field.CustomFieldValue{UserID: userID, ContactID: contactID, FieldID: f.FieldID, Value: want, NumberValue: numberValue(f, want)}
+			if err := uow.DB.Create(value).Error; err != nil {
+				return apperror.NewInternalError("failed to revert custom field value")
+			}
+		case keep && have.Value != want:
+			if err := uow.DB.Model(have).Updates(map[string]interface{}{"value": want, "number_value": numberValue(f, want)}).Error; err != nil {
+				return apperror.NewInternalError("failed to revert custom field value")
+			}
+		}
+	}
+	return nil
+}
+
+func numberValue(field *custom_field.CustomFieldDefinition, value string) *float64 {
+	if field.Type != custom_field.TypeNumber {
+		return nil
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil
+	}
+	return &n
+}
+
+func sameID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func loadVersion(uow *repository.UnitOfWork, userID, contactID uint, version int) (*contact_version.ContactVersion, error) {
+	var v contact_version.ContactVersion
+	if err := uow.DB.Where("contact_id = ? AND user_id = ? AND version = ?", contactID, userID, version).
+		First(&v).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewNotFoundError("contact version", version)
+		}
+		return nil, apperror.NewInternalError("failed to fetch contact version")
+	}
+	return &v, nil
+}
+
+func decodeSnapshot(v *contact_version.ContactVersion) (*contact_version.Snapshot, error) {
+	var snapshot contact_version.Snapshot
+	if err := json.Unmarshal([]byte(v.Snapshot), &snapshot); err != nil {
+		return nil, apperror.NewInternalError("failed to decode contact snapshot")
+	}
+	return &snapshot, nil
+}
@@ -0,0 +1,239 @@
+package controller
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/component/auth"
+	"Contact_App/component/important_date/service"
+	"Contact_App/export"
+	"Contact_App/models/calendar_feed"
+	"Contact_App/web"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type DateController struct {
+	Service *service.DateService
+}
+
+func NewDateController(svc *service.DateService) *DateController {
+	return &DateController{Service: svc}
+}
+
+// GET /users/{userID}/contacts/{contactID}/dates
+func (c *DateController) ListDatesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+
+	dates, err := c.Service.ListDates(userID, contactID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, dates)
+}
+
+// POST /users/{userID}/contacts/{contactID}/dates
+func (c *DateController) AddDateHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+
+	var input service.DateInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	date, err := c.Service.AddDate(userID, contactID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusCreated, date)
+}
+
+// PUT /users/{userID}/contacts/{contactID}/dates/{dateID}
+func (c *DateController) UpdateDateHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+	dateID, ok := web.ParseID(w, r, "dateID")
+	if !ok {
+		return
+	}
+
+	var input service.DateInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	date, err := c.Service.UpdateDate(userID, contactID, dateID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, date)
+}
+
+// DELETE /users/{userID}/contacts/{contactID}/dates/{dateID}
+func (c *DateController) DeleteDateHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+	dateID, ok := web.ParseID(w, r, "dateID")
+	if !ok {
+		return
+	}
+
+	if err := c.Service.DeleteDate(userID, contactID, dateID); err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, map[string]string{"message": "date deleted"})
+}
+
+// GET /users/{userID}/dates/upcoming?days=30&tz=Europe/Berlin
+func (c *DateController) UpcomingHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	days := 0
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			apperror.HandleBadRequest(w, "days must be a positive number")
+			return
+		}
+		days = parsed
+	}
+
+	now := time.Now()
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			apperror.HandleBadRequest(w, "unknown tz")
+			return
+		}
+		now = now.In(loc)
+	}
+
+	upcoming, err := c.Service.Upcoming(userID, days, now)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, upcoming)
+}
+
+// GET /users/{userID}/calendar-feed
+// Creates the feed token on first call
+func (c *DateController) GetFeedHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	feed, err := c.Service.GetFeed(userID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, feedResponse(r, feed))
+}
+
+// POST /users/{userID}/calendar-feed/rotate
+func (c *DateController) RotateFeedHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	feed, err := c.Service.RotateFeed(userID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, feedResponse(r, feed))
+}
+
+// DELETE /users/{userID}/calendar-feed
+func (c *DateController) DisableFeedHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.Service.DisableFeed(userID); err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, map[string]string{"message": "calendar feed disabled"})
+}
+
+// GET /calendar/{token}.ics
+// Public: the token in the URL is the only credential, so calendar apps can subscribe
+func (c *DateController) FeedHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", export.ICalendarContentType)
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	if err := c.Service.WriteFeed(w, mux.Vars(r)["token"]); err != nil {
+		w.Header().Del("Cache-Control")
+		apperror.HandleError(w, err)
+	}
+}
+
+func feedResponse(r *http.Request, feed *calendar_feed.CalendarFeed) map[string]interface{} {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return map[string]interface{}{
+		"token":      feed.Token,
+		"url":        scheme + "://" + r.Host + "/calendar/" + feed.Token + ".ics",
+		"created_at": feed.CreatedAt,
+	}
+}
+
+func (c *DateController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/dates", c.ListDatesHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/dates", c.AddDateHandler).Methods("POST")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/dates/{dateID:[0-9]+}", c.UpdateDateHandler).Methods("PUT")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/dates/{dateID:[0-9]+}", c.DeleteDateHandler).Methods("DELETE")
+	router.HandleFunc("/users/{userID}/dates/upcoming", c.UpcomingHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/calendar-feed", c.GetFeedHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/calendar-feed/rotate", c.RotateFeedHandler).Methods("POST")
+	router.HandleFunc("/users/{userID}/calendar-feed", c.DisableFeedHandler).Methods("DELETE")
+}
+
+// RegisterPublicRoutes serves the calendar feeds on the root router, outside
+// the authenticated JSON API
+func (c *DateController) RegisterPublicRoutes(router *mux.Router) {
+	router.HandleFunc("/calendar/{token:[0-9a-f]+}.ics", c.FeedHandler).Methods("GET")
+}
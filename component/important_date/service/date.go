@@ -0,0 +1,348 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	shareService "Contact_App/component/share/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_date"
+	"Contact_App/repository"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaxUpcomingDays bounds the look-ahead window of the upcoming dates listing
+const MaxUpcomingDays = 366
+
+// DefaultUpcomingDays is used when the request does not ask for a window
+const DefaultUpcomingDays = 30
+
+var dateTypes = map[string]bool{
+	contact_date.TypeBirthday:    true,
+	contact_date.TypeAnniversary: true,
+	contact_date.TypeOther:       true,
+}
+
+// DateInput is the body accepted when creating or replacing a date. Date is
+// either YYYY-MM-DD or --MM-DD when the year is unknown.
+type DateInput struct {
+	Type  string `json:"type"`
+	Label string `json:"label"`
+	Date  string `json:"date"`
+}
+
+// UpcomingDate is one occurrence of a contact date inside the requested window
+type UpcomingDate struct {
+	DateID    uint   `json:"date_id"`
+	ContactID uint   `json:"contact_id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Type      string `json:"type"`
+	Label     string `json:"label,omitempty"`
+	Date      string `json:"date"`
+	Next      string `json:"next"`
+	DaysUntil int    `json:"days_until"`
+	// Years is the age reached or the anniversary count, when the year is known
+	Years *int `json:"years,omitempty"`
+}
+
+type DateService struct {
+	repo repository.Repository
+}
+
+func NewDateService() *DateService {
+	return &DateService{repo: repository.NewGormRepository()}
+}
+
+// ListDates returns the dates of a contact the user can see in calendar order
+func (s *DateService) ListDates(userID, contactID uint) ([]*contact_date.ContactDate, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := shareService.ResolveContact(uow.DB, userID, contactID, false); err != nil {
+		return nil, err
+	}
+
+	dates := []*contact_date.ContactDate{}
+	if err := uow.DB.Where("contact_id = ?", contactID).
+		Order("month, day, date_id").
+		Find(&dates).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load dates")
+	}
+	return dates, nil
+}
+
+// AddDate creates a date on a contact the user may change. The date belongs
+// to the contact's owner. A contact has at most one birthday.
+func (s *DateService) AddDate(userID, contactID uint, input DateInput) (*contact_date.ContactDate, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	c, err := shareService.ResolveContact(uow.DB, userID, contactID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &contact_date.ContactDate{UserID: c.UserID, ContactID: contactID}
+	if err := applyInput(d, input); err != nil {
+		return nil, err
+	}
+	if err := ensureSingleBirthday(uow, d); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Add(uow, d); err != nil {
+		return nil, err
+	}
+
+	uow.Commit()
+	return d, nil
+}
+
+// UpdateDate replaces type, label and date of an existing entry
+func (s *DateService) UpdateDate(userID, contactID, dateID uint, input DateInput) (*contact_date.ContactDate, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	d, err := findDate(uow, userID, contactID, dateID)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyInput(d, input); err != nil {
+		return nil, err
+	}
+	if err := ensureSingleBirthday(uow, d); err != nil {
+		return nil, err
+	}
+	if err := uow.DB.Select("type", "label", "month", "day", "year").Save(d).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to update date")
+	}
+
+	uow.Commit()
+	return d, nil
+}
+
+func (s *DateService) DeleteDate(userID, contactID, dateID uint) error {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return err
+	}
+	defer uow.Rollback()
+
+	d, err := findDate(uow, userID, contactID, dateID)
+	if err != nil {
+		return err
+	}
+	if err := uow.DB.Delete(d).Error; err != nil {
+		return apperror.NewInternalError("failed to delete date")
+	}
+
+	uow.Commit()
+	return nil
+}
+
+// DeleteDatesWithUOW drops the dates of contacts being permanently deleted
+func DeleteDatesWithUOW(uow *repository.UnitOfWork, contactIDs []uint) error {
+	if err := uow.DB.Where("contact_id IN ?", contactIDs).Delete(&contact_date.ContactDate{}).Error; err != nil {
+		return apperror.NewInternalError("failed to delete contact dates")
+	}
+	return nil
+}
+
+// Upcoming lists every date of the active contacts the user can see that falls within
+// the next days days, counting today, as seen from now's location. Feb 29 is
+// observed on Feb 28 in common years.
+func (s *DateService) Upcoming(userID uint, days int, now time.Time) ([]*UpcomingDate, error) {
+	if days <= 0 {
+		days = DefaultUpcomingDays
+	}
+	if days > MaxUpcomingDays {
+		return nil, apperror.NewValidationError("days", fmt.Sprintf("must be at most %d", MaxUpcomingDays))
+	}
+
+	rows, err := loadContactDates(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	today := civilDate(now)
+	upcoming := []*UpcomingDate{}
+	for _, row := range rows {
+		next := nextOccurrence(row.Month, row.Day, today)
+		until := int(next.Sub(today).Hours() / 24)
+		if until >= days {
+			continue
+		}
+
+		item := &UpcomingDate{
+			DateID:    row.DateID,
+			ContactID: row.ContactID,
+			FirstName: row.FName,
+			LastName:  row.LName,
+			Type:      row.Type,
+			Label:     row.Label,
+			Date:      row.Format(),
+			Next:      next.Format("2006-01-02"),
+			DaysUntil: until,
+		}
+		if row.Year != nil && *row.Year <= next.Year() {
+			years := next.Year() - *row.Year
+			item.Years = &years
+		}
+		upcoming = append(upcoming, item)
+	}
+
+	sort.SliceStable(upcoming, func(i, j int) bool {
+		if upcoming[i].DaysUntil != upcoming[j].DaysUntil {
+			return upcoming[i].DaysUntil < upcoming[j].DaysUntil
+		}
+		if upcoming[i].LastName != upcoming[j].LastName {
+			return upcoming[i].LastName < upcoming[j].LastName
+		}
+		return upcoming[i].FirstName < upcoming[j].FirstName
+	})
+	return upcoming, nil
+}
+
+// contactDateRow is a date joined with the name of its contact
+type contactDateRow struct {
+	contact_date.ContactDate
+	FName string `gorm:"column:f_name"`
+	LName string `gorm:"column:l_name"`
+}
+
+// loadContactDates lists the dates of the active contacts the user can see
+func loadContactDates(userID uint) ([]*contactDateRow, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []*contactDateRow
+	if err := uow.DB.Model(&contact.Contact{}).
+		Select("contact_dates.*, contacts.f_name, contacts.l_name").
+		Joins("JOIN contact_dates ON contact_dates.contact_id = contacts.contact_id").
+		Where(shareService.AccessibleContacts(uow.DB, userID)).
+		Where("contacts.is_active = ?", true).
+		Order("contact_dates.month, contact_dates.day, contact_dates.date_id").
+		Scan(&rows).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load dates")
+	}
+	return rows, nil
+}
+
+// ParseDate accepts YYYY-MM-DD or --MM-DD and returns month, day and the
+// optional year
+func ParseDate(value string) (int, int, *int, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "--") {
+		// validate against a leap year so --02-29 is accepted
+		t, err := time.Parse("2006-01-02", "2000-"+value[2:])
+		if err != nil {
+			return 0, 0, nil, apperror.NewValidationError("date", "must be YYYY-MM-DD or --MM-DD")
+		}
+		return int(t.Month()), t.Day(), nil, nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return 0, 0, nil, apperror.NewValidationError("date", "must be YYYY-MM-DD or --MM-DD")
+	}
+	year := t.Year()
+	return int(t.Month()), t.Day(), &year, nil
+}
+
+func applyInput(d *contact_date.ContactDate, input DateInput) error {
+	dateType := strings.ToLower(strings.TrimSpace(input.Type))
+	if dateType == "" {
+		dateType = contact_date.TypeOther
+	}
+	if !dateTypes[dateType] {
+		return apperror.NewValidationError("type", "must be birthday, anniversary or other")
+	}
+
+	label := strings.TrimSpace(input.Label)
+	if len(label) > 255 {
+		return apperror.NewValidationError("label", "must be at most 255 characters")
+	}
+	if dateType == contact_date.TypeOther && label == "" {
+		return apperror.NewValidationError("label", "is required for type other")
+	}
+
+	month, day, year, err := ParseDate(input.Date)
+	if err != nil {
+		return err
+	}
+
+	d.Type, d.Label = dateType, label
+	d.Month, d.Day, d.Year = month, day, year
+	d.Date = d.Format()
+	return nil
+}
+
+func ensureSingleBirthday(uow *repository.UnitOfWork, d *contact_date.ContactDate) error {
+	if d.Type != contact_date.TypeBirthday {
+		return nil
+	}
+	var count int64
+	if err := uow.DB.Model(&contact_date.ContactDate{}).
+		Where("contact_id = ? AND type = ? AND date_id <> ?", d.ContactID, contact_date.TypeBirthday, d.DateID).
+		Count(&count).Error; err != nil {
+		return apperror.NewInternalError("failed to check existing birthday")
+	}
+	if count > 0 {
+		return apperror.NewConflictError("birthday", "contact already has a birthday")
+	}
+	return nil
+}
+
+// findDate loads a date of a contact the user may change
+func findDate(uow *repository.UnitOfWork, userID, contactID, dateID uint) (*contact_date.ContactDate, error) {
+	if _, err := shareService.ResolveContact(uow.DB, userID, contactID, true); err != nil {
+		return nil, err
+	}
+	var d contact_date.ContactDate
+	if err := uow.DB.Where("date_id = ? AND contact_id = ?", dateID, contactID).
+		First(&d).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewNotFoundError("date", int(dateID))
+		}
+		return nil, apperror.NewInternalError("failed to load date")
+	}
+	return &d, nil
+}
+
+// civilDate drops the time of day, keeping the calendar date of t's location.
+// Dates are compared in UTC so DST shifts never change a day count.
+func civilDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func nextOccurrence(month, day int, today time.Time) time.Time {
+	next := occurrenceIn(today.Year(), month, day)
+	if next.Before(today) {
+		next = occurrenceIn(today.Year()+1, month, day)
+	}
+	return next
+}
+
+func occurrenceIn(year, month, day int) time.Time {
+	if month == 2 && day == 29 && !isLeap(year) {
+		day = 28
+	}
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+func isLeap(year int) bool {
+	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
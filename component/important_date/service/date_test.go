@@ -0,0 +1,73 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	year := func(y int) *int { return &y }
+	tests := []struct {
+		value     string
+		wantMonth int
+		wantDay   int
+		wantYear  *int
+		wantErr   bool
+	}{
+		{value: "1990-07-14", wantMonth: 7, wantDay: 14, wantYear: year(1990)},
+		{value: " 1990-07-14 ", wantMonth: 7, wantDay: 14, wantYear: year(1990)},
+		{value: "--07-14", wantMonth: 7, wantDay: 14},
+		{value: "--02-29", wantMonth: 2, wantDay: 29},
+		{value: "2000-02-29", wantMonth: 2, wantDay: 29, wantYear: year(2000)},
+		{value: "2001-02-29", wantErr: true},
+		{value: "--02-30", wantErr: true},
+		{value: "--13-01", wantErr: true},
+		{value: "14/07/1990", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			month, day, year, err := ParseDate(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDate(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if month != tt.wantMonth || day != tt.wantDay {
+				t.Errorf("ParseDate(%q) = %d-%d, want %d-%d", tt.value, month, day, tt.wantMonth, tt.wantDay)
+			}
+			if (year == nil) != (tt.wantYear == nil) || (year != nil && *year != *tt.wantYear) {
+				t.Errorf("ParseDate(%q) year = %v, want %v", tt.value, year, tt.wantYear)
+			}
+		})
+	}
+}
+
+func TestNextOccurrence(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name       string
+		month, day int
+		today      time.Time
+		wantNext   time.Time
+	}{
+		{"later this year", 7, 14, date(2023, 3, 1), date(2023, 7, 14)},
+		{"today", 7, 14, date(2023, 7, 14), date(2023, 7, 14)},
+		{"already passed", 7, 14, date(2023, 7, 15), date(2024, 7, 14)},
+		{"new year wrap", 1, 1, date(2023, 12, 31), date(2024, 1, 1)},
+		{"leap day in a leap year", 2, 29, date(2024, 1, 10), date(2024, 2, 29)},
+		{"leap day falls on 28th otherwise", 2, 29, date(2023, 1, 10), date(2023, 2, 28)},
+		{"leap day passed, next year is a leap year", 2, 29, date(2023, 3, 1), date(2024, 2, 29)},
+		{"leap day in a century year", 2, 29, date(1900, 1, 1), date(1900, 2, 28)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextOccurrence(tt.month, tt.day, tt.today); !got.Equal(tt.wantNext) {
+				t.Errorf("nextOccurrence(%d, %d, %v) = %v, want %v", tt.month, tt.day, tt.today, got, tt.wantNext)
+			}
+		})
+	}
+}
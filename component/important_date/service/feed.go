@@ -0,0 +1,147 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/db"
+	"Contact_App/export"
+	"Contact_App/models/calendar_feed"
+	"Contact_App/models/contact_date"
+	"Contact_App/repository"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GetFeed returns the user's calendar feed, creating a token on first use
+func (s *DateService) GetFeed(userID uint) (*calendar_feed.CalendarFeed, error) {
+	uow := repository.NewUnitOfWork(db.GetDB(), false)
+	defer uow.Rollback()
+
+	var feed calendar_feed.CalendarFeed
+	err := uow.DB.Where("user_id = ?", userID).First(&feed).Error
+	if err == nil {
+		return &feed, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, apperror.NewInternalError("failed to load calendar feed")
+	}
+
+	token, err := newFeedToken()
+	if err != nil {
+		return nil, err
+	}
+	feed = calendar_feed.CalendarFeed{UserID: userID, Token: token}
+	if err := s.repo.Add(uow, &feed); err != nil {
+		return nil, err
+	}
+
+	uow.Commit()
+	return &feed, nil
+}
+
+// RotateFeed replaces the token so previously shared feed URLs stop working
+func (s *DateService) RotateFeed(userID uint) (*calendar_feed.CalendarFeed, error) {
+	uow := repository.NewUnitOfWork(db.GetDB(), false)
+	defer uow.Rollback()
+
+	token, err := newFeedToken()
+	if err != nil {
+		return nil, err
+	}
+	feed := calendar_feed.CalendarFeed{UserID: userID, Token: token}
+	if err := uow.DB.Where("user_id = ?", userID).Delete(&calendar_feed.CalendarFeed{}).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to rotate calendar feed")
+	}
+	if err := s.repo.Add(uow, &feed); err != nil {
+		return nil, err
+	}
+
+	uow.Commit()
+	return &feed, nil
+}
+
+// DisableFeed removes the token; a later GetFeed issues a fresh one
+func (s *DateService) DisableFeed(userID uint) error {
+	if err := db.GetDB().Where("user_id = ?", userID).Delete(&calendar_feed.CalendarFeed{}).Error; err != nil {
+		return apperror.NewInternalError("failed to disable calendar feed")
+	}
+	return nil
+}
+
+// WriteFeed renders the calendar behind token as iCalendar. Every date
+// becomes an all-day event repeating yearly.
+func (s *DateService) WriteFeed(w io.Writer, token string) error {
+	var feed calendar_feed.CalendarFeed
+	if err := db.GetDB().Where("token = ?", token).First(&feed).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return apperror.NewNotFoundError("calendar feed", 0)
+		}
+		return apperror.NewInternalError("failed to load calendar feed")
+	}
+
+	rows, err := loadContactDates(feed.UserID)
+	if err != nil {
+		return err
+	}
+
+	events := make([]export.CalendarEvent, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, calendarEvent(row))
+	}
+	return export.WriteICalendar(w, "Contact dates", events)
+}
+
+func calendarEvent(row *contactDateRow) export.CalendarEvent {
+	name := strings.TrimSpace(row.FName + " " + row.LName)
+
+	var summary, description string
+	switch row.Type {
+	case contact_date.TypeBirthday:
+		summary = name + "'s birthday"
+		if row.Year != nil {
+			description = fmt.Sprintf("Born %d", *row.Year)
+		}
+	case contact_date.TypeAnniversary:
+		summary = name + "'s anniversary"
+		if row.Label != "" {
+			summary = name + ": " + row.Label
+		}
+		if row.Year != nil {
+			description = fmt.Sprintf("Since %d", *row.Year)
+		}
+	default:
+		summary = name + ": " + row.Label
+	}
+
+	// without a year the series starts in a leap year so Feb 29 is valid
+	year := 2000
+	if row.Year != nil {
+		year = *row.Year
+	}
+	rrule := "FREQ=YEARLY"
+	if row.Month == 2 && row.Day == 29 {
+		// last day of February: the 29th in leap years, the 28th otherwise
+		rrule = "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=-1"
+	}
+
+	return export.CalendarEvent{
+		UID:         fmt.Sprintf("contact-date-%d@contact-app", row.DateID),
+		Summary:     summary,
+		Description: description,
+		Date:        time.Date(year, time.Month(row.Month), row.Day, 0, 0, 0, 0, time.UTC),
+		RRule:       rrule,
+	}
+}
+
+func newFeedToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", apperror.NewInternalError("failed to generate feed token")
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/component/auth"
+	"Contact_App/component/interaction/service"
+	"Contact_App/web"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type InteractionController struct {
+	Service *service.InteractionService
+}
+
+func NewInteractionController(svc *service.InteractionService) *InteractionController {
+	return &InteractionController{Service: svc}
+}
+
+// GET /users/{userID}/contacts/{contactID}/interactions?kind=call
+func (c *InteractionController) ListInteractionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+
+	entries, err := c.Service.ListInteractions(userID, contactID, r.URL.Query().Get("kind"))
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, entries)
+}
+
+// GET /users/{userID}/contacts/{contactID}/interactions/{interactionID}
+func (c *InteractionController) GetInteractionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+	interactionID, ok := web.ParseID(w, r, "interactionID")
+	if !ok {
+		return
+	}
+
+	entry, err := c.Service.GetInteraction(userID, contactID, interactionID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, entry)
+}
+
+// POST /users/{userID}/contacts/{contactID}/interactions
+func (c *InteractionController) AddInteractionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+
+	var input service.InteractionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	entry, err := c.Service.AddInteraction(userID, contactID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusCreated, entry)
+}
+
+// PUT /users/{userID}/contacts/{contactID}/interactions/{interactionID}
+func (c *InteractionController) UpdateInteractionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+	interactionID, ok := web.ParseID(w, r, "interactionID")
+	if !ok {
+		return
+	}
+
+	var input service.InteractionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	entry, err := c.Service.UpdateInteraction(userID, contactID, interactionID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, entry)
+}
+
+// DELETE /users/{userID}/contacts/{contactID}/interactions/{interactionID}
+func (c *InteractionController) DeleteInteractionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+	interactionID, ok := web.ParseID(w, r, "interactionID")
+	if !ok {
+		return
+	}
+
+	if err := c.Service.DeleteInteraction(userID, contactID, interactionID); err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, map[string]string{"message": "interaction deleted"})
+}
+
+func (c *InteractionController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/interactions", c.ListInteractionsHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/interactions", c.AddInteractionHandler).Methods("POST")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/interactions/{interactionID:[0-9]+}", c.GetInteractionHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/interactions/{interactionID:[0-9]+}", c.UpdateInteractionHandler).Methods("PUT")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/interactions/{interactionID:[0-9]+}", c.DeleteInteractionHandler).Methods("DELETE")
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	shareService "Contact_App/component/share/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/models/interaction"
+	"Contact_App/repository"
+	"Contact_App/search"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var kinds = map[string]bool{
+	interaction.KindNote:    true,
+	interaction.KindCall:    true,
+	interaction.KindEmail:   true,
+	interaction.KindMeeting: true,
+}
+
+// InteractionInput is the body accepted when creating or replacing an entry.
+// OccurredAt defaults to now on create.
+type InteractionInput struct {
+	Kind            string     `json:"kind"`
+	Body            string     `json:"body"`
+	OccurredAt      *time.Time `json:"occurred_at"`
+	DurationMinutes *int       `json:"duration_minutes"`
+}
+
+type InteractionService struct {
+	repo repository.Repository
+}
+
+func NewInteractionService() *InteractionService {
+	return &InteractionService{repo: repository.NewGormRepository()}
+}
+
+// ListInteractions returns the log of a contact the user can see, newest
+// first, optionally limited to one kind
+func (s *InteractionService) ListInteractions(userID, contactID uint, kind string) ([]*interaction.Interaction, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := shareService.ResolveContact(uow.DB, userID, contactID, false); err != nil {
+		return nil, err
+	}
+
+	query := uow.DB.Where("contact_id = ?", contactID)
+	if kind = strings.ToLower(strings.TrimSpace(kind)); kind != "" {
+		if !kinds[kind] {
+			return nil, apperror.NewValidationError("kind", "must be note, call, email or meeting")
+		}
+		query = query.Where("kind = ?", kind)
+	}
+
+	entries := []*interaction.Interaction{}
+	if err := query.Order("occurred_at DESC, interaction_id DESC").Find(&entries).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load interactions")
+	}
+	return entries, nil
+}
+
+func (s *InteractionService) GetInteraction(userID, contactID, interactionID uint) (*interaction.Interaction, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := shareService.ResolveContact(uow.DB, userID, contactID, false); err != nil {
+		return nil, err
+	}
+	return findInteraction(uow, contactID, interactionID)
+}
+
+// AddInteraction logs an interaction with a contact the user may change. The
+// log belongs to the contact's owner, like the rest of its data.
+func (s *InteractionService) AddInteraction(userID, contactID uint, input InteractionInput) (*interaction.Interaction, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	c, err := shareService.ResolveContact(uow.DB, userID, contactID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &interaction.Interaction{UserID: c.UserID, ContactID: contactID, OccurredAt: time.Now()}
+	if err := applyInput(entry, input); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Add(uow, entry); err != nil {
+		return nil, err
+	}
+	invalidateNotes(uow, entry)
+
+	uow.Commit()
+	return entry, nil
+}
+
+// UpdateInteraction replaces an entry. occurred_at is kept when omitted.
+func (s *InteractionService) UpdateInteraction(userID, contactID, interactionID uint, input InteractionInput) (*interaction.Interaction, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	if _, err := shareService.ResolveContact(uow.DB, userID, contactID, true); err != nil {
+		return nil, err
+	}
+	entry, err := findInteraction(uow, contactID, interactionID)
+	if err != nil {
+		return nil, err
+	}
+	wasNote := entry.Kind == interaction.KindNote
+	if err := applyInput(entry, input); err != nil {
+		return nil, err
+	}
+	if err := uow.DB.Select("kind", "body", "occurred_at", "duration_minutes").Save(entry).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to update interaction")
+	}
+	if wasNote {
+		search.InvalidateOnCommit(uow, entry.UserID)
+	} else {
+		invalidateNotes(uow, entry)
+	}
+
+	uow.Commit()
+	return entry, nil
+}
+
+func (s *InteractionService) DeleteInteraction(userID, contactID, interactionID uint) error {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return err
+	}
+	defer uow.Rollback()
+
+	if _, err := shareService.ResolveContact(uow.DB, userID, contactID, true); err != nil {
+		return err
+	}
+	entry, err := findInteraction(uow, contactID, interactionID)
+	if err != nil {
+		return err
+	}
+	if err := uow.DB.Delete(entry).Error; err != nil {
+		return apperror.NewInternalError("failed to delete interaction")
+	}
+	invalidateNotes(uow, entry)
+
+	uow.Commit()
+	return nil
+}
+
+// DeleteInteractionsWithUOW drops the log of contacts being permanently deleted
+func DeleteInteractionsWithUOW(uow *repository.UnitOfWork, contactIDs []uint) error {
+	if err := uow.DB.Unscoped().Where("contact_id IN ?", contactIDs).
+		Delete(&interaction.Interaction{}).Error; err != nil {
+		return apperror.NewInternalError("failed to delete interactions")
+	}
+	return nil
+}
+
+// LastContacted returns the latest past call, email or meeting per contact.
+// Contacts without one are absent from the map.
+func LastContacted(conn *gorm.DB, userID uint, contactIDs []uint) (map[uint]time.Time, error) {
+	last := make(map[uint]time.Time)
+	if len(contactIDs) == 0 {
+		return last, nil
+	}
+
+	var rows []struct {
+		ContactID uint
+		Last      time.Time
+	}
+	if err := conn.Model(&interaction.Interaction{}).
+		Select("contact_id, MAX(occurred_at) AS last").
+		Where("user_id = ? AND contact_id IN ? AND kind <> ? AND occurred_at <= ?", userID, contactIDs, interaction.KindNote, time.Now()).
+		Group("contact_id").
+		Scan(&rows).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load last contacted")
+	}
+	for _, row := range rows {
+		last[row.ContactID] = row.Last
+	}
+	return last, nil
+}
+
+// Notes returns the bodies of the owner's notes per contact, oldest first
+func Notes(conn *gorm.DB, ownerID uint) (map[uint][]string, error) {
+	var rows []*interaction.Interaction
+	if err := conn.Select("contact_id", "body").
+		Where("user_id = ? AND kind = ?", ownerID, interaction.KindNote).
+		Order("occurred_at, interaction_id").
+		Find(&rows).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load notes")
+	}
+	notes := make(map[uint][]string)
+	for _, row := range rows {
+		notes[row.ContactID] = append(notes[row.ContactID], row.Body)
+	}
+	return notes, nil
+}
+
+// ContactedSince selects the contact ids with a call, email or meeting
+// between since and now, for use as a subquery of a contacts query that
+// already limits who may see them
+func ContactedSince(conn *gorm.DB, since time.Time) *gorm.DB {
+	return conn.Model(&interaction.Interaction{}).
+		Select("contact_id").
+		Where("kind <> ? AND occurred_at >= ? AND occurred_at <= ?", interaction.KindNote, since, time.Now())
+}
+
+func applyInput(entry *interaction.Interaction, input InteractionInput) error {
+	kind := strings.ToLower(strings.TrimSpace(input.Kind))
+	if !kinds[kind] {
+		return apperror.NewValidationError("kind", "must be note, call, email or meeting")
+	}
+
+	body := strings.TrimSpace(input.Body)
+	if kind == interaction.KindNote && body == "" {
+		return apperror.NewValidationError("body", "is required for notes")
+	}
+	if len(body) > 65535 {
+		return apperror.NewValidationError("body", "must be at most 65535 bytes")
+	}
+
+	if input.DurationMinutes != nil {
+		if kind == interaction.KindNote {
+			return apperror.NewValidationError("duration_minutes", "is not allowed on notes")
+		}
+		if *input.DurationMinutes < 0 {
+			return apperror.NewValidationError("duration_minutes", "cannot be negative")
+		}
+	}
+
+	entry.Kind = kind
+	entry.Body = body
+	entry.DurationMinutes = input.DurationMinutes
+	if input.OccurredAt != nil {
+		entry.OccurredAt = *input.OccurredAt
+	}
+	return nil
+}
+
+// invalidateNotes drops the owner's search documents once uow commits when
+// entry is a note, since notes are searchable
+func invalidateNotes(uow *repository.UnitOfWork, entry *interaction.Interaction) {
+	if entry.Kind == interaction.KindNote {
+		search.InvalidateOnCommit(uow, entry.UserID)
+	}
+}
+
+func findInteraction(uow *repository.UnitOfWork, contactID, interactionID uint) (*interaction.Interaction, error) {
+	var entry interaction.Interaction
+	if err := uow.DB.Where("interaction_id = ? AND contact_id = ?", interactionID, contactID).
+		First(&entry).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewNotFoundError("interaction", int(interactionID))
+		}
+		return nil, apperror.NewInternalError("failed to load interaction")
+	}
+	return &entry, nil
+}
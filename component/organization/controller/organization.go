@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/component/auth"
+	"Contact_App/component/organization/service"
+	"Contact_App/web"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type OrganizationController struct {
+	Service *service.OrganizationService
+}
+
+func NewOrganizationController(svc *service.OrganizationService) *OrganizationController {
+	return &OrganizationController{Service: svc}
+}
+
+// GET /users/{userID}/organizations?q=acme
+func (c *OrganizationController) ListOrganizationsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	orgs, err := c.Service.ListOrganizations(userID, r.URL.Query().Get("q"))
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, orgs)
+}
+
+// POST /users/{userID}/organizations
+func (c *OrganizationController) CreateOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	var input service.OrganizationInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	org, err := c.Service.CreateOrganization(userID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusCreated, org)
+}
+
+// GET /users/{userID}/organizations/{orgID}
+func (c *OrganizationController) GetOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	orgID, ok := web.ParseID(w, r, "orgID")
+	if !ok {
+		return
+	}
+
+	org, err := c.Service.GetOrganization(userID, orgID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, org)
+}
+
+// PUT /users/{userID}/organizations/{orgID}
+func (c *OrganizationController) UpdateOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	orgID, ok := web.ParseID(w, r, "orgID")
+	if !ok {
+		return
+	}
+
+	var input service.OrganizationInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	org, err := c.Service.UpdateOrganization(userID, orgID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, org)
+}
+
+// DELETE /users/{userID}/organizations/{orgID}
+func (c *OrganizationController) DeleteOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	orgID, ok := web.ParseID(w, r, "orgID")
+	if !ok {
+		return
+	}
+
+	if err := c.Service.DeleteOrganization(userID, orgID); err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, map[string]string{"message": "organization deleted"})
+}
+
+// GET /users/{userID}/organizations/{orgID}/contacts
+func (c *OrganizationController) ListContactsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	orgID, ok := web.ParseID(w, r, "orgID")
+	if !ok {
+		return
+	}
+
+	contacts, err := c.Service.ListContacts(userID, orgID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, contacts)
+}
+
+// GET /users/{userID}/organizations/suggest?email=jane@example.com
+func (c *OrganizationController) SuggestHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	email := r.URL.Query().Get("email")
+	if service.EmailDomain(email) == "" {
+		apperror.HandleBadRequest(w, "email must be a valid email address")
+		return
+	}
+
+	org, err := c.Service.SuggestForEmail(userID, email)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, map[string]interface{}{"organization": org})
+}
+
+func (c *OrganizationController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/users/{userID}/organizations", c.ListOrganizationsHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/organizations", c.CreateOrganizationHandler).Methods("POST")
+	router.HandleFunc("/users/{userID}/organizations/suggest", c.SuggestHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/organizations/{orgID:[0-9]+}", c.GetOrganizationHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/organizations/{orgID:[0-9]+}", c.UpdateOrganizationHandler).Methods("PUT")
+	router.HandleFunc("/users/{userID}/organizations/{orgID:[0-9]+}", c.DeleteOrganizationHandler).Methods("DELETE")
+	router.HandleFunc("/users/{userID}/organizations/{orgID:[0-9]+}/contacts", c.ListContactsHandler).Methods("GET")
+}
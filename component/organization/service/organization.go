@@ -0,0 +1,359 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	history "Contact_App/component/history/service"
+	shareService "Contact_App/component/share/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/db"
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_version"
+	"Contact_App/models/organization"
+	"Contact_App/repository"
+	"Contact_App/search"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var domainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}$`)
+
+// OrganizationInput is the body accepted when creating or replacing an organization
+type OrganizationInput struct {
+	Name    string `json:"name"`
+	Domain  string `json:"domain"`
+	Address string `json:"address"`
+	Notes   string `json:"notes"`
+}
+
+type OrganizationService struct {
+	repo repository.Repository
+}
+
+func NewOrganizationService() *OrganizationService {
+	return &OrganizationService{repo: repository.NewGormRepository()}
+}
+
+// ListOrganizations returns the user's organizations by name with their
+// contact counts. q filters on name or domain.
+func (s *OrganizationService) ListOrganizations(userID uint, q string) ([]*organization.Organization, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	query := uow.DB.Where("user_id = ?", userID)
+	if q = strings.TrimSpace(q); q != "" {
+		query = query.Where("name LIKE ? OR domain LIKE ?", "%"+q+"%", "%"+q+"%")
+	}
+
+	orgs := []*organization.Organization{}
+	if err := query.Order("name").Find(&orgs).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load organizations")
+	}
+	if len(orgs) == 0 {
+		return orgs, nil
+	}
+
+	var counts []struct {
+		OrganizationID uint
+		Count          int64
+	}
+	if err := uow.DB.Model(&contact.Contact{}).
+		Select("organization_id, COUNT(*) AS count").
+		Where("organization_id IN ? AND is_active = ?", organizationIDs(orgs), true).
+		Group("organization_id").
+		Scan(&counts).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to count organization contacts")
+	}
+	byID := make(map[uint]int64, len(counts))
+	for _, c := range counts {
+		byID[c.OrganizationID] = c.Count
+	}
+	for _, o := range orgs {
+		count := byID[o.OrganizationID]
+		o.ContactCount = &count
+	}
+	return orgs, nil
+}
+
+func (s *OrganizationService) GetOrganization(userID, orgID uint) (*organization.Organization, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+	return findOrganization(uow, userID, orgID)
+}
+
+func (s *OrganizationService) CreateOrganization(userID uint, input OrganizationInput) (*organization.Organization, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	org := &organization.Organization{UserID: userID}
+	if err := applyInput(org, input); err != nil {
+		return nil, err
+	}
+	if err := ensureUniqueName(uow, org); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Add(uow, org); err != nil {
+		return nil, err
+	}
+
+	uow.Commit()
+	return org, nil
+}
+
+func (s *OrganizationService) UpdateOrganization(userID, orgID uint, input OrganizationInput) (*organization.Organization, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	org, err := findOrganization(uow, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyInput(org, input); err != nil {
+		return nil, err
+	}
+	if err := ensureUniqueName(uow, org); err != nil {
+		return nil, err
+	}
+	if err := uow.DB.Select("name", "domain", "address", "notes").Save(org).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to update organization")
+	}
+	// contacts are searchable by their organization's name
+	search.InvalidateOnCommit(uow, userID)
+
+	uow.Commit()
+	return org, nil
+}
+
+// DeleteOrganization removes an organization and unlinks its contacts, which
+// keep their job title and department
+func (s *OrganizationService) DeleteOrganization(userID, orgID uint) error {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return err
+	}
+	defer uow.Rollback()
+
+	org, err := findOrganization(uow, userID, orgID)
+	if err != nil {
+		return err
+	}
+
+	var contactIDs []uint
+	if err := uow.DB.Model(&contact.Contact{}).
+		Where("organization_id = ?", orgID).
+		Pluck("contact_id", &contactIDs).Error; err != nil {
+		return apperror.NewInternalError("failed to load organization contacts")
+	}
+	// soft deleted contacts are unlinked too so they never point at a missing row
+	if err := uow.DB.Unscoped().Model(&contact.Contact{}).
+		Where("organization_id = ?", orgID).
+		UpdateColumn("organization_id", nil).Error; err != nil {
+		return apperror.NewInternalError("failed to unlink contacts")
+	}
+	if err := history.BumpVersions(uow, contactIDs...); err != nil {
+		return err
+	}
+	for _, id := range contactIDs {
+		if err := history.RecordVersion(uow, userID, id, userID, contact_version.ActionUpdate); err != nil {
+			return err
+		}
+	}
+	if len(contactIDs) > 0 {
+		search.InvalidateOnCommit(uow, userID)
+	}
+
+	if err := uow.DB.Delete(org).Error; err != nil {
+		return apperror.NewInternalError("failed to delete organization")
+	}
+
+	uow.Commit()
+	return nil
+}
+
+// ListContacts returns the active contacts linked to an organization
+func (s *OrganizationService) ListContacts(userID, orgID uint) ([]*contact.Contact, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := findOrganization(uow, userID, orgID); err != nil {
+		return nil, err
+	}
+
+	contacts := []*contact.Contact{}
+	if err := uow.DB.Preload("Details").
+		Where("organization_id = ? AND is_active = ?", orgID, true).
+		Order("l_name, f_name, contact_id").
+		Find(&contacts).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load organization contacts")
+	}
+	return contacts, nil
+}
+
+// SuggestForEmail finds the user's organization whose domain matches the
+// email address, or a parent domain of it (mail.example.com -> example.com).
+// It returns nil when nothing matches.
+func (s *OrganizationService) SuggestForEmail(userID uint, email string) (*organization.Organization, error) {
+	domain := EmailDomain(email)
+	if domain == "" {
+		return nil, nil
+	}
+
+	candidates := []string{}
+	for d := domain; strings.Contains(d, "."); d = d[strings.Index(d, ".")+1:] {
+		candidates = append(candidates, d)
+	}
+
+	var orgs []*organization.Organization
+	if err := db.GetDB().Where("user_id = ? AND domain IN ?", userID, candidates).
+		Find(&orgs).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to look up organization")
+	}
+
+	// the most specific domain wins
+	var best *organization.Organization
+	for _, o := range orgs {
+		if best == nil || len(o.Domain) > len(best.Domain) ||
+			(len(o.Domain) == len(best.Domain) && o.OrganizationID < best.OrganizationID) {
+			best = o
+		}
+	}
+	return best, nil
+}
+
+// SuggestForContact suggests an organization of the contact's owner for a
+// contact that is not yet linked to one, based on a newly added email address
+func (s *OrganizationService) SuggestForContact(userID, contactID uint, email string) (*organization.Organization, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := shareService.ResolveContact(uow.DB, userID, contactID, false)
+	if err != nil {
+		return nil, err
+	}
+	var c contact.Contact
+	if err := uow.DB.Select("contact_id", "organization_id").
+		Where("contact_id = ?", contactID).
+		First(&c).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, apperror.NewInternalError("failed to load contact")
+	}
+	if c.OrganizationID != nil {
+		return nil, nil
+	}
+	return s.SuggestForEmail(owner.UserID, email)
+}
+
+// EnsureOrganization checks that orgID names one of the user's organizations
+func EnsureOrganization(uow *repository.UnitOfWork, userID, orgID uint) error {
+	_, err := findOrganization(uow, userID, orgID)
+	return err
+}
+
+// EmailDomain returns the lower-cased domain of an email address, or "" when
+// the address cannot be parsed
+func EmailDomain(email string) string {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil {
+		return ""
+	}
+	at := strings.LastIndex(addr.Address, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(addr.Address[at+1:]), ".")
+}
+
+// NormalizeDomain accepts a bare domain, a URL or an email address and
+// returns the bare lower-cased domain without a leading www.
+func NormalizeDomain(value string) (string, error) {
+	d := strings.ToLower(strings.TrimSpace(value))
+	if d == "" {
+		return "", nil
+	}
+	if at := strings.LastIndex(d, "@"); at >= 0 {
+		d = d[at+1:]
+	}
+	if i := strings.Index(d, "://"); i >= 0 {
+		d = d[i+3:]
+	}
+	if i := strings.IndexAny(d, "/?#:"); i >= 0 {
+		d = d[:i]
+	}
+	d = strings.TrimPrefix(strings.TrimSuffix(d, "."), "www.")
+	if len(d) > 255 || !domainPattern.MatchString(d) {
+		return "", apperror.NewValidationError("domain", "must be a domain such as example.com")
+	}
+	return d, nil
+}
+
+func applyInput(org *organization.Organization, input OrganizationInput) error {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return apperror.NewValidationError("name", "is required")
+	}
+	if len(name) > 255 {
+		return apperror.NewValidationError("name", "must be at most 255 characters")
+	}
+	domain, err := NormalizeDomain(input.Domain)
+	if err != nil {
+		return err
+	}
+	address := strings.TrimSpace(input.Address)
+	if len(address) > 1024 {
+		return apperror.NewValidationError("address", "must be at most 1024 characters")
+	}
+
+	org.Name = name
+	org.Domain = domain
+	org.Address = address
+	org.Notes = strings.TrimSpace(input.Notes)
+	return nil
+}
+
+func ensureUniqueName(uow *repository.UnitOfWork, org *organization.Organization) error {
+	var count int64
+	if err := uow.DB.Model(&organization.Organization{}).
+		Where("user_id = ? AND name = ? AND organization_id <> ?", org.UserID, org.Name, org.OrganizationID).
+		Count(&count).Error; err != nil {
+		return apperror.NewInternalError("failed to check organization name")
+	}
+	if count > 0 {
+		return apperror.NewConflictError("organization", "an organization with this name already exists")
+	}
+	return nil
+}
+
+func findOrganization(uow *repository.UnitOfWork, userID, orgID uint) (*organization.Organization, error) {
+	var org organization.Organization
+	if err := uow.DB.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&org).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewNotFoundError("organization", int(orgID))
+		}
+		return nil, apperror.NewInternalError("failed to load organization")
+	}
+	return &org, nil
+}
+
+func organizationIDs(orgs []*organization.Organization) []uint {
+	ids := make([]uint, len(orgs))
+	for i, o := range orgs {
+		ids[i] = o.OrganizationID
+	}
+	return ids
+}
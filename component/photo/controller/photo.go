@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/component/auth"
+	"Contact_App/component/photo/service"
+	"Contact_App/web"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type PhotoController struct {
+	Service *service.PhotoService
+}
+
+func NewPhotoController(svc *service.PhotoService) *PhotoController {
+	return &PhotoController{Service: svc}
+}
+
+// PUT /users/{userID}/contacts/{contactID}/photo
+// Accepts a multipart form with a "photo" file field or the raw image as the body
+func (c *PhotoController) UploadPhotoHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+
+	limit := service.MaxPhotoBytes()
+	// leave headroom for multipart boundaries and headers
+	r.Body = http.MaxBytesReader(w, r.Body, limit+64*1024)
+
+	var src io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("photo")
+		if err != nil {
+			apperror.HandleBadRequest(w, "multipart body must contain a photo file")
+			return
+		}
+		defer file.Close()
+		src = file
+	}
+
+	data, err := io.ReadAll(io.LimitReader(src, limit+1))
+	if err != nil {
+		apperror.HandleError(w, apperror.NewValidationError("photo", fmt.Sprintf("must be at most %d bytes", limit)))
+		return
+	}
+
+	photo, err := c.Service.UploadPhoto(userID, contactID, data)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.RespondJSON(w, http.StatusOK, photo)
+}
+
+// GET /users/{userID}/contacts/{contactID}/photo?size=64|128|256
+// Without size the original upload is returned
+func (c *PhotoController) GetPhotoHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+
+	size := 0
+	if raw := r.URL.Query().Get("size"); raw != "" && raw != "original" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			apperror.HandleBadRequest(w, "size must be a number")
+			return
+		}
+		size = parsed
+	}
+
+	photo, rc, contentType, err := c.Service.GetPhoto(userID, contactID, size)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	defer rc.Close()
+
+	if web.NotModifiedTag(w, r, fmt.Sprintf(`"%s-%d"`, photo.Checksum[:16], size)) {
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, rc)
+}
+
+// DELETE /users/{userID}/contacts/{contactID}/photo
+func (c *PhotoController) DeletePhotoHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+
+	if err := c.Service.DeletePhoto(userID, contactID); err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+
+	web.RespondJSON(w, http.StatusOK, map[string]string{"message": "photo deleted"})
+}
+
+func (c *PhotoController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/photo", c.UploadPhotoHandler).Methods("PUT", "POST")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/photo", c.GetPhotoHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/photo", c.DeletePhotoHandler).Methods("DELETE")
+}
@@ -0,0 +1,371 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/blobstore"
+	shareService "Contact_App/component/share/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/db"
+	"Contact_App/export"
+	"Contact_App/helper"
+	"Contact_App/models/contact_photo"
+	"Contact_App/repository"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// ThumbnailSizes are the square edge lengths generated for every upload
+var ThumbnailSizes = []int{64, 128, 256}
+
+// ExportThumbnailSize is the thumbnail embedded in vCard exports
+const ExportThumbnailSize = 256
+
+// MaxPhotoDimension bounds width and height so a tiny compressed file cannot
+// decode into an enormous bitmap
+const MaxPhotoDimension = 8000
+
+var allowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+type PhotoService struct {
+	store blobstore.Store
+}
+
+func NewPhotoService() *PhotoService {
+	return &PhotoService{store: blobstore.Default()}
+}
+
+// MaxPhotoBytes caps upload size (PHOTO_MAX_BYTES, default 5 MiB)
+func MaxPhotoBytes() int64 {
+	if n, err := strconv.ParseInt(os.Getenv("PHOTO_MAX_BYTES"), 10, 64); err == nil && n > 0 {
+		return n
+	}
+	return 5 << 20
+}
+
+// UploadPhoto stores data as the contact's photo, replacing any previous one
+func (s *PhotoService) UploadPhoto(userID, contactID uint, data []byte) (*contact_photo.ContactPhoto, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	photo, err := s.SavePhotoWithUOW(uow, userID, contactID, data)
+	if err != nil {
+		return nil, err
+	}
+
+	uow.Commit()
+	return photo, nil
+}
+
+// SavePhotoWithUOW validates, resizes and stores a photo of a contact the
+// user may change, using the provided transaction. The photo belongs to the
+// contact's owner. Blobs are written before the row and removed again if the
+// transaction rolls back; superseded blobs are removed only after commit so a
+// rollback never leaves a row pointing at nothing.
+func (s *PhotoService) SavePhotoWithUOW(uow *repository.UnitOfWork, userID, contactID uint, data []byte) (*contact_photo.ContactPhoto, error) {
+	c, err := shareService.ResolveContact(uow.DB, userID, contactID, true)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > MaxPhotoBytes() {
+		return nil, apperror.NewValidationError("photo", fmt.Sprintf("must be at most %d bytes", MaxPhotoBytes()))
+	}
+	if len(data) == 0 {
+		return nil, apperror.NewValidationError("photo", "is empty")
+	}
+
+	contentType := http.DetectContentType(data)
+	if !allowedTypes[contentType] {
+		return nil, apperror.NewValidationError("photo", "must be a JPEG, PNG or GIF image, got "+contentType)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, apperror.NewValidationError("photo", "image could not be decoded")
+	}
+	if cfg.Width > MaxPhotoDimension || cfg.Height > MaxPhotoDimension {
+		return nil, apperror.NewValidationError("photo", fmt.Sprintf("must be at most %dx%d pixels", MaxPhotoDimension, MaxPhotoDimension))
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, apperror.NewValidationError("photo", "image could not be decoded")
+	}
+
+	sum := sha256.Sum256(data)
+	photo := &contact_photo.ContactPhoto{
+		ContactID:   contactID,
+		UserID:      c.UserID,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+		Checksum:    hex.EncodeToString(sum[:]),
+	}
+
+	var previous contact_photo.ContactPhoto
+	err = uow.DB.Where("contact_id = ?", contactID).First(&previous).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, apperror.NewInternalError("failed to load photo")
+	}
+	exists := err == nil
+	unchanged := exists && previous.Checksum == photo.Checksum
+
+	if !unchanged {
+		if err := s.writeBlobs(photo, img, data); err != nil {
+			return nil, err
+		}
+		uow.AfterRollback(func() { s.discardBlobs(photo) })
+	}
+
+	if exists {
+		photo.PhotoID = previous.PhotoID
+		photo.CreatedAt = previous.CreatedAt
+		err = uow.DB.Save(photo).Error
+	} else {
+		err = uow.DB.Create(photo).Error
+	}
+	if err != nil {
+		return nil, apperror.NewInternalError("failed to save photo")
+	}
+
+	if exists && !unchanged {
+		stale := blobKeys(&previous)
+		uow.AfterCommit(func() { s.removeBlobs(stale) })
+	}
+	return photo, nil
+}
+
+// GetPhoto returns the photo record of a contact the user can see and a
+// reader for the original (size 0) or one of ThumbnailSizes, along with the
+// content type of the returned bytes
+func (s *PhotoService) GetPhoto(userID, contactID uint, size int) (*contact_photo.ContactPhoto, io.ReadCloser, string, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if _, err := shareService.ResolveContact(uow.DB, userID, contactID, false); err != nil {
+		return nil, nil, "", err
+	}
+	photo, err := findPhoto(uow, contactID)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	key := ""
+	contentType := photo.ContentType
+	if size == 0 {
+		key = originalKey(photo)
+	} else {
+		if !isThumbnailSize(size) {
+			return nil, nil, "", apperror.NewValidationError("size", fmt.Sprintf("must be one of %v", ThumbnailSizes))
+		}
+		key = thumbnailKey(photo, size)
+		contentType = thumbnailContentType(photo.ContentType)
+	}
+
+	rc, err := s.store.Get(key)
+	if err == blobstore.ErrNotFound {
+		return nil, nil, "", apperror.NewNotFoundError("photo", int(contactID))
+	}
+	if err != nil {
+		return nil, nil, "", apperror.NewInternalError("failed to read photo")
+	}
+	return photo, rc, contentType, nil
+}
+
+// DeletePhoto removes the photo of a contact the user may change, and its thumbnails
+func (s *PhotoService) DeletePhoto(userID, contactID uint) error {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return err
+	}
+	defer uow.Rollback()
+
+	if _, err := shareService.ResolveContact(uow.DB, userID, contactID, true); err != nil {
+		return err
+	}
+	photo, err := findPhoto(uow, contactID)
+	if err != nil {
+		return err
+	}
+	if err := uow.DB.Delete(photo).Error; err != nil {
+		return apperror.NewInternalError("failed to delete photo")
+	}
+
+	keys := blobKeys(photo)
+	uow.AfterCommit(func() { s.removeBlobs(keys) })
+
+	uow.Commit()
+	return nil
+}
+
+// DeletePhotosWithUOW drops the photos of contacts being permanently deleted
+func (s *PhotoService) DeletePhotosWithUOW(uow *repository.UnitOfWork, contactIDs []uint) error {
+	var photos []*contact_photo.ContactPhoto
+	if err := uow.DB.Where("contact_id IN ?", contactIDs).Find(&photos).Error; err != nil {
+		return apperror.NewInternalError("failed to load photos")
+	}
+	if len(photos) == 0 {
+		return nil
+	}
+	if err := uow.DB.Where("contact_id IN ?", contactIDs).Delete(&contact_photo.ContactPhoto{}).Error; err != nil {
+		return apperror.NewInternalError("failed to delete photos")
+	}
+
+	var keys []string
+	for _, p := range photos {
+		keys = append(keys, blobKeys(p)...)
+	}
+	uow.AfterCommit(func() { s.removeBlobs(keys) })
+	return nil
+}
+
+// LoadExportPhotos reads the export-sized thumbnail of every listed contact
+// that has a photo. Callers pass contacts they already checked the user can see.
+func (s *PhotoService) LoadExportPhotos(contactIDs []uint) (export.Photos, error) {
+	photos := export.Photos{}
+	if len(contactIDs) == 0 {
+		return photos, nil
+	}
+
+	var rows []*contact_photo.ContactPhoto
+	if err := db.GetDB().Where("contact_id IN ?", contactIDs).Find(&rows).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load photos")
+	}
+	for _, p := range rows {
+		rc, err := s.store.Get(thumbnailKey(p, ExportThumbnailSize))
+		if err != nil {
+			log.Printf("photo for contact %d unavailable: %v", p.ContactID, err)
+			continue
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			log.Printf("photo for contact %d unreadable: %v", p.ContactID, err)
+			continue
+		}
+		photos[p.ContactID] = &export.Photo{ContentType: thumbnailContentType(p.ContentType), Data: data}
+	}
+	return photos, nil
+}
+
+// writeBlobs stores the original and every thumbnail, cleaning up after itself on failure
+func (s *PhotoService) writeBlobs(photo *contact_photo.ContactPhoto, img image.Image, data []byte) error {
+	written := []string{}
+	if err := s.store.Put(originalKey(photo), bytes.NewReader(data)); err != nil {
+		return apperror.NewInternalError("failed to store photo")
+	}
+	written = append(written, originalKey(photo))
+
+	for _, size := range ThumbnailSizes {
+		encoded, err := encodeThumbnail(helper.SquareThumbnail(img, size), photo.ContentType)
+		if err != nil {
+			s.removeBlobs(written)
+			return apperror.NewInternalError("failed to encode thumbnail")
+		}
+		key := thumbnailKey(photo, size)
+		if err := s.store.Put(key, bytes.NewReader(encoded)); err != nil {
+			s.removeBlobs(written)
+			return apperror.NewInternalError("failed to store thumbnail")
+		}
+		written = append(written, key)
+	}
+	return nil
+}
+
+func findPhoto(uow *repository.UnitOfWork, contactID uint) (*contact_photo.ContactPhoto, error) {
+	var photo contact_photo.ContactPhoto
+	if err := uow.DB.Where("contact_id = ?", contactID).First(&photo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewNotFoundError("photo", int(contactID))
+		}
+		return nil, apperror.NewInternalError("failed to load photo")
+	}
+	return &photo, nil
+}
+
+// discardBlobs removes the blobs written for a photo whose transaction rolled
+// back, unless a committed row holds the same image: keys are content-addressed,
+// so a concurrent upload of it shares them
+func (s *PhotoService) discardBlobs(photo *contact_photo.ContactPhoto) {
+	var count int64
+	if err := db.GetDB().Model(&contact_photo.ContactPhoto{}).
+		Where("contact_id = ? AND checksum = ?", photo.ContactID, photo.Checksum).
+		Count(&count).Error; err != nil || count > 0 {
+		return
+	}
+	s.removeBlobs(blobKeys(photo))
+}
+
+func (s *PhotoService) removeBlobs(keys []string) {
+	for _, key := range keys {
+		if err := s.store.Delete(key); err != nil {
+			log.Printf("failed to remove blob %s: %v", key, err)
+		}
+	}
+}
+
+// keys are content-addressed so a replaced photo never overwrites blobs a
+// concurrent reader may still be streaming
+func originalKey(p *contact_photo.ContactPhoto) string {
+	return fmt.Sprintf("photos/%d/%d/%s/original", p.UserID, p.ContactID, p.Checksum)
+}
+
+func thumbnailKey(p *contact_photo.ContactPhoto, size int) string {
+	return fmt.Sprintf("photos/%d/%d/%s/%d", p.UserID, p.ContactID, p.Checksum, size)
+}
+
+func blobKeys(p *contact_photo.ContactPhoto) []string {
+	keys := []string{originalKey(p)}
+	for _, size := range ThumbnailSizes {
+		keys = append(keys, thumbnailKey(p, size))
+	}
+	return keys
+}
+
+func isThumbnailSize(size int) bool {
+	for _, s := range ThumbnailSizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+// thumbnails are JPEG for JPEG sources and PNG otherwise, keeping transparency
+func thumbnailContentType(original string) string {
+	if original == "image/jpeg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+func encodeThumbnail(img image.Image, originalType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if thumbnailContentType(originalType) == "image/jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	return buf.Bytes(), err
+}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/component/auth"
+	"Contact_App/component/quality/service"
+	"Contact_App/web"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type QualityController struct {
+	Service *service.QualityService
+}
+
+func NewQualityController(svc *service.QualityService) *QualityController {
+	return &QualityController{Service: svc}
+}
+
+// GET /users/{userID}/contacts/quality?threshold=60&duplicate_threshold=0.6&limit=100
+func (c *QualityController) ReportHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	var opts service.ReportOptions
+	query := r.URL.Query()
+	if v := query.Get("threshold"); v != "" {
+		threshold, err := strconv.Atoi(v)
+		if err != nil || threshold < 1 || threshold > 100 {
+			apperror.HandleBadRequest(w, "threshold must be a number between 1 and 100")
+			return
+		}
+		opts.Threshold = threshold
+	}
+	if v := query.Get("duplicate_threshold"); v != "" {
+		threshold, err := strconv.ParseFloat(v, 64)
+		if err != nil || threshold <= 0 || threshold > 1 {
+			apperror.HandleBadRequest(w, "duplicate_threshold must be a number between 0 and 1")
+			return
+		}
+		opts.DuplicateThreshold = threshold
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			apperror.HandleBadRequest(w, "invalid limit")
+			return
+		}
+		opts.Limit = limit
+	}
+
+	report, err := c.Service.Report(userID, opts)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, report)
+}
+
+// GET /users/{userID}/contacts/{contactID}/completeness
+func (c *QualityController) CompletenessHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID64, err := strconv.ParseUint(mux.Vars(r)["contactID"], 10, 64)
+	if err != nil {
+		apperror.HandleBadRequest(w, "invalid contactID")
+		return
+	}
+
+	result, err := c.Service.ContactCompleteness(userID, uint(contactID64))
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, result)
+}
+
+func (c *QualityController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/users/{userID}/contacts/quality", c.ReportHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/completeness", c.CompletenessHandler).Methods("GET")
+}
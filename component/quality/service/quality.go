@@ -0,0 +1,186 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	contactService "Contact_App/component/contact/service"
+	duplicateService "Contact_App/component/duplicate/service"
+	shareService "Contact_App/component/share/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/models/contact"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	DefaultThreshold = 60
+	DefaultLimit     = 100
+	MaxLimit         = 1000
+)
+
+// ReportOptions tunes a data-quality report: contacts scoring under
+// Threshold count as incomplete, pairs scoring DuplicateThreshold or more as
+// probable duplicates, and each list holds at most Limit entries
+type ReportOptions struct {
+	Threshold          int
+	DuplicateThreshold float64
+	Limit              int
+}
+
+// Report summarizes the data quality of a user's address book. The counts
+// cover every contact; the lists are cut at the report's limit.
+type Report struct {
+	TotalContacts  int            `json:"total_contacts"`
+	AverageScore   float64        `json:"average_score"`
+	Threshold      int            `json:"threshold"`
+	BelowThreshold int            `json:"below_threshold"`
+	Missing        map[string]int `json:"missing"`
+	InvalidCount   int            `json:"invalid_count"`
+	DuplicateCount int            `json:"duplicate_count"`
+
+	Incomplete []*ContactScore  `json:"incomplete"`
+	Invalid    []*InvalidEntry  `json:"invalid"`
+	Duplicates []*DuplicatePair `json:"duplicates"`
+}
+
+// ContactScore is a contact's completeness score and the fields it lacks
+type ContactScore struct {
+	ContactID uint     `json:"contact_id"`
+	FirstName string   `json:"first_name"`
+	LastName  string   `json:"last_name"`
+	Score     int      `json:"score"`
+	Missing   []string `json:"missing"`
+}
+
+// InvalidEntry is an invalid detail value and the contact it belongs to
+type InvalidEntry struct {
+	ContactID uint   `json:"contact_id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	*contactService.InvalidValue
+}
+
+// DuplicatePair is two contacts that probably describe the same person
+type DuplicatePair struct {
+	ContactID   uint     `json:"contact_id"`
+	DuplicateID uint     `json:"duplicate_id"`
+	Score       float64  `json:"score"`
+	Reasons     []string `json:"reasons"`
+}
+
+type QualityService struct {
+	contacts *contactService.ContactService
+}
+
+func NewQualityService(contacts *contactService.ContactService) *QualityService {
+	return &QualityService{contacts: contacts}
+}
+
+// ContactCompleteness scores one contact the user can see
+func (s *QualityService) ContactCompleteness(userID, contactID uint) (*contactService.Completeness, error) {
+	c, err := s.contacts.GetContactByIDWithDetails(userID, contactID)
+	if err != nil {
+		return nil, err
+	}
+	return contactService.ScoreCompleteness(c), nil
+}
+
+// Report scores every active contact the user can see and looks for
+// probable duplicates among them. Incomplete contacts are listed lowest score first.
+func (s *QualityService) Report(userID uint, opts ReportOptions) (*Report, error) {
+	if opts.Threshold == 0 {
+		opts.Threshold = DefaultThreshold
+	}
+	if opts.Threshold < 1 || opts.Threshold > 100 {
+		return nil, apperror.NewValidationError("threshold", "must be a number between 1 and 100")
+	}
+	if opts.DuplicateThreshold == 0 {
+		opts.DuplicateThreshold = duplicateService.DefaultThreshold
+	}
+	if opts.Limit == 0 {
+		opts.Limit = DefaultLimit
+	}
+	if opts.Limit < 1 || opts.Limit > MaxLimit {
+		return nil, apperror.NewValidationError("limit", fmt.Sprintf("must be between 1 and %d", MaxLimit))
+	}
+
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+	var contacts []*contact.Contact
+	if err := uow.DB.Preload("Details", "is_active = ?", true).
+		Where(shareService.AccessibleContacts(uow.DB, userID)).
+		Where("is_active = ?", true).
+		Order("contact_id").
+		Find(&contacts).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to fetch contacts")
+	}
+
+	report := &Report{
+		TotalContacts: len(contacts),
+		Threshold:     opts.Threshold,
+		Missing:       make(map[string]int, len(contactService.CompletenessFields)),
+		Incomplete:    []*ContactScore{},
+		Invalid:       []*InvalidEntry{},
+		Duplicates:    []*DuplicatePair{},
+	}
+	for _, field := range contactService.CompletenessFields {
+		report.Missing[field] = 0
+	}
+
+	total := 0
+	for _, c := range contacts {
+		result := contactService.ScoreCompleteness(c)
+		total += result.Score
+		for _, field := range result.Missing {
+			report.Missing[field]++
+		}
+		if result.Score < opts.Threshold {
+			report.BelowThreshold++
+			report.Incomplete = append(report.Incomplete, &ContactScore{
+				ContactID: c.ContactID,
+				FirstName: c.FName,
+				LastName:  c.LName,
+				Score:     result.Score,
+				Missing:   result.Missing,
+			})
+		}
+		for _, invalid := range result.Invalid {
+			report.InvalidCount++
+			if len(report.Invalid) < opts.Limit {
+				report.Invalid = append(report.Invalid, &InvalidEntry{
+					ContactID:    c.ContactID,
+					FirstName:    c.FName,
+					LastName:     c.LName,
+					InvalidValue: invalid,
+				})
+			}
+		}
+	}
+	if len(contacts) > 0 {
+		report.AverageScore = math.Round(float64(total)/float64(len(contacts))*10) / 10
+	}
+
+	sort.SliceStable(report.Incomplete, func(i, j int) bool {
+		return report.Incomplete[i].Score < report.Incomplete[j].Score
+	})
+	if len(report.Incomplete) > opts.Limit {
+		report.Incomplete = report.Incomplete[:opts.Limit]
+	}
+
+	candidates := duplicateService.FindCandidates(contacts, opts.DuplicateThreshold)
+	report.DuplicateCount = len(candidates)
+	for _, candidate := range candidates {
+		if len(report.Duplicates) == opts.Limit {
+			break
+		}
+		report.Duplicates = append(report.Duplicates, &DuplicatePair{
+			ContactID:   candidate.Contact.ContactID,
+			DuplicateID: candidate.Duplicate.ContactID,
+			Score:       candidate.Score,
+			Reasons:     candidate.Reasons,
+		})
+	}
+	return report, nil
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/component/auth"
+	"Contact_App/component/relationship/service"
+	"Contact_App/web"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type RelationshipController struct {
+	Service *service.RelationshipService
+}
+
+func NewRelationshipController(svc *service.RelationshipService) *RelationshipController {
+	return &RelationshipController{Service: svc}
+}
+
+// GET /users/{userID}/contacts/{contactID}/relationships
+func (c *RelationshipController) ListRelationshipsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+
+	related, err := c.Service.ListRelationships(userID, contactID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, related)
+}
+
+// POST /users/{userID}/contacts/{contactID}/relationships
+func (c *RelationshipController) AddRelationshipHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+
+	var input service.RelationshipInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	related, err := c.Service.AddRelationship(userID, contactID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusCreated, related)
+}
+
+// PUT /users/{userID}/contacts/{contactID}/relationships/{relationshipID}
+func (c *RelationshipController) UpdateRelationshipHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+	relationshipID, ok := web.ParseID(w, r, "relationshipID")
+	if !ok {
+		return
+	}
+
+	var input service.RelationshipInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	related, err := c.Service.UpdateRelationship(userID, contactID, relationshipID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, related)
+}
+
+// DELETE /users/{userID}/contacts/{contactID}/relationships/{relationshipID}
+func (c *RelationshipController) DeleteRelationshipHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+	relationshipID, ok := web.ParseID(w, r, "relationshipID")
+	if !ok {
+		return
+	}
+
+	if err := c.Service.DeleteRelationship(userID, contactID, relationshipID); err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, map[string]string{"message": "relationship deleted"})
+}
+
+func (c *RelationshipController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/relationships", c.ListRelationshipsHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/relationships", c.AddRelationshipHandler).Methods("POST")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/relationships/{relationshipID:[0-9]+}", c.UpdateRelationshipHandler).Methods("PUT")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/relationships/{relationshipID:[0-9]+}", c.DeleteRelationshipHandler).Methods("DELETE")
+}
@@ -0,0 +1,401 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	shareService "Contact_App/component/share/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_relationship"
+	"Contact_App/repository"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// RelationshipInput is the body accepted when creating or replacing a
+// relationship. Type is always given from the point of view of the contact in
+// the URL: {"related_contact_id": 7, "type": "manager"} means contact 7 is
+// this contact's manager.
+type RelationshipInput struct {
+	RelatedContactID uint   `json:"related_contact_id"`
+	Type             string `json:"type"`
+	Label            string `json:"label"`
+	Bidirectional    bool   `json:"bidirectional"`
+}
+
+type RelationshipService struct {
+	repo repository.Repository
+}
+
+func NewRelationshipService() *RelationshipService {
+	return &RelationshipService{repo: repository.NewGormRepository()}
+}
+
+// ListRelationships returns the contact's own relationships plus the
+// bidirectional ones recorded on other contacts
+func (s *RelationshipService) ListRelationships(userID, contactID uint) ([]*contact_relationship.Related, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ensureContact(uow, userID, contactID, false); err != nil {
+		return nil, err
+	}
+
+	related, err := LoadRelated(uow.DB, userID, []uint{contactID})
+	if err != nil {
+		return nil, err
+	}
+	if related[contactID] == nil {
+		return []*contact_relationship.Related{}, nil
+	}
+	return related[contactID], nil
+}
+
+func (s *RelationshipService) AddRelationship(userID, contactID uint, input RelationshipInput) (*contact_relationship.Related, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	c, err := ensureContact(uow, userID, contactID, true)
+	if err != nil {
+		return nil, err
+	}
+	if input.RelatedContactID == 0 {
+		return nil, apperror.NewValidationError("related_contact_id", "is required")
+	}
+	if input.RelatedContactID == contactID {
+		return nil, apperror.NewValidationError("related_contact_id", "a contact cannot be related to itself")
+	}
+	if _, err := ensureContact(uow, userID, input.RelatedContactID, false); err != nil {
+		return nil, err
+	}
+
+	rel := &contact_relationship.ContactRelationship{
+		UserID:           c.UserID,
+		ContactID:        contactID,
+		RelatedContactID: input.RelatedContactID,
+	}
+	if err := applyInput(rel, input, false); err != nil {
+		return nil, err
+	}
+	if err := ensureUnique(uow, rel); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Add(uow, rel); err != nil {
+		return nil, err
+	}
+	if err := touchContacts(uow, rel); err != nil {
+		return nil, err
+	}
+
+	view, err := viewFrom(uow, rel, contactID)
+	if err != nil {
+		return nil, err
+	}
+	uow.Commit()
+	return view, nil
+}
+
+// UpdateRelationship changes type, label and direction. A bidirectional
+// relationship may be updated from either side; the related contact itself
+// cannot change.
+func (s *RelationshipService) UpdateRelationship(userID, contactID, relationshipID uint, input RelationshipInput) (*contact_relationship.Related, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	if _, err := ensureContact(uow, userID, contactID, true); err != nil {
+		return nil, err
+	}
+	rel, err := findRelationship(uow, contactID, relationshipID)
+	if err != nil {
+		return nil, err
+	}
+
+	incoming := rel.ContactID != contactID
+	other := rel.RelatedContactID
+	if incoming {
+		other = rel.ContactID
+	}
+	if input.RelatedContactID != 0 && input.RelatedContactID != other {
+		return nil, apperror.NewValidationError("related_contact_id", "cannot be changed; delete and recreate the relationship")
+	}
+
+	if err := applyInput(rel, input, incoming); err != nil {
+		return nil, err
+	}
+	if err := ensureUnique(uow, rel); err != nil {
+		return nil, err
+	}
+	if err := uow.DB.Select("type", "label", "bidirectional").Save(rel).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to update relationship")
+	}
+	if err := touchContacts(uow, rel); err != nil {
+		return nil, err
+	}
+
+	view, err := viewFrom(uow, rel, contactID)
+	if err != nil {
+		return nil, err
+	}
+	uow.Commit()
+	return view, nil
+}
+
+// DeleteRelationship removes a relationship from whichever side it is viewed
+func (s *RelationshipService) DeleteRelationship(userID, contactID, relationshipID uint) error {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return err
+	}
+	defer uow.Rollback()
+
+	if _, err := ensureContact(uow, userID, contactID, true); err != nil {
+		return err
+	}
+	rel, err := findRelationship(uow, contactID, relationshipID)
+	if err != nil {
+		return err
+	}
+	if err := uow.DB.Delete(rel).Error; err != nil {
+		return apperror.NewInternalError("failed to delete relationship")
+	}
+	if err := touchContacts(uow, rel); err != nil {
+		return err
+	}
+
+	uow.Commit()
+	return nil
+}
+
+// DeleteForContactsWithUOW removes every relationship either side of which is
+// one of contactIDs, bumping the version of the contacts left behind so their
+// ETags change
+func DeleteForContactsWithUOW(uow *repository.UnitOfWork, contactIDs []uint) error {
+	var rels []*contact_relationship.ContactRelationship
+	if err := uow.DB.Where("contact_id IN ? OR related_contact_id IN ?", contactIDs, contactIDs).
+		Find(&rels).Error; err != nil {
+		return apperror.NewInternalError("failed to load relationships")
+	}
+	if len(rels) == 0 {
+		return nil
+	}
+
+	removed := make(map[uint]bool, len(contactIDs))
+	for _, id := range contactIDs {
+		removed[id] = true
+	}
+	var survivors []uint
+	for _, rel := range rels {
+		for _, id := range []uint{rel.ContactID, rel.RelatedContactID} {
+			if !removed[id] {
+				survivors = append(survivors, id)
+			}
+		}
+	}
+
+	if err := uow.DB.Where("contact_id IN ? OR related_contact_id IN ?", contactIDs, contactIDs).
+		Delete(&contact_relationship.ContactRelationship{}).Error; err != nil {
+		return apperror.NewInternalError("failed to delete relationships")
+	}
+	if len(survivors) > 0 {
+		if err := uow.DB.Model(&contact.Contact{}).
+			Where("contact_id IN ?", survivors).
+			UpdateColumn("version", gorm.Expr("version + 1")).Error; err != nil {
+			return apperror.NewInternalError("failed to update contact versions")
+		}
+	}
+	return nil
+}
+
+type relatedRow struct {
+	contact_relationship.ContactRelationship
+	FName string `gorm:"column:f_name"`
+	LName string `gorm:"column:l_name"`
+}
+
+// LoadRelated returns the relationships of each listed contact as that
+// contact sees them. Relationships to deleted contacts, or to contacts the
+// viewer cannot see, are left out.
+func LoadRelated(conn *gorm.DB, viewerID uint, contactIDs []uint) (map[uint][]*contact_relationship.Related, error) {
+	related := make(map[uint][]*contact_relationship.Related)
+	if len(contactIDs) == 0 {
+		return related, nil
+	}
+
+	var outgoing []*relatedRow
+	if err := conn.Table("contact_relationships").
+		Select("contact_relationships.*, contacts.f_name, contacts.l_name").
+		Joins("JOIN contacts ON contacts.contact_id = contact_relationships.related_contact_id").
+		Where("contact_relationships.contact_id IN ?", contactIDs).
+		Where("contacts.is_active = ? AND contacts.deleted_at IS NULL", true).
+		Where(shareService.AccessibleContacts(conn, viewerID)).
+		Scan(&outgoing).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load relationships")
+	}
+	for _, row := range outgoing {
+		related[row.ContactID] = append(related[row.ContactID], toView(row, row.ContactID))
+	}
+
+	var incoming []*relatedRow
+	if err := conn.Table("contact_relationships").
+		Select("contact_relationships.*, contacts.f_name, contacts.l_name").
+		Joins("JOIN contacts ON contacts.contact_id = contact_relationships.contact_id").
+		Where("contact_relationships.related_contact_id IN ?", contactIDs).
+		Where("contact_relationships.bidirectional = ?", true).
+		Where("contacts.is_active = ? AND contacts.deleted_at IS NULL", true).
+		Where(shareService.AccessibleContacts(conn, viewerID)).
+		Scan(&incoming).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load relationships")
+	}
+	for _, row := range incoming {
+		related[row.RelatedContactID] = append(related[row.RelatedContactID], toView(row, row.RelatedContactID))
+	}
+
+	for _, views := range related {
+		sort.Slice(views, func(i, j int) bool {
+			if views[i].Type != views[j].Type {
+				return views[i].Type < views[j].Type
+			}
+			return views[i].RelationshipID < views[j].RelationshipID
+		})
+	}
+	return related, nil
+}
+
+// toView presents row from the point of view of contact viewer
+func toView(row *relatedRow, viewer uint) *contact_relationship.Related {
+	view := &contact_relationship.Related{
+		RelationshipID:   row.RelationshipID,
+		RelatedContactID: row.RelatedContactID,
+		FirstName:        row.FName,
+		LastName:         row.LName,
+		Type:             row.Type,
+		Label:            row.Label,
+		Bidirectional:    row.Bidirectional,
+		Direction:        contact_relationship.DirectionOutgoing,
+	}
+	if viewer != row.ContactID {
+		view.RelatedContactID = row.ContactID
+		view.Type = contact_relationship.Inverse[row.Type]
+		view.Direction = contact_relationship.DirectionIncoming
+	}
+	return view
+}
+
+func viewFrom(uow *repository.UnitOfWork, rel *contact_relationship.ContactRelationship, viewer uint) (*contact_relationship.Related, error) {
+	other := rel.RelatedContactID
+	if viewer != rel.ContactID {
+		other = rel.ContactID
+	}
+	var c contact.Contact
+	if err := uow.DB.Select("f_name", "l_name").Where("contact_id = ?", other).First(&c).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load related contact")
+	}
+	return toView(&relatedRow{ContactRelationship: *rel, FName: c.FName, LName: c.LName}, viewer), nil
+}
+
+// applyInput validates input; incoming means the caller sees rel from the
+// related contact's side, so the type is stored inverted
+func applyInput(rel *contact_relationship.ContactRelationship, input RelationshipInput, incoming bool) error {
+	relType := strings.ToLower(strings.TrimSpace(input.Type))
+	inverse, ok := contact_relationship.Inverse[relType]
+	if !ok {
+		types := make([]string, 0, len(contact_relationship.Inverse))
+		for t := range contact_relationship.Inverse {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		return apperror.NewValidationError("type", "must be one of "+strings.Join(types, ", "))
+	}
+
+	label := strings.TrimSpace(input.Label)
+	if relType == "other" && label == "" {
+		return apperror.NewValidationError("label", "is required for type other")
+	}
+	if len([]rune(label)) > 255 {
+		return apperror.NewValidationError("label", "must be at most 255 characters")
+	}
+
+	if incoming {
+		relType = inverse
+	}
+	rel.Type = relType
+	rel.Label = label
+	rel.Bidirectional = input.Bidirectional
+	return nil
+}
+
+// ensureUnique rejects a second relationship of the same type between the
+// same contacts, including the mirror image of a bidirectional one
+func ensureUnique(uow *repository.UnitOfWork, rel *contact_relationship.ContactRelationship) error {
+	var count int64
+	query := uow.DB.Model(&contact_relationship.ContactRelationship{}).
+		Where("relationship_id <> ?", rel.RelationshipID)
+	mirror := uow.DB.Where("contact_id = ? AND related_contact_id = ? AND type = ?", rel.RelatedContactID, rel.ContactID, contact_relationship.Inverse[rel.Type])
+	if !rel.Bidirectional {
+		mirror = mirror.Where("bidirectional = ?", true)
+	}
+	if err := query.Where(
+		uow.DB.Where("contact_id = ? AND related_contact_id = ? AND type = ?", rel.ContactID, rel.RelatedContactID, rel.Type).
+			Or(mirror),
+	).Count(&count).Error; err != nil {
+		return apperror.NewInternalError("failed to check existing relationships")
+	}
+	if count > 0 {
+		return apperror.NewConflictError("relationship", "these contacts already have this relationship")
+	}
+	return nil
+}
+
+// touchContacts bumps both contacts' versions since relationships are part
+// of the contact representation and its ETag
+func touchContacts(uow *repository.UnitOfWork, rel *contact_relationship.ContactRelationship) error {
+	if err := uow.DB.Model(&contact.Contact{}).
+		Where("contact_id IN ?", []uint{rel.ContactID, rel.RelatedContactID}).
+		UpdateColumn("version", gorm.Expr("version + 1")).Error; err != nil {
+		return apperror.NewInternalError("failed to update contact versions")
+	}
+	return nil
+}
+
+// findRelationship loads a relationship visible from contactID: one it owns
+// or a bidirectional one pointing at it
+func findRelationship(uow *repository.UnitOfWork, contactID, relationshipID uint) (*contact_relationship.ContactRelationship, error) {
+	var rel contact_relationship.ContactRelationship
+	if err := uow.DB.Where("relationship_id = ?", relationshipID).
+		Where(uow.DB.Where("contact_id = ?", contactID).
+			Or("related_contact_id = ? AND bidirectional = ?", contactID, true)).
+		First(&rel).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewNotFoundError("relationship", int(relationshipID))
+		}
+		return nil, apperror.NewInternalError("failed to load relationship")
+	}
+	return &rel, nil
+}
+
+// ensureContact resolves an active contact the user may see, or change when
+// write is set
+func ensureContact(uow *repository.UnitOfWork, userID, contactID uint, write bool) (*contact.Contact, error) {
+	c, err := shareService.ResolveContact(uow.DB, userID, contactID, write)
+	if err != nil {
+		return nil, err
+	}
+	var count int64
+	if err := uow.DB.Model(&contact.Contact{}).
+		Where("contact_id = ? AND is_active = ?", contactID, true).
+		Count(&count).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load contact")
+	}
+	if count == 0 {
+		return nil, apperror.NewNotFoundError("contact", int(contactID))
+	}
+	return c, nil
+}
@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/component/auth"
+	"Contact_App/component/share/service"
+	"Contact_App/web"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type ShareController struct {
+	Service *service.ShareService
+}
+
+func NewShareController(svc *service.ShareService) *ShareController {
+	return &ShareController{Service: svc}
+}
+
+// GET /users/{userID}/shares
+// Shares the user has handed out
+func (c *ShareController) ListSharesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	shares, err := c.Service.ListShares(userID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, shares)
+}
+
+// GET /users/{userID}/shares/received
+func (c *ShareController) ListReceivedHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	shares, err := c.Service.ListReceived(userID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, shares)
+}
+
+// POST /users/{userID}/shares
+func (c *ShareController) CreateShareHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	var input service.ShareInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	share, err := c.Service.CreateShare(userID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusCreated, share)
+}
+
+// PUT /users/{userID}/shares/{shareID}
+// Only the permission can change
+func (c *ShareController) UpdateShareHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	shareID, ok := web.ParseID(w, r, "shareID")
+	if !ok {
+		return
+	}
+
+	var input service.ShareInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	share, err := c.Service.UpdateShare(userID, shareID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, share)
+}
+
+// DELETE /users/{userID}/shares/{shareID}
+// Revokes a share; recipients may use it to leave a share
+func (c *ShareController) DeleteShareHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	shareID, ok := web.ParseID(w, r, "shareID")
+	if !ok {
+		return
+	}
+
+	if err := c.Service.DeleteShare(userID, shareID); err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, map[string]string{"message": "share revoked"})
+}
+
+func (c *ShareController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/users/{userID}/shares", c.ListSharesHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/shares", c.CreateShareHandler).Methods("POST")
+	router.HandleFunc("/users/{userID}/shares/received", c.ListReceivedHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/shares/{shareID:[0-9]+}", c.UpdateShareHandler).Methods("PUT")
+	router.HandleFunc("/users/{userID}/shares/{shareID:[0-9]+}", c.DeleteShareHandler).Methods("DELETE")
+}
@@ -0,0 +1,534 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/db"
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_share"
+	"Contact_App/models/group"
+	"Contact_App/models/user"
+	"Contact_App/repository"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ShareInput is the body accepted when sharing a contact or a group. Exactly
+// one of ContactID and GroupID is required; Permission defaults to read.
+type ShareInput struct {
+	ContactID      *uint  `json:"contact_id"`
+	GroupID        *uint  `json:"group_id"`
+	RecipientEmail string `json:"recipient_email"`
+	Permission     string `json:"permission"`
+}
+
+type ShareService struct {
+	repo repository.Repository
+}
+
+func NewShareService() *ShareService {
+	return &ShareService{repo: repository.NewGormRepository()}
+}
+
+// ListShares returns the shares the user has handed out, newest first
+func (s *ShareService) ListShares(ownerID uint) ([]*contact_share.ContactShare, error) {
+	return listShares(db.GetDB().Where("owner_id = ?", ownerID))
+}
+
+// ListReceived returns the shares other users have given the user
+func (s *ShareService) ListReceived(recipientID uint) ([]*contact_share.ContactShare, error) {
+	return listShares(db.GetDB().Where("recipient_id = ?", recipientID))
+}
+
+func (s *ShareService) CreateShare(ownerID uint, input ShareInput) (*contact_share.ContactShare, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(ownerID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	permission, err := parsePermission(input.Permission)
+	if err != nil {
+		return nil, err
+	}
+	if (input.ContactID == nil) == (input.GroupID == nil) {
+		return nil, apperror.NewValidationError("share", "exactly one of contact_id and group_id is required")
+	}
+
+	share := &contact_share.ContactShare{OwnerID: ownerID, Permission: permission}
+	duplicate := uow.DB.Model(&contact_share.ContactShare{})
+	if input.ContactID != nil {
+		// workspace contacts are shared through workspace membership instead
+		var count int64
+		if err := uow.DB.Model(&contact.Contact{}).
+			Where("contact_id = ? AND user_id = ? AND is_active = ? AND workspace_id IS NULL", *input.ContactID, ownerID, true).
+			Count(&count).Error; err != nil {
+			return nil, apperror.NewInternalError("failed to load contact")
+		}
+		if count == 0 {
+			return nil, apperror.NewNotFoundError("contact", int(*input.ContactID))
+		}
+		share.ContactID = input.ContactID
+		duplicate = duplicate.Where("contact_id = ?", *input.ContactID)
+	} else {
+		var count int64
+		if err := uow.DB.Model(&group.Group{}).
+			Where("group_id = ? AND user_id = ?", *input.GroupID, ownerID).
+			Count(&count).Error; err != nil {
+			return nil, apperror.NewInternalError("failed to load group")
+		}
+		if count == 0 {
+			return nil, apperror.NewNotFoundError("group", int(*input.GroupID))
+		}
+		share.GroupID = input.GroupID
+		duplicate = duplicate.Where("group_id = ?", *input.GroupID)
+	}
+
+	email := strings.ToLower(strings.TrimSpace(input.RecipientEmail))
+	if email == "" {
+		return nil, apperror.NewValidationError("recipient_email", "is required")
+	}
+	var recipient user.User
+	if err := uow.DB.Where("email = ? AND is_active = ?", email, true).First(&recipient).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewValidationError("recipient_email", "does not belong to a user")
+		}
+		return nil, apperror.NewInternalError("failed to load recipient")
+	}
+	if recipient.UserID == ownerID {
+		return nil, apperror.NewValidationError("recipient_email", "cannot share with yourself")
+	}
+	share.RecipientID = recipient.UserID
+
+	var count int64
+	if err := duplicate.Where("recipient_id = ?", recipient.UserID).Count(&count).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to check existing shares")
+	}
+	if count > 0 {
+		return nil, apperror.NewConflictError("share", "this item is already shared with that user")
+	}
+
+	if err := s.repo.Add(uow, share); err != nil {
+		return nil, err
+	}
+	if err := applyParties(uow.DB, []*contact_share.ContactShare{share}); err != nil {
+		return nil, err
+	}
+
+	uow.Commit()
+	return share, nil
+}
+
+// UpdateShare changes the permission of a share the user handed out
+func (s *ShareService) UpdateShare(ownerID, shareID uint, input ShareInput) (*contact_share.ContactShare, error) {
+	uow := repository.NewUnitOfWork(db.GetDB(), false)
+	defer uow.Rollback()
+
+	permission, err := parsePermission(input.Permission)
+	if err != nil {
+		return nil, err
+	}
+	share, err := findShare(uow, shareID)
+	if err != nil {
+		return nil, err
+	}
+	if share.OwnerID != ownerID {
+		return nil, apperror.NewNotFoundError("share", int(shareID))
+	}
+
+	share.Permission = permission
+	if err := uow.DB.Select("permission").Save(share).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to update share")
+	}
+	if err := applyParties(uow.DB, []*contact_share.ContactShare{share}); err != nil {
+		return nil, err
+	}
+
+	uow.Commit()
+	return share, nil
+}
+
+// DeleteShare revokes a share. The owner revokes it; the recipient may also
+// remove a share they no longer want.
+func (s *ShareService) DeleteShare(userID, shareID uint) error {
+	uow := repository.NewUnitOfWork(db.GetDB(), false)
+	defer uow.Rollback()
+
+	share, err := findShare(uow, shareID)
+	if err != nil {
+		return err
+	}
+	if share.OwnerID != userID && share.RecipientID != userID {
+		return apperror.NewNotFoundError("share", int(shareID))
+	}
+	if err := uow.DB.Delete(share).Error; err != nil {
+		return apperror.NewInternalError("failed to revoke share")
+	}
+	if err := recordShareRevokes(uow, []*contact_share.ContactShare{share}, nil); err != nil {
+		return err
+	}
+
+	uow.Commit()
+	return nil
+}
+
+// ContactPermission returns the strongest permission userID holds on another
+// user's contact through a direct or group share, or "" without one
+func ContactPermission(conn *gorm.DB, userID, contactID uint) (string, error) {
+	permissions, err := contactPermissions(conn, userID, []uint{contactID})
+	if err != nil {
+		return "", err
+	}
+	return permissions[contactID], nil
+}
+
+// GroupPermission returns the permission userID holds on another user's group, or ""
+func GroupPermission(conn *gorm.DB, userID, groupID uint) (string, error) {
+	permissions, err := groupPermissions(conn, userID, []uint{groupID})
+	if err != nil {
+		return "", err
+	}
+	return permissions[groupID], nil
+}
+
+// AccessibleContacts is a condition for contacts queries matching the
+// user's own contacts, the contacts of their workspaces and every contact
+// shared with them
+func AccessibleContacts(conn *gorm.DB, userID uint) *gorm.DB {
+	conn = conn.Session(&gorm.Session{NewDB: true})
+	direct := conn.Model(&contact_share.ContactShare{}).Select("contact_id").
+		Where("recipient_id = ? AND contact_id IS NOT NULL", userID)
+	viaGroups := conn.Model(&group.GroupContact{}).Select("group_contacts.contact_id").
+		Joins(groupShareJoin).
+		Where("contact_shares.recipient_id = ?", userID)
+	return conn.Where("contacts.user_id = ? AND contacts.workspace_id IS NULL", userID).
+		Or("contacts.workspace_id IN (?)", workspaceService.WorkspaceIDs(conn, userID)).
+		Or("contacts.contact_id IN (?)", direct).
+		Or("contacts.contact_id IN (?)", viaGroups)
+}
+
+// ResolveContact returns the owner and workspace of a contact the user may
+// access: their own, one of their workspaces' or one shared with them.
+// Contacts that are none of these are reported as not found. Write access
+// needs an editor role in the workspace or a write share.
+func ResolveContact(conn *gorm.DB, userID, contactID uint, write bool) (*contact.Contact, error) {
+	var c contact.Contact
+	if err := conn.Select("contact_id", "user_id", "workspace_id").Where("contact_id = ?", contactID).First(&c).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewNotFoundError("contact", int(contactID))
+		}
+		return nil, apperror.NewInternalError("failed to load contact")
+	}
+
+	if c.WorkspaceID != nil {
+		role, err := workspaceService.Role(conn, userID, *c.WorkspaceID)
+		if err != nil {
+			return nil, err
+		}
+		if role == "" {
+			return nil, apperror.NewNotFoundError("contact", int(contactID))
+		}
+		if write && !workspaceService.CanWrite(role) {
+			return nil, apperror.NewForbiddenError("contact", "your workspace role only allows reading contacts")
+		}
+		return &c, nil
+	}
+	if c.UserID == userID {
+		return &c, nil
+	}
+
+	permission, err := ContactPermission(conn, userID, contactID)
+	if err != nil {
+		return nil, err
+	}
+	if permission == "" {
+		return nil, apperror.NewNotFoundError("contact", int(contactID))
+	}
+	if write && permission != contact_share.PermissionWrite {
+		return nil, apperror.NewForbiddenError("contact", "this contact is shared with you read-only")
+	}
+	return &c, nil
+}
+
+// SharedGroupIDs selects the ids of groups shared with the user, for use as a subquery
+func SharedGroupIDs(conn *gorm.DB, userID uint) *gorm.DB {
+	return conn.Session(&gorm.Session{NewDB: true}).Model(&contact_share.ContactShare{}).
+		Select("group_id").
+		Where("recipient_id = ? AND group_id IS NOT NULL", userID)
+}
+
+// SharingOwners returns the users who share anything with userID, and the
+// other creators of contacts in userID's workspaces
+func SharingOwners(conn *gorm.DB, userID uint) ([]uint, error) {
+	var owners, creators []uint
+	if err := conn.Model(&contact_share.ContactShare{}).
+		Where("recipient_id = ?", userID).
+		Distinct().Pluck("owner_id", &owners).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load shares")
+	}
+	if err := conn.Model(&contact.Contact{}).
+		Where("workspace_id IN (?) AND user_id <> ?", workspaceService.WorkspaceIDs(conn, userID), userID).
+		Distinct().Pluck("user_id", &creators).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load workspace contacts")
+	}
+
+	seen := make(map[uint]bool, len(owners))
+	for _, id := range owners {
+		seen[id] = true
+	}
+	for _, id := range creators {
+		if !seen[id] {
+			owners = append(owners, id)
+		}
+	}
+	return owners, nil
+}
+
+// ApplySharedBy marks the contacts viewerID does not own with their owner and
+// the viewer's permission. Workspace contacts are left alone: they belong to
+// the team, not to whoever created them.
+func ApplySharedBy(conn *gorm.DB, viewerID uint, contacts []*contact.Contact) error {
+	var ids, owners []uint
+	for _, c := range contacts {
+		if c.UserID != viewerID && c.WorkspaceID == nil {
+			ids = append(ids, c.ContactID)
+			owners = append(owners, c.UserID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	permissions, err := contactPermissions(conn, viewerID, ids)
+	if err != nil {
+		return err
+	}
+	parties, err := loadParties(conn, owners)
+	if err != nil {
+		return err
+	}
+	for _, c := range contacts {
+		if c.UserID == viewerID || c.WorkspaceID != nil {
+			continue
+		}
+		c.SharedBy = &contact_share.SharedBy{Party: partyOf(parties, c.UserID), Permission: permissions[c.ContactID]}
+	}
+	return nil
+}
+
+// ApplyGroupSharedBy marks the groups viewerID does not own like ApplySharedBy
+func ApplyGroupSharedBy(conn *gorm.DB, viewerID uint, groups []*group.Group) error {
+	var ids, owners []uint
+	for _, g := range groups {
+		if g.UserID != viewerID {
+			ids = append(ids, g.GroupID)
+			owners = append(owners, g.UserID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	permissions, err := groupPermissions(conn, viewerID, ids)
+	if err != nil {
+		return err
+	}
+	parties, err := loadParties(conn, owners)
+	if err != nil {
+		return err
+	}
+	for _, g := range groups {
+		if g.UserID == viewerID {
+			continue
+		}
+		g.SharedBy = &contact_share.SharedBy{Party: partyOf(parties, g.UserID), Permission: permissions[g.GroupID]}
+	}
+	return nil
+}
+
+// DeleteForContactsWithUOW drops the shares of contacts being permanently deleted
+func DeleteForContactsWithUOW(uow *repository.UnitOfWork, contactIDs []uint) error {
+	if err := uow.DB.Where("contact_id IN ?", contactIDs).Delete(&contact_share.ContactShare{}).Error; err != nil {
+		return apperror.NewInternalError("failed to delete contact shares")
+	}
+	return nil
+}
+
+// DeleteForGroupWithUOW drops the shares of a deleted group. It runs before
+// the group's memberships go, so the recipients can be told which contacts
+// they lost.
+func DeleteForGroupWithUOW(uow *repository.UnitOfWork, groupID uint) error {
+	var shares []*contact_share.ContactShare
+	if err := uow.DB.Where("group_id = ?", groupID).Find(&shares).Error; err != nil {
+		return apperror.NewInternalError("failed to load group shares")
+	}
+	if len(shares) == 0 {
+		return nil
+	}
+	if err := uow.DB.Where("group_id = ?", groupID).Delete(&contact_share.ContactShare{}).Error; err != nil {
+		return apperror.NewInternalError("failed to delete group shares")
+	}
+	return recordShareRevokes(uow, shares, nil)
+}
+
+// RevokeGroupContactsWithUOW tells the recipients of a group's shares that
+// contacts taken out of the group are no longer shared with them
+func RevokeGroupContactsWithUOW(uow *repository.UnitOfWork, groupID uint, contactIDs []uint) error {
+	var shares []*contact_share.ContactShare
+	if err := uow.DB.Where("group_id = ?", groupID).Find(&shares).Error; err != nil {
+		return apperror.NewInternalError("failed to load group shares")
+	}
+	return recordShareRevokes(uow, shares, contactIDs)
+}
+
+// recordShareRevokes records that each share's recipient lost the contacts
+// it covered: its contact, or the members of its group. groupContacts limits
+// group shares to those members; nil reads the group's current members.
+func recordShareRevokes(uow *repository.UnitOfWork, shares []*contact_share.ContactShare, groupContacts []uint) error {
+	for _, share := range shares {
+		contactIDs := groupContacts
+		switch {
+		case share.ContactID != nil:
+			contactIDs = []uint{*share.ContactID}
+		case contactIDs == nil:
+			if err := uow.DB.Model(&group.GroupContact{}).
+				Where("group_id = ?", *share.GroupID).
+				Pluck("contact_id", &contactIDs).Error; err != nil {
+				return apperror.NewInternalError("failed to load group contacts")
+			}
+		}
+		if err := workspaceService.RecordRevokesWithUOW(uow, share.RecipientID, contactIDs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupShareJoin joins group members to the shares of their group. A group
+// share only covers the personal contacts of whoever shared the group, so a
+// member the sharer merely sees through a share never passes on more access.
+const groupShareJoin = "JOIN contact_shares ON contact_shares.group_id = group_contacts.group_id " +
+	"JOIN contacts AS shared_contacts ON shared_contacts.contact_id = group_contacts.contact_id " +
+	"AND shared_contacts.user_id = contact_shares.owner_id AND shared_contacts.workspace_id IS NULL"
+
+// contactPermissions resolves direct and group shares; write wins over read
+func contactPermissions(conn *gorm.DB, userID uint, contactIDs []uint) (map[uint]string, error) {
+	var rows []struct {
+		ContactID  uint
+		Permission string
+	}
+	if err := conn.Model(&contact_share.ContactShare{}).
+		Select("contact_id, permission").
+		Where("recipient_id = ? AND contact_id IN ?", userID, contactIDs).
+		Scan(&rows).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load shares")
+	}
+
+	var viaGroups []struct {
+		ContactID  uint
+		Permission string
+	}
+	if err := conn.Model(&group.GroupContact{}).
+		Select("group_contacts.contact_id, contact_shares.permission").
+		Joins(groupShareJoin).
+		Where("contact_shares.recipient_id = ? AND group_contacts.contact_id IN ?", userID, contactIDs).
+		Scan(&viaGroups).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load shares")
+	}
+
+	permissions := make(map[uint]string)
+	for _, row := range append(rows, viaGroups...) {
+		if permissions[row.ContactID] != contact_share.PermissionWrite {
+			permissions[row.ContactID] = row.Permission
+		}
+	}
+	return permissions, nil
+}
+
+// groupPermissions returns the permission userID holds on each shared group
+func groupPermissions(conn *gorm.DB, userID uint, groupIDs []uint) (map[uint]string, error) {
+	var shares []*contact_share.ContactShare
+	if err := conn.Select("group_id", "permission").
+		Where("recipient_id = ? AND group_id IN ?", userID, groupIDs).
+		Find(&shares).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load shares")
+	}
+	permissions := make(map[uint]string, len(shares))
+	for _, share := range shares {
+		permissions[*share.GroupID] = share.Permission
+	}
+	return permissions, nil
+}
+
+func parsePermission(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", contact_share.PermissionRead:
+		return contact_share.PermissionRead, nil
+	case contact_share.PermissionWrite, "read-write", "read_write":
+		return contact_share.PermissionWrite, nil
+	}
+	return "", apperror.NewValidationError("permission", "must be read or write")
+}
+
+func listShares(query *gorm.DB) ([]*contact_share.ContactShare, error) {
+	shares := []*contact_share.ContactShare{}
+	if err := query.Order("created_at DESC, share_id DESC").Find(&shares).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load shares")
+	}
+	if err := applyParties(db.GetDB(), shares); err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+func applyParties(conn *gorm.DB, shares []*contact_share.ContactShare) error {
+	ids := make([]uint, 0, len(shares)*2)
+	for _, sh := range shares {
+		ids = append(ids, sh.OwnerID, sh.RecipientID)
+	}
+	parties, err := loadParties(conn, ids)
+	if err != nil {
+		return err
+	}
+	for _, sh := range shares {
+		owner, recipient := partyOf(parties, sh.OwnerID), partyOf(parties, sh.RecipientID)
+		sh.Owner, sh.Recipient = &owner, &recipient
+	}
+	return nil
+}
+
+func loadParties(conn *gorm.DB, userIDs []uint) (map[uint]contact_share.Party, error) {
+	parties := make(map[uint]contact_share.Party)
+	if len(userIDs) == 0 {
+		return parties, nil
+	}
+	var users []*user.User
+	if err := conn.Select("user_id", "f_name", "l_name", "email").
+		Where("user_id IN ?", userIDs).
+		Find(&users).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load users")
+	}
+	for _, u := range users {
+		parties[u.UserID] = contact_share.Party{UserID: u.UserID, Name: strings.TrimSpace(u.FName + " " + u.LName), Email: u.Email}
+	}
+	return parties, nil
+}
+
+func partyOf(parties map[uint]contact_share.Party, userID uint) contact_share.Party {
+	if p, ok := parties[userID]; ok {
+		return p
+	}
+	return contact_share.Party{UserID: userID}
+}
+
+func findShare(uow *repository.UnitOfWork, shareID uint) (*contact_share.ContactShare, error) {
+	var share contact_share.ContactShare
+	if err := uow.DB.Where("share_id = ?", shareID).First(&share).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewNotFoundError("share", int(shareID))
+		}
+		return nil, apperror.NewInternalError("failed to load share")
+	}
+	return &share, nil
+}
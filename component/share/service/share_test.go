@@ -0,0 +1,135 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/db/dbtest"
+	"Contact_App/models/contact_share"
+	"Contact_App/models/group"
+	"Contact_App/models/user"
+	"Contact_App/models/workspace"
+	"net/http"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestResolveContact(t *testing.T) {
+	conn := dbtest.Open(t)
+	owner := dbtest.User(t, conn, "owner@example.com")
+	friend := dbtest.User(t, conn, "friend@example.com")
+	stranger := dbtest.User(t, conn, "stranger@example.com")
+	editor := dbtest.User(t, conn, "editor@example.com")
+	viewer := dbtest.User(t, conn, "viewer@example.com")
+
+	private := dbtest.Contact(t, conn, owner, nil, "Private", "Contact")
+	readShared := dbtest.Contact(t, conn, owner, nil, "Read", "Shared")
+	writeShared := dbtest.Contact(t, conn, owner, nil, "Write", "Shared")
+	inReadGroup := dbtest.Contact(t, conn, owner, nil, "Read", "Group")
+	inBothGroups := dbtest.Contact(t, conn, owner, nil, "Both", "Groups")
+	dbtest.ShareContact(t, conn, owner, friend, readShared.ContactID, contact_share.PermissionRead)
+	dbtest.ShareContact(t, conn, owner, friend, writeShared.ContactID, contact_share.PermissionWrite)
+	readGroup := dbtest.Group(t, conn, owner, "Read", inReadGroup, inBothGroups)
+	writeGroup := dbtest.Group(t, conn, owner, "Write", inBothGroups)
+	dbtest.ShareGroup(t, conn, owner, friend, readGroup.GroupID, contact_share.PermissionRead)
+	dbtest.ShareGroup(t, conn, owner, friend, writeGroup.GroupID, contact_share.PermissionWrite)
+
+	team := dbtest.Workspace(t, conn, "Team", map[*user.User]string{owner: workspace.RoleAdmin, editor: workspace.RoleEditor, viewer: workspace.RoleViewer})
+	teamContact := dbtest.Contact(t, conn, viewer, &team.WorkspaceID, "Team", "Contact")
+
+	const ok = 0
+	tests := []struct {
+		name      string
+		user      *user.User
+		contactID uint
+		read      int
+		write     int
+	}{
+		{"owner", owner, private.ContactID, ok, ok},
+		{"stranger", stranger, private.ContactID, http.StatusNotFound, http.StatusNotFound},
+		{"unshared contact of a sharer", friend, private.ContactID, http.StatusNotFound, http.StatusNotFound},
+		{"read share", friend, readShared.ContactID, ok, http.StatusForbidden},
+		{"write share", friend, writeShared.ContactID, ok, ok},
+		{"read group share", friend, inReadGroup.ContactID, ok, http.StatusForbidden},
+		{"write wins across group shares", friend, inBothGroups.ContactID, ok, ok},
+		{"workspace editor", editor, teamContact.ContactID, ok, ok},
+		{"workspace viewer who created the contact", viewer, teamContact.ContactID, ok, http.StatusForbidden},
+		{"non-member of the workspace", stranger, teamContact.ContactID, http.StatusNotFound, http.StatusNotFound},
+		{"missing contact", owner, 9999, http.StatusNotFound, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uow, err := workspaceService.ScopedUnitOfWork(tt.user.UserID, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, check := range []struct {
+				write bool
+				want  int
+			}{{false, tt.read}, {true, tt.write}} {
+				c, err := ResolveContact(uow.DB, tt.user.UserID, tt.contactID, check.write)
+				if check.want == ok {
+					if err != nil {
+						t.Errorf("ResolveContact(write=%v) error = %v", check.write, err)
+					} else if c.ContactID != tt.contactID {
+						t.Errorf("ResolveContact(write=%v) = contact %d, want %d", check.write, c.ContactID, tt.contactID)
+					}
+					continue
+				}
+				if appErr, isApp := err.(apperror.AppError); !isApp || appErr.StatusCode() != check.want {
+					t.Errorf("ResolveContact(write=%v) error = %v, want status %d", check.write, err, check.want)
+				}
+			}
+		})
+	}
+}
+
+// Listing groups resolves the viewer's permissions in one query, however
+// many shared groups there are.
+func TestApplyGroupSharedBy(t *testing.T) {
+	conn := dbtest.Open(t)
+	owner := dbtest.User(t, conn, "owner@example.com")
+	other := dbtest.User(t, conn, "other@example.com")
+	viewer := dbtest.User(t, conn, "viewer@example.com")
+
+	own := dbtest.Group(t, conn, viewer, "Own")
+	groups := []*group.Group{own}
+	for i, permission := range []string{contact_share.PermissionRead, contact_share.PermissionWrite, contact_share.PermissionRead} {
+		sharer := owner
+		if i == 2 {
+			sharer = other
+		}
+		g := dbtest.Group(t, conn, sharer, "Shared")
+		dbtest.ShareGroup(t, conn, sharer, viewer, g.GroupID, permission)
+		groups = append(groups, g)
+	}
+
+	queries := 0
+	if err := conn.Callback().Query().After("gorm:query").Register("test:count_queries", func(*gorm.DB) { queries++ }); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyGroupSharedBy(conn, viewer.UserID, groups); err != nil {
+		t.Fatal(err)
+	}
+	if queries != 2 {
+		t.Errorf("ApplyGroupSharedBy ran %d queries, want 2", queries)
+	}
+
+	if own.SharedBy != nil {
+		t.Errorf("own group SharedBy = %+v, want nil", own.SharedBy)
+	}
+	want := []struct {
+		ownerID    uint
+		permission string
+	}{
+		{owner.UserID, contact_share.PermissionRead},
+		{owner.UserID, contact_share.PermissionWrite},
+		{other.UserID, contact_share.PermissionRead},
+	}
+	for i, w := range want {
+		got := groups[i+1].SharedBy
+		if got == nil || got.UserID != w.ownerID || got.Permission != w.permission || got.Email == "" {
+			t.Errorf("group %d SharedBy = %+v, want owner %d with %s", i+1, got, w.ownerID, w.permission)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package controller
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/component/auth"
+	"Contact_App/component/task/service"
+	"Contact_App/web"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type TaskController struct {
+	Service *service.TaskService
+}
+
+func NewTaskController(svc *service.TaskService) *TaskController {
+	return &TaskController{Service: svc}
+}
+
+// parseTaskRoute resolves {userID}, {contactID} and {taskID}
+func parseTaskRoute(w http.ResponseWriter, r *http.Request) (uint, uint, uint, bool) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return 0, 0, 0, false
+	}
+	taskID, ok := web.ParseID(w, r, "taskID")
+	if !ok {
+		return 0, 0, 0, false
+	}
+	return userID, contactID, taskID, true
+}
+
+// GET /users/{userID}/contacts/{contactID}/tasks?done=true|false
+func (c *TaskController) ListTasksHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+
+	var done *bool
+	if v := r.URL.Query().Get("done"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			apperror.HandleBadRequest(w, "invalid done")
+			return
+		}
+		done = &parsed
+	}
+
+	tasks, err := c.Service.ListTasks(userID, contactID, done)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, tasks)
+}
+
+// GET /users/{userID}/contacts/{contactID}/tasks/{taskID}
+func (c *TaskController) GetTaskHandler(w http.ResponseWriter, r *http.Request) {
+	userID, contactID, taskID, ok := parseTaskRoute(w, r)
+	if !ok {
+		return
+	}
+
+	t, err := c.Service.GetTask(userID, contactID, taskID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, t)
+}
+
+// POST /users/{userID}/contacts/{contactID}/tasks
+func (c *TaskController) AddTaskHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	contactID, ok := web.ParseID(w, r, "contactID")
+	if !ok {
+		return
+	}
+
+	var input service.TaskInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	t, err := c.Service.AddTask(userID, contactID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusCreated, t)
+}
+
+// PUT /users/{userID}/contacts/{contactID}/tasks/{taskID}
+func (c *TaskController) UpdateTaskHandler(w http.ResponseWriter, r *http.Request) {
+	userID, contactID, taskID, ok := parseTaskRoute(w, r)
+	if !ok {
+		return
+	}
+
+	var input service.TaskInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	t, err := c.Service.UpdateTask(userID, contactID, taskID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, t)
+}
+
+// PUT /users/{userID}/contacts/{contactID}/tasks/{taskID}/done
+func (c *TaskController) CompleteTaskHandler(w http.ResponseWriter, r *http.Request) {
+	c.setDone(w, r, true)
+}
+
+// DELETE /users/{userID}/contacts/{contactID}/tasks/{taskID}/done
+// Reopens the task
+func (c *TaskController) ReopenTaskHandler(w http.ResponseWriter, r *http.Request) {
+	c.setDone(w, r, false)
+}
+
+func (c *TaskController) setDone(w http.ResponseWriter, r *http.Request, done bool) {
+	userID, contactID, taskID, ok := parseTaskRoute(w, r)
+	if !ok {
+		return
+	}
+
+	t, err := c.Service.SetDone(userID, contactID, taskID, done)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, t)
+}
+
+// DELETE /users/{userID}/contacts/{contactID}/tasks/{taskID}
+func (c *TaskController) DeleteTaskHandler(w http.ResponseWriter, r *http.Request) {
+	userID, contactID, taskID, ok := parseTaskRoute(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.Service.DeleteTask(userID, contactID, taskID); err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, map[string]string{"message": "task deleted"})
+}
+
+// GET /users/{userID}/tasks/overdue
+func (c *TaskController) OverdueTasksHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	tasks, err := c.Service.Overdue(userID, time.Now())
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, tasks)
+}
+
+// GET /users/{userID}/tasks/upcoming?days=7
+func (c *TaskController) UpcomingTasksHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	days := 0
+	if v := r.URL.Query().Get("days"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			apperror.HandleBadRequest(w, "invalid days")
+			return
+		}
+		days = parsed
+	}
+
+	tasks, err := c.Service.Upcoming(userID, days, time.Now())
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, tasks)
+}
+
+func (c *TaskController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/users/{userID}/tasks/overdue", c.OverdueTasksHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/tasks/upcoming", c.UpcomingTasksHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/tasks", c.ListTasksHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/tasks", c.AddTaskHandler).Methods("POST")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/tasks/{taskID:[0-9]+}", c.GetTaskHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/tasks/{taskID:[0-9]+}", c.UpdateTaskHandler).Methods("PUT")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/tasks/{taskID:[0-9]+}", c.DeleteTaskHandler).Methods("DELETE")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/tasks/{taskID:[0-9]+}/done", c.CompleteTaskHandler).Methods("PUT")
+	router.HandleFunc("/users/{userID}/contacts/{contactID:[0-9]+}/tasks/{taskID:[0-9]+}/done", c.ReopenTaskHandler).Methods("DELETE")
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/db"
+	"Contact_App/models/task"
+	"Contact_App/notify"
+	"Contact_App/repository"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// ReminderKind is the notification kind of task reminders
+	ReminderKind = "task_reminder"
+
+	defaultReminderIntervalMinutes = 5
+	reminderBatchSize              = 500
+
+	// maxReminderAttempts is how often a reminder is tried before it is
+	// given up; the wait between tries starts at reminderRetryDelay and doubles
+	maxReminderAttempts = 5
+	reminderRetryDelay  = 5 * time.Minute
+)
+
+// SendDueReminders notifies the owners of open tasks whose reminder time
+// (or due date, without one) has passed, once per task. Each task is claimed
+// before it is sent so overlapping runs never notify twice; a failed
+// delivery releases the claim and is retried after a growing delay, so a
+// task that keeps failing does not hold back the rest of the batch.
+func (s *TaskService) SendDueReminders(now time.Time, notifier notify.Notifier) (int, error) {
+	// the job runs for every user, so it opts out of tenant scoping
+	var rows []*taskRow
+	if err := repository.WithoutTenant(db.GetDB()).Table("tasks").
+		Select("tasks.*, contacts.f_name, contacts.l_name").
+		Joins("JOIN contacts ON contacts.contact_id = tasks.contact_id").
+		Where("tasks.done = ? AND tasks.reminded_at IS NULL AND tasks.deleted_at IS NULL", false).
+		Where("COALESCE(tasks.remind_at, tasks.due_at) <= ?", now).
+		Where("tasks.reminder_attempts < ?", maxReminderAttempts).
+		Where("tasks.next_reminder_at IS NULL OR tasks.next_reminder_at <= ?", now).
+		Where("contacts.is_active = ? AND contacts.deleted_at IS NULL", true).
+		Order("tasks.due_at, tasks.task_id").
+		Limit(reminderBatchSize).
+		Scan(&rows).Error; err != nil {
+		return 0, apperror.NewInternalError("failed to load due tasks")
+	}
+
+	sent := 0
+	for _, row := range rows {
+		claim := db.GetDB().Model(&task.Task{}).
+			Where("task_id = ? AND reminded_at IS NULL", row.TaskID).
+			UpdateColumn("reminded_at", now)
+		if claim.Error != nil {
+			return sent, apperror.NewInternalError("failed to claim task reminder")
+		}
+		if claim.RowsAffected == 0 {
+			continue
+		}
+
+		if err := notifier.Notify(reminder(row, now)); err != nil {
+			log.Printf("Task reminder %d failed: %v\n", row.TaskID, err)
+			db.GetDB().Model(&task.Task{}).Where("task_id = ?", row.TaskID).
+				UpdateColumns(map[string]interface{}{
+					"reminded_at":       gorm.Expr("NULL"),
+					"reminder_attempts": gorm.Expr("reminder_attempts + 1"),
+					"next_reminder_at":  now.Add(retryDelay(row.ReminderAttempts)),
+				})
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// retryDelay is the wait before retrying a reminder that failed after
+// attempts earlier failures
+func retryDelay(attempts int) time.Duration {
+	return reminderRetryDelay << attempts
+}
+
+func reminder(row *taskRow, now time.Time) *notify.Notification {
+	name := strings.TrimSpace(row.FName + " " + row.LName)
+	if name == "" {
+		name = "a contact"
+	}
+	return &notify.Notification{
+		Kind:    ReminderKind,
+		UserID:  row.UserID,
+		Subject: "Follow up with " + name,
+		Body:    row.Note,
+		Data: map[string]interface{}{
+			"task_id":    row.TaskID,
+			"contact_id": row.ContactID,
+			"due_at":     row.DueAt,
+		},
+		CreatedAt: now,
+	}
+}
+
+// ReminderScheduler periodically sends the reminders of tasks that came due
+type ReminderScheduler struct {
+	Service  *TaskService
+	Notifier notify.Notifier
+	Interval time.Duration
+}
+
+func NewReminderScheduler(svc *TaskService) *ReminderScheduler {
+	minutes, err := strconv.Atoi(os.Getenv("TASK_REMINDER_INTERVAL_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = defaultReminderIntervalMinutes
+	}
+	return &ReminderScheduler{
+		Service:  svc,
+		Notifier: notify.Default(),
+		Interval: time.Duration(minutes) * time.Minute,
+	}
+}
+
+// Start runs the reminder loop in a goroutine until stop is closed
+func (r *ReminderScheduler) Start(wg *sync.WaitGroup, stop <-chan struct{}) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(r.Interval)
+		defer ticker.Stop()
+
+		for {
+			r.runOnce()
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (r *ReminderScheduler) runOnce() {
+	sent, err := r.Service.SendDueReminders(time.Now(), r.Notifier)
+	if err != nil {
+		log.Println("Task reminders failed:", err)
+		return
+	}
+	if sent > 0 {
+		log.Printf("Sent %d task reminders\n", sent)
+	}
+}
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	shareService "Contact_App/component/share/service"
+	workspaceService "Contact_App/component/workspace/service"
+	"Contact_App/models/contact"
+	"Contact_App/models/task"
+	"Contact_App/repository"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultUpcomingDays = 7
+	MaxUpcomingDays     = 365
+)
+
+// TaskInput is the body accepted when creating or replacing a task. Done is
+// kept as it is when omitted on update.
+type TaskInput struct {
+	Note     string     `json:"note"`
+	DueAt    *time.Time `json:"due_at"`
+	RemindAt *time.Time `json:"remind_at"`
+	Done     *bool      `json:"done"`
+}
+
+// TaskWithContact is a task listed across contacts, with its contact's name
+type TaskWithContact struct {
+	*task.Task
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+type TaskService struct {
+	repo repository.Repository
+}
+
+func NewTaskService() *TaskService {
+	return &TaskService{repo: repository.NewGormRepository()}
+}
+
+// ListTasks returns a contact's tasks by due date, optionally only the open
+// or the done ones
+func (s *TaskService) ListTasks(userID, contactID uint, done *bool) ([]*task.Task, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := shareService.ResolveContact(uow.DB, userID, contactID, false); err != nil {
+		return nil, err
+	}
+
+	query := uow.DB.Where("contact_id = ? AND user_id = ?", contactID, userID)
+	if done != nil {
+		query = query.Where("done = ?", *done)
+	}
+
+	tasks := []*task.Task{}
+	if err := query.Order("due_at, task_id").Find(&tasks).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load tasks")
+	}
+	return tasks, nil
+}
+
+func (s *TaskService) GetTask(userID, contactID, taskID uint) (*task.Task, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+	return findTask(uow, userID, contactID, taskID)
+}
+
+func (s *TaskService) AddTask(userID, contactID uint, input TaskInput) (*task.Task, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	if _, err := shareService.ResolveContact(uow.DB, userID, contactID, false); err != nil {
+		return nil, err
+	}
+
+	t := &task.Task{UserID: userID, ContactID: contactID}
+	if err := applyInput(t, input, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Add(uow, t); err != nil {
+		return nil, err
+	}
+
+	uow.Commit()
+	return t, nil
+}
+
+// UpdateTask replaces a task's note, due date and reminder time. Moving
+// either time re-arms a reminder that was already sent.
+func (s *TaskService) UpdateTask(userID, contactID, taskID uint, input TaskInput) (*task.Task, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	t, err := findTask(uow, userID, contactID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyInput(t, input, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := uow.DB.Select("note", "due_at", "remind_at", "reminded_at", "reminder_attempts", "next_reminder_at", "done", "done_at").Save(t).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to update task")
+	}
+
+	uow.Commit()
+	return t, nil
+}
+
+// SetDone marks a task done or open again
+func (s *TaskService) SetDone(userID, contactID, taskID uint, done bool) (*task.Task, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	t, err := findTask(uow, userID, contactID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if t.Done != done {
+		markDone(t, done, time.Now())
+		if err := uow.DB.Select("done", "done_at").Save(t).Error; err != nil {
+			return nil, apperror.NewInternalError("failed to update task")
+		}
+	}
+
+	uow.Commit()
+	return t, nil
+}
+
+func (s *TaskService) DeleteTask(userID, contactID, taskID uint) error {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return err
+	}
+	defer uow.Rollback()
+
+	t, err := findTask(uow, userID, contactID, taskID)
+	if err != nil {
+		return err
+	}
+	if err := uow.DB.Delete(t).Error; err != nil {
+		return apperror.NewInternalError("failed to delete task")
+	}
+
+	uow.Commit()
+	return nil
+}
+
+// DeleteTasksWithUOW drops the tasks of contacts being permanently deleted
+func DeleteTasksWithUOW(uow *repository.UnitOfWork, contactIDs []uint) error {
+	if err := uow.DB.Unscoped().Where("contact_id IN ?", contactIDs).
+		Delete(&task.Task{}).Error; err != nil {
+		return apperror.NewInternalError("failed to delete tasks")
+	}
+	return nil
+}
+
+// Overdue lists the user's open tasks that were due before now, oldest first
+func (s *TaskService) Overdue(userID uint, now time.Time) ([]*TaskWithContact, error) {
+	return listOpenTasks(userID, "tasks.due_at < ?", now)
+}
+
+// Upcoming lists the user's open tasks due from now until days days ahead,
+// soonest first
+func (s *TaskService) Upcoming(userID uint, days int, now time.Time) ([]*TaskWithContact, error) {
+	if days <= 0 {
+		days = DefaultUpcomingDays
+	}
+	if days > MaxUpcomingDays {
+		return nil, apperror.NewValidationError("days", fmt.Sprintf("must be at most %d", MaxUpcomingDays))
+	}
+	until := now.AddDate(0, 0, days)
+	return listOpenTasks(userID, "tasks.due_at >= ? AND tasks.due_at < ?", now, until)
+}
+
+// taskRow is a task joined with the name of its contact
+type taskRow struct {
+	task.Task
+	FName string `gorm:"column:f_name"`
+	LName string `gorm:"column:l_name"`
+}
+
+// listOpenTasks lists the user's open tasks matching the due date condition
+// on active contacts they can still see
+func listOpenTasks(userID uint, due string, args ...interface{}) ([]*TaskWithContact, error) {
+	uow, err := workspaceService.ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []*taskRow
+	if err := uow.DB.Model(&contact.Contact{}).
+		Select("tasks.*, contacts.f_name, contacts.l_name").
+		Joins("JOIN tasks ON tasks.contact_id = contacts.contact_id").
+		Where(shareService.AccessibleContacts(uow.DB, userID)).
+		Where(due, args...).
+		Where("tasks.user_id = ? AND tasks.done = ? AND tasks.deleted_at IS NULL", userID, false).
+		Where("contacts.is_active = ?", true).
+		Order("tasks.due_at, tasks.task_id").
+		Scan(&rows).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load tasks")
+	}
+
+	tasks := make([]*TaskWithContact, 0, len(rows))
+	for _, row := range rows {
+		t := row.Task
+		tasks = append(tasks, &TaskWithContact{Task: &t, FirstName: row.FName, LastName: row.LName})
+	}
+	return tasks, nil
+}
+
+func applyInput(t *task.Task, input TaskInput, now time.Time) error {
+	note := strings.TrimSpace(input.Note)
+	if note == "" {
+		return apperror.NewValidationError("note", "is required")
+	}
+	if len(note) > 65535 {
+		return apperror.NewValidationError("note", "must be at most 65535 bytes")
+	}
+	if input.DueAt == nil || input.DueAt.IsZero() {
+		return apperror.NewValidationError("due_at", "is required")
+	}
+	if input.RemindAt != nil && input.RemindAt.After(*input.DueAt) {
+		return apperror.NewValidationError("remind_at", "cannot be after due_at")
+	}
+
+	if !t.DueAt.Equal(*input.DueAt) || !sameTime(t.RemindAt, input.RemindAt) {
+		t.RemindedAt = nil
+		t.ReminderAttempts = 0
+		t.NextReminderAt = nil
+	}
+	t.Note = note
+	t.DueAt = *input.DueAt
+	t.RemindAt = input.RemindAt
+	if input.Done != nil && *input.Done != t.Done {
+		markDone(t, *input.Done, now)
+	}
+	return nil
+}
+
+func markDone(t *task.Task, done bool, now time.Time) {
+	t.Done = done
+	t.DoneAt = nil
+	if done {
+		t.DoneAt = &now
+	}
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func findTask(uow *repository.UnitOfWork, userID, contactID, taskID uint) (*task.Task, error) {
+	var t task.Task
+	if err := uow.DB.Where("task_id = ? AND contact_id = ? AND user_id = ?", taskID, contactID, userID).
+		First(&t).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewNotFoundError("task", int(taskID))
+		}
+		return nil, apperror.NewInternalError("failed to load task")
+	}
+	return &t, nil
+}
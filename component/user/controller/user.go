@@ -13,6 +13,23 @@ import (
 	"gorm.io/gorm"
 )
 
+// userResource whitelists the fields clients may sort, select and filter users on
+var userResource = web.ResourceSpec{
+	PrimaryKey: "user_id",
+	Fields: map[string]web.FieldSpec{
+		"user_id":    {JSONName: "user_id", Column: "user_id", Type: web.FieldNumber},
+		"first_name": {JSONName: "first_name", Column: "f_name", Type: web.FieldString},
+		"f_name":     {JSONName: "first_name", Column: "f_name", Type: web.FieldString},
+		"last_name":  {JSONName: "last_name", Column: "l_name", Type: web.FieldString},
+		"l_name":     {JSONName: "last_name", Column: "l_name", Type: web.FieldString},
+		"email":      {JSONName: "email", Column: "email", Type: web.FieldString},
+		"is_admin":   {JSONName: "is_admin", Column: "is_admin", Type: web.FieldBool},
+		"is_active":  {JSONName: "is_active", Column: "is_active", Type: web.FieldBool},
+		"created_at": {JSONName: "created_at", Column: "created_at", Type: web.FieldTime},
+		"updated_at": {JSONName: "updated_at", Column: "updated_at", Type: web.FieldTime},
+	},
+}
+
 type UserHandler struct {
 	DB *gorm.DB
 }
@@ -44,6 +61,7 @@ func (h *UserHandler) RegisterRoutes(router *mux.Router) {
 	admin.Use(auth.MiddlewareAdminActive)
 	admin.HandleFunc("", h.GetAllUsersHandler).Methods("GET")
 	admin.HandleFunc("/{userID}", h.UpdateUserHandler).Methods("PUT")
+	admin.HandleFunc("/{userID}", h.PatchUserHandler).Methods("PATCH")
 	admin.HandleFunc("/{userID}", h.DeleteUserHandler).Methods("DELETE")
 
 	secured := router.PathPrefix("/user").Subrouter()
@@ -118,6 +136,9 @@ func (h *UserHandler) GetUserByIDHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if web.NotModified(w, r, u.Version) {
+		return
+	}
 	web.RespondJSON(w, http.StatusOK, u)
 }
 
@@ -136,7 +157,14 @@ func (h *UserHandler) GetAllUsersHandler(w http.ResponseWriter, r *http.Request)
 		filters = append(filters, repository.Filter("email LIKE ?", "%"+email+"%"))
 	}
 
-	if err := service.GetAllUsersPaginated(uow.DB, w, r, filters...); err != nil {
+	spec, err := web.ParseQuerySpec(r.URL.Query(), userResource)
+	if err != nil {
+		web.RespondError(w, err)
+		return
+	}
+	filters = append(filters, spec.FilterProcessors()...)
+
+	if err := service.GetAllUsersPaginated(uow.DB, w, r, spec, filters...); err != nil {
 		web.RespondError(w, err)
 	}
 }
@@ -188,13 +216,67 @@ func (h *UserHandler) UpdateUserHandler(w http.ResponseWriter, r *http.Request)
 		updateData.IsAdmin = input.IsAdmin
 	}
 
-	updatedUser, err := service.UpdateUserByID(userRepo, uow, claims, userID, updateData)
+	ifMatch, err := web.IfMatchVersion(r)
+	if err != nil {
+		web.RespondError(w, err)
+		return
+	}
+
+	updatedUser, err := service.UpdateUserByID(userRepo, uow, claims, userID, ifMatch, updateData)
 	if err != nil {
 		web.RespondError(w, err)
 		return
 	}
 
 	uow.Commit()
+	web.SetETag(w, updatedUser.Version)
+	web.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "User updated successfully",
+		"user":    updatedUser,
+	})
+}
+
+// PatchUserHandler accepts application/merge-patch+json or application/json-patch+json
+func (h *UserHandler) PatchUserHandler(w http.ResponseWriter, r *http.Request) {
+	claims := auth.GetUserClaims(r)
+	if claims == nil || !claims.IsActive {
+		web.RespondErrorMessage(w, http.StatusUnauthorized, "Unauthorized or inactive user")
+		return
+	}
+
+	userID, err := strconv.Atoi(mux.Vars(r)["userID"])
+	if err != nil {
+		web.RespondErrorMessage(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if !claims.IsAdmin && claims.UserID != userID {
+		web.RespondErrorMessage(w, http.StatusForbidden, "You can only update your own profile")
+		return
+	}
+
+	ifMatch, err := web.IfMatchVersion(r)
+	if err != nil {
+		web.RespondError(w, err)
+		return
+	}
+	body, err := web.ReadPatchBody(w, r)
+	if err != nil {
+		web.RespondError(w, err)
+		return
+	}
+
+	uow := repository.NewUnitOfWork(h.DB, false)
+	defer uow.Rollback()
+
+	updatedUser, err := service.PatchUserByID(uow, claims, userID, ifMatch, r.Header.Get("Content-Type"), body)
+	if err != nil {
+		web.RespondError(w, err)
+		return
+	}
+
+	uow.Commit()
+	web.SetETag(w, updatedUser.Version)
 	web.RespondJSON(w, http.StatusOK, map[string]interface{}{
 		"message": "User updated successfully",
 		"user":    updatedUser,
@@ -215,11 +297,17 @@ func (h *UserHandler) DeleteUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	ifMatch, err := web.IfMatchVersion(r)
+	if err != nil {
+		web.RespondError(w, err)
+		return
+	}
+
 	uow := repository.NewUnitOfWork(h.DB, false)
 	defer uow.Rollback()
 
 	userRepo := repository.NewGormRepository()
-	if err := service.DeleteUserByIDSoftDelete(userRepo, uow, claims.UserID, userID); err != nil {
+	if err := service.DeleteUserByIDSoftDelete(userRepo, uow, claims.UserID, userID, ifMatch); err != nil {
 		web.RespondError(w, err)
 		return
 	}
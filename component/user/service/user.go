@@ -5,18 +5,19 @@ import (
 	"Contact_App/component/auth"
 	"Contact_App/helper"
 	"Contact_App/models/user"
+	"Contact_App/patch"
 	"Contact_App/repository"
 	"Contact_App/web"
 	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var jwtSecret = []byte("your-secret-key")
@@ -122,6 +123,7 @@ func UpdateUserByID(
 	uow *repository.UnitOfWork,
 	claims *auth.Claims,
 	userID int,
+	ifMatch uint,
 	updates *struct {
 		FName    string `json:"f_name"`
 		LName    string `json:"l_name"`
@@ -131,8 +133,8 @@ func UpdateUserByID(
 	},
 ) (*user.User, error) {
 
-	var existing user.User
-	if err := repo.GetByID(uow, uint(userID), &existing); err != nil {
+	existing, err := lockUser(uow, userID, ifMatch)
+	if err != nil {
 		return nil, err
 	}
 
@@ -155,6 +157,7 @@ func UpdateUserByID(
 		updateMap["password"] = string(hashedPass)
 	}
 	updateMap["is_admin"] = updates.IsAdmin
+	updateMap["version"] = gorm.Expr("version + 1")
 
 	if err := repo.UpdateWithMap(uow, &user.User{}, updateMap,
 		repository.Filter("user_id = ?", userID),
@@ -176,8 +179,107 @@ func UpdateUserByID(
 			existing.IsAdmin = v.(bool)
 		}
 	}
+	existing.Version++
+
+	return existing, nil
+}
+
+// userDocument is the view of a user that PATCH requests operate on; the
+// password is write-only, so it may be added by a patch but is never shown
+type userDocument struct {
+	FName    string  `json:"first_name"`
+	LName    *string `json:"last_name"`
+	Email    string  `json:"email"`
+	IsAdmin  bool    `json:"is_admin"`
+	Password string  `json:"password,omitempty"`
+}
 
-	return &existing, nil
+var userPatchSchema = patch.Schema{
+	"first_name": {Kind: patch.KindString, Required: true, MaxLength: 255},
+	"last_name":  {Kind: patch.KindString, Nullable: true, MaxLength: 255},
+	"email":      {Kind: patch.KindString, Required: true, MaxLength: 255},
+	"is_admin":   {Kind: patch.KindBool, Required: true},
+	"password":   {Kind: patch.KindString},
+}
+
+// PatchUserByID applies a merge patch or JSON Patch to a user. A null or
+// removed last_name clears it; only admins may change is_admin.
+func PatchUserByID(uow *repository.UnitOfWork, claims *auth.Claims, userID int, ifMatch uint, contentType string, body []byte) (*user.User, error) {
+	existing, err := lockUser(uow, userID, ifMatch)
+	if err != nil {
+		return nil, err
+	}
+
+	lastName := existing.LName
+	current := userDocument{FName: existing.FName, LName: &lastName, Email: existing.Email, IsAdmin: existing.IsAdmin}
+	patched, err := patch.Apply(contentType, current, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := userPatchSchema.Validate(patched); err != nil {
+		return nil, err
+	}
+	var target userDocument
+	if err := patch.Decode(patched, &target); err != nil {
+		return nil, err
+	}
+
+	updateMap := make(map[string]interface{})
+	if name := strings.TrimSpace(target.FName); name != existing.FName {
+		updateMap["f_name"] = name
+	}
+	newLast := ""
+	if target.LName != nil {
+		newLast = strings.TrimSpace(*target.LName)
+	}
+	if newLast != existing.LName {
+		updateMap["l_name"] = newLast
+	}
+
+	email := strings.ToLower(strings.TrimSpace(target.Email))
+	if email != existing.Email {
+		if !helper.IsValidEmail(email) {
+			return nil, apperror.NewValidationError("email", "is not a valid address")
+		}
+		var taken int64
+		if err := uow.DB.Model(&user.User{}).Where("email = ? AND user_id <> ?", email, userID).Count(&taken).Error; err != nil {
+			return nil, apperror.NewInternalError("failed to check email")
+		}
+		if taken > 0 {
+			return nil, apperror.NewConflictError("email", "email is already in use")
+		}
+		updateMap["email"] = email
+	}
+
+	if target.IsAdmin != existing.IsAdmin {
+		if !claims.IsAdmin {
+			return nil, apperror.NewAuthError("change is_admin")
+		}
+		updateMap["is_admin"] = target.IsAdmin
+	}
+
+	if target.Password != "" {
+		hashedPass, err := bcrypt.GenerateFromPassword([]byte(target.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, apperror.NewInternalError("failed to hash password")
+		}
+		updateMap["password"] = string(hashedPass)
+	}
+
+	if len(updateMap) == 0 {
+		return existing, nil
+	}
+	updateMap["version"] = gorm.Expr("version + 1")
+
+	if err := uow.DB.Model(&user.User{}).Where("user_id = ?", userID).Updates(updateMap).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to patch user")
+	}
+
+	var updated user.User
+	if err := uow.DB.Where("user_id = ?", userID).First(&updated).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to reload user")
+	}
+	return &updated, nil
 }
 
 // Hard delete (permanently removes user)
@@ -222,7 +324,7 @@ func DeleteUserByID(repo repository.Repository, uow *repository.UnitOfWork, admi
 	return nil
 }
 
-func DeleteUserByIDSoftDelete(repo repository.Repository, uow *repository.UnitOfWork, adminID int, userID int) error {
+func DeleteUserByIDSoftDelete(repo repository.Repository, uow *repository.UnitOfWork, adminID int, userID int, ifMatch uint) error {
 	var admin user.User
 	if err := repo.GetByID(uow, uint(adminID), &admin); err != nil {
 		return err
@@ -241,8 +343,8 @@ func DeleteUserByIDSoftDelete(repo repository.Repository, uow *repository.UnitOf
 		return apperror.NewValidationError("user", "admin cannot delete their own account")
 	}
 
-	var target user.User
-	if err := repo.GetByID(uow, uint(userID), &target); err != nil {
+	target, err := lockUser(uow, userID, ifMatch)
+	if err != nil {
 		return err
 	}
 
@@ -252,17 +354,37 @@ func DeleteUserByIDSoftDelete(repo repository.Repository, uow *repository.UnitOf
 
 	target.IsActive = false
 
-	if err := uow.DB.Save(&target).Error; err != nil {
+	target.Version++
+
+	if err := uow.DB.Save(target).Error; err != nil {
 		return apperror.NewInternalError("failed to update user as inactive")
 	}
 
-	if err := uow.DB.Delete(&target).Error; err != nil {
+	if err := uow.DB.Delete(target).Error; err != nil {
 		return apperror.NewInternalError("failed to soft delete user")
 	}
 
 	return nil
 }
 
+// lockUser loads a user FOR UPDATE and checks it against the version the
+// client last saw
+func lockUser(uow *repository.UnitOfWork, userID int, ifMatch uint) (*user.User, error) {
+	var u user.User
+	if err := uow.DB.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ?", userID).
+		First(&u).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewNotFoundError("user", userID)
+		}
+		return nil, apperror.NewInternalError("failed to load user")
+	}
+	if ifMatch != 0 && u.Version != ifMatch {
+		return nil, apperror.NewPreconditionFailedError("user", u.Version)
+	}
+	return &u, nil
+}
+
 func ExposeNewUserInternal(repo repository.Repository, uow *repository.UnitOfWork, fname, lname string, isAdmin bool) (*user.User, error) {
 	fname = strings.TrimSpace(fname)
 	lname = strings.TrimSpace(lname)
@@ -307,7 +429,7 @@ func CreateInitialAdminUser(repo repository.Repository, uow *repository.UnitOfWo
 	return ExposeNewUserInternal(repo, uow, "Admin", "User", true)
 }
 
-func GetAllUsersPaginated(db *gorm.DB, w http.ResponseWriter, r *http.Request, filters ...repository.QueryProcessor) error {
+func GetAllUsersPaginated(db *gorm.DB, w http.ResponseWriter, r *http.Request, spec *web.QuerySpec, filters ...repository.QueryProcessor) error {
 	baseQuery := db.Model(&user.User{})
 	var err error
 
@@ -319,35 +441,14 @@ func GetAllUsersPaginated(db *gorm.DB, w http.ResponseWriter, r *http.Request, f
 		}
 	}
 
-	// Pagination
-	pageStr := r.URL.Query().Get("page")
-	limitStr := r.URL.Query().Get("limit")
-	page, _ := strconv.Atoi(pageStr)
-	limit, _ := strconv.Atoi(limitStr)
-	if page <= 0 {
-		page = 1
-	}
-	if limit <= 0 {
-		limit = 5
-	}
-
-	var total int64
-	if err := baseQuery.Count(&total).Error; err != nil {
-		return err
-	}
-
+	// Keyset pagination
 	var users []user.User
-	offset := (page - 1) * limit
-	if err := baseQuery.Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+	page, err := web.PaginateQuery(r, baseQuery, &users, spec)
+	if err != nil {
 		return err
 	}
 
-	web.RespondJSON(w, http.StatusOK, map[string]interface{}{
-		"data":  users,
-		"total": total,
-		"page":  page,
-		"limit": limit,
-	})
+	web.RespondJSON(w, http.StatusOK, page.Project(spec))
 
 	return nil
 }
@@ -0,0 +1,227 @@
+package controller
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/component/auth"
+	"Contact_App/component/workspace/service"
+	"Contact_App/web"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type WorkspaceController struct {
+	Service *service.WorkspaceService
+}
+
+func NewWorkspaceController(svc *service.WorkspaceService) *WorkspaceController {
+	return &WorkspaceController{Service: svc}
+}
+
+// GET /users/{userID}/workspaces
+func (c *WorkspaceController) ListWorkspacesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	workspaces, err := c.Service.ListWorkspaces(userID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, workspaces)
+}
+
+// POST /users/{userID}/workspaces
+func (c *WorkspaceController) CreateWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+
+	var input service.WorkspaceInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	ws, err := c.Service.CreateWorkspace(userID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusCreated, ws)
+}
+
+// GET /users/{userID}/workspaces/{workspaceID}
+func (c *WorkspaceController) GetWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	workspaceID, ok := web.ParseID(w, r, "workspaceID")
+	if !ok {
+		return
+	}
+
+	ws, err := c.Service.GetWorkspace(userID, workspaceID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, ws)
+}
+
+// PUT /users/{userID}/workspaces/{workspaceID}
+func (c *WorkspaceController) UpdateWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	workspaceID, ok := web.ParseID(w, r, "workspaceID")
+	if !ok {
+		return
+	}
+
+	var input service.WorkspaceInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	ws, err := c.Service.UpdateWorkspace(userID, workspaceID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, ws)
+}
+
+// DELETE /users/{userID}/workspaces/{workspaceID}
+func (c *WorkspaceController) DeleteWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	workspaceID, ok := web.ParseID(w, r, "workspaceID")
+	if !ok {
+		return
+	}
+
+	if err := c.Service.DeleteWorkspace(userID, workspaceID); err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, map[string]string{"message": "workspace deleted"})
+}
+
+// GET /users/{userID}/workspaces/{workspaceID}/members
+func (c *WorkspaceController) ListMembersHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	workspaceID, ok := web.ParseID(w, r, "workspaceID")
+	if !ok {
+		return
+	}
+
+	members, err := c.Service.ListMembers(userID, workspaceID)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, members)
+}
+
+// POST /users/{userID}/workspaces/{workspaceID}/members
+func (c *WorkspaceController) AddMemberHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	workspaceID, ok := web.ParseID(w, r, "workspaceID")
+	if !ok {
+		return
+	}
+
+	var input service.MemberInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	member, err := c.Service.AddMember(userID, workspaceID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusCreated, member)
+}
+
+// PUT /users/{userID}/workspaces/{workspaceID}/members/{memberID}
+// memberID is the member's user ID; only the role can change
+func (c *WorkspaceController) UpdateMemberHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	workspaceID, ok := web.ParseID(w, r, "workspaceID")
+	if !ok {
+		return
+	}
+	memberID, ok := web.ParseID(w, r, "memberID")
+	if !ok {
+		return
+	}
+
+	var input service.MemberInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		apperror.HandleBadRequest(w, "invalid JSON")
+		return
+	}
+
+	member, err := c.Service.UpdateMember(userID, workspaceID, memberID, input)
+	if err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, member)
+}
+
+// DELETE /users/{userID}/workspaces/{workspaceID}/members/{memberID}
+// Members may remove themselves to leave the workspace
+func (c *WorkspaceController) RemoveMemberHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.AuthorizeOwner(w, r)
+	if !ok {
+		return
+	}
+	workspaceID, ok := web.ParseID(w, r, "workspaceID")
+	if !ok {
+		return
+	}
+	memberID, ok := web.ParseID(w, r, "memberID")
+	if !ok {
+		return
+	}
+
+	if err := c.Service.RemoveMember(userID, workspaceID, memberID); err != nil {
+		apperror.HandleError(w, err)
+		return
+	}
+	web.RespondJSON(w, http.StatusOK, map[string]string{"message": "member removed"})
+}
+
+func (c *WorkspaceController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/users/{userID}/workspaces", c.ListWorkspacesHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/workspaces", c.CreateWorkspaceHandler).Methods("POST")
+	router.HandleFunc("/users/{userID}/workspaces/{workspaceID:[0-9]+}", c.GetWorkspaceHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/workspaces/{workspaceID:[0-9]+}", c.UpdateWorkspaceHandler).Methods("PUT")
+	router.HandleFunc("/users/{userID}/workspaces/{workspaceID:[0-9]+}", c.DeleteWorkspaceHandler).Methods("DELETE")
+	router.HandleFunc("/users/{userID}/workspaces/{workspaceID:[0-9]+}/members", c.ListMembersHandler).Methods("GET")
+	router.HandleFunc("/users/{userID}/workspaces/{workspaceID:[0-9]+}/members", c.AddMemberHandler).Methods("POST")
+	router.HandleFunc("/users/{userID}/workspaces/{workspaceID:[0-9]+}/members/{memberID:[0-9]+}", c.UpdateMemberHandler).Methods("PUT")
+	router.HandleFunc("/users/{userID}/workspaces/{workspaceID:[0-9]+}/members/{memberID:[0-9]+}", c.RemoveMemberHandler).Methods("DELETE")
+}
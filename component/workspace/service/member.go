@@ -0,0 +1,227 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/models/contact"
+	"Contact_App/models/user"
+	"Contact_App/models/workspace"
+	"Contact_App/repository"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// MemberInput is the body accepted when adding a member or changing their
+// role. Email is only read when adding; Role defaults to editor.
+type MemberInput struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// ListMembers returns the members of a workspace the user belongs to
+func (s *WorkspaceService) ListMembers(userID, workspaceID uint) ([]*workspace.WorkspaceMember, error) {
+	uow, err := ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := requireRole(uow, userID, workspaceID, false); err != nil {
+		return nil, err
+	}
+	return loadMembers(uow, workspaceID)
+}
+
+// AddMember adds the user registered under input.Email to the workspace
+func (s *WorkspaceService) AddMember(userID, workspaceID uint, input MemberInput) (*workspace.WorkspaceMember, error) {
+	role, err := parseRole(input.Role)
+	if err != nil {
+		return nil, err
+	}
+	email := strings.ToLower(strings.TrimSpace(input.Email))
+	if email == "" {
+		return nil, apperror.NewValidationError("email", "is required")
+	}
+
+	uow, err := ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	if _, err := requireRole(uow, userID, workspaceID, true); err != nil {
+		return nil, err
+	}
+
+	var u user.User
+	if err := uow.DB.Where("email = ? AND is_active = ?", email, true).First(&u).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewValidationError("email", "does not belong to a user")
+		}
+		return nil, apperror.NewInternalError("failed to load user")
+	}
+	existing, err := Role(uow.DB, u.UserID, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != "" {
+		return nil, apperror.NewConflictError("workspace member", "that user is already a member of this workspace")
+	}
+
+	member := &workspace.WorkspaceMember{WorkspaceID: workspaceID, UserID: u.UserID, Role: role}
+	if err := s.repo.Add(uow, member); err != nil {
+		return nil, err
+	}
+	member.Name, member.Email = strings.TrimSpace(u.FName+" "+u.LName), u.Email
+
+	uow.Commit()
+	return member, nil
+}
+
+// UpdateMember changes a member's role. The last admin cannot be demoted.
+func (s *WorkspaceService) UpdateMember(userID, workspaceID, memberUserID uint, input MemberInput) (*workspace.WorkspaceMember, error) {
+	role, err := parseRole(input.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	uow, err := ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	if _, err := requireRole(uow, userID, workspaceID, true); err != nil {
+		return nil, err
+	}
+	member, err := findMember(uow, workspaceID, memberUserID)
+	if err != nil {
+		return nil, err
+	}
+	if member.Role == workspace.RoleAdmin && role != workspace.RoleAdmin {
+		if err := ensureAnotherAdmin(uow, workspaceID); err != nil {
+			return nil, err
+		}
+	}
+
+	member.Role = role
+	if err := uow.DB.Select("role").Save(member).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to update workspace member")
+	}
+	if err := applyUsers(uow, []*workspace.WorkspaceMember{member}); err != nil {
+		return nil, err
+	}
+
+	uow.Commit()
+	return member, nil
+}
+
+// RemoveMember takes a user out of a workspace. Admins remove anyone; other
+// members may only leave. The last admin has to hand over first. The member's
+// next delta sync drops the workspace's contacts.
+func (s *WorkspaceService) RemoveMember(userID, workspaceID, memberUserID uint) error {
+	uow, err := ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return err
+	}
+	defer uow.Rollback()
+
+	if _, err := requireRole(uow, userID, workspaceID, memberUserID != userID); err != nil {
+		return err
+	}
+	member, err := findMember(uow, workspaceID, memberUserID)
+	if err != nil {
+		return err
+	}
+	if member.Role == workspace.RoleAdmin {
+		if err := ensureAnotherAdmin(uow, workspaceID); err != nil {
+			return err
+		}
+	}
+	if err := uow.DB.Delete(member).Error; err != nil {
+		return apperror.NewInternalError("failed to remove workspace member")
+	}
+
+	var contactIDs []uint
+	if err := uow.DB.Unscoped().Model(&contact.Contact{}).
+		Where("workspace_id = ?", workspaceID).
+		Pluck("contact_id", &contactIDs).Error; err != nil {
+		return apperror.NewInternalError("failed to load workspace contacts")
+	}
+	if err := RecordRevokesWithUOW(uow, memberUserID, contactIDs); err != nil {
+		return err
+	}
+
+	uow.Commit()
+	return nil
+}
+
+func loadMembers(uow *repository.UnitOfWork, workspaceID uint) ([]*workspace.WorkspaceMember, error) {
+	members := []*workspace.WorkspaceMember{}
+	if err := uow.DB.Where("workspace_id = ?", workspaceID).Order("member_id").Find(&members).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load workspace members")
+	}
+	if err := applyUsers(uow, members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// applyUsers fills in the name and email of each member
+func applyUsers(uow *repository.UnitOfWork, members []*workspace.WorkspaceMember) error {
+	ids := make([]uint, len(members))
+	for i, m := range members {
+		ids[i] = m.UserID
+	}
+	var users []*user.User
+	if len(ids) > 0 {
+		if err := uow.DB.Select("user_id", "f_name", "l_name", "email").
+			Where("user_id IN ?", ids).
+			Find(&users).Error; err != nil {
+			return apperror.NewInternalError("failed to load users")
+		}
+	}
+	byID := make(map[uint]*user.User, len(users))
+	for _, u := range users {
+		byID[u.UserID] = u
+	}
+	for _, m := range members {
+		if u := byID[m.UserID]; u != nil {
+			m.Name, m.Email = strings.TrimSpace(u.FName+" "+u.LName), u.Email
+		}
+	}
+	return nil
+}
+
+func findMember(uow *repository.UnitOfWork, workspaceID, memberUserID uint) (*workspace.WorkspaceMember, error) {
+	var member workspace.WorkspaceMember
+	if err := uow.DB.Where("workspace_id = ? AND user_id = ?", workspaceID, memberUserID).First(&member).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewNotFoundError("workspace member", int(memberUserID))
+		}
+		return nil, apperror.NewInternalError("failed to load workspace member")
+	}
+	return &member, nil
+}
+
+func ensureAnotherAdmin(uow *repository.UnitOfWork, workspaceID uint) error {
+	var admins int64
+	if err := uow.DB.Model(&workspace.WorkspaceMember{}).
+		Where("workspace_id = ? AND role = ?", workspaceID, workspace.RoleAdmin).
+		Count(&admins).Error; err != nil {
+		return apperror.NewInternalError("failed to count workspace admins")
+	}
+	if admins <= 1 {
+		return apperror.NewConflictError("workspace member", "a workspace needs at least one admin")
+	}
+	return nil
+}
+
+func parseRole(value string) (string, error) {
+	switch role := strings.ToLower(strings.TrimSpace(value)); role {
+	case "":
+		return workspace.RoleEditor, nil
+	case workspace.RoleAdmin, workspace.RoleEditor, workspace.RoleViewer:
+		return role, nil
+	}
+	return "", apperror.NewValidationError("role", "must be admin, editor or viewer")
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/db"
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_change"
+	"Contact_App/models/workspace"
+	"Contact_App/repository"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// WorkspaceInput is the body accepted when creating or renaming a workspace
+type WorkspaceInput struct {
+	Name string `json:"name"`
+}
+
+type WorkspaceService struct {
+	repo repository.Repository
+}
+
+func NewWorkspaceService() *WorkspaceService {
+	return &WorkspaceService{repo: repository.NewGormRepository()}
+}
+
+// ListWorkspaces returns the workspaces the user belongs to, with their role in each
+func (s *WorkspaceService) ListWorkspaces(userID uint) ([]*workspace.Workspace, error) {
+	uow, err := ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var memberships []*workspace.WorkspaceMember
+	if err := uow.DB.Where("user_id = ?", userID).Find(&memberships).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load workspaces")
+	}
+	roles := make(map[uint]string, len(memberships))
+	ids := make([]uint, 0, len(memberships))
+	for _, m := range memberships {
+		roles[m.WorkspaceID] = m.Role
+		ids = append(ids, m.WorkspaceID)
+	}
+
+	workspaces := []*workspace.Workspace{}
+	if len(ids) == 0 {
+		return workspaces, nil
+	}
+	if err := uow.DB.Where("workspace_id IN ?", ids).Order("name, workspace_id").Find(&workspaces).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load workspaces")
+	}
+	for _, w := range workspaces {
+		w.Role = roles[w.WorkspaceID]
+	}
+	return workspaces, nil
+}
+
+// GetWorkspace returns a workspace the user belongs to together with its members
+func (s *WorkspaceService) GetWorkspace(userID, workspaceID uint) (*workspace.Workspace, error) {
+	uow, err := ScopedUnitOfWork(userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := requireRole(uow, userID, workspaceID, false)
+	if err != nil {
+		return nil, err
+	}
+	w, err := findWorkspace(uow, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if w.Members, err = loadMembers(uow, workspaceID); err != nil {
+		return nil, err
+	}
+	w.Role = role
+	return w, nil
+}
+
+// CreateWorkspace creates a workspace with the user as its first admin
+func (s *WorkspaceService) CreateWorkspace(userID uint, input WorkspaceInput) (*workspace.Workspace, error) {
+	name, err := workspaceName(input.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// the new workspace is not part of the user's tenant yet, so this unit
+	// of work is left unscoped
+	uow := repository.NewUnitOfWork(db.GetDB(), false)
+	defer uow.Rollback()
+
+	w := &workspace.Workspace{Name: name, CreatedBy: userID}
+	if err := s.repo.Add(uow, w); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Add(uow, &workspace.WorkspaceMember{WorkspaceID: w.WorkspaceID, UserID: userID, Role: workspace.RoleAdmin}); err != nil {
+		return nil, err
+	}
+
+	uow.Commit()
+	w.Role = workspace.RoleAdmin
+	return w, nil
+}
+
+// UpdateWorkspace renames a workspace; only admins may
+func (s *WorkspaceService) UpdateWorkspace(userID, workspaceID uint, input WorkspaceInput) (*workspace.Workspace, error) {
+	name, err := workspaceName(input.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	uow, err := ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer uow.Rollback()
+
+	if _, err := requireRole(uow, userID, workspaceID, true); err != nil {
+		return nil, err
+	}
+	w, err := findWorkspace(uow, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	w.Name = name
+	if err := uow.DB.Select("name").Save(w).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to update workspace")
+	}
+
+	uow.Commit()
+	w.Role = workspace.RoleAdmin
+	return w, nil
+}
+
+// DeleteWorkspace removes an empty workspace and its memberships. Workspaces
+// still owning contacts, including trashed ones, are kept.
+func (s *WorkspaceService) DeleteWorkspace(userID, workspaceID uint) error {
+	uow, err := ScopedUnitOfWork(userID, false)
+	if err != nil {
+		return err
+	}
+	defer uow.Rollback()
+
+	if _, err := requireRole(uow, userID, workspaceID, true); err != nil {
+		return err
+	}
+
+	var count int64
+	if err := uow.DB.Unscoped().Model(&contact.Contact{}).
+		Where("workspace_id = ?", workspaceID).
+		Count(&count).Error; err != nil {
+		return apperror.NewInternalError("failed to count workspace contacts")
+	}
+	if count > 0 {
+		return apperror.NewConflictError("workspace", fmt.Sprintf("the workspace still owns %d contact(s)", count))
+	}
+
+	if err := uow.DB.Where("workspace_id = ?", workspaceID).Delete(&workspace.WorkspaceMember{}).Error; err != nil {
+		return apperror.NewInternalError("failed to delete workspace members")
+	}
+	if err := uow.DB.Where("workspace_id = ?", workspaceID).Delete(&workspace.Workspace{}).Error; err != nil {
+		return apperror.NewInternalError("failed to delete workspace")
+	}
+
+	uow.Commit()
+	return nil
+}
+
+// TenantFor returns the tenant of a user: the workspaces they belong to
+func TenantFor(conn *gorm.DB, userID uint) (*repository.Tenant, error) {
+	tenant := &repository.Tenant{UserID: userID}
+	if err := conn.Model(&workspace.WorkspaceMember{}).
+		Where("user_id = ?", userID).
+		Pluck("workspace_id", &tenant.WorkspaceIDs).Error; err != nil {
+		return nil, apperror.NewInternalError("failed to load workspaces")
+	}
+	return tenant, nil
+}
+
+// ScopedUnitOfWork opens a unit of work limited to the user's tenant
+func ScopedUnitOfWork(userID uint, readonly bool) (*repository.UnitOfWork, error) {
+	tenant, err := TenantFor(db.GetDB(), userID)
+	if err != nil {
+		return nil, err
+	}
+	return repository.NewUnitOfWork(db.GetDB(), readonly).ScopeTo(tenant), nil
+}
+
+// Role returns the user's role in a workspace, or "" when they are not a member
+func Role(conn *gorm.DB, userID, workspaceID uint) (string, error) {
+	var roles []string
+	if err := conn.Model(&workspace.WorkspaceMember{}).
+		Where("workspace_id = ? AND user_id = ?", workspaceID, userID).
+		Pluck("role", &roles).Error; err != nil {
+		return "", apperror.NewInternalError("failed to load workspace membership")
+	}
+	if len(roles) == 0 {
+		return "", nil
+	}
+	return roles[0], nil
+}
+
+// CanWrite reports whether a role may change the workspace's contacts
+func CanWrite(role string) bool {
+	return role == workspace.RoleAdmin || role == workspace.RoleEditor
+}
+
+// WorkspaceIDs selects the ids of the user's workspaces, for use as a subquery
+func WorkspaceIDs(conn *gorm.DB, userID uint) *gorm.DB {
+	return conn.Session(&gorm.Session{NewDB: true}).Model(&workspace.WorkspaceMember{}).
+		Select("workspace_id").
+		Where("user_id = ?", userID)
+}
+
+// RecordRevokesWithUOW adds a revoke to the change sequence for each contact
+// viewerID can no longer see, so their next delta sync drops it. A contact
+// they still reach another way is synced as updated instead.
+func RecordRevokesWithUOW(uow *repository.UnitOfWork, viewerID uint, contactIDs []uint) error {
+	if len(contactIDs) == 0 {
+		return nil
+	}
+	changes := make([]*contact_change.ContactChange, len(contactIDs))
+	for i, contactID := range contactIDs {
+		changes[i] = &contact_change.ContactChange{UserID: viewerID, ContactID: contactID, Action: contact_change.ActionRevoke}
+	}
+	// revokes belong to no workspace, so they are written outside the actor's scope
+	if err := repository.WithoutTenant(uow.DB).CreateInBatches(&changes, 500).Error; err != nil {
+		return apperror.NewInternalError("failed to record revoked access")
+	}
+	return nil
+}
+
+// requireRole checks that the user belongs to the workspace and, when admin
+// is set, that they administer it. Non-members get not found.
+func requireRole(uow *repository.UnitOfWork, userID, workspaceID uint, admin bool) (string, error) {
+	role, err := Role(uow.DB, userID, workspaceID)
+	if err != nil {
+		return "", err
+	}
+	if role == "" {
+		return "", apperror.NewNotFoundError("workspace", int(workspaceID))
+	}
+	if admin && role != workspace.RoleAdmin {
+		return "", apperror.NewForbiddenError("workspace", "only workspace admins can do this")
+	}
+	return role, nil
+}
+
+func findWorkspace(uow *repository.UnitOfWork, workspaceID uint) (*workspace.Workspace, error) {
+	var w workspace.Workspace
+	if err := uow.DB.Where("workspace_id = ?", workspaceID).First(&w).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NewNotFoundError("workspace", int(workspaceID))
+		}
+		return nil, apperror.NewInternalError("failed to load workspace")
+	}
+	return &w, nil
+}
+
+func workspaceName(value string) (string, error) {
+	name := strings.TrimSpace(value)
+	if name == "" {
+		return "", apperror.NewValidationError("name", "cannot be empty")
+	}
+	if len([]rune(name)) > 255 {
+		return "", apperror.NewValidationError("name", "must be at most 255 characters")
+	}
+	return name, nil
+}
@@ -5,9 +5,28 @@ import (
 	"log"
 	"os"
 
+	"Contact_App/models/calendar_feed"
+	"Contact_App/models/card_link"
+	"Contact_App/models/carddav"
 	"Contact_App/models/contact"
+	"Contact_App/models/contact_change"
+	"Contact_App/models/contact_date"
 	"Contact_App/models/contact_detail"
+	"Contact_App/models/contact_favorite"
+	"Contact_App/models/contact_merge"
+	"Contact_App/models/contact_photo"
+	"Contact_App/models/contact_relationship"
+	"Contact_App/models/contact_share"
+	"Contact_App/models/contact_version"
+	"Contact_App/models/contact_view"
+	"Contact_App/models/custom_field"
+	"Contact_App/models/group"
+	"Contact_App/models/interaction"
+	"Contact_App/models/organization"
+	"Contact_App/models/task"
 	"Contact_App/models/user"
+	"Contact_App/models/workspace"
+	"Contact_App/repository"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/mysql"
@@ -19,22 +38,58 @@ var DB *gorm.DB
 func InitDB() {
 	dsn := getDSN()
 
-	var err error
-	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	conn, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
+	if err := Setup(conn); err != nil {
+		log.Fatalf("Failed to set up database: %v", err)
+	}
+
+	log.Println("Database connected and models migrated successfully.")
+}
 
-	err = DB.AutoMigrate(
+// Setup enforces tenant scopes on conn, migrates every model and makes conn
+// the database GetDB returns. Tests call it with a database of their own.
+func Setup(conn *gorm.DB) error {
+	if err := repository.RegisterTenantScope(conn, &contact.Contact{}, &contact_change.ContactChange{}); err != nil {
+		return fmt.Errorf("register tenant scope: %w", err)
+	}
+
+	err := repository.WithoutTenant(conn).AutoMigrate(
 		&user.User{},
+		&organization.Organization{},
 		&contact.Contact{},
 		&contact_detail.ContactDetail{},
+		&group.Group{},
+		&group.GroupContact{},
+		&contact_merge.ContactMerge{},
+		&contact_version.ContactVersion{},
+		&contact_photo.ContactPhoto{},
+		&contact_date.ContactDate{},
+		&calendar_feed.CalendarFeed{},
+		&interaction.Interaction{},
+		&contact_relationship.ContactRelationship{},
+		&custom_field.CustomFieldDefinition{},
+		&custom_field.CustomFieldValue{},
+		&contact_share.ContactShare{},
+		&workspace.Workspace{},
+		&workspace.WorkspaceMember{},
+		&contact_favorite.ContactFavorite{},
+		&contact_view.ContactView{},
+		&contact_view.RecentSettings{},
+		&carddav.AppPassword{},
+		&carddav.CardResource{},
+		&contact_change.ContactChange{},
+		&card_link.CardLink{},
+		&task.Task{},
 	)
 	if err != nil {
-		log.Fatalf("AutoMigrate failed: %v", err)
+		return fmt.Errorf("migrate: %w", err)
 	}
 
-	log.Println("Database connected and models migrated successfully.")
+	DB = conn
+	return nil
 }
 
 func GetDB() *gorm.DB {
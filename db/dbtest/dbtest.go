@@ -0,0 +1,139 @@
+// Package dbtest sets up a throwaway SQLite database for service tests and
+// seeds the rows most tests need.
+package dbtest
+
+import (
+	"Contact_App/db"
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_detail"
+	"Contact_App/models/contact_share"
+	"Contact_App/models/group"
+	"Contact_App/models/user"
+	"Contact_App/models/workspace"
+	"Contact_App/repository"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+)
+
+// Open migrates a fresh database in the test's temp dir and makes it the one
+// db.GetDB returns until the test ends.
+func Open(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_journal_mode=WAL&_busy_timeout=5000&_foreign_keys=off"
+	conn, err := gorm.Open(dialector{&sqlite.Dialector{DSN: dsn}}, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	previous := db.DB
+	if err := db.Setup(conn); err != nil {
+		t.Fatalf("set up test database: %v", err)
+	}
+	t.Cleanup(func() {
+		db.DB = previous
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return conn
+}
+
+// dialector is SQLite with every auto-increment primary key made a rowid
+// alias: SQLite only assigns ids to INTEGER PRIMARY KEY columns, and the
+// models declare theirs as BIGINT UNSIGNED for MySQL.
+type dialector struct{ *sqlite.Dialector }
+
+func (d dialector) DataTypeOf(field *schema.Field) string {
+	if field.PrimaryKey && field.AutoIncrement {
+		return "integer PRIMARY KEY AUTOINCREMENT"
+	}
+	return d.Dialector.DataTypeOf(field)
+}
+
+func (d dialector) Migrator(conn *gorm.DB) gorm.Migrator {
+	return sqlite.Migrator{Migrator: migrator.Migrator{Config: migrator.Config{
+		DB:                          conn,
+		Dialector:                   d,
+		CreateIndexAfterCreateTable: true,
+	}}}
+}
+
+// User creates an active user named after email.
+func User(t *testing.T, conn *gorm.DB, email string) *user.User {
+	t.Helper()
+	u := &user.User{FName: email, Email: email, Password: "x", IsActive: true}
+	mustCreate(t, conn, u)
+	return u
+}
+
+// Contact creates an active contact of owner, in workspaceID when not nil.
+func Contact(t *testing.T, conn *gorm.DB, owner *user.User, workspaceID *uint, fName, lName string) *contact.Contact {
+	t.Helper()
+	c := &contact.Contact{UserID: owner.UserID, WorkspaceID: workspaceID, FName: fName, LName: lName, IsActive: true}
+	mustCreate(t, conn, c)
+	return c
+}
+
+// Detail adds an active detail to a contact.
+func Detail(t *testing.T, conn *gorm.DB, c *contact.Contact, detailType, value string) *contact_detail.ContactDetail {
+	t.Helper()
+	d := &contact_detail.ContactDetail{UserID: c.UserID, ContactID: c.ContactID, Type: detailType, Value: value, IsActive: true}
+	mustCreate(t, conn, d)
+	return d
+}
+
+// Workspace creates a workspace and gives every member the role it maps to.
+func Workspace(t *testing.T, conn *gorm.DB, name string, members map[*user.User]string) *workspace.Workspace {
+	t.Helper()
+	w := &workspace.Workspace{Name: name}
+	for member, role := range members {
+		if role == workspace.RoleAdmin {
+			w.CreatedBy = member.UserID
+		}
+	}
+	mustCreate(t, conn, w)
+	for member, role := range members {
+		mustCreate(t, conn, &workspace.WorkspaceMember{WorkspaceID: w.WorkspaceID, UserID: member.UserID, Role: role})
+	}
+	return w
+}
+
+// ShareContact shares a contact of owner with recipient.
+func ShareContact(t *testing.T, conn *gorm.DB, owner, recipient *user.User, contactID uint, permission string) *contact_share.ContactShare {
+	t.Helper()
+	share := &contact_share.ContactShare{OwnerID: owner.UserID, RecipientID: recipient.UserID, ContactID: &contactID, Permission: permission}
+	mustCreate(t, conn, share)
+	return share
+}
+
+// ShareGroup shares a group of owner with recipient.
+func ShareGroup(t *testing.T, conn *gorm.DB, owner, recipient *user.User, groupID uint, permission string) *contact_share.ContactShare {
+	t.Helper()
+	share := &contact_share.ContactShare{OwnerID: owner.UserID, RecipientID: recipient.UserID, GroupID: &groupID, Permission: permission}
+	mustCreate(t, conn, share)
+	return share
+}
+
+// Group creates an active group of owner holding contacts.
+func Group(t *testing.T, conn *gorm.DB, owner *user.User, name string, contacts ...*contact.Contact) *group.Group {
+	t.Helper()
+	g := &group.Group{UserID: owner.UserID, Name: name, IsActive: true}
+	mustCreate(t, conn, g)
+	for _, c := range contacts {
+		mustCreate(t, conn, &group.GroupContact{GroupID: g.GroupID, ContactID: c.ContactID, UserID: owner.UserID})
+	}
+	return g
+}
+
+func mustCreate(t *testing.T, conn *gorm.DB, value interface{}) {
+	t.Helper()
+	if err := repository.WithoutTenant(conn).Create(value).Error; err != nil {
+		t.Fatalf("create %T: %v", value, err)
+	}
+}
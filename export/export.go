@@ -0,0 +1,317 @@
+package export
+
+import (
+	"Contact_App/models/contact"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatVCard = "vcard"
+)
+
+// ContentType returns the MIME type used when serving the given export format.
+func ContentType(format string) string {
+	switch format {
+	case FormatVCard:
+		return "text/vcard; charset=utf-8"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+// FileExtension returns the file extension used for the given export format.
+func FileExtension(format string) string {
+	if format == FormatVCard {
+		return "vcf"
+	}
+	return "csv"
+}
+
+// IsSupportedFormat reports whether format can be passed to Write.
+func IsSupportedFormat(format string) bool {
+	return format == FormatCSV || format == FormatVCard
+}
+
+// Photo is an image embedded in a vCard PHOTO property.
+type Photo struct {
+	ContentType string
+	Data        []byte
+}
+
+// Photos maps contact IDs to the photo exported with that contact.
+type Photos map[uint]*Photo
+
+// Write serializes contacts in the requested format. Photos are only used by
+// the vCard format and may be nil.
+func Write(w io.Writer, format string, contacts []*contact.Contact, photos Photos) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, contacts)
+	case FormatVCard:
+		return WriteVCard(w, contacts, photos)
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// Respond writes contacts as a downloadable attachment named after filename.
+func Respond(w http.ResponseWriter, format, filename string, contacts []*contact.Contact, photos Photos) error {
+	w.Header().Set("Content-Type", ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+"."+FileExtension(format)))
+	w.WriteHeader(http.StatusOK)
+	return Write(w, format, contacts, photos)
+}
+
+// WriteCSV writes one row per contact. Every detail type present in the set
+// becomes its own column; multiple values of the same type are joined by "; ".
+// Custom fields follow as cf.<key> columns.
+func WriteCSV(w io.Writer, contacts []*contact.Contact) error {
+	types := detailTypes(contacts)
+	keys := customFieldKeys(contacts)
+
+	cw := csv.NewWriter(w)
+	header := append([]string{"contact_id", "first_name", "last_name"}, types...)
+	for _, key := range keys {
+		header = append(header, "cf."+key)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, c := range contacts {
+		values := make(map[string][]string)
+		for _, d := range c.Details {
+			if d == nil || !d.IsActive {
+				continue
+			}
+			values[d.Type] = append(values[d.Type], d.Value)
+		}
+
+		row := []string{strconv.FormatUint(uint64(c.ContactID), 10), c.FName, c.LName}
+		for _, t := range types {
+			row = append(row, strings.Join(values[t], "; "))
+		}
+		for _, key := range keys {
+			row = append(row, customFieldText(c.CustomFields[key]))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteVCard writes each contact as a vCard 3.0 entry, embedding its photo when photos has one.
+func WriteVCard(w io.Writer, contacts []*contact.Contact, photos Photos) error {
+	for _, c := range contacts {
+		if _, err := io.WriteString(w, VCardWithPhoto(c, photos[c.ContactID])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VCard renders a single contact as a vCard 3.0 entry.
+func VCard(c *contact.Contact) string {
+	return VCardWithPhoto(c, nil)
+}
+
+// VCardWithPhoto renders a contact as a vCard 3.0 entry with an inline base64 PHOTO.
+func VCardWithPhoto(c *contact.Contact, photo *Photo) string {
+	return VCardWithUID(c, photo, "")
+}
+
+// VCardWithUID renders a contact like VCardWithPhoto but under the given UID,
+// so a card stored by a sync client keeps the UID the client chose. An empty
+// uid falls back to contact-<id>.
+func VCardWithUID(c *contact.Contact, photo *Photo, uid string) string {
+	if uid == "" {
+		uid = DefaultUID(c.ContactID)
+	}
+
+	var b strings.Builder
+	writeLine(&b, "BEGIN:VCARD")
+	writeLine(&b, "VERSION:3.0")
+	writeLine(&b, "N:"+escape(c.LName)+";"+escape(c.FName)+";;;")
+	writeLine(&b, "FN:"+escape(strings.TrimSpace(c.FName+" "+c.LName)))
+
+	for _, d := range c.Details {
+		if d == nil || !d.IsActive {
+			continue
+		}
+		writeLine(&b, detailProperty(d.Type)+":"+escape(d.Value))
+	}
+
+	for _, key := range sortedKeys(c.CustomFields) {
+		writeLine(&b, customFieldProperty(key)+":"+escape(customFieldText(c.CustomFields[key])))
+	}
+
+	if photo != nil && len(photo.Data) > 0 {
+		writeLine(&b, "PHOTO;ENCODING=b;TYPE="+photoType(photo.ContentType)+":"+base64.StdEncoding.EncodeToString(photo.Data))
+	}
+
+	writeLine(&b, "UID:"+escape(uid))
+	writeLine(&b, "END:VCARD")
+	return b.String()
+}
+
+// DefaultUID is the UID a contact is exported under unless a client chose another.
+func DefaultUID(contactID uint) string {
+	return "contact-" + strconv.FormatUint(uint64(contactID), 10)
+}
+
+// Properties lists the unescaped values of the vCard properties VCardWithUID
+// writes for c, keyed by property name, for matching cards against filters
+// without rendering and parsing them again.
+func Properties(c *contact.Contact, uid string) map[string][]string {
+	if uid == "" {
+		uid = DefaultUID(c.ContactID)
+	}
+
+	props := map[string][]string{
+		"N":   {c.LName + ";" + c.FName + ";;;"},
+		"FN":  {strings.TrimSpace(c.FName + " " + c.LName)},
+		"UID": {uid},
+	}
+	for _, d := range c.Details {
+		if d == nil || !d.IsActive {
+			continue
+		}
+		name := detailProperty(d.Type)
+		props[name] = append(props[name], d.Value)
+	}
+	for key, value := range c.CustomFields {
+		props[customFieldProperty(key)] = []string{customFieldText(value)}
+	}
+	return props
+}
+
+func detailProperty(detailType string) string {
+	switch strings.ToLower(detailType) {
+	case "email":
+		return "EMAIL"
+	case "phone", "mobile", "tel":
+		return "TEL"
+	case "address", "adr":
+		return "LABEL"
+	case "url", "website":
+		return "URL"
+	default:
+		return "X-" + strings.ToUpper(sanitizeName(detailType))
+	}
+}
+
+// customFieldProperty names the vCard property of a custom field, e.g.
+// account_tier -> X-CUSTOM-ACCOUNT-TIER. ParseVCards reverses it.
+func customFieldProperty(key string) string {
+	return customFieldPrefix + strings.ToUpper(strings.ReplaceAll(key, "_", "-"))
+}
+
+func customFieldText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func customFieldKeys(contacts []*contact.Contact) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, c := range contacts {
+		for key := range c.CustomFields {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// photoType maps a MIME type to the vCard 3.0 TYPE parameter, e.g. image/jpeg -> JPEG.
+func photoType(contentType string) string {
+	sub := strings.TrimPrefix(strings.ToLower(contentType), "image/")
+	if sub == "jpg" {
+		sub = "jpeg"
+	}
+	return strings.ToUpper(sanitizeName(sub))
+}
+
+func detailTypes(contacts []*contact.Contact) []string {
+	seen := make(map[string]bool)
+	var types []string
+	for _, c := range contacts {
+		for _, d := range c.Details {
+			if d == nil || !d.IsActive || seen[d.Type] {
+				continue
+			}
+			seen[d.Type] = true
+			types = append(types, d.Type)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+func sanitizeName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
+
+func escape(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(value)
+}
+
+// writeLine folds content lines longer than 75 octets as required by RFC 6350.
+// Continuation lines start with a space, so they carry one octet less.
+func writeLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func isRuneStart(c byte) bool {
+	return c&0xC0 != 0x80
+}
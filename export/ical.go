@@ -0,0 +1,56 @@
+package export
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+const ICalendarContentType = "text/calendar; charset=utf-8"
+
+// CalendarEvent is an all-day VEVENT. RRule is written verbatim when set,
+// e.g. "FREQ=YEARLY".
+type CalendarEvent struct {
+	UID         string
+	Summary     string
+	Description string
+	Date        time.Time
+	RRule       string
+}
+
+// WriteICalendar renders events as an RFC 5545 calendar. Content lines use
+// the same escaping and folding rules as vCard.
+func WriteICalendar(w io.Writer, name string, events []CalendarEvent) error {
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+
+	var b strings.Builder
+	writeLine(&b, "BEGIN:VCALENDAR")
+	writeLine(&b, "VERSION:2.0")
+	writeLine(&b, "PRODID:-//Contact_App//Important Dates//EN")
+	writeLine(&b, "CALSCALE:GREGORIAN")
+	writeLine(&b, "METHOD:PUBLISH")
+	if name != "" {
+		writeLine(&b, "X-WR-CALNAME:"+escape(name))
+	}
+
+	for _, e := range events {
+		writeLine(&b, "BEGIN:VEVENT")
+		writeLine(&b, "UID:"+escape(e.UID))
+		writeLine(&b, "DTSTAMP:"+stamp)
+		writeLine(&b, "DTSTART;VALUE=DATE:"+e.Date.Format("20060102"))
+		writeLine(&b, "DTEND;VALUE=DATE:"+e.Date.AddDate(0, 0, 1).Format("20060102"))
+		if e.RRule != "" {
+			writeLine(&b, "RRULE:"+e.RRule)
+		}
+		writeLine(&b, "SUMMARY:"+escape(e.Summary))
+		if e.Description != "" {
+			writeLine(&b, "DESCRIPTION:"+escape(e.Description))
+		}
+		writeLine(&b, "TRANSP:TRANSPARENT")
+		writeLine(&b, "END:VEVENT")
+	}
+
+	writeLine(&b, "END:VCALENDAR")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,105 @@
+package export
+
+import (
+	"Contact_App/models/contact"
+	"fmt"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// QR code image formats
+const (
+	QRFormatPNG = "png"
+	QRFormatSVG = "svg"
+
+	PNGContentType = "image/png"
+	SVGContentType = "image/svg+xml"
+)
+
+// IsSupportedQRFormat reports whether format is a QR code image format
+func IsSupportedQRFormat(format string) bool {
+	return format == QRFormatPNG || format == QRFormatSVG
+}
+
+// QRContentType is the content type of a QR code image format
+func QRContentType(format string) string {
+	if format == QRFormatSVG {
+		return SVGContentType
+	}
+	return PNGContentType
+}
+
+// QRCode encodes text as a QR code image about size pixels wide, with the
+// quiet zone scanners need around it. Text longer than a QR code holds is
+// an error.
+func QRCode(text, format string, size int) ([]byte, error) {
+	code, err := qrcode.New(text, qrcode.Medium)
+	if err != nil {
+		return nil, err
+	}
+	if format == QRFormatSVG {
+		return qrSVG(code.Bitmap(), size), nil
+	}
+	return code.PNG(size)
+}
+
+// qrSVG draws the dark modules as one path, a run of neighbours per segment,
+// on a white background scaled to size
+func qrSVG(bitmap [][]bool, size int) []byte {
+	n := len(bitmap)
+	var path strings.Builder
+	for y, row := range bitmap {
+		for x := 0; x < len(row); {
+			if !row[x] {
+				x++
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&path, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, n, n)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/>`, n, n)
+	b.WriteString(`<path fill="#000" d="` + path.String() + `"/>`)
+	b.WriteString("</svg>\n")
+	return []byte(b.String())
+}
+
+// MeCard renders a contact in the MECARD format, which holds less than a
+// vCard but makes a smaller QR code that phone cameras still read
+func MeCard(c *contact.Contact) string {
+	var b strings.Builder
+	b.WriteString("MECARD:N:" + meCardEscape(c.LName) + "," + meCardEscape(c.FName) + ";")
+	if c.Organization != nil && c.Organization.Name != "" {
+		b.WriteString("ORG:" + meCardEscape(c.Organization.Name) + ";")
+	}
+	for _, d := range c.Details {
+		if d == nil || !d.IsActive {
+			continue
+		}
+		switch detailProperty(d.Type) {
+		case "TEL":
+			b.WriteString("TEL:" + meCardEscape(d.Value) + ";")
+		case "EMAIL":
+			b.WriteString("EMAIL:" + meCardEscape(d.Value) + ";")
+		case "URL":
+			b.WriteString("URL:" + meCardEscape(d.Value) + ";")
+		case "LABEL":
+			b.WriteString("ADR:" + meCardEscape(d.Value) + ";")
+		}
+	}
+	b.WriteString(";")
+	return b.String()
+}
+
+func meCardEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, `:`, `\:`, "\"", "\\\"", "\r", "", "\n", " ")
+	return r.Replace(strings.TrimSpace(s))
+}
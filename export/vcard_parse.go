@@ -0,0 +1,331 @@
+package export
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// customFieldPrefix marks the properties that carry custom field values
+const customFieldPrefix = "X-CUSTOM-"
+
+// Card is a contact read back from a vCard. Details use the same type names
+// the exporter writes, so an export followed by an import round-trips.
+// CustomFields holds the X-CUSTOM- properties keyed by custom field key.
+type Card struct {
+	UID          string
+	FName        string
+	LName        string
+	Details      []CardDetail
+	CustomFields map[string]string
+	Photo        *Photo
+}
+
+type CardDetail struct {
+	Type  string
+	Value string
+}
+
+// property is one unfolded content line: GROUP.NAME;PARAM=a,b:value
+type property struct {
+	name   string
+	params map[string][]string
+	value  string
+}
+
+// ParseVCards reads every vCard (versions 2.1, 3.0 and 4.0) from r.
+// Properties the contact model has no place for are skipped.
+func ParseVCards(r io.Reader) ([]*Card, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var cards []*Card
+	var current *Card
+	var fullName string
+	for n, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		prop, ok := parseProperty(line)
+		if !ok {
+			return nil, fmt.Errorf("line %d: malformed vCard property", n+1)
+		}
+
+		switch prop.name {
+		case "BEGIN":
+			if strings.EqualFold(prop.value, "VCARD") {
+				if current != nil {
+					return nil, fmt.Errorf("line %d: nested BEGIN:VCARD", n+1)
+				}
+				current = &Card{}
+				fullName = ""
+			}
+			continue
+		case "END":
+			if strings.EqualFold(prop.value, "VCARD") {
+				if current == nil {
+					return nil, fmt.Errorf("line %d: END:VCARD without BEGIN", n+1)
+				}
+				if current.FName == "" && current.LName == "" {
+					current.FName, current.LName = splitFullName(fullName)
+				}
+				cards = append(cards, current)
+				current = nil
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		switch prop.name {
+		case "N":
+			parts := splitComponents(prop.value, ';')
+			if len(parts) > 0 {
+				current.LName = unescape(parts[0])
+			}
+			if len(parts) > 1 {
+				current.FName = unescape(parts[1])
+			}
+		case "FN":
+			fullName = unescape(prop.value)
+		case "UID":
+			current.UID = unescape(prop.value)
+		case "EMAIL":
+			current.addDetail("email", unescape(prop.value))
+		case "TEL":
+			current.addDetail("phone", strings.TrimPrefix(unescape(prop.value), "tel:"))
+		case "URL":
+			current.addDetail("url", unescape(prop.value))
+		case "LABEL":
+			current.addDetail("address", unescape(prop.value))
+		case "ADR":
+			var parts []string
+			for _, part := range splitComponents(prop.value, ';') {
+				if part = strings.TrimSpace(unescape(part)); part != "" {
+					parts = append(parts, part)
+				}
+			}
+			current.addDetail("address", strings.Join(parts, ", "))
+		case "PHOTO":
+			photo, err := parsePhoto(prop)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n+1, err)
+			}
+			if photo != nil {
+				current.Photo = photo
+			}
+		default:
+			if strings.HasPrefix(prop.name, customFieldPrefix) && len(prop.name) > len(customFieldPrefix) {
+				key := strings.ToLower(strings.ReplaceAll(prop.name[len(customFieldPrefix):], "-", "_"))
+				if current.CustomFields == nil {
+					current.CustomFields = make(map[string]string)
+				}
+				current.CustomFields[key] = strings.TrimSpace(unescape(prop.value))
+			} else if strings.HasPrefix(prop.name, "X-") && len(prop.name) > 2 {
+				current.addDetail(strings.ToLower(prop.name[2:]), unescape(prop.value))
+			}
+		}
+	}
+	if current != nil {
+		return nil, fmt.Errorf("unterminated vCard")
+	}
+	return cards, nil
+}
+
+func (c *Card) addDetail(detailType, value string) {
+	if value = strings.TrimSpace(value); value != "" {
+		c.Details = append(c.Details, CardDetail{Type: detailType, Value: value})
+	}
+}
+
+// unfold joins continuation lines (those starting with a space or tab) onto
+// the previous line and normalises line endings.
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func parseProperty(line string) (property, bool) {
+	colon := indexUnquoted(line, ':')
+	if colon < 0 {
+		return property{}, false
+	}
+	head, value := line[:colon], line[colon+1:]
+
+	segments := splitUnquoted(head, ';')
+	name := strings.ToUpper(strings.TrimSpace(segments[0]))
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		name = name[dot+1:]
+	}
+	if name == "" {
+		return property{}, false
+	}
+
+	params := make(map[string][]string)
+	for _, seg := range segments[1:] {
+		key, val, found := strings.Cut(seg, "=")
+		key = strings.ToUpper(strings.TrimSpace(key))
+		if !found {
+			// vCard 2.1 allows bare parameter values such as ;JPEG or ;BASE64
+			val, key = key, ""
+		}
+		for _, v := range strings.Split(val, ",") {
+			params[key] = append(params[key], strings.Trim(strings.TrimSpace(v), `"`))
+		}
+	}
+	return property{name: name, params: params, value: value}, true
+}
+
+// parsePhoto decodes inline PHOTO values: vCard 3.0 ENCODING=b, vCard 2.1
+// BASE64 and vCard 4.0 data: URIs. Remote URLs are ignored.
+func parsePhoto(prop property) (*Photo, error) {
+	value := strings.TrimSpace(prop.value)
+
+	if strings.HasPrefix(strings.ToLower(value), "data:") {
+		meta, payload, found := strings.Cut(value[5:], ",")
+		if !found {
+			return nil, fmt.Errorf("malformed PHOTO data URI")
+		}
+		contentType := strings.Split(meta, ";")[0]
+		var data []byte
+		var err error
+		if strings.HasSuffix(strings.ToLower(meta), ";base64") {
+			data, err = decodeBase64(payload)
+		} else {
+			var unescaped string
+			unescaped, err = url.PathUnescape(payload)
+			data = []byte(unescaped)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid PHOTO data: %v", err)
+		}
+		return &Photo{ContentType: contentType, Data: data}, nil
+	}
+
+	if !hasParam(prop, "ENCODING", "B", "BASE64") && !hasParam(prop, "", "BASE64") {
+		return nil, nil
+	}
+	data, err := decodeBase64(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PHOTO data: %v", err)
+	}
+
+	contentType := ""
+	for _, key := range []string{"TYPE", ""} {
+		for _, v := range prop.params[key] {
+			switch strings.ToUpper(v) {
+			case "JPEG", "JPG":
+				contentType = "image/jpeg"
+			case "PNG":
+				contentType = "image/png"
+			case "GIF":
+				contentType = "image/gif"
+			}
+		}
+	}
+	return &Photo{ContentType: contentType, Data: data}, nil
+}
+
+func hasParam(prop property, key string, values ...string) bool {
+	for _, v := range prop.params[key] {
+		for _, want := range values {
+			if strings.EqualFold(v, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, s)
+	if data, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return data, nil
+	}
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// splitComponents splits a structured value on sep, ignoring escaped separators
+func splitComponents(value string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' {
+			i++
+			continue
+		}
+		if value[i] == sep {
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+func splitUnquoted(s string, sep byte) []string {
+	var parts []string
+	start, quoted := 0, false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func indexUnquoted(s string, c byte) int {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case c:
+			if !quoted {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func unescape(value string) string {
+	r := strings.NewReplacer(`\\`, `\`, `\,`, ",", `\;`, ";", `\n`, "\n", `\N`, "\n")
+	return r.Replace(value)
+}
+
+// splitFullName falls back to FN when a card has no N property
+func splitFullName(full string) (string, string) {
+	full = strings.TrimSpace(full)
+	if i := strings.LastIndex(full, " "); i > 0 {
+		return strings.TrimSpace(full[:i]), strings.TrimSpace(full[i+1:])
+	}
+	return full, ""
+}
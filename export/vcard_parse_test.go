@@ -0,0 +1,196 @@
+package export
+
+import (
+	"Contact_App/models/contact"
+	"Contact_App/models/contact_detail"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseVCards(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []*Card
+	}{
+		{
+			name:  "empty input",
+			input: "",
+			want:  nil,
+		},
+		{
+			name: "vCard 3.0 with details and custom fields",
+			input: "BEGIN:VCARD\r\n" +
+				"VERSION:3.0\r\n" +
+				"UID:abc-123\r\n" +
+				"N:Lovelace;Ada;;;\r\n" +
+				"FN:Ada Lovelace\r\n" +
+				"EMAIL;TYPE=INTERNET:ada@example.com\r\n" +
+				"TEL;TYPE=CELL:+44 20 7946 0000\r\n" +
+				"item1.URL:https://example.com\r\n" +
+				"ADR;TYPE=HOME:;;12 St James\\, Square;London;;SW1Y;UK\r\n" +
+				"X-CUSTOM-FAVOURITE-COLOUR:green\r\n" +
+				"X-TWITTER:@ada\r\n" +
+				"NOTE:skipped\r\n" +
+				"END:VCARD\r\n",
+			want: []*Card{{
+				UID:   "abc-123",
+				FName: "Ada",
+				LName: "Lovelace",
+				Details: []CardDetail{
+					{Type: "email", Value: "ada@example.com"},
+					{Type: "phone", Value: "+44 20 7946 0000"},
+					{Type: "url", Value: "https://example.com"},
+					{Type: "address", Value: "12 St James, Square, London, SW1Y, UK"},
+					{Type: "twitter", Value: "@ada"},
+				},
+				CustomFields: map[string]string{"favourite_colour": "green"},
+			}},
+		},
+		{
+			name: "folded lines and escapes",
+			input: "BEGIN:VCARD\n" +
+				"N:Hopper;Grace\n" +
+				"LABEL:1 Navy Way\\nArlington\n" +
+				"EMAIL:grace@exa\n" +
+				" mple.com\n" +
+				"END:VCARD\n",
+			want: []*Card{{
+				FName: "Grace",
+				LName: "Hopper",
+				Details: []CardDetail{
+					{Type: "address", Value: "1 Navy Way\nArlington"},
+					{Type: "email", Value: "grace@example.com"},
+				},
+			}},
+		},
+		{
+			name: "FN without N and tel URI",
+			input: "BEGIN:VCARD\nVERSION:4.0\nFN:Alan Mathison Turing\nTEL;VALUE=uri:tel:+441234\nEND:VCARD\n" +
+				"BEGIN:VCARD\nFN:Plato\nEMAIL: \nEND:VCARD\n",
+			want: []*Card{
+				{FName: "Alan Mathison", LName: "Turing", Details: []CardDetail{{Type: "phone", Value: "+441234"}}},
+				{FName: "Plato"},
+			},
+		},
+		{
+			name: "photos",
+			input: "BEGIN:VCARD\nN:A;B\nPHOTO;ENCODING=b;TYPE=JPEG:aGVsbG8=\nEND:VCARD\n" +
+				"BEGIN:VCARD\nN:C;D\nPHOTO;PNG;BASE64:aGVs\n bG8=\nEND:VCARD\n" +
+				"BEGIN:VCARD\nN:E;F\nPHOTO:data:image/gif;base64,aGVsbG8=\nEND:VCARD\n" +
+				"BEGIN:VCARD\nN:G;H\nPHOTO;VALUE=uri:https://example.com/me.jpg\nEND:VCARD\n",
+			want: []*Card{
+				{FName: "B", LName: "A", Photo: &Photo{ContentType: "image/jpeg", Data: []byte("hello")}},
+				{FName: "D", LName: "C", Photo: &Photo{ContentType: "image/png", Data: []byte("hello")}},
+				{FName: "F", LName: "E", Photo: &Photo{ContentType: "image/gif", Data: []byte("hello")}},
+				{FName: "H", LName: "G"},
+			},
+		},
+		{
+			name:  "properties outside a card are ignored",
+			input: "EMAIL:stray@example.com\nBEGIN:VCARD\nN:Doe;Jane\nEND:VCARD\n",
+			want:  []*Card{{FName: "Jane", LName: "Doe"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseVCards(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("ParseVCards() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseVCards() = %s, want %s", describeCards(got), describeCards(tt.want))
+			}
+		})
+	}
+}
+
+func TestParseVCardsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"unterminated card", "BEGIN:VCARD\nN:Doe;Jane\n"},
+		{"nested card", "BEGIN:VCARD\nBEGIN:VCARD\nEND:VCARD\nEND:VCARD\n"},
+		{"end without begin", "END:VCARD\n"},
+		{"line without colon", "BEGIN:VCARD\nN\nEND:VCARD\n"},
+		{"bad photo data", "BEGIN:VCARD\nPHOTO;ENCODING=b:***\nEND:VCARD\n"},
+		{"photo data URI without comma", "BEGIN:VCARD\nPHOTO:data:image/png;base64\nEND:VCARD\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if cards, err := ParseVCards(strings.NewReader(tt.input)); err == nil {
+				t.Errorf("ParseVCards() = %s, want an error", describeCards(cards))
+			}
+		})
+	}
+}
+
+// An exported address book must import back into the same contacts.
+func TestVCardRoundTrip(t *testing.T) {
+	contacts := []*contact.Contact{
+		{
+			ContactID: 1,
+			FName:     "Ada",
+			LName:     "Lovelace",
+			Details: []*contact_detail.ContactDetail{
+				{Type: "email", Value: "ada@example.com", IsActive: true},
+				{Type: "phone", Value: "+44 20 7946 0000", IsActive: true},
+				{Type: "address", Value: "12 St James's Square; London, UK", IsActive: true},
+				{Type: "email", Value: "old@example.com", IsActive: false},
+			},
+			CustomFields: map[string]interface{}{"favourite_colour": "green"},
+		},
+		{ContactID: 2, FName: "Grace", LName: "Hopper"},
+	}
+	photos := Photos{2: {ContentType: "image/png", Data: []byte("\x89PNG fake image")}}
+
+	var buf bytes.Buffer
+	if err := WriteVCard(&buf, contacts, photos); err != nil {
+		t.Fatal(err)
+	}
+	cards, err := ParseVCards(&buf)
+	if err != nil {
+		t.Fatalf("ParseVCards() error = %v", err)
+	}
+
+	want := []*Card{
+		{
+			UID:   "contact-1",
+			FName: "Ada",
+			LName: "Lovelace",
+			Details: []CardDetail{
+				{Type: "email", Value: "ada@example.com"},
+				{Type: "phone", Value: "+44 20 7946 0000"},
+				{Type: "address", Value: "12 St James's Square; London, UK"},
+			},
+			CustomFields: map[string]string{"favourite_colour": "green"},
+		},
+		{UID: "contact-2", FName: "Grace", LName: "Hopper", Photo: photos[2]},
+	}
+	if !reflect.DeepEqual(cards, want) {
+		t.Errorf("round trip = %s, want %s", describeCards(cards), describeCards(want))
+	}
+}
+
+func describeCards(cards []*Card) string {
+	parts := make([]string, 0, len(cards))
+	for _, c := range cards {
+		var b strings.Builder
+		b.WriteString("{UID:" + c.UID + " FName:" + c.FName + " LName:" + c.LName)
+		for _, d := range c.Details {
+			b.WriteString(" " + d.Type + "=" + d.Value)
+		}
+		for key, value := range c.CustomFields {
+			b.WriteString(" X-" + key + "=" + value)
+		}
+		if c.Photo != nil {
+			b.WriteString(" Photo:" + c.Photo.ContentType + "/" + string(c.Photo.Data))
+		}
+		b.WriteString("}")
+		parts = append(parts, b.String())
+	}
+	return "[" + strings.Join(parts, "; ") + "]"
+}
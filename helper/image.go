@@ -0,0 +1,70 @@
+package helper
+
+import (
+	"image"
+	"image/draw"
+)
+
+// SquareThumbnail center-crops img to a square and downsamples it to size×size
+// by averaging every source pixel that falls inside each target pixel. Images
+// smaller than size are cropped but never upscaled.
+func SquareThumbnail(img image.Image, size int) *image.NRGBA {
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	if size > side {
+		size = side
+	}
+	if size < 1 {
+		size = 1
+	}
+
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(
+		bounds.Min.X+(bounds.Dx()-side)/2,
+		bounds.Min.Y+(bounds.Dy()-side)/2,
+	))
+	src := image.NewNRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(src, src.Bounds(), img, crop.Min, draw.Src)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0 := y * side / size
+		y1 := (y + 1) * side / size
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < size; x++ {
+			x0 := x * side / size
+			x1 := (x + 1) * side / size
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					// weight colour by alpha so transparent pixels don't darken edges
+					alpha := uint64(p[3])
+					r += uint64(p[0]) * alpha
+					g += uint64(p[1]) * alpha
+					b += uint64(p[2]) * alpha
+					a += alpha
+					n++
+				}
+			}
+
+			o := dst.PixOffset(x, y)
+			if a > 0 {
+				dst.Pix[o] = uint8(r / a)
+				dst.Pix[o+1] = uint8(g / a)
+				dst.Pix[o+2] = uint8(b / a)
+			}
+			dst.Pix[o+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,92 @@
+package helper
+
+import (
+	"net/mail"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeName lowercases a name, strips accents and punctuation and
+// collapses whitespace so "  José  O'Neil " and "jose oneil" compare equal.
+func NormalizeName(name string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range norm.NFD.String(strings.ToLower(name)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-' || r == '_' || r == '.':
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// NormalizeEmail trims and lowercases an email address.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormalizePhone keeps only the digits of a phone number. Numbers longer than
+// ten digits are reduced to their last ten so that country prefixes do not
+// prevent "+1 555 123 4567" from matching "(555) 123-4567".
+func NormalizePhone(phone string) string {
+	var b strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	digits := b.String()
+	if len(digits) > 10 {
+		digits = digits[len(digits)-10:]
+	}
+	return digits
+}
+
+// IsValidEmail reports whether email is a bare, syntactically valid address.
+func IsValidEmail(email string) bool {
+	email = strings.TrimSpace(email)
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return false
+	}
+	at := strings.LastIndex(email, "@")
+	return at > 0 && strings.Contains(email[at+1:], ".")
+}
+
+// IsValidPhone reports whether phone contains a plausible number of digits
+// and nothing but digits, spaces and the usual separators.
+func IsValidPhone(phone string) bool {
+	digits := 0
+	for _, r := range strings.TrimSpace(phone) {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == ' ' || r == '-' || r == '(' || r == ')' || r == '.' || r == '+':
+		default:
+			return false
+		}
+	}
+	return digits >= 7 && digits <= 15
+}
+
+// NormalizeDetailValue normalizes a contact detail value according to its type.
+func NormalizeDetailValue(detailType, value string) string {
+	switch strings.ToLower(strings.TrimSpace(detailType)) {
+	case "email":
+		return NormalizeEmail(value)
+	case "phone", "mobile", "tel":
+		return NormalizePhone(value)
+	default:
+		return strings.ToLower(strings.Join(strings.Fields(value), " "))
+	}
+}
@@ -0,0 +1,187 @@
+package helper
+
+import (
+	"strings"
+)
+
+var soundexCodes = map[rune]byte{
+	'b': '1', 'f': '1', 'p': '1', 'v': '1',
+	'c': '2', 'g': '2', 'j': '2', 'k': '2', 'q': '2', 's': '2', 'x': '2', 'z': '2',
+	'd': '3', 't': '3',
+	'l': '4',
+	'm': '5', 'n': '5',
+	'r': '6',
+}
+
+// Soundex returns the four character American Soundex code of word, or an
+// empty string when word has no ASCII letters.
+func Soundex(word string) string {
+	letters := asciiLetters(word)
+	if letters == "" {
+		return ""
+	}
+
+	code := []byte{letters[0] - 'a' + 'A'}
+	last := soundexCodes[rune(letters[0])]
+	for _, r := range letters[1:] {
+		c, ok := soundexCodes[r]
+		switch {
+		case !ok && (r == 'h' || r == 'w'):
+			// h and w do not separate letters with the same code
+			continue
+		case !ok:
+			last = 0
+			continue
+		case c == last:
+			continue
+		}
+		code = append(code, c)
+		last = c
+		if len(code) == 4 {
+			break
+		}
+	}
+	for len(code) < 4 {
+		code = append(code, '0')
+	}
+	return string(code)
+}
+
+// Metaphone returns the original Metaphone key of word, which groups words
+// that sound alike in English ("Smith"/"Smyth", "Knight"/"Night").
+func Metaphone(word string) string {
+	w := asciiLetters(word)
+	if w == "" {
+		return ""
+	}
+
+	switch {
+	case strings.HasPrefix(w, "ae"), strings.HasPrefix(w, "gn"), strings.HasPrefix(w, "kn"),
+		strings.HasPrefix(w, "pn"), strings.HasPrefix(w, "wr"):
+		w = w[1:]
+	case w[0] == 'x':
+		w = "s" + w[1:]
+	case strings.HasPrefix(w, "wh"):
+		w = "w" + w[2:]
+	}
+
+	at := func(i int) byte {
+		if i < 0 || i >= len(w) {
+			return 0
+		}
+		return w[i]
+	}
+	isVowel := func(c byte) bool {
+		return c == 'a' || c == 'e' || c == 'i' || c == 'o' || c == 'u'
+	}
+	isFrontVowel := func(c byte) bool {
+		return c == 'e' || c == 'i' || c == 'y'
+	}
+
+	var key strings.Builder
+	for i := 0; i < len(w); i++ {
+		c := w[i]
+		if c != 'c' && i > 0 && at(i-1) == c {
+			continue
+		}
+
+		switch c {
+		case 'a', 'e', 'i', 'o', 'u':
+			if i == 0 {
+				key.WriteByte(c - 'a' + 'A')
+			}
+		case 'b':
+			if !(at(i-1) == 'm' && i == len(w)-1) {
+				key.WriteByte('B')
+			}
+		case 'c':
+			switch {
+			case at(i+1) == 'i' && at(i+2) == 'a', at(i+1) == 'h' && at(i-1) != 's':
+				key.WriteByte('X')
+			case isFrontVowel(at(i + 1)):
+				if at(i-1) != 's' {
+					key.WriteByte('S')
+				}
+			default:
+				key.WriteByte('K')
+			}
+		case 'd':
+			if at(i+1) == 'g' && isFrontVowel(at(i+2)) {
+				key.WriteByte('J')
+				i++
+			} else {
+				key.WriteByte('T')
+			}
+		case 'g':
+			switch {
+			case at(i+1) == 'h' && i+2 < len(w) && !isVowel(at(i+2)):
+			case at(i+1) == 'n' && (i+2 == len(w) || (at(i+2) == 'e' && at(i+3) == 'd' && i+4 == len(w))):
+			case isFrontVowel(at(i+1)) && at(i-1) != 'g':
+				key.WriteByte('J')
+			default:
+				key.WriteByte('K')
+			}
+		case 'h':
+			prev := at(i - 1)
+			if isVowel(at(i+1)) && prev != 'c' && prev != 's' && prev != 'p' && prev != 't' && prev != 'g' {
+				key.WriteByte('H')
+			}
+		case 'k':
+			if at(i-1) != 'c' {
+				key.WriteByte('K')
+			}
+		case 'p':
+			if at(i+1) == 'h' {
+				key.WriteByte('F')
+			} else {
+				key.WriteByte('P')
+			}
+		case 'q':
+			key.WriteByte('K')
+		case 's':
+			switch {
+			case at(i+1) == 'h':
+				key.WriteByte('X')
+				i++
+			case at(i+1) == 'i' && (at(i+2) == 'o' || at(i+2) == 'a'):
+				key.WriteByte('X')
+			default:
+				key.WriteByte('S')
+			}
+		case 't':
+			switch {
+			case at(i+1) == 'i' && (at(i+2) == 'o' || at(i+2) == 'a'):
+				key.WriteByte('X')
+			case at(i+1) == 'h':
+				key.WriteByte('0')
+				i++
+			case at(i+1) == 'c' && at(i+2) == 'h':
+			default:
+				key.WriteByte('T')
+			}
+		case 'v':
+			key.WriteByte('F')
+		case 'w', 'y':
+			if isVowel(at(i + 1)) {
+				key.WriteByte(c - 'a' + 'A')
+			}
+		case 'x':
+			key.WriteString("KS")
+		case 'z':
+			key.WriteByte('S')
+		default:
+			key.WriteByte(c - 'a' + 'A')
+		}
+	}
+	return key.String()
+}
+
+func asciiLetters(word string) string {
+	var b strings.Builder
+	for _, r := range NormalizeName(word) {
+		if r >= 'a' && r <= 'z' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
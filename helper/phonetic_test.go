@@ -0,0 +1,64 @@
+package helper
+
+import "testing"
+
+func TestSoundex(t *testing.T) {
+	tests := []struct {
+		word, want string
+	}{
+		{"", ""},
+		{"123", ""},
+		{"Robert", "R163"},
+		{"Rupert", "R163"},
+		{"Rubin", "R150"},
+		{"Ashcraft", "A261"},
+		{"Tymczak", "T522"},
+		{"Pfister", "P236"},
+		{"Honeyman", "H555"},
+		{"Lee", "L000"},
+		{"O'Hara", "O600"},
+	}
+	for _, tt := range tests {
+		if got := Soundex(tt.word); got != tt.want {
+			t.Errorf("Soundex(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestMetaphone(t *testing.T) {
+	tests := []struct {
+		word, want string
+	}{
+		{"", ""},
+		{"Smith", "SM0"},
+		{"Thomas", "0MS"},
+		{"Knight", "NT"},
+		{"Wright", "RT"},
+		{"Xavier", "SFR"},
+		{"Philip", "FLP"},
+	}
+	for _, tt := range tests {
+		if got := Metaphone(tt.word); got != tt.want {
+			t.Errorf("Metaphone(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestMetaphoneGroupsSoundAlikes(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"Smith", "Smyth", true},
+		{"Knight", "Night", true},
+		{"Philip", "Filip", true},
+		{"Catherine", "Kathryn", true},
+		{"Smith", "Jones", false},
+	}
+	for _, tt := range tests {
+		if got := Metaphone(tt.a) == Metaphone(tt.b); got != tt.same {
+			t.Errorf("Metaphone(%q) = %q, Metaphone(%q) = %q, same = %v, want %v",
+				tt.a, Metaphone(tt.a), tt.b, Metaphone(tt.b), got, tt.same)
+		}
+	}
+}
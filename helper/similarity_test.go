@@ -0,0 +1,52 @@
+package helper
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"same", "same", 0},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"jon", "john", 1},
+		{"café", "cafe", 1},
+		{"müller", "muller", 1},
+	}
+	for _, tt := range tests {
+		if got := Levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("Levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := Levenshtein(tt.b, tt.a); got != tt.want {
+			t.Errorf("Levenshtein(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestJaroWinkler(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 1},
+		{"", "abc", 0},
+		{"abc", "xyz", 0},
+		{"martha", "martha", 1},
+		{"martha", "marhta", 0.9611},
+		{"dwayne", "duane", 0.84},
+		{"dixon", "dicksonx", 0.8133},
+		{"jellyfish", "smellyfish", 0.8963},
+	}
+	for _, tt := range tests {
+		if got := JaroWinkler(tt.a, tt.b); math.Abs(got-tt.want) > 0.0005 {
+			t.Errorf("JaroWinkler(%q, %q) = %.4f, want %.4f", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -3,11 +3,16 @@ package main
 import (
 	"Contact_App/app"
 	"Contact_App/db"
+	"Contact_App/web"
 	"fmt"
 	"log"
 )
 
 func main() {
+	if err := web.InitCursorSecret(); err != nil {
+		log.Fatalf("Failed to configure cursors: %v", err)
+	}
+
 	db.InitDB()
 	database := db.GetDB()
 
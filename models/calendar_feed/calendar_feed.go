@@ -0,0 +1,11 @@
+package calendar_feed
+
+import "time"
+
+// CalendarFeed holds the secret token that lets calendar clients subscribe to
+// a user's important dates without a login
+type CalendarFeed struct {
+	UserID    uint      `gorm:"primaryKey;type:BIGINT UNSIGNED" json:"user_id"`
+	Token     string    `gorm:"size:64;not null;uniqueIndex" json:"token"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,26 @@
+package calendar_feed
+
+import (
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type ModuleConfig struct {
+	DB *gorm.DB
+}
+
+func NewCalendarFeedModuleConfig(db *gorm.DB) *ModuleConfig {
+	return &ModuleConfig{DB: db}
+}
+
+func (config *ModuleConfig) TableMigration(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if err := config.DB.AutoMigrate(&CalendarFeed{}); err != nil {
+		log.Println("CalendarFeed Auto Migration Error:", err)
+	}
+
+	log.Println("CalendarFeed Table Migrated")
+}
@@ -0,0 +1,26 @@
+package card_link
+
+import "time"
+
+// CardLink is a public link that serves one contact's vCard without a login.
+// The token in the URL is the only credential; a link stops working once it
+// expires or is revoked.
+type CardLink struct {
+	LinkID         uint       `gorm:"primaryKey;autoIncrement;type:BIGINT UNSIGNED" json:"link_id"`
+	UserID         uint       `gorm:"not null;index;type:BIGINT UNSIGNED" json:"user_id"`
+	ContactID      uint       `gorm:"not null;index;type:BIGINT UNSIGNED" json:"contact_id"`
+	Token          string     `gorm:"size:64;not null;uniqueIndex" json:"token"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	AccessCount    uint       `gorm:"not null;default:0" json:"access_count"`
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+
+	Active bool   `gorm:"-" json:"active"`
+	URL    string `gorm:"-" json:"url,omitempty"`
+}
+
+// IsActive reports whether the link still serves the card at now
+func (l *CardLink) IsActive(now time.Time) bool {
+	return l.RevokedAt == nil && (l.ExpiresAt == nil || now.Before(*l.ExpiresAt))
+}
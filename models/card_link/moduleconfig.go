@@ -0,0 +1,26 @@
+package card_link
+
+import (
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type ModuleConfig struct {
+	DB *gorm.DB
+}
+
+func NewCardLinkModuleConfig(db *gorm.DB) *ModuleConfig {
+	return &ModuleConfig{DB: db}
+}
+
+func (config *ModuleConfig) TableMigration(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if err := config.DB.AutoMigrate(&CardLink{}); err != nil {
+		log.Println("CardLink Auto Migration Error:", err)
+	}
+
+	log.Println("CardLink Table Migrated")
+}
@@ -0,0 +1,28 @@
+package carddav
+
+import "time"
+
+// AppPassword lets a CardDAV client sign in with HTTP basic auth without
+// knowing the account password. Only a SHA-256 hash of the secret is kept;
+// the secret itself is shown once, when the password is created.
+type AppPassword struct {
+	AppPasswordID uint       `gorm:"primaryKey;autoIncrement;type:BIGINT UNSIGNED" json:"app_password_id"`
+	UserID        uint       `gorm:"not null;index;type:BIGINT UNSIGNED" json:"user_id"`
+	Name          string     `gorm:"size:100;not null" json:"name"`
+	SecretHash    string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	LastUsedAt    *time.Time `json:"last_used_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+
+	Secret string `gorm:"-" json:"secret,omitempty"`
+}
+
+// CardResource remembers the resource name and vCard UID a CardDAV client
+// chose when it created a contact, so the card stays at the URL the client
+// put it. Contacts created elsewhere are served as <contact_id>.vcf.
+type CardResource struct {
+	ResourceID uint   `gorm:"primaryKey;autoIncrement;type:BIGINT UNSIGNED" json:"resource_id"`
+	UserID     uint   `gorm:"not null;uniqueIndex:idx_card_resource_name,priority:1;type:BIGINT UNSIGNED" json:"user_id"`
+	Name       string `gorm:"size:255;not null;uniqueIndex:idx_card_resource_name,priority:2" json:"name"`
+	ContactID  uint   `gorm:"not null;index;type:BIGINT UNSIGNED" json:"contact_id"`
+	UID        string `gorm:"size:255" json:"uid"`
+}
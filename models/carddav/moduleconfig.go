@@ -0,0 +1,26 @@
+package carddav
+
+import (
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type ModuleConfig struct {
+	DB *gorm.DB
+}
+
+func NewCardDAVModuleConfig(db *gorm.DB) *ModuleConfig {
+	return &ModuleConfig{DB: db}
+}
+
+func (config *ModuleConfig) TableMigration(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if err := config.DB.AutoMigrate(&AppPassword{}, &CardResource{}); err != nil {
+		log.Println("CardDAV Auto Migration Error:", err)
+	}
+
+	log.Println("CardDAV Table Migrated")
+}
@@ -2,6 +2,10 @@ package contact
 
 import (
 	"Contact_App/models/contact_detail"
+	"Contact_App/models/contact_relationship"
+	"Contact_App/models/contact_share"
+	"Contact_App/models/organization"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -12,7 +16,47 @@ type Contact struct {
 	FName     string `gorm:"column:f_name;not null" json:"first_name"`
 	LName     string `gorm:"column:l_name;not null" json:"last_name"`
 	IsActive  bool   `gorm:"default:true" json:"is_active"`
+	Version   uint   `gorm:"column:version;not null;default:1" json:"version"`
 
-	Details   []*contact_detail.ContactDetail `gorm:"foreignKey:ContactID;constraint:OnDelete:CASCADE" json:"details"`
-	DeletedAt gorm.DeletedAt                  `gorm:"index" json:"-"`
+	OrganizationID *uint  `gorm:"column:organization_id;index;type:BIGINT UNSIGNED" json:"organization_id"`
+	JobTitle       string `gorm:"column:job_title;size:255" json:"job_title"`
+	Department     string `gorm:"column:department;size:255" json:"department"`
+
+	// WorkspaceID makes the contact part of a team address book; UserID then
+	// only records who created it
+	WorkspaceID *uint `gorm:"column:workspace_id;index;type:BIGINT UNSIGNED" json:"workspace_id"`
+
+	// ExternalID is the contact's id in the system named by Source, set when
+	// it was created or matched by an upsert so the next import finds it again
+	ExternalID string `gorm:"column:external_id;size:255;index:idx_contact_external,priority:2" json:"external_id,omitempty"`
+	Source     string `gorm:"column:source;size:100;index:idx_contact_external,priority:1" json:"source,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Details      []*contact_detail.ContactDetail `gorm:"foreignKey:ContactID;constraint:OnDelete:CASCADE" json:"details"`
+	Organization *organization.Organization      `gorm:"foreignKey:OrganizationID;constraint:OnDelete:SET NULL" json:"organization,omitempty"`
+	DeletedAt    gorm.DeletedAt                  `gorm:"index" json:"-"`
+
+	// DeleteBatch ties a deleted contact to the details deleted with it
+	DeleteBatch string `gorm:"column:delete_batch;size:32" json:"-"`
+
+	SearchScore   float64                         `gorm:"-" json:"search_score,omitempty"`
+	Highlights    map[string][]string             `gorm:"-" json:"highlights,omitempty"`
+	LastContacted *time.Time                      `gorm:"-" json:"last_contacted,omitempty"`
+	Relationships []*contact_relationship.Related `gorm:"-" json:"relationships,omitempty"`
+	CustomFields  map[string]interface{}          `gorm:"-" json:"custom_fields,omitempty"`
+	SharedBy      *contact_share.SharedBy         `gorm:"-" json:"shared_by,omitempty"`
+	IsFavorite    bool                            `gorm:"-" json:"is_favorite"`
+	ViewedAt      *time.Time                      `gorm:"-" json:"viewed_at,omitempty"`
+	Completeness  int                             `gorm:"-" json:"completeness"`
+}
+
+// BeforeCreate starts every new row at version 1 so the ETag handed back on
+// create matches what the database stores
+func (c *Contact) BeforeCreate(tx *gorm.DB) error {
+	if c.Version == 0 {
+		c.Version = 1
+	}
+	return nil
 }
@@ -0,0 +1,42 @@
+package contact_change
+
+import "time"
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+
+	// ActionRevoke records that UserID lost access to a contact that still
+	// exists, through a revoked share or by leaving its workspace. Only that
+	// user reads it, and syncs it as a delete.
+	ActionRevoke = "revoke"
+)
+
+// ContactChange is one step of the change sequence that delta sync reads.
+// Every contact version adds a row for the contact and one for each detail
+// the version created, changed or removed; losing access to a contact adds a
+// revoke for the user who lost it. Seq only grows, so it orders the
+// changes across all users.
+type ContactChange struct {
+	Seq         uint      `gorm:"column:seq;primaryKey;autoIncrement;type:BIGINT UNSIGNED" json:"seq"`
+	UserID      uint      `gorm:"column:user_id;not null;index;type:BIGINT UNSIGNED" json:"user_id"`
+	WorkspaceID *uint     `gorm:"column:workspace_id;index;type:BIGINT UNSIGNED" json:"workspace_id"`
+	ContactID   uint      `gorm:"column:contact_id;not null;index;type:BIGINT UNSIGNED" json:"contact_id"`
+	DetailID    *uint     `gorm:"column:detail_id;type:BIGINT UNSIGNED" json:"detail_id,omitempty"`
+	Action      string    `gorm:"column:action;size:10;not null" json:"action"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+}
+
+// ContactTombstone tells a syncing client to drop a contact and its details
+type ContactTombstone struct {
+	ContactID uint      `json:"contact_id"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// DetailTombstone tells a syncing client to drop one detail of a contact it keeps
+type DetailTombstone struct {
+	ContactDetailsID uint      `json:"contact_details_id"`
+	ContactID        uint      `json:"contact_id"`
+	DeletedAt        time.Time `json:"deleted_at"`
+}
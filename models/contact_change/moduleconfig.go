@@ -0,0 +1,26 @@
+package contact_change
+
+import (
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type ModuleConfig struct {
+	DB *gorm.DB
+}
+
+func NewContactChangeModuleConfig(db *gorm.DB) *ModuleConfig {
+	return &ModuleConfig{DB: db}
+}
+
+func (config *ModuleConfig) TableMigration(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if err := config.DB.AutoMigrate(&ContactChange{}); err != nil {
+		log.Println("ContactChange Auto Migration Error:", err)
+	}
+
+	log.Println("ContactChange Table Migrated")
+}
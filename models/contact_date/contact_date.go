@@ -0,0 +1,48 @@
+package contact_date
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	TypeBirthday    = "birthday"
+	TypeAnniversary = "anniversary"
+	TypeOther       = "other"
+)
+
+// ContactDate is a recurring yearly date on a contact. Year is nil when only
+// the day and month are known.
+type ContactDate struct {
+	DateID    uint      `gorm:"column:date_id;primaryKey;autoIncrement;type:BIGINT UNSIGNED" json:"date_id"`
+	UserID    uint      `gorm:"not null;index;type:BIGINT UNSIGNED" json:"user_id"`
+	ContactID uint      `gorm:"not null;index;type:BIGINT UNSIGNED" json:"contact_id"`
+	Type      string    `gorm:"size:32;not null" json:"type"`
+	Label     string    `gorm:"size:255" json:"label,omitempty"`
+	Month     int       `gorm:"not null" json:"month"`
+	Day       int       `gorm:"not null" json:"day"`
+	Year      *int      `json:"year"`
+	Date      string    `gorm:"-" json:"date"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Format renders the date as YYYY-MM-DD, or --MM-DD when the year is unknown
+func (d *ContactDate) Format() string {
+	if d.Year == nil {
+		return fmt.Sprintf("--%02d-%02d", d.Month, d.Day)
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", *d.Year, d.Month, d.Day)
+}
+
+func (d *ContactDate) AfterFind(tx *gorm.DB) error {
+	d.Date = d.Format()
+	return nil
+}
+
+func (d *ContactDate) AfterSave(tx *gorm.DB) error {
+	d.Date = d.Format()
+	return nil
+}
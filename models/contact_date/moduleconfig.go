@@ -0,0 +1,26 @@
+package contact_date
+
+import (
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type ModuleConfig struct {
+	DB *gorm.DB
+}
+
+func NewContactDateModuleConfig(db *gorm.DB) *ModuleConfig {
+	return &ModuleConfig{DB: db}
+}
+
+func (config *ModuleConfig) TableMigration(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if err := config.DB.AutoMigrate(&ContactDate{}); err != nil {
+		log.Println("ContactDate Auto Migration Error:", err)
+	}
+
+	log.Println("ContactDate Table Migrated")
+}
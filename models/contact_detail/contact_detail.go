@@ -1,6 +1,8 @@
 package contact_detail
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -11,5 +13,20 @@ type ContactDetail struct {
 	Type             string         `gorm:"not null" json:"type"`
 	Value            string         `gorm:"not null" json:"value"`
 	IsActive         bool           `gorm:"default:true" json:"is_active"`
+	ExternalID       string         `gorm:"size:255" json:"external_id,omitempty"`
+	Source           string         `gorm:"size:100" json:"source,omitempty"`
+	Version          uint           `gorm:"not null;default:1" json:"version"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
 	DeletedAt        gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	DeleteBatch      string         `gorm:"size:32" json:"-"`
+}
+
+// BeforeCreate starts every new row at version 1 so the ETag handed back on
+// create matches what the database stores
+func (d *ContactDetail) BeforeCreate(tx *gorm.DB) error {
+	if d.Version == 0 {
+		d.Version = 1
+	}
+	return nil
 }
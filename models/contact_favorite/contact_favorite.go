@@ -0,0 +1,12 @@
+package contact_favorite
+
+import "time"
+
+// ContactFavorite stars a contact for one user. Favorites are personal, so
+// users seeing the same shared or workspace contact star it independently.
+type ContactFavorite struct {
+	FavoriteID uint      `gorm:"primaryKey;autoIncrement;type:BIGINT UNSIGNED" json:"favorite_id"`
+	UserID     uint      `gorm:"not null;uniqueIndex:idx_favorite_user_contact,priority:1;type:BIGINT UNSIGNED" json:"user_id"`
+	ContactID  uint      `gorm:"not null;uniqueIndex:idx_favorite_user_contact,priority:2;index;type:BIGINT UNSIGNED" json:"contact_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,26 @@
+package contact_favorite
+
+import (
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type ModuleConfig struct {
+	DB *gorm.DB
+}
+
+func NewContactFavoriteModuleConfig(db *gorm.DB) *ModuleConfig {
+	return &ModuleConfig{DB: db}
+}
+
+func (config *ModuleConfig) TableMigration(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if err := config.DB.AutoMigrate(&ContactFavorite{}); err != nil {
+		log.Println("ContactFavorite Auto Migration Error:", err)
+	}
+
+	log.Println("ContactFavorite Table Migrated")
+}
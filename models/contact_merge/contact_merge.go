@@ -0,0 +1,30 @@
+package contact_merge
+
+import (
+	"time"
+)
+
+type ContactMerge struct {
+	MergeID     uint       `gorm:"column:merge_id;primaryKey;autoIncrement;type:BIGINT UNSIGNED" json:"merge_id"`
+	UserID      uint       `gorm:"column:user_id;not null;index;type:BIGINT UNSIGNED" json:"user_id"`
+	PrimaryID   uint       `gorm:"column:primary_id;not null;index;type:BIGINT UNSIGNED" json:"primary_id"`
+	SecondaryID uint       `gorm:"column:secondary_id;not null;index;type:BIGINT UNSIGNED" json:"secondary_id"`
+	Snapshot    string     `gorm:"column:snapshot;type:TEXT" json:"-"`
+	MergedAt    time.Time  `gorm:"column:merged_at;not null" json:"merged_at"`
+	ExpiresAt   time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`
+	UndoneAt    *time.Time `gorm:"column:undone_at" json:"undone_at,omitempty"`
+}
+
+// Snapshot holds what a merge changed so that it can be reverted.
+type Snapshot struct {
+	PrimaryFName     string `json:"primary_first_name"`
+	PrimaryLName     string `json:"primary_last_name"`
+	MovedDetailIDs   []uint `json:"moved_detail_ids"`
+	DroppedDetailIDs []uint `json:"dropped_detail_ids"`
+	AddedGroupIDs    []uint `json:"added_group_ids"`
+}
+
+// CanUndo reports whether the merge is still inside its retention window.
+func (m *ContactMerge) CanUndo(now time.Time) bool {
+	return m.UndoneAt == nil && now.Before(m.ExpiresAt)
+}
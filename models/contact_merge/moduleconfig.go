@@ -0,0 +1,26 @@
+package contact_merge
+
+import (
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type ModuleConfig struct {
+	DB *gorm.DB
+}
+
+func NewContactMergeModuleConfig(db *gorm.DB) *ModuleConfig {
+	return &ModuleConfig{DB: db}
+}
+
+func (config *ModuleConfig) TableMigration(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if err := config.DB.AutoMigrate(&ContactMerge{}); err != nil {
+		log.Println("ContactMerge Auto Migration Error:", err)
+	}
+
+	log.Println("ContactMerge Table Migrated")
+}
@@ -0,0 +1,18 @@
+package contact_photo
+
+import "time"
+
+// ContactPhoto describes the image attached to a contact. The bytes live in
+// the blob store; this row records where and what they are.
+type ContactPhoto struct {
+	PhotoID     uint      `gorm:"column:photo_id;primaryKey;autoIncrement;type:BIGINT UNSIGNED" json:"photo_id"`
+	ContactID   uint      `gorm:"column:contact_id;not null;uniqueIndex;type:BIGINT UNSIGNED" json:"contact_id"`
+	UserID      uint      `gorm:"column:user_id;not null;index;type:BIGINT UNSIGNED" json:"user_id"`
+	ContentType string    `gorm:"column:content_type;not null" json:"content_type"`
+	Size        int64     `gorm:"column:size;not null" json:"size"`
+	Width       int       `gorm:"column:width;not null" json:"width"`
+	Height      int       `gorm:"column:height;not null" json:"height"`
+	Checksum    string    `gorm:"column:checksum;not null;size:64" json:"checksum"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,26 @@
+package contact_photo
+
+import (
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type ModuleConfig struct {
+	DB *gorm.DB
+}
+
+func NewContactPhotoModuleConfig(db *gorm.DB) *ModuleConfig {
+	return &ModuleConfig{DB: db}
+}
+
+func (config *ModuleConfig) TableMigration(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if err := config.DB.AutoMigrate(&ContactPhoto{}); err != nil {
+		log.Println("ContactPhoto Auto Migration Error:", err)
+	}
+
+	log.Println("ContactPhoto Table Migrated")
+}
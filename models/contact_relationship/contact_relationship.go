@@ -0,0 +1,53 @@
+package contact_relationship
+
+import "time"
+
+// ContactRelationship records that RelatedContactID is ContactID's Type, e.g.
+// "B is A's manager". When Bidirectional is set the relationship also shows
+// up on the related contact under the inverse type ("A is B's report").
+type ContactRelationship struct {
+	RelationshipID   uint      `gorm:"primaryKey;autoIncrement;type:BIGINT UNSIGNED" json:"relationship_id"`
+	UserID           uint      `gorm:"not null;index;type:BIGINT UNSIGNED" json:"user_id"`
+	ContactID        uint      `gorm:"not null;uniqueIndex:idx_relationship_pair,priority:1;type:BIGINT UNSIGNED" json:"contact_id"`
+	RelatedContactID uint      `gorm:"not null;uniqueIndex:idx_relationship_pair,priority:2;index;type:BIGINT UNSIGNED" json:"related_contact_id"`
+	Type             string    `gorm:"size:32;not null;uniqueIndex:idx_relationship_pair,priority:3" json:"type"`
+	Label            string    `gorm:"size:255" json:"label,omitempty"`
+	Bidirectional    bool      `gorm:"not null;default:false" json:"bidirectional"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// Related is a relationship as seen from one contact: RelatedContactID is
+// that contact's Type. Direction is "incoming" when the row was recorded on
+// the other contact and is shown here because it is bidirectional.
+type Related struct {
+	RelationshipID   uint   `json:"relationship_id"`
+	RelatedContactID uint   `json:"related_contact_id"`
+	FirstName        string `json:"first_name"`
+	LastName         string `json:"last_name"`
+	Type             string `json:"type"`
+	Label            string `json:"label,omitempty"`
+	Bidirectional    bool   `json:"bidirectional"`
+	Direction        string `json:"direction"`
+}
+
+const (
+	DirectionOutgoing = "outgoing"
+	DirectionIncoming = "incoming"
+)
+
+// Inverse maps each type to the type the other contact sees
+var Inverse = map[string]string{
+	"spouse":    "spouse",
+	"partner":   "partner",
+	"sibling":   "sibling",
+	"friend":    "friend",
+	"colleague": "colleague",
+	"parent":    "child",
+	"child":     "parent",
+	"manager":   "report",
+	"report":    "manager",
+	"assistant": "executive",
+	"executive": "assistant",
+	"other":     "other",
+}
@@ -0,0 +1,26 @@
+package contact_relationship
+
+import (
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type ModuleConfig struct {
+	DB *gorm.DB
+}
+
+func NewContactRelationshipModuleConfig(db *gorm.DB) *ModuleConfig {
+	return &ModuleConfig{DB: db}
+}
+
+func (config *ModuleConfig) TableMigration(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if err := config.DB.AutoMigrate(&ContactRelationship{}); err != nil {
+		log.Println("ContactRelationship Auto Migration Error:", err)
+	}
+
+	log.Println("ContactRelationship Table Migrated")
+}
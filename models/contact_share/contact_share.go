@@ -0,0 +1,37 @@
+package contact_share
+
+import "time"
+
+const (
+	PermissionRead  = "read"
+	PermissionWrite = "write"
+)
+
+// ContactShare gives another user access to one contact or, through GroupID,
+// to every current member of a group. Exactly one of ContactID and GroupID is set.
+type ContactShare struct {
+	ShareID     uint      `gorm:"primaryKey;autoIncrement;type:BIGINT UNSIGNED" json:"share_id"`
+	OwnerID     uint      `gorm:"not null;index;type:BIGINT UNSIGNED" json:"owner_id"`
+	RecipientID uint      `gorm:"not null;uniqueIndex:idx_share_recipient_contact,priority:1;uniqueIndex:idx_share_recipient_group,priority:1;type:BIGINT UNSIGNED" json:"recipient_id"`
+	ContactID   *uint     `gorm:"uniqueIndex:idx_share_recipient_contact,priority:2;type:BIGINT UNSIGNED" json:"contact_id,omitempty"`
+	GroupID     *uint     `gorm:"uniqueIndex:idx_share_recipient_group,priority:2;type:BIGINT UNSIGNED" json:"group_id,omitempty"`
+	Permission  string    `gorm:"size:8;not null" json:"permission"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	Owner     *Party `gorm:"-" json:"owner,omitempty"`
+	Recipient *Party `gorm:"-" json:"recipient,omitempty"`
+}
+
+// Party is the public view of a user on either side of a share
+type Party struct {
+	UserID uint   `json:"user_id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+}
+
+// SharedBy marks a contact or group the caller sees through a share
+type SharedBy struct {
+	Party
+	Permission string `json:"permission"`
+}
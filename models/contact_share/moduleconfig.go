@@ -0,0 +1,26 @@
+package contact_share
+
+import (
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type ModuleConfig struct {
+	DB *gorm.DB
+}
+
+func NewContactShareModuleConfig(db *gorm.DB) *ModuleConfig {
+	return &ModuleConfig{DB: db}
+}
+
+func (config *ModuleConfig) TableMigration(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if err := config.DB.AutoMigrate(&ContactShare{}); err != nil {
+		log.Println("ContactShare Auto Migration Error:", err)
+	}
+
+	log.Println("ContactShare Table Migrated")
+}
@@ -0,0 +1,45 @@
+package contact_version
+
+import (
+	"time"
+)
+
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionMerge   = "merge"
+	ActionRevert  = "revert"
+)
+
+type ContactVersion struct {
+	VersionID uint      `gorm:"column:version_id;primaryKey;autoIncrement;type:BIGINT UNSIGNED" json:"version_id"`
+	ContactID uint      `gorm:"column:contact_id;not null;uniqueIndex:idx_contact_version;type:BIGINT UNSIGNED" json:"contact_id"`
+	Version   int       `gorm:"column:version;not null;uniqueIndex:idx_contact_version" json:"version"`
+	UserID    uint      `gorm:"column:user_id;not null;index;type:BIGINT UNSIGNED" json:"user_id"`
+	ActorID   uint      `gorm:"column:actor_id;not null;type:BIGINT UNSIGNED" json:"actor_id"`
+	Action    string    `gorm:"column:action;not null" json:"action"`
+	Snapshot  string    `gorm:"column:snapshot;type:TEXT" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Snapshot is the state of a contact right after a change.
+type Snapshot struct {
+	FName    string           `json:"first_name"`
+	LName    string           `json:"last_name"`
+	IsActive bool             `json:"is_active"`
+	Details  []DetailSnapshot `json:"details"`
+
+	OrganizationID *uint  `json:"organization_id,omitempty"`
+	JobTitle       string `json:"job_title,omitempty"`
+	Department     string `json:"department,omitempty"`
+
+	CustomFields map[string]string `json:"custom_fields,omitempty"`
+}
+
+type DetailSnapshot struct {
+	ID    uint   `json:"id"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
@@ -0,0 +1,26 @@
+package contact_version
+
+import (
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type ModuleConfig struct {
+	DB *gorm.DB
+}
+
+func NewContactVersionModuleConfig(db *gorm.DB) *ModuleConfig {
+	return &ModuleConfig{DB: db}
+}
+
+func (config *ModuleConfig) TableMigration(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if err := config.DB.AutoMigrate(&ContactVersion{}); err != nil {
+		log.Println("ContactVersion Auto Migration Error:", err)
+	}
+
+	log.Println("ContactVersion Table Migrated")
+}
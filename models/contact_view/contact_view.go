@@ -0,0 +1,21 @@
+package contact_view
+
+import "time"
+
+// ContactView records when a user last opened a contact. Each user keeps one
+// row per contact, trimmed to their history length.
+type ContactView struct {
+	ViewID    uint      `gorm:"primaryKey;autoIncrement;type:BIGINT UNSIGNED" json:"view_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_view_user_contact,priority:1;index:idx_view_user_time,priority:1;type:BIGINT UNSIGNED" json:"user_id"`
+	ContactID uint      `gorm:"not null;uniqueIndex:idx_view_user_contact,priority:2;index;type:BIGINT UNSIGNED" json:"contact_id"`
+	ViewedAt  time.Time `gorm:"not null;index:idx_view_user_time,priority:2" json:"viewed_at"`
+}
+
+// RecentSettings holds a user's recently viewed preferences. Users without a
+// row track views with the default history length.
+type RecentSettings struct {
+	UserID        uint      `gorm:"primaryKey;autoIncrement:false;type:BIGINT UNSIGNED" json:"user_id"`
+	Enabled       bool      `gorm:"not null" json:"enabled"`
+	HistoryLength int       `gorm:"not null" json:"history_length"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
@@ -0,0 +1,26 @@
+package contact_view
+
+import (
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type ModuleConfig struct {
+	DB *gorm.DB
+}
+
+func NewContactViewModuleConfig(db *gorm.DB) *ModuleConfig {
+	return &ModuleConfig{DB: db}
+}
+
+func (config *ModuleConfig) TableMigration(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if err := config.DB.AutoMigrate(&ContactView{}, &RecentSettings{}); err != nil {
+		log.Println("ContactView Auto Migration Error:", err)
+	}
+
+	log.Println("ContactView Table Migrated")
+}
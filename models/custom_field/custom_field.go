@@ -0,0 +1,38 @@
+package custom_field
+
+import "time"
+
+const (
+	TypeText    = "text"
+	TypeNumber  = "number"
+	TypeDate    = "date"
+	TypeBoolean = "boolean"
+	TypeEnum    = "enum"
+)
+
+// CustomFieldDefinition is an attribute a user adds to all of their contacts.
+// Key is the stable name used in request bodies, filters and exports; Options
+// lists the allowed values of an enum field.
+type CustomFieldDefinition struct {
+	FieldID   uint      `gorm:"primaryKey;autoIncrement;type:BIGINT UNSIGNED" json:"field_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_custom_field_user_key,priority:1;type:BIGINT UNSIGNED" json:"user_id"`
+	Key       string    `gorm:"column:field_key;size:64;not null;uniqueIndex:idx_custom_field_user_key,priority:2" json:"key"`
+	Label     string    `gorm:"size:255;not null" json:"label"`
+	Type      string    `gorm:"size:16;not null" json:"type"`
+	Options   []string  `gorm:"type:text;serializer:json" json:"options,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CustomFieldValue is one contact's value for a field. Value holds the
+// canonical text form (2024-05-01, true, 42.5); number fields also fill
+// NumberValue so they filter and sort numerically.
+type CustomFieldValue struct {
+	ValueID     uint      `gorm:"primaryKey;autoIncrement;type:BIGINT UNSIGNED" json:"value_id"`
+	UserID      uint      `gorm:"not null;index;type:BIGINT UNSIGNED" json:"user_id"`
+	ContactID   uint      `gorm:"not null;uniqueIndex:idx_custom_value_contact_field,priority:1;type:BIGINT UNSIGNED" json:"contact_id"`
+	FieldID     uint      `gorm:"not null;uniqueIndex:idx_custom_value_contact_field,priority:2;index;type:BIGINT UNSIGNED" json:"field_id"`
+	Value       string    `gorm:"size:1024;not null" json:"value"`
+	NumberValue *float64  `json:"number_value,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
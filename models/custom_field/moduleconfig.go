@@ -0,0 +1,26 @@
+package custom_field
+
+import (
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type ModuleConfig struct {
+	DB *gorm.DB
+}
+
+func NewCustomFieldModuleConfig(db *gorm.DB) *ModuleConfig {
+	return &ModuleConfig{DB: db}
+}
+
+func (config *ModuleConfig) TableMigration(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if err := config.DB.AutoMigrate(&CustomFieldDefinition{}, &CustomFieldValue{}); err != nil {
+		log.Println("CustomField Auto Migration Error:", err)
+	}
+
+	log.Println("CustomField Table Migrated")
+}
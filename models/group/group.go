@@ -0,0 +1,29 @@
+package group
+
+import (
+	"Contact_App/models/contact_share"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Group struct {
+	GroupID     uint   `gorm:"column:group_id;primaryKey;autoIncrement;type:BIGINT UNSIGNED" json:"group_id"`
+	UserID      uint   `gorm:"column:user_id;not null;index;type:BIGINT UNSIGNED" json:"user_id"`
+	Name        string `gorm:"column:name;not null" json:"name"`
+	Color       string `gorm:"column:color" json:"color"`
+	Description string `gorm:"column:description" json:"description"`
+	IsActive    bool   `gorm:"default:true" json:"is_active"`
+
+	ContactCount int64                   `gorm:"-" json:"contact_count"`
+	SharedBy     *contact_share.SharedBy `gorm:"-" json:"shared_by,omitempty"`
+	DeletedAt    gorm.DeletedAt          `gorm:"index" json:"-"`
+}
+
+// GroupContact is the membership row linking a contact to a group.
+type GroupContact struct {
+	GroupID   uint      `gorm:"column:group_id;primaryKey;type:BIGINT UNSIGNED" json:"group_id"`
+	ContactID uint      `gorm:"column:contact_id;primaryKey;index;type:BIGINT UNSIGNED" json:"contact_id"`
+	UserID    uint      `gorm:"column:user_id;not null;index;type:BIGINT UNSIGNED" json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
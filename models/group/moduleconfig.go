@@ -0,0 +1,26 @@
+package group
+
+import (
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type ModuleConfig struct {
+	DB *gorm.DB
+}
+
+func NewGroupModuleConfig(db *gorm.DB) *ModuleConfig {
+	return &ModuleConfig{DB: db}
+}
+
+func (config *ModuleConfig) TableMigration(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if err := config.DB.AutoMigrate(&Group{}, &GroupContact{}); err != nil {
+		log.Println("Group Auto Migration Error:", err)
+	}
+
+	log.Println("Group Table Migrated")
+}
@@ -0,0 +1,29 @@
+package interaction
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	KindNote    = "note"
+	KindCall    = "call"
+	KindEmail   = "email"
+	KindMeeting = "meeting"
+)
+
+// Interaction is a timestamped entry in a contact's log. Notes are kept
+// alongside calls, emails and meetings but do not count as contact.
+type Interaction struct {
+	InteractionID   uint           `gorm:"primaryKey;autoIncrement;type:BIGINT UNSIGNED" json:"interaction_id"`
+	UserID          uint           `gorm:"not null;index;type:BIGINT UNSIGNED" json:"user_id"`
+	ContactID       uint           `gorm:"not null;index:idx_interaction_contact_time,priority:1;type:BIGINT UNSIGNED" json:"contact_id"`
+	Kind            string         `gorm:"size:16;not null" json:"kind"`
+	Body            string         `gorm:"type:text" json:"body"`
+	OccurredAt      time.Time      `gorm:"not null;index:idx_interaction_contact_time,priority:2" json:"occurred_at"`
+	DurationMinutes *int           `json:"duration_minutes,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+}
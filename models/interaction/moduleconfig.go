@@ -0,0 +1,26 @@
+package interaction
+
+import (
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type ModuleConfig struct {
+	DB *gorm.DB
+}
+
+func NewInteractionModuleConfig(db *gorm.DB) *ModuleConfig {
+	return &ModuleConfig{DB: db}
+}
+
+func (config *ModuleConfig) TableMigration(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if err := config.DB.AutoMigrate(&Interaction{}); err != nil {
+		log.Println("Interaction Auto Migration Error:", err)
+	}
+
+	log.Println("Interaction Table Migrated")
+}
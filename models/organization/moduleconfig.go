@@ -0,0 +1,26 @@
+package organization
+
+import (
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type ModuleConfig struct {
+	DB *gorm.DB
+}
+
+func NewOrganizationModuleConfig(db *gorm.DB) *ModuleConfig {
+	return &ModuleConfig{DB: db}
+}
+
+func (config *ModuleConfig) TableMigration(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if err := config.DB.AutoMigrate(&Organization{}); err != nil {
+		log.Println("Organization Auto Migration Error:", err)
+	}
+
+	log.Println("Organization Table Migrated")
+}
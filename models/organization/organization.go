@@ -0,0 +1,18 @@
+package organization
+
+import "time"
+
+// Organization is a company or other body contacts can belong to. Domain is
+// the bare email domain (example.com) used to suggest it for new contacts.
+type Organization struct {
+	OrganizationID uint      `gorm:"primaryKey;autoIncrement;type:BIGINT UNSIGNED" json:"organization_id"`
+	UserID         uint      `gorm:"not null;uniqueIndex:idx_organization_user_name,priority:1;index:idx_organization_user_domain,priority:1;type:BIGINT UNSIGNED" json:"user_id"`
+	Name           string    `gorm:"size:255;not null;uniqueIndex:idx_organization_user_name,priority:2" json:"name"`
+	Domain         string    `gorm:"size:255;index:idx_organization_user_domain,priority:2" json:"domain"`
+	Address        string    `gorm:"size:1024" json:"address"`
+	Notes          string    `gorm:"type:text" json:"notes"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	ContactCount *int64 `gorm:"-" json:"contact_count,omitempty"`
+}
@@ -0,0 +1,29 @@
+package task
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Task is a follow-up attached to a contact. A reminder goes out once at
+// RemindAt, or at DueAt when no reminder time is set, unless the task is
+// done by then; RemindedAt records that it was sent. A failed delivery is
+// counted in ReminderAttempts and retried no sooner than NextReminderAt.
+type Task struct {
+	TaskID     uint           `gorm:"primaryKey;autoIncrement;type:BIGINT UNSIGNED" json:"task_id"`
+	UserID     uint           `gorm:"not null;index:idx_task_user_due,priority:1;type:BIGINT UNSIGNED" json:"user_id"`
+	ContactID  uint           `gorm:"not null;index;type:BIGINT UNSIGNED" json:"contact_id"`
+	Note       string         `gorm:"type:text;not null" json:"note"`
+	DueAt      time.Time      `gorm:"not null;index:idx_task_user_due,priority:2" json:"due_at"`
+	RemindAt   *time.Time     `json:"remind_at"`
+	RemindedAt *time.Time     `gorm:"index" json:"reminded_at,omitempty"`
+	Done       bool           `gorm:"not null;default:false" json:"done"`
+	DoneAt     *time.Time     `json:"done_at,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+
+	ReminderAttempts int        `gorm:"not null;default:0" json:"-"`
+	NextReminderAt   *time.Time `json:"-"`
+}
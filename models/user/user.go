@@ -2,6 +2,7 @@ package user
 
 import (
 	"Contact_App/models/contact"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -14,8 +15,21 @@ type User struct {
 	Password string `gorm:"column:password" json:"password"`
 	IsAdmin  bool   `gorm:"column:is_admin;default:false" json:"is_admin"`
 	IsActive bool   `gorm:"column:is_active;default:true" json:"is_active"`
+	Version  uint   `gorm:"column:version;not null;default:1" json:"version"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 
 	Contacts []*contact.Contact `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"contacts"`
 
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
+
+// BeforeCreate starts every new row at version 1 so the ETag handed back on
+// create matches what the database stores
+func (u *User) BeforeCreate(tx *gorm.DB) error {
+	if u.Version == 0 {
+		u.Version = 1
+	}
+	return nil
+}
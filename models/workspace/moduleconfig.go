@@ -0,0 +1,26 @@
+package workspace
+
+import (
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type ModuleConfig struct {
+	DB *gorm.DB
+}
+
+func NewWorkspaceModuleConfig(db *gorm.DB) *ModuleConfig {
+	return &ModuleConfig{DB: db}
+}
+
+func (config *ModuleConfig) TableMigration(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if err := config.DB.AutoMigrate(&Workspace{}, &WorkspaceMember{}); err != nil {
+		log.Println("Workspace Auto Migration Error:", err)
+	}
+
+	log.Println("Workspace Table Migrated")
+}
@@ -0,0 +1,36 @@
+package workspace
+
+import "time"
+
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// Workspace is a team address book. Contacts carrying its workspace_id belong
+// to the team rather than to the member who created them.
+type Workspace struct {
+	WorkspaceID uint      `gorm:"primaryKey;autoIncrement;type:BIGINT UNSIGNED" json:"workspace_id"`
+	Name        string    `gorm:"size:255;not null" json:"name"`
+	CreatedBy   uint      `gorm:"not null;type:BIGINT UNSIGNED" json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	Role    string             `gorm:"-" json:"role,omitempty"`
+	Members []*WorkspaceMember `gorm:"-" json:"members,omitempty"`
+}
+
+// WorkspaceMember gives a user a role in a workspace. Admins manage the workspace and
+// its members, editors change its contacts and viewers only read them.
+type WorkspaceMember struct {
+	MemberID    uint      `gorm:"primaryKey;autoIncrement;type:BIGINT UNSIGNED" json:"member_id"`
+	WorkspaceID uint      `gorm:"not null;uniqueIndex:idx_workspace_member,priority:1;type:BIGINT UNSIGNED" json:"workspace_id"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_workspace_member,priority:2;index;type:BIGINT UNSIGNED" json:"user_id"`
+	Role        string    `gorm:"size:16;not null" json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	Name  string `gorm:"-" json:"name,omitempty"`
+	Email string `gorm:"-" json:"email,omitempty"`
+}
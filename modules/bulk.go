@@ -0,0 +1,16 @@
+package modules
+
+import (
+	"Contact_App/app"
+	bulkCtrl "Contact_App/component/bulk/controller"
+	"Contact_App/component/bulk/service"
+)
+
+func RegisterBulkRoutes(appObj *app.App) {
+
+	bulkService := service.NewBulkService()
+
+	bulkController := bulkCtrl.NewBulkController(bulkService)
+
+	bulkController.RegisterRoutes(appObj.Router)
+}
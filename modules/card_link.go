@@ -0,0 +1,17 @@
+package modules
+
+import (
+	"Contact_App/app"
+	cardLinkCtrl "Contact_App/component/card_link/controller"
+	"Contact_App/component/card_link/service"
+	contactService "Contact_App/component/contact/service"
+)
+
+func RegisterCardLinkRoutes(appObj *app.App) {
+
+	cardLinkService := service.NewCardLinkService(contactService.NewContactService())
+
+	cardLinkController := cardLinkCtrl.NewCardLinkController(cardLinkService)
+
+	cardLinkController.RegisterRoutes(appObj.Router)
+}
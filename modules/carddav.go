@@ -0,0 +1,18 @@
+package modules
+
+import (
+	"Contact_App/app"
+	carddavCtrl "Contact_App/component/carddav/controller"
+	"Contact_App/component/carddav/service"
+	contactService "Contact_App/component/contact/service"
+)
+
+func RegisterCardDAVRoutes(appObj *app.App) {
+
+	carddavService := service.NewCardDAVService(contactService.NewContactService())
+
+	carddavController := carddavCtrl.NewCardDAVController(carddavService)
+
+	carddavController.RegisterRoutes(appObj.Router)
+	carddavController.RegisterDAVRoutes(appObj.Router)
+}
@@ -14,6 +14,22 @@ func SetupRouter(appObj *app.App) {
 	RegisterUserRoutes(appObj)
 	RegisterContactRoutes(appObj)
 	RegisterContactDetailRoutes(appObj)
+	RegisterGroupRoutes(appObj)
+	RegisterDuplicateRoutes(appObj)
+	RegisterHistoryRoutes(appObj)
+	RegisterBulkRoutes(appObj)
+	RegisterPhotoRoutes(appObj)
+	RegisterImportantDateRoutes(appObj)
+	RegisterInteractionRoutes(appObj)
+	RegisterOrganizationRoutes(appObj)
+	RegisterRelationshipRoutes(appObj)
+	RegisterCustomFieldRoutes(appObj)
+	RegisterShareRoutes(appObj)
+	RegisterWorkspaceRoutes(appObj)
+	RegisterCardDAVRoutes(appObj)
+	RegisterCardLinkRoutes(appObj)
+	RegisterTaskRoutes(appObj)
+	RegisterQualityRoutes(appObj)
 
 	if err := appObj.Router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
 		path, _ := route.GetPathTemplate()
@@ -0,0 +1,16 @@
+package modules
+
+import (
+	"Contact_App/app"
+	customFieldCtrl "Contact_App/component/custom_field/controller"
+	"Contact_App/component/custom_field/service"
+)
+
+func RegisterCustomFieldRoutes(appObj *app.App) {
+
+	customFieldService := service.NewCustomFieldService()
+
+	customFieldController := customFieldCtrl.NewCustomFieldController(customFieldService)
+
+	customFieldController.RegisterRoutes(appObj.Router)
+}
@@ -0,0 +1,16 @@
+package modules
+
+import (
+	"Contact_App/app"
+	duplicateCtrl "Contact_App/component/duplicate/controller"
+	"Contact_App/component/duplicate/service"
+)
+
+func RegisterDuplicateRoutes(appObj *app.App) {
+
+	duplicateService := service.NewDuplicateService()
+
+	duplicateController := duplicateCtrl.NewDuplicateController(duplicateService)
+
+	duplicateController.RegisterRoutes(appObj.Router)
+}
@@ -0,0 +1,17 @@
+package modules
+
+import (
+	"Contact_App/app"
+	groupCtrl "Contact_App/component/group/controller"
+	"Contact_App/component/group/service"
+	photoService "Contact_App/component/photo/service"
+)
+
+func RegisterGroupRoutes(appObj *app.App) {
+
+	groupService := service.NewGroupService()
+
+	groupController := groupCtrl.NewGroupController(groupService, photoService.NewPhotoService())
+
+	groupController.RegisterRoutes(appObj.Router)
+}
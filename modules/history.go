@@ -0,0 +1,16 @@
+package modules
+
+import (
+	"Contact_App/app"
+	historyCtrl "Contact_App/component/history/controller"
+	"Contact_App/component/history/service"
+)
+
+func RegisterHistoryRoutes(appObj *app.App) {
+
+	historyService := service.NewHistoryService()
+
+	historyController := historyCtrl.NewHistoryController(historyService)
+
+	historyController.RegisterRoutes(appObj.Router)
+}
@@ -0,0 +1,16 @@
+package modules
+
+import (
+	"Contact_App/app"
+	dateCtrl "Contact_App/component/important_date/controller"
+	"Contact_App/component/important_date/service"
+)
+
+func RegisterImportantDateRoutes(appObj *app.App) {
+
+	dateService := service.NewDateService()
+
+	dateController := dateCtrl.NewDateController(dateService)
+
+	dateController.RegisterRoutes(appObj.Router)
+}
@@ -0,0 +1,16 @@
+package modules
+
+import (
+	"Contact_App/app"
+	interactionCtrl "Contact_App/component/interaction/controller"
+	"Contact_App/component/interaction/service"
+)
+
+func RegisterInteractionRoutes(appObj *app.App) {
+
+	interactionService := service.NewInteractionService()
+
+	interactionController := interactionCtrl.NewInteractionController(interactionService)
+
+	interactionController.RegisterRoutes(appObj.Router)
+}
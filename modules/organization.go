@@ -0,0 +1,16 @@
+package modules
+
+import (
+	"Contact_App/app"
+	organizationCtrl "Contact_App/component/organization/controller"
+	"Contact_App/component/organization/service"
+)
+
+func RegisterOrganizationRoutes(appObj *app.App) {
+
+	organizationService := service.NewOrganizationService()
+
+	organizationController := organizationCtrl.NewOrganizationController(organizationService)
+
+	organizationController.RegisterRoutes(appObj.Router)
+}
@@ -0,0 +1,16 @@
+package modules
+
+import (
+	"Contact_App/app"
+	photoCtrl "Contact_App/component/photo/controller"
+	"Contact_App/component/photo/service"
+)
+
+func RegisterPhotoRoutes(appObj *app.App) {
+
+	photoService := service.NewPhotoService()
+
+	photoController := photoCtrl.NewPhotoController(photoService)
+
+	photoController.RegisterRoutes(appObj.Router)
+}
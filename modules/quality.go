@@ -0,0 +1,17 @@
+package modules
+
+import (
+	"Contact_App/app"
+	contactService "Contact_App/component/contact/service"
+	qualityCtrl "Contact_App/component/quality/controller"
+	"Contact_App/component/quality/service"
+)
+
+func RegisterQualityRoutes(appObj *app.App) {
+
+	qualityService := service.NewQualityService(contactService.NewContactService())
+
+	qualityController := qualityCtrl.NewQualityController(qualityService)
+
+	qualityController.RegisterRoutes(appObj.Router)
+}
@@ -0,0 +1,16 @@
+package modules
+
+import (
+	"Contact_App/app"
+	relationshipCtrl "Contact_App/component/relationship/controller"
+	"Contact_App/component/relationship/service"
+)
+
+func RegisterRelationshipRoutes(appObj *app.App) {
+
+	relationshipService := service.NewRelationshipService()
+
+	relationshipController := relationshipCtrl.NewRelationshipController(relationshipService)
+
+	relationshipController.RegisterRoutes(appObj.Router)
+}
@@ -0,0 +1,16 @@
+package modules
+
+import (
+	"Contact_App/app"
+	shareCtrl "Contact_App/component/share/controller"
+	"Contact_App/component/share/service"
+)
+
+func RegisterShareRoutes(appObj *app.App) {
+
+	shareService := service.NewShareService()
+
+	shareController := shareCtrl.NewShareController(shareService)
+
+	shareController.RegisterRoutes(appObj.Router)
+}
@@ -0,0 +1,16 @@
+package modules
+
+import (
+	"Contact_App/app"
+	taskCtrl "Contact_App/component/task/controller"
+	"Contact_App/component/task/service"
+)
+
+func RegisterTaskRoutes(appObj *app.App) {
+
+	taskService := service.NewTaskService()
+
+	taskController := taskCtrl.NewTaskController(taskService)
+
+	taskController.RegisterRoutes(appObj.Router)
+}
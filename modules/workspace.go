@@ -0,0 +1,16 @@
+package modules
+
+import (
+	"Contact_App/app"
+	workspaceCtrl "Contact_App/component/workspace/controller"
+	"Contact_App/component/workspace/service"
+)
+
+func RegisterWorkspaceRoutes(appObj *app.App) {
+
+	workspaceService := service.NewWorkspaceService()
+
+	workspaceController := workspaceCtrl.NewWorkspaceController(workspaceService)
+
+	workspaceController.RegisterRoutes(appObj.Router)
+}
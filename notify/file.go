@@ -0,0 +1,42 @@
+package notify
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileNotifier appends notifications to a file as JSON lines, for a mail
+// relay or another process to pick up
+type FileNotifier struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func NewFileNotifier(path string) (*FileNotifier, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return &FileNotifier{Path: path}, nil
+}
+
+func (n *FileNotifier) Notify(notification *Notification) error {
+	line, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
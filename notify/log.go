@@ -0,0 +1,15 @@
+package notify
+
+import "log"
+
+// LogNotifier writes notifications to the application log
+type LogNotifier struct{}
+
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) Notify(notification *Notification) error {
+	log.Printf("Notification [%s] for user %d: %s\n", notification.Kind, notification.UserID, notification.Subject)
+	return nil
+}
@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notification is a message for one user, such as a task reminder. Data
+// carries the ids a client needs to act on it.
+type Notification struct {
+	Kind      string                 `json:"kind"`
+	UserID    uint                   `json:"user_id"`
+	Subject   string                 `json:"subject"`
+	Body      string                 `json:"body,omitempty"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// Notifier delivers notifications. Implementations must be safe for
+// concurrent use; an error means the notification was not delivered and
+// may be sent again.
+type Notifier interface {
+	Notify(n *Notification) error
+}
+
+var (
+	defaultNotifier Notifier
+	defaultOnce     sync.Once
+)
+
+// Default returns the process-wide notifier chosen by NOTIFIER: "log" (the
+// default), "file", appending to NOTIFIER_FILE (default
+// "data/notifications.log"), or "webhook", posting to NOTIFIER_WEBHOOK_URL
+func Default() Notifier {
+	defaultOnce.Do(func() {
+		notifier, err := fromEnv()
+		if err != nil {
+			log.Fatalf("notifier init failed: %v", err)
+		}
+		defaultNotifier = notifier
+	})
+	return defaultNotifier
+}
+
+// SetDefault replaces the process-wide notifier, e.g. with an email or push implementation
+func SetDefault(notifier Notifier) {
+	defaultOnce.Do(func() {})
+	defaultNotifier = notifier
+}
+
+func fromEnv() (Notifier, error) {
+	switch kind := strings.ToLower(strings.TrimSpace(os.Getenv("NOTIFIER"))); kind {
+	case "", "log":
+		return NewLogNotifier(), nil
+	case "file":
+		path := os.Getenv("NOTIFIER_FILE")
+		if path == "" {
+			path = "data/notifications.log"
+		}
+		return NewFileNotifier(path)
+	case "webhook":
+		return NewWebhookNotifier(os.Getenv("NOTIFIER_WEBHOOK_URL"), os.Getenv("NOTIFIER_WEBHOOK_SECRET"))
+	default:
+		return nil, &ConfigError{"unknown NOTIFIER " + kind}
+	}
+}
+
+// ConfigError reports a notifier that cannot be set up as configured
+type ConfigError struct {
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body, keyed
+// with the webhook secret, so receivers can check where a call came from
+const SignatureHeader = "X-Contact-App-Signature"
+
+// WebhookNotifier posts each notification as JSON to a URL. Any status
+// other than 2xx counts as a failed delivery.
+type WebhookNotifier struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+func NewWebhookNotifier(target, secret string) (*WebhookNotifier, error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, &ConfigError{"NOTIFIER_WEBHOOK_URL must be an http or https URL"}
+	}
+	return &WebhookNotifier{URL: target, Secret: secret, Client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+func (n *WebhookNotifier) Notify(notification *Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(n.Secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
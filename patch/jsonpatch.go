@@ -0,0 +1,341 @@
+package patch
+
+import (
+	"Contact_App/apperror"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Operation is a single RFC 6902 step. Value stays raw so a missing value can
+// be told apart from an explicit null.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// applyJSONPatch runs every operation in order against doc. Any failing
+// operation aborts the whole patch, so the caller never sees a partial result.
+func applyJSONPatch(doc interface{}, body []byte) (interface{}, error) {
+	var ops []Operation
+	if err := json.Unmarshal(body, &ops); err != nil {
+		return nil, apperror.NewValidationError("patch", "JSON Patch must be an array of operations")
+	}
+
+	for i, op := range ops {
+		var err error
+		doc, err = applyOperation(doc, op)
+		if err != nil {
+			return nil, wrapOperationError(i, op, err)
+		}
+	}
+	return doc, nil
+}
+
+func applyOperation(doc interface{}, op Operation) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		value, err := operationValue(op)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "remove":
+		return remove(doc, path)
+	case "replace":
+		value, err := operationValue(op)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := get(doc, path); err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		return update(doc, path, func(parent interface{}, key string) (interface{}, error) {
+			switch p := parent.(type) {
+			case map[string]interface{}:
+				p[key] = value
+				return p, nil
+			case []interface{}:
+				i, err := arrayIndex(key, len(p), false)
+				if err != nil {
+					return nil, err
+				}
+				p[i] = value
+				return p, nil
+			}
+			return nil, fmt.Errorf("cannot replace %q in a scalar", key)
+		})
+	case "move":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if isProperPrefix(from, path) {
+			return nil, fmt.Errorf("cannot move a value into one of its own children")
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if doc, err = remove(doc, from); err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		copied, err := toGeneric(value)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, copied)
+	case "test":
+		value, err := operationValue(op)
+		if err != nil {
+			return nil, err
+		}
+		current, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(current, value) {
+			return nil, errTestFailed
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown op %q", op.Op)
+}
+
+var errTestFailed = fmt.Errorf("test failed")
+
+func wrapOperationError(index int, op Operation, err error) error {
+	if err == errTestFailed {
+		return apperror.NewConflictError("patch", fmt.Sprintf("operation %d: test of %q failed", index, op.Path))
+	}
+	return apperror.NewValidationError("patch", fmt.Sprintf("operation %d (%s %q): %v", index, op.Op, op.Path, err))
+}
+
+func operationValue(op Operation) (interface{}, error) {
+	if op.Value == nil {
+		return nil, fmt.Errorf("missing value")
+	}
+	var value interface{}
+	if err := decodeJSON(op.Value, &value); err != nil {
+		return nil, fmt.Errorf("invalid value")
+	}
+	return value, nil
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("pointer %q must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func get(doc interface{}, path []string) (interface{}, error) {
+	node := doc
+	for _, key := range path {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			child, ok := n[key]
+			if !ok {
+				return nil, fmt.Errorf("path member %q does not exist", key)
+			}
+			node = child
+		case []interface{}:
+			i, err := arrayIndex(key, len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("cannot descend into scalar at %q", key)
+		}
+	}
+	return node, nil
+}
+
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return update(doc, path, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			p[key] = value
+			return p, nil
+		case []interface{}:
+			i, err := arrayIndex(key, len(p), true)
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, nil)
+			copy(p[i+1:], p[i:])
+			p[i] = value
+			return p, nil
+		}
+		return nil, fmt.Errorf("cannot add %q to a scalar", key)
+	})
+}
+
+func remove(doc interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("cannot remove the whole document")
+	}
+	return update(doc, path, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := p[key]; !ok {
+				return nil, fmt.Errorf("path member %q does not exist", key)
+			}
+			delete(p, key)
+			return p, nil
+		case []interface{}:
+			i, err := arrayIndex(key, len(p), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(p[:i], p[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("cannot remove %q from a scalar", key)
+	})
+}
+
+// update walks to the parent of the last token and lets fn rewrite it. The
+// rewritten parent is stored back on the way up because slices may have been
+// reallocated.
+func update(node interface{}, path []string, fn func(parent interface{}, key string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("path member %q does not exist", path[0])
+		}
+		updated, err := update(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[path[0]] = updated
+		return n, nil
+	case []interface{}:
+		i, err := arrayIndex(path[0], len(n), false)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := update(n[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = updated
+		return n, nil
+	}
+	return nil, fmt.Errorf("cannot descend into scalar at %q", path[0])
+}
+
+// arrayIndex parses an array reference token. "-" (one past the end) and
+// index == length are only valid when adding.
+func arrayIndex(token string, length int, forAdd bool) (int, error) {
+	if token == "-" {
+		if forAdd {
+			return length, nil
+		}
+		return 0, fmt.Errorf("index - is only valid for add")
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+
+	limit := length - 1
+	if forAdd {
+		limit = length
+	}
+	if i > limit {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+func isProperPrefix(prefix, path []string) bool {
+	if len(prefix) >= len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// equal compares two decoded JSON values, treating numbers by value so 1 and 1.0 match
+func equal(a, b interface{}) bool {
+	switch av := a.(type) {
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		af, errA := av.Float64()
+		bf, errB := bv.Float64()
+		return errA == nil && errB == nil && af == bf
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			other, ok := bv[k]
+			if !ok || !equal(v, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !equal(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
@@ -0,0 +1,111 @@
+package patch
+
+import (
+	"Contact_App/apperror"
+	"bytes"
+	"encoding/json"
+	"mime"
+	"strings"
+)
+
+const (
+	MediaTypeMergePatch = "application/merge-patch+json"
+	MediaTypeJSONPatch  = "application/json-patch+json"
+)
+
+// Apply patches document with body, interpreting body according to the
+// request's Content-Type; plain application/json is read as a merge patch.
+// The document is any value that marshals to a JSON object; the patched object
+// is returned as a generic map ready for Schema.Validate.
+func Apply(contentType string, document interface{}, body []byte) (map[string]interface{}, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.TrimSpace(contentType)
+	}
+
+	target, err := toGeneric(document)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	switch mediaType {
+	case MediaTypeMergePatch, "application/json":
+		result, err = applyMergePatch(target, body)
+	case MediaTypeJSONPatch:
+		result, err = applyJSONPatch(target, body)
+	default:
+		return nil, apperror.NewUnsupportedMediaTypeError(mediaType, MediaTypeMergePatch, MediaTypeJSONPatch)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	obj, ok := result.(map[string]interface{})
+	if !ok {
+		return nil, apperror.NewValidationError("patch", "result must be a JSON object")
+	}
+	return obj, nil
+}
+
+// Decode copies a validated patch result into out, typically a struct of
+// pointer fields so callers can tell explicit nulls from absent members
+func Decode(doc map[string]interface{}, out interface{}) error {
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return apperror.NewInternalError("failed to encode patched document")
+	}
+	if err := json.Unmarshal(encoded, out); err != nil {
+		return apperror.NewValidationError("patch", err.Error())
+	}
+	return nil
+}
+
+// applyMergePatch implements RFC 7396: objects merge recursively, null removes
+// a member and every other value replaces the target wholesale
+func applyMergePatch(target interface{}, body []byte) (interface{}, error) {
+	var p interface{}
+	if err := decodeJSON(body, &p); err != nil {
+		return nil, apperror.NewValidationError("patch", "invalid merge patch JSON")
+	}
+	return mergePatch(target, p), nil
+}
+
+func mergePatch(target, p interface{}) interface{} {
+	patchObj, ok := p.(map[string]interface{})
+	if !ok {
+		return p
+	}
+
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+	return targetObj
+}
+
+// toGeneric round-trips v through JSON so patches operate on plain maps and slices
+func toGeneric(v interface{}) (interface{}, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, apperror.NewInternalError("failed to encode document for patching")
+	}
+	var out interface{}
+	if err := decodeJSON(encoded, &out); err != nil {
+		return nil, apperror.NewInternalError("failed to decode document for patching")
+	}
+	return out, nil
+}
+
+func decodeJSON(data []byte, out interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(out)
+}
@@ -0,0 +1,230 @@
+package patch
+
+import (
+	"Contact_App/apperror"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type document struct {
+	FirstName string            `json:"first_name"`
+	LastName  string            `json:"last_name"`
+	Favorite  bool              `json:"is_favorite"`
+	Tags      []string          `json:"tags"`
+	Custom    map[string]string `json:"custom_fields"`
+}
+
+func testDocument() document {
+	return document{
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		Tags:      []string{"math", "poetry"},
+		Custom:    map[string]string{"team": "engines", "pet": "cat"},
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{
+			name:        "merge patch replaces a member",
+			contentType: MediaTypeMergePatch,
+			body:        `{"first_name":"Augusta"}`,
+			want:        `{"first_name":"Augusta","last_name":"Lovelace","is_favorite":false,"tags":["math","poetry"],"custom_fields":{"team":"engines","pet":"cat"}}`,
+		},
+		{
+			name:        "merge patch merges objects and removes nulls",
+			contentType: MediaTypeMergePatch + "; charset=utf-8",
+			body:        `{"custom_fields":{"pet":null,"city":"London"},"last_name":null}`,
+			want:        `{"first_name":"Ada","is_favorite":false,"tags":["math","poetry"],"custom_fields":{"team":"engines","city":"London"}}`,
+		},
+		{
+			name:        "merge patch replaces arrays wholesale",
+			contentType: MediaTypeMergePatch,
+			body:        `{"tags":["computing"]}`,
+			want:        `{"first_name":"Ada","last_name":"Lovelace","is_favorite":false,"tags":["computing"],"custom_fields":{"team":"engines","pet":"cat"}}`,
+		},
+		{
+			name:        "plain json is a merge patch",
+			contentType: "application/json",
+			body:        `{"is_favorite":true}`,
+			want:        `{"first_name":"Ada","last_name":"Lovelace","is_favorite":true,"tags":["math","poetry"],"custom_fields":{"team":"engines","pet":"cat"}}`,
+		},
+		{
+			name:        "json patch operations in order",
+			contentType: MediaTypeJSONPatch,
+			body: `[
+				{"op":"test","path":"/first_name","value":"Ada"},
+				{"op":"replace","path":"/first_name","value":"Augusta"},
+				{"op":"add","path":"/tags/-","value":"engines"},
+				{"op":"remove","path":"/tags/0"},
+				{"op":"remove","path":"/custom_fields/pet"}
+			]`,
+			want: `{"first_name":"Augusta","last_name":"Lovelace","is_favorite":false,"tags":["poetry","engines"],"custom_fields":{"team":"engines"}}`,
+		},
+		{
+			name:        "json patch copy and move",
+			contentType: MediaTypeJSONPatch,
+			body: `[
+				{"op":"copy","from":"/last_name","path":"/custom_fields/maiden_name"},
+				{"op":"move","from":"/tags/1","path":"/tags/0"}
+			]`,
+			want: `{"first_name":"Ada","last_name":"Lovelace","is_favorite":false,"tags":["poetry","math"],"custom_fields":{"team":"engines","pet":"cat","maiden_name":"Lovelace"}}`,
+		},
+		{
+			name:        "json patch pointer escapes",
+			contentType: MediaTypeJSONPatch,
+			body:        `[{"op":"add","path":"/custom_fields/a~1b~0c","value":"x"}]`,
+			want:        `{"first_name":"Ada","last_name":"Lovelace","is_favorite":false,"tags":["math","poetry"],"custom_fields":{"team":"engines","pet":"cat","a/b~c":"x"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply(tt.contentType, testDocument(), []byte(tt.body))
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			var want map[string]interface{}
+			if err := decodeJSON([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Apply() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{"unsupported media type", "text/plain", `{}`, http.StatusUnsupportedMediaType},
+		{"invalid merge patch", MediaTypeMergePatch, `{`, http.StatusBadRequest},
+		{"merge patch result not an object", MediaTypeMergePatch, `[1]`, http.StatusBadRequest},
+		{"json patch not an array", MediaTypeJSONPatch, `{}`, http.StatusBadRequest},
+		{"json patch unknown op", MediaTypeJSONPatch, `[{"op":"frobnicate","path":"/first_name"}]`, http.StatusBadRequest},
+		{"json patch failed test", MediaTypeJSONPatch, `[{"op":"test","path":"/first_name","value":"Grace"}]`, http.StatusConflict},
+		{"json patch missing member", MediaTypeJSONPatch, `[{"op":"remove","path":"/nickname"}]`, http.StatusBadRequest},
+		{"json patch index out of range", MediaTypeJSONPatch, `[{"op":"add","path":"/tags/5","value":"x"}]`, http.StatusBadRequest},
+		{"json patch move into own child", MediaTypeJSONPatch, `[{"op":"move","from":"/custom_fields","path":"/custom_fields/inner"}]`, http.StatusBadRequest},
+		{"json patch bad pointer", MediaTypeJSONPatch, `[{"op":"replace","path":"first_name","value":"x"}]`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Apply(tt.contentType, testDocument(), []byte(tt.body))
+			if err == nil {
+				t.Fatal("Apply() succeeded, want an error")
+			}
+			appErr, ok := err.(apperror.AppError)
+			if !ok {
+				t.Fatalf("Apply() error = %T, want an apperror.AppError", err)
+			}
+			if appErr.StatusCode() != tt.wantStatus {
+				t.Errorf("Apply() status = %d, want %d (%v)", appErr.StatusCode(), tt.wantStatus, err)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	type input struct {
+		FirstName *string `json:"first_name"`
+		LastName  *string `json:"last_name"`
+		Favorite  *bool   `json:"is_favorite"`
+	}
+	name, yes := "Ada", true
+	tests := []struct {
+		name    string
+		doc     string
+		want    input
+		wantErr bool
+	}{
+		{"all present", `{"first_name":"Ada","last_name":null,"is_favorite":true}`, input{FirstName: &name, Favorite: &yes}, false},
+		{"absent members stay nil", `{}`, input{}, false},
+		{"wrong type", `{"is_favorite":"yes"}`, input{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+				t.Fatal(err)
+			}
+			var got input
+			err := Decode(doc, &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema := Schema{
+		"first_name":  {Kind: KindString, Required: true, MaxLength: 5},
+		"last_name":   {Kind: KindString, Nullable: true},
+		"is_favorite": {Kind: KindBool},
+		"age":         {Kind: KindNumber, Nullable: true},
+		"custom":      {Kind: KindObject, Nullable: true},
+		"details": {Kind: KindArray, Items: Schema{
+			"type":  {Kind: KindString, Required: true},
+			"value": {Kind: KindString, Required: true},
+		}},
+	}
+	tests := []struct {
+		name      string
+		doc       string
+		wantField string
+	}{
+		{"valid", `{"first_name":"Ada","last_name":null,"is_favorite":true,"age":36,"custom":{},"details":[{"type":"email","value":"a@b.c"}]}`, ""},
+		{"only required", `{"first_name":"Ada"}`, ""},
+		{"unknown member", `{"first_name":"Ada","nickname":"x"}`, "patch"},
+		{"required missing", `{"last_name":"L"}`, "first_name"},
+		{"required null", `{"first_name":null}`, "first_name"},
+		{"required blank", `{"first_name":"  "}`, "first_name"},
+		{"too long", `{"first_name":"Augusta"}`, "first_name"},
+		{"null not allowed", `{"first_name":"Ada","is_favorite":null}`, "is_favorite"},
+		{"not a string", `{"first_name":1}`, "first_name"},
+		{"not a bool", `{"first_name":"Ada","is_favorite":"yes"}`, "is_favorite"},
+		{"not a number", `{"first_name":"Ada","age":"36"}`, "age"},
+		{"not an object", `{"first_name":"Ada","custom":[]}`, "custom"},
+		{"not an array", `{"first_name":"Ada","details":{}}`, "details"},
+		{"item not an object", `{"first_name":"Ada","details":["x"]}`, "details[0]"},
+		{"item member missing", `{"first_name":"Ada","details":[{"type":"email","value":"a"},{"type":"phone"}]}`, "details[1].value"},
+		{"item unknown member", `{"first_name":"Ada","details":[{"type":"email","value":"a","label":"work"}]}`, "patch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc map[string]interface{}
+			if err := decodeJSON([]byte(tt.doc), &doc); err != nil {
+				t.Fatal(err)
+			}
+			err := schema.Validate(doc)
+			if tt.wantField == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			appErr, ok := err.(apperror.AppError)
+			if !ok {
+				t.Fatalf("Validate() error = %v, want a validation error on %s", err, tt.wantField)
+			}
+			if appErr.ErrorContext() != tt.wantField {
+				t.Errorf("Validate() error on %q, want %q (%v)", appErr.ErrorContext(), tt.wantField, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package patch
+
+import (
+	"Contact_App/apperror"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Kind int
+
+const (
+	KindString Kind = iota
+	KindBool
+	KindNumber
+	KindArray
+	KindObject
+)
+
+// Field describes one member of a patchable document. Required members may
+// be neither removed nor set to null; Nullable members may be null or absent,
+// which callers treat as clearing the value.
+type Field struct {
+	Kind      Kind
+	Required  bool
+	Nullable  bool
+	MaxLength int
+	Items     Schema
+}
+
+// Schema lists every member a patched document may contain
+type Schema map[string]Field
+
+// Validate checks a patched document against the schema: unknown members,
+// wrong types, missing required members and over-long strings are rejected
+func (s Schema) Validate(doc map[string]interface{}) error {
+	return s.validate(doc, "")
+}
+
+func (s Schema) validate(doc map[string]interface{}, prefix string) error {
+	unknown := []string{}
+	for key := range doc {
+		if _, ok := s[key]; !ok {
+			unknown = append(unknown, prefix+key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return apperror.NewValidationError("patch", "unknown field(s) "+strings.Join(unknown, ", "))
+	}
+
+	for name, field := range s {
+		value, present := doc[name]
+		if !present || value == nil {
+			if field.Required {
+				return apperror.NewValidationError(prefix+name, "is required and cannot be null")
+			}
+			if present && !field.Nullable {
+				return apperror.NewValidationError(prefix+name, "cannot be null")
+			}
+			continue
+		}
+		if err := field.check(value, prefix+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f Field) check(value interface{}, name string) error {
+	switch f.Kind {
+	case KindString:
+		str, ok := value.(string)
+		if !ok {
+			return apperror.NewValidationError(name, "must be a string")
+		}
+		if f.Required && strings.TrimSpace(str) == "" {
+			return apperror.NewValidationError(name, "cannot be empty")
+		}
+		if f.MaxLength > 0 && len([]rune(str)) > f.MaxLength {
+			return apperror.NewValidationError(name, fmt.Sprintf("must be at most %d characters", f.MaxLength))
+		}
+	case KindBool:
+		if _, ok := value.(bool); !ok {
+			return apperror.NewValidationError(name, "must be a boolean")
+		}
+	case KindNumber:
+		if _, ok := value.(json.Number); !ok {
+			return apperror.NewValidationError(name, "must be a number")
+		}
+	case KindObject:
+		// free-form members; the caller validates them
+		if _, ok := value.(map[string]interface{}); !ok {
+			return apperror.NewValidationError(name, "must be an object")
+		}
+	case KindArray:
+		items, ok := value.([]interface{})
+		if !ok {
+			return apperror.NewValidationError(name, "must be an array")
+		}
+		if f.Items == nil {
+			return nil
+		}
+		for i, item := range items {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				return apperror.NewValidationError(fmt.Sprintf("%s[%d]", name, i), "must be an object")
+			}
+			if err := f.Items.validate(obj, fmt.Sprintf("%s[%d].", name, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -28,6 +28,9 @@ type UnitOfWork struct {
 	DB        *gorm.DB
 	Committed bool
 	Readonly  bool
+
+	afterCommit   []func()
+	afterRollback []func()
 }
 
 func NewUnitOfWork(db *gorm.DB, readonly bool) *UnitOfWork {
@@ -49,12 +52,52 @@ func (uow *UnitOfWork) Commit() {
 	if !uow.Readonly && !uow.Committed {
 		uow.Committed = true
 		uow.DB.Commit()
+		for _, fn := range uow.afterCommit {
+			fn()
+		}
+		uow.afterCommit, uow.afterRollback = nil, nil
+	}
+}
+
+// AfterCommit registers fn to run once the transaction has been committed.
+// Callbacks are dropped on rollback.
+func (uow *UnitOfWork) AfterCommit(fn func()) {
+	uow.afterCommit = append(uow.afterCommit, fn)
+}
+
+// AfterRollback registers fn to run if the transaction is rolled back rather
+// than committed, to undo work done outside the database. Callbacks are
+// dropped on commit.
+func (uow *UnitOfWork) AfterRollback(fn func()) {
+	uow.afterRollback = append(uow.afterRollback, fn)
+}
+
+// SavePoint marks a point inside the transaction that RollbackTo can return to
+func (uow *UnitOfWork) SavePoint(name string) error {
+	if uow.Readonly {
+		return apperror.NewInternalError("cannot set a savepoint on a readonly unit of work")
+	}
+	if err := uow.DB.SavePoint(name).Error; err != nil {
+		return apperror.NewInternalError("failed to create savepoint: " + err.Error())
 	}
+	return nil
+}
+
+// RollbackTo undoes everything done since the named savepoint while keeping the transaction open
+func (uow *UnitOfWork) RollbackTo(name string) error {
+	if err := uow.DB.RollbackTo(name).Error; err != nil {
+		return apperror.NewInternalError("failed to roll back to savepoint: " + err.Error())
+	}
+	return nil
 }
 
 func (uow *UnitOfWork) Rollback() {
 	if !uow.Committed && !uow.Readonly {
 		uow.DB.Rollback()
+		for _, fn := range uow.afterRollback {
+			fn()
+		}
+		uow.afterRollback = nil
 	}
 }
 
@@ -106,6 +149,12 @@ func Select(fields string) QueryProcessor {
 	}
 }
 
+func OrderBy(order string) QueryProcessor {
+	return func(db *gorm.DB, out interface{}) (*gorm.DB, error) {
+		return db.Order(order), nil
+	}
+}
+
 func applyQueryProcessors(db *gorm.DB, out interface{}, processors ...QueryProcessor) (*gorm.DB, error) {
 	var err error
 	for _, process := range processors {
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"Contact_App/apperror"
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Tenant is what a scoped unit of work may see: rows of tenant-scoped models
+// are only visible when they belong to no workspace or to one of WorkspaceIDs
+type Tenant struct {
+	UserID       uint
+	WorkspaceIDs []uint
+}
+
+type tenantKey struct{}
+
+// tenantTables are the tables registered with RegisterTenantScope
+var tenantTables = map[string]bool{}
+
+// ScopeTo limits every query run through the unit of work to tenant
+func (uow *UnitOfWork) ScopeTo(tenant *Tenant) *UnitOfWork {
+	uow.DB = uow.DB.WithContext(context.WithValue(statementContext(uow.DB), tenantKey{}, tenant))
+	return uow
+}
+
+// WithoutTenant lifts the tenant scope, for work such as background jobs or
+// building another user's search index that must see rows of every tenant.
+// Statements on tenant-scoped models fail unless they are scoped or run
+// through WithoutTenant.
+func WithoutTenant(db *gorm.DB) *gorm.DB {
+	return db.WithContext(context.WithValue(statementContext(db), tenantKey{}, (*Tenant)(nil)))
+}
+
+// RegisterTenantScope installs the callbacks that enforce tenant scopes on db
+// for models, each of which has a workspace_id column. Every statement on
+// those models must come from a unit of work scoped with ScopeTo, or opt out
+// with WithoutTenant.
+func RegisterTenantScope(db *gorm.DB, models ...interface{}) error {
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		if stmt.Schema.LookUpField("workspace_id") == nil {
+			return fmt.Errorf("%s has no workspace_id column", stmt.Schema.Table)
+		}
+		tenantTables[stmt.Schema.Table] = true
+	}
+
+	callbacks := db.Callback()
+	if err := callbacks.Query().Before("gorm:query").Register("tenant:query", scopeToTenant); err != nil {
+		return err
+	}
+	if err := callbacks.Row().Before("gorm:row").Register("tenant:row", scopeToTenant); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("tenant:update", scopeToTenant); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register("tenant:delete", scopeToTenant); err != nil {
+		return err
+	}
+	return callbacks.Create().Before("gorm:create").Register("tenant:create", checkTenant)
+}
+
+func statementContext(db *gorm.DB) context.Context {
+	if db.Statement.Context != nil {
+		return db.Statement.Context
+	}
+	return context.Background()
+}
+
+// tenantField returns the statement's tenant and the workspace_id field of
+// its model, or nils when the statement is not scoped. A statement on a
+// tenant-scoped model that neither carries a tenant nor opted out with
+// WithoutTenant is refused.
+func tenantField(db *gorm.DB) (*Tenant, *schema.Field) {
+	if db.Statement.Schema == nil || !tenantTables[db.Statement.Schema.Table] {
+		return nil, nil
+	}
+	value := statementContext(db).Value(tenantKey{})
+	if value == nil {
+		db.AddError(apperror.NewInternalError("query on " + db.Statement.Schema.Table + " outside a tenant scope"))
+		return nil, nil
+	}
+	tenant, _ := value.(*Tenant)
+	if tenant == nil {
+		return nil, nil
+	}
+	return tenant, db.Statement.Schema.LookUpField("workspace_id")
+}
+
+func scopeToTenant(db *gorm.DB) {
+	tenant, field := tenantField(db)
+	if tenant == nil {
+		return
+	}
+
+	column := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+	ids := make([]interface{}, len(tenant.WorkspaceIDs))
+	for i, id := range tenant.WorkspaceIDs {
+		ids[i] = id
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Or(clause.Eq{Column: column, Value: nil}, clause.IN{Column: column, Values: ids}),
+	}})
+}
+
+// checkTenant refuses to create rows in a workspace outside the tenant
+func checkTenant(db *gorm.DB) {
+	tenant, field := tenantField(db)
+	if tenant == nil {
+		return
+	}
+
+	allowed := make(map[uint]bool, len(tenant.WorkspaceIDs))
+	for _, id := range tenant.WorkspaceIDs {
+		allowed[id] = true
+	}
+	check := func(rv reflect.Value) {
+		value, zero := field.ValueOf(db.Statement.Context, rv)
+		if zero {
+			return
+		}
+		if id, ok := workspaceID(value); ok && !allowed[id] {
+			db.AddError(apperror.NewForbiddenError("workspace", "you are not a member of this workspace"))
+		}
+	}
+
+	rv := reflect.Indirect(db.Statement.ReflectValue)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			check(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		check(rv)
+	}
+}
+
+func workspaceID(value interface{}) (uint, bool) {
+	switch v := value.(type) {
+	case uint:
+		return v, true
+	case *uint:
+		if v != nil {
+			return *v, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,83 @@
+package repository_test
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/db/dbtest"
+	"Contact_App/models/contact"
+	"Contact_App/models/user"
+	"Contact_App/models/workspace"
+	"Contact_App/repository"
+	"net/http"
+	"testing"
+)
+
+// A scoped unit of work sees personal contacts and those of its workspaces,
+// and cannot reach into any other workspace.
+func TestTenantScope(t *testing.T) {
+	conn := dbtest.Open(t)
+	member := dbtest.User(t, conn, "member@example.com")
+	outsider := dbtest.User(t, conn, "outsider@example.com")
+	mine := dbtest.Workspace(t, conn, "Mine", map[*user.User]string{member: workspace.RoleAdmin})
+	theirs := dbtest.Workspace(t, conn, "Theirs", map[*user.User]string{outsider: workspace.RoleAdmin})
+
+	personal := dbtest.Contact(t, conn, member, nil, "Personal", "Contact")
+	inMine := dbtest.Contact(t, conn, member, &mine.WorkspaceID, "Mine", "Contact")
+	inTheirs := dbtest.Contact(t, conn, outsider, &theirs.WorkspaceID, "Theirs", "Contact")
+
+	tenant := &repository.Tenant{UserID: member.UserID, WorkspaceIDs: []uint{mine.WorkspaceID}}
+	scoped := func() *repository.UnitOfWork {
+		return repository.NewUnitOfWork(conn, false).ScopeTo(tenant)
+	}
+
+	t.Run("unscoped statements are refused", func(t *testing.T) {
+		var contacts []*contact.Contact
+		if err := conn.Find(&contacts).Error; err == nil {
+			t.Fatal("query outside a tenant scope succeeded")
+		}
+	})
+
+	t.Run("WithoutTenant sees every workspace", func(t *testing.T) {
+		var count int64
+		if err := repository.WithoutTenant(conn).Model(&contact.Contact{}).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		if count != 3 {
+			t.Errorf("count = %d, want 3", count)
+		}
+	})
+
+	t.Run("queries see personal and own workspace contacts", func(t *testing.T) {
+		uow := scoped()
+		defer uow.Rollback()
+		var ids []uint
+		if err := uow.DB.Model(&contact.Contact{}).Order("contact_id").Pluck("contact_id", &ids).Error; err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) != 2 || ids[0] != personal.ContactID || ids[1] != inMine.ContactID {
+			t.Errorf("visible contacts = %v, want [%d %d]", ids, personal.ContactID, inMine.ContactID)
+		}
+	})
+
+	t.Run("updates and deletes skip other workspaces", func(t *testing.T) {
+		uow := scoped()
+		defer uow.Rollback()
+		result := uow.DB.Model(&contact.Contact{}).Where("contact_id = ?", inTheirs.ContactID).Update("f_name", "Taken")
+		if result.Error != nil || result.RowsAffected != 0 {
+			t.Errorf("update = %d rows, %v; want none", result.RowsAffected, result.Error)
+		}
+		result = uow.DB.Where("contact_id = ?", inTheirs.ContactID).Delete(&contact.Contact{})
+		if result.Error != nil || result.RowsAffected != 0 {
+			t.Errorf("delete = %d rows, %v; want none", result.RowsAffected, result.Error)
+		}
+	})
+
+	t.Run("creates in another workspace are forbidden", func(t *testing.T) {
+		uow := scoped()
+		defer uow.Rollback()
+		err := uow.DB.Create(&contact.Contact{UserID: member.UserID, WorkspaceID: &theirs.WorkspaceID, FName: "Planted", LName: "Contact", IsActive: true}).Error
+		appErr, ok := err.(apperror.AppError)
+		if !ok || appErr.StatusCode() != http.StatusForbidden {
+			t.Fatalf("create error = %v, want forbidden", err)
+		}
+	})
+}
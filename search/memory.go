@@ -0,0 +1,305 @@
+package search
+
+import (
+	"Contact_App/helper"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// match qualities, multiplied by the field boost
+const (
+	exactMatch    = 1.0
+	prefixMatch   = 0.75
+	digitsMatch   = 0.7
+	fuzzyMatch    = 0.6
+	phoneticMatch = 0.5
+)
+
+// MemoryIndex is the built-in in-process Index. It keeps an inverted index
+// per owner and supports exact, prefix, typo-tolerant and phonetic matches.
+type MemoryIndex struct {
+	mu          sync.RWMutex
+	owners      map[uint]*shard
+	generations map[uint]uint64
+}
+
+type shard struct {
+	docs     map[uint]Document
+	postings map[string]map[uint]map[string]bool // term -> doc -> fields
+	phonetic map[string]map[string]bool          // soundex/metaphone code -> terms
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{owners: make(map[uint]*shard), generations: make(map[uint]uint64)}
+}
+
+func newShard() *shard {
+	return &shard{
+		docs:     make(map[uint]Document),
+		postings: make(map[string]map[uint]map[string]bool),
+		phonetic: make(map[string]map[string]bool),
+	}
+}
+
+func (m *MemoryIndex) Indexed(ownerID uint) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.owners[ownerID]
+	return ok
+}
+
+func (m *MemoryIndex) Generation(ownerID uint) uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.generations[ownerID]
+}
+
+func (m *MemoryIndex) Replace(ownerID uint, generation uint64, docs []Document) bool {
+	s := newShard()
+	for _, doc := range docs {
+		s.add(doc)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.generations[ownerID] != generation {
+		return false
+	}
+	m.owners[ownerID] = s
+	return true
+}
+
+func (m *MemoryIndex) Put(doc Document) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.owners[doc.OwnerID]
+	if !ok {
+		// the owner is loaded lazily in full; a partial shard would hide documents
+		return
+	}
+	s.remove(doc.ID)
+	s.add(doc)
+}
+
+func (m *MemoryIndex) Remove(ownerID, id uint) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.owners[ownerID]; ok {
+		s.remove(id)
+	}
+}
+
+func (m *MemoryIndex) Invalidate(ownerID uint) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.owners, ownerID)
+	m.generations[ownerID]++
+}
+
+func (m *MemoryIndex) Search(ownerID uint, query string) []Hit {
+	terms := Tokenize(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, ok := m.owners[ownerID]
+	if !ok {
+		return nil
+	}
+
+	scores := make(map[uint]float64)
+	matchedTerms := make(map[uint]map[string]map[string]bool) // doc -> field -> indexed terms
+	for i, term := range terms {
+		best := s.matchTerm(term)
+		for docID, fields := range best {
+			if i > 0 {
+				if _, ok := scores[docID]; !ok {
+					continue
+				}
+			}
+			top := 0.0
+			for field, fm := range fields {
+				top = max(top, fm.quality*fieldBoost(field))
+				if matchedTerms[docID] == nil {
+					matchedTerms[docID] = make(map[string]map[string]bool)
+				}
+				if matchedTerms[docID][field] == nil {
+					matchedTerms[docID][field] = make(map[string]bool)
+				}
+				for t := range fm.terms {
+					matchedTerms[docID][field][t] = true
+				}
+			}
+			scores[docID] += top
+		}
+		// every query word must match: drop documents this word missed
+		for docID := range scores {
+			if _, ok := best[docID]; !ok {
+				delete(scores, docID)
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for docID, score := range scores {
+		hits = append(hits, Hit{
+			ID:         docID,
+			Score:      float64(int(score*100+0.5)) / 100,
+			Highlights: s.highlights(docID, matchedTerms[docID]),
+		})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	return hits
+}
+
+type fieldMatch struct {
+	quality float64
+	terms   map[string]bool
+}
+
+// matchTerm finds, for every document, the best quality match of term in each field
+func (s *shard) matchTerm(term string) map[uint]map[string]*fieldMatch {
+	result := make(map[uint]map[string]*fieldMatch)
+	record := func(indexed string, quality float64) {
+		for docID, fields := range s.postings[indexed] {
+			if result[docID] == nil {
+				result[docID] = make(map[string]*fieldMatch)
+			}
+			for field := range fields {
+				fm := result[docID][field]
+				if fm == nil {
+					fm = &fieldMatch{terms: make(map[string]bool)}
+					result[docID][field] = fm
+				}
+				fm.quality = max(fm.quality, quality)
+				fm.terms[indexed] = true
+			}
+		}
+	}
+
+	record(term, exactMatch)
+
+	maxEdits := 0
+	switch runes := len([]rune(term)); {
+	case runes >= 8:
+		maxEdits = 2
+	case runes >= 4:
+		maxEdits = 1
+	}
+
+	for indexed := range s.postings {
+		if indexed == term {
+			continue
+		}
+		switch {
+		case len(term) >= 2 && strings.HasPrefix(indexed, term):
+			record(indexed, prefixMatch)
+		case isDigits(term) && len(term) >= 3 && isDigits(indexed) && strings.Contains(indexed, term):
+			record(indexed, digitsMatch)
+		case maxEdits > 0 && abs(len(indexed)-len(term)) <= maxEdits && helper.Levenshtein(indexed, term) <= maxEdits:
+			record(indexed, fuzzyMatch)
+		}
+	}
+
+	if !isDigits(term) && len(term) >= 3 {
+		for _, code := range phoneticCodes(term) {
+			for indexed := range s.phonetic[code] {
+				record(indexed, phoneticMatch)
+			}
+		}
+	}
+	return result
+}
+
+func (s *shard) add(doc Document) {
+	s.docs[doc.ID] = doc
+	for field, values := range doc.Fields {
+		for _, value := range values {
+			terms := Tokenize(value)
+			if digits := digitsOnly(value); len(digits) >= 3 && len(terms) > 1 {
+				// "555-123-4567" is also searchable as "5551234567"
+				terms = append(terms, digits)
+			}
+			for _, term := range terms {
+				if s.postings[term] == nil {
+					s.postings[term] = make(map[uint]map[string]bool)
+				}
+				if s.postings[term][doc.ID] == nil {
+					s.postings[term][doc.ID] = make(map[string]bool)
+				}
+				s.postings[term][doc.ID][field] = true
+
+				if !isDigits(term) && len(term) >= 3 {
+					for _, code := range phoneticCodes(term) {
+						if s.phonetic[code] == nil {
+							s.phonetic[code] = make(map[string]bool)
+						}
+						s.phonetic[code][term] = true
+					}
+				}
+			}
+		}
+	}
+}
+
+func (s *shard) remove(docID uint) {
+	if _, ok := s.docs[docID]; !ok {
+		return
+	}
+	delete(s.docs, docID)
+	for term, docs := range s.postings {
+		delete(docs, docID)
+		if len(docs) == 0 {
+			delete(s.postings, term)
+			for _, code := range phoneticCodes(term) {
+				delete(s.phonetic[code], term)
+			}
+		}
+	}
+}
+
+func (s *shard) highlights(docID uint, matched map[string]map[string]bool) map[string][]string {
+	doc := s.docs[docID]
+	result := make(map[string][]string)
+	for field, terms := range matched {
+		for _, value := range doc.Fields[field] {
+			highlighted := Highlight(value, terms)
+			if highlighted == value && terms[digitsOnly(value)] {
+				highlighted = "<em>" + value + "</em>"
+			}
+			if highlighted != value {
+				result[field] = append(result[field], highlighted)
+			}
+		}
+	}
+	return result
+}
+
+func phoneticCodes(term string) []string {
+	var codes []string
+	if code := helper.Soundex(term); code != "" {
+		codes = append(codes, "s:"+code)
+	}
+	if code := helper.Metaphone(term); code != "" {
+		codes = append(codes, "m:"+code)
+	}
+	return codes
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
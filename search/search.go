@@ -0,0 +1,188 @@
+package search
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/models/contact"
+	"Contact_App/repository"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Document is one searchable record, such as a contact, grouped by owner.
+// Fields maps a field name ("first_name", "email", "notes", ...) to its values.
+type Document struct {
+	ID      uint
+	OwnerID uint
+	Fields  map[string][]string
+}
+
+// Hit is a matching document with its relevance score and the field values
+// that matched, with matched words wrapped in <em> tags.
+type Hit struct {
+	ID         uint                `json:"id"`
+	Score      float64             `json:"score"`
+	Highlights map[string][]string `json:"highlights"`
+}
+
+// Index is a per-owner full-text index. Implementations must be safe for
+// concurrent use.
+type Index interface {
+	// Indexed reports whether the owner's documents have been loaded.
+	Indexed(ownerID uint) bool
+	// Generation returns the owner's generation, which moves on every
+	// Invalidate. Loaders read it before reading the owner's documents.
+	Generation(ownerID uint) uint64
+	// Replace loads the complete set of documents for an owner, read at
+	// generation. The documents are dropped, and false returned, when the
+	// owner was invalidated since, as they may predate that change.
+	Replace(ownerID uint, generation uint64, docs []Document) bool
+	// Put adds or replaces a single document.
+	Put(doc Document)
+	// Remove drops a single document.
+	Remove(ownerID, id uint)
+	// Invalidate forgets everything about an owner so it is reloaded on next
+	// use, and moves its generation.
+	Invalidate(ownerID uint)
+	// Search returns the owner's documents matching every word of query,
+	// best matches first.
+	Search(ownerID uint, query string) []Hit
+}
+
+// DefaultIndex is the process-wide index shared by services.
+var DefaultIndex Index = NewMemoryIndex()
+
+// fieldBoosts weights matches by where they were found.
+var fieldBoosts = map[string]float64{
+	"first_name":   3,
+	"last_name":    3,
+	"email":        2,
+	"phone":        2,
+	"company":      2,
+	"organization": 2,
+}
+
+func fieldBoost(field string) float64 {
+	if boost, ok := fieldBoosts[field]; ok {
+		return boost
+	}
+	return 1
+}
+
+// Tokenize lowercases text, strips accents and splits it into words made of
+// letters and digits.
+func Tokenize(text string) []string {
+	var tokens []string
+	for _, span := range tokenSpans(text) {
+		tokens = append(tokens, span.token)
+	}
+	return tokens
+}
+
+type span struct {
+	start, end int
+	token      string
+}
+
+func tokenSpans(text string) []span {
+	var spans []span
+	start := -1
+	flush := func(end int) {
+		if start >= 0 {
+			if token := foldToken(text[start:end]); token != "" {
+				spans = append(spans, span{start: start, end: end, token: token})
+			}
+			start = -1
+		}
+	}
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(text))
+	return spans
+}
+
+func foldToken(token string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(strings.ToLower(token)) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func digitsOnly(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func isDigits(token string) bool {
+	for _, r := range token {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return token != ""
+}
+
+// Highlight wraps the words of value whose folded form is in matched with
+// <em> tags.
+func Highlight(value string, matched map[string]bool) string {
+	var b strings.Builder
+	last := 0
+	for _, s := range tokenSpans(value) {
+		if !matched[s.token] {
+			continue
+		}
+		b.WriteString(value[last:s.start])
+		b.WriteString("<em>")
+		b.WriteString(value[s.start:s.end])
+		b.WriteString("</em>")
+		last = s.end
+	}
+	b.WriteString(value[last:])
+	return b.String()
+}
+
+// InvalidateOnCommit drops the owners' documents from DefaultIndex once uow
+// commits, moving their generations so that a load which read them before the
+// commit is not kept. Documents are indexed under the contact's owner and
+// searched by everyone who can see it (the owner, workspace members and share
+// recipients), so invalidating the owner reaches every viewer.
+func InvalidateOnCommit(uow *repository.UnitOfWork, ownerIDs ...uint) {
+	uow.AfterCommit(func() {
+		for _, ownerID := range ownerIDs {
+			DefaultIndex.Invalidate(ownerID)
+		}
+	})
+}
+
+// InvalidateContactsOnCommit is InvalidateOnCommit for the owners of
+// contacts, for writers that may not own what they change
+func InvalidateContactsOnCommit(uow *repository.UnitOfWork, contactIDs ...uint) error {
+	if len(contactIDs) == 0 {
+		return nil
+	}
+	var owners []uint
+	if err := uow.DB.Unscoped().Model(&contact.Contact{}).
+		Where("contact_id IN ?", contactIDs).
+		Distinct().Pluck("user_id", &owners).Error; err != nil {
+		return apperror.NewInternalError("failed to load contact owners")
+	}
+	InvalidateOnCommit(uow, owners...)
+	return nil
+}
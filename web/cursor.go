@@ -0,0 +1,77 @@
+package web
+
+import (
+	"Contact_App/apperror"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+)
+
+// Cursor marks a position in a sorted collection by the sort key values of a
+// row, so pages stay stable when rows are inserted or deleted elsewhere.
+type Cursor struct {
+	Values   []interface{} `json:"v"`
+	Backward bool          `json:"b,omitempty"`
+	Sort     string        `json:"s"`
+}
+
+// cursorKey signs cursors; it is set by InitCursorSecret at startup
+var cursorKey []byte
+
+// InitCursorSecret reads the key cursors are signed with from CURSOR_SECRET,
+// or JWT_SECRET without one. It fails when neither is set, since a well-known
+// key would let clients forge cursors.
+func InitCursorSecret() error {
+	secret := os.Getenv("CURSOR_SECRET")
+	if secret == "" {
+		secret = os.Getenv("JWT_SECRET")
+	}
+	if secret == "" {
+		return errors.New("CURSOR_SECRET or JWT_SECRET must be set")
+	}
+	cursorKey = []byte(secret)
+	return nil
+}
+
+// EncodeCursor serializes and signs a cursor into an opaque URL-safe token.
+func EncodeCursor(c Cursor) string {
+	payload, _ := json.Marshal(c)
+	mac := hmac.New(sha256.New, cursorKey)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// DecodeCursor verifies the signature of token and returns the cursor it holds.
+func DecodeCursor(token string) (*Cursor, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, apperror.NewValidationError("cursor", "malformed cursor")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, apperror.NewValidationError("cursor", "malformed cursor")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, apperror.NewValidationError("cursor", "malformed cursor")
+	}
+
+	mac := hmac.New(sha256.New, cursorKey)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, apperror.NewValidationError("cursor", "signature mismatch")
+	}
+
+	var c Cursor
+	decoder := json.NewDecoder(strings.NewReader(string(payload)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&c); err != nil {
+		return nil, apperror.NewValidationError("cursor", "malformed cursor")
+	}
+	return &c, nil
+}
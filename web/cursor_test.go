@@ -0,0 +1,122 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInitCursorSecret(t *testing.T) {
+	tests := []struct {
+		name         string
+		cursorSecret string
+		jwtSecret    string
+		wantKey      string
+		wantErr      bool
+	}{
+		{"cursor secret", "cursor", "jwt", "cursor", false},
+		{"falls back to jwt secret", "", "jwt", "jwt", false},
+		{"no secret", "", "", "", true},
+	}
+	defer func(key []byte) { cursorKey = key }(cursorKey)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CURSOR_SECRET", tt.cursorSecret)
+			t.Setenv("JWT_SECRET", tt.jwtSecret)
+			cursorKey = nil
+
+			err := InitCursorSecret()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InitCursorSecret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(cursorKey) != tt.wantKey {
+				t.Errorf("cursorKey = %q, want %q", cursorKey, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	defer func(key []byte) { cursorKey = key }(cursorKey)
+	cursorKey = []byte("test-secret")
+
+	tests := []struct {
+		name   string
+		cursor Cursor
+		want   Cursor
+	}{
+		{
+			name:   "string and number values",
+			cursor: Cursor{Values: []interface{}{"Smith", 42}, Sort: "last_name"},
+			want:   Cursor{Values: []interface{}{"Smith", json.Number("42")}, Sort: "last_name"},
+		},
+		{
+			name:   "backward",
+			cursor: Cursor{Values: []interface{}{"2024-01-02T03:04:05Z", 7}, Backward: true, Sort: "-updated_at"},
+			want:   Cursor{Values: []interface{}{"2024-01-02T03:04:05Z", json.Number("7")}, Backward: true, Sort: "-updated_at"},
+		},
+		{
+			name:   "null value",
+			cursor: Cursor{Values: []interface{}{nil, 1}, Sort: "birthday"},
+			want:   Cursor{Values: []interface{}{nil, json.Number("1")}, Sort: "birthday"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := EncodeCursor(tt.cursor)
+			if strings.ContainsAny(token, "+/=") {
+				t.Errorf("EncodeCursor() = %q, want a URL-safe token", token)
+			}
+			got, err := DecodeCursor(token)
+			if err != nil {
+				t.Fatalf("DecodeCursor() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("DecodeCursor() = %#v, want %#v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	defer func(key []byte) { cursorKey = key }(cursorKey)
+	cursorKey = []byte("test-secret")
+	valid := EncodeCursor(Cursor{Values: []interface{}{1}, Sort: "id"})
+	payload, signature, _ := strings.Cut(valid, ".")
+
+	cursorKey = []byte("other-secret")
+	foreign := EncodeCursor(Cursor{Values: []interface{}{1}, Sort: "id"})
+	cursorKey = []byte("test-secret")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"no signature", payload},
+		{"too many parts", valid + ".x"},
+		{"bad payload encoding", "!!!." + signature},
+		{"bad signature encoding", payload + ".!!!"},
+		{"tampered payload", "e30." + signature},
+		{"signed with another key", foreign},
+		{"signed garbage", signedPayload("not json")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if c, err := DecodeCursor(tt.token); err == nil {
+				t.Errorf("DecodeCursor(%q) = %#v, want an error", tt.token, c)
+			}
+		})
+	}
+}
+
+// signedPayload signs an arbitrary payload the way EncodeCursor does
+func signedPayload(payload string) string {
+	mac := hmac.New(sha256.New, cursorKey)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"Contact_App/apperror"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ETag renders a resource version as a strong entity tag
+func ETag(version uint) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// ViewETag is the entity tag of a resource at version whose representation
+// also carries state that changes without a new version, such as what the
+// viewer starred. It is the version followed by a hash of that state, so it
+// still names the version when sent back as If-Match.
+func ViewETag(version uint, state interface{}) string {
+	encoded, _ := json.Marshal(state)
+	sum := sha256.Sum256(encoded)
+	return fmt.Sprintf(`"%d-%s"`, version, hex.EncodeToString(sum[:8]))
+}
+
+// SetETag exposes the resource version to the client as an ETag header
+func SetETag(w http.ResponseWriter, version uint) {
+	SetNewHeader(w, "ETag", ETag(version))
+}
+
+// IfMatchVersion reads the If-Match header. It returns 0 when the header is
+// absent or "*", which callers treat as an unconditional request.
+func IfMatchVersion(r *http.Request) (uint, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	if strings.Contains(header, ",") {
+		return 0, apperror.NewValidationError("If-Match", "must name a single version")
+	}
+
+	version, ok := parseETag(header)
+	if !ok || version == 0 {
+		return 0, apperror.NewValidationError("If-Match", "must be an ETag returned by the server")
+	}
+	return version, nil
+}
+
+// NotModified sets the ETag for version and, when the request's If-None-Match
+// already names it, answers 304 and reports true so the caller can stop
+func NotModified(w http.ResponseWriter, r *http.Request, version uint) bool {
+	return NotModifiedTag(w, r, ETag(version))
+}
+
+// NotModifiedTag is NotModified for resources whose entity tag is not a
+// version number, such as a content hash. tag must already be quoted.
+func NotModifiedTag(w http.ResponseWriter, r *http.Request, tag string) bool {
+	SetNewHeader(w, "ETag", tag)
+
+	header := strings.TrimSpace(r.Header.Get("If-None-Match"))
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	// If-None-Match uses weak comparison, so W/ prefixes are ignored on both sides
+	want := strings.TrimPrefix(tag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == want {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// parseETag accepts `"3"`, a ViewETag such as `"3-9f86d081884c7d65"` or the
+// weak form of either, and returns the version
+func parseETag(tag string) (uint, bool) {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	version, _, _ := strings.Cut(tag[1:len(tag)-1], "-")
+	v, err := strconv.ParseUint(version, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(v), true
+}
@@ -1,45 +1,439 @@
 package web
 
 import (
+	"Contact_App/apperror"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
-func Paginate(w http.ResponseWriter, r *http.Request, db *gorm.DB, out interface{}, baseQuery *gorm.DB) {
-	page := 1
-	limit := 5
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// PageRequest is the parsed form of the limit, cursor and with_total parameters.
+type PageRequest struct {
+	Limit     int
+	Cursor    *Cursor
+	WithTotal bool
+}
 
-	if p := r.URL.Query().Get("page"); p != "" {
-		if pi, err := strconv.Atoi(p); err == nil && pi > 0 {
-			page = pi
+// Page is the response envelope of every paginated collection.
+type Page struct {
+	Data       interface{} `json:"data"`
+	Limit      int         `json:"limit"`
+	Next       string      `json:"next,omitempty"`
+	Prev       string      `json:"prev,omitempty"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	PrevCursor string      `json:"prev_cursor,omitempty"`
+	Total      *int64      `json:"total,omitempty"`
+}
+
+// ParsePageRequest reads limit (capped at MaxPageSize), cursor and with_total.
+func ParsePageRequest(r *http.Request) (*PageRequest, error) {
+	page := &PageRequest{Limit: DefaultPageSize}
+
+	if l := strings.TrimSpace(r.URL.Query().Get("limit")); l != "" {
+		li, err := strconv.Atoi(l)
+		if err != nil || li <= 0 {
+			return nil, apperror.NewValidationError("limit", "must be a positive integer")
 		}
+		page.Limit = min(li, MaxPageSize)
 	}
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if li, err := strconv.Atoi(l); err == nil && li > 0 {
-			limit = li
+
+	if c := strings.TrimSpace(r.URL.Query().Get("cursor")); c != "" {
+		cursor, err := DecodeCursor(c)
+		if err != nil {
+			return nil, err
 		}
+		page.Cursor = cursor
 	}
-	offset := (page - 1) * limit
 
-	var total int64
-	if err := baseQuery.Count(&total).Error; err != nil {
+	page.WithTotal, _ = strconv.ParseBool(r.URL.Query().Get("with_total"))
+	return page, nil
+}
+
+// Paginate runs baseQuery one keyset page at a time and responds with a Page.
+// Rows are ordered by the spec's sort fields followed by the primary key.
+func Paginate(w http.ResponseWriter, r *http.Request, out interface{}, baseQuery *gorm.DB, spec *QuerySpec) {
+	page, err := PaginateQuery(r, baseQuery, out, spec)
+	if err != nil {
 		RespondError(w, err)
 		return
 	}
+	RespondJSON(w, http.StatusOK, page.Project(spec))
+}
 
-	if err := baseQuery.Limit(limit).Offset(offset).Find(out).Error; err != nil {
-		RespondError(w, err)
-		return
+// Project trims the page data down to the fields requested in spec.
+func (p *Page) Project(spec *QuerySpec) *Page {
+	p.Data = spec.Project(p.Data)
+	return p
+}
+
+// PaginateQuery loads one keyset page of baseQuery into out, which must be a
+// pointer to a slice, and returns the page envelope. The rows in out can still
+// be adjusted before the page is projected and sent.
+func PaginateQuery(r *http.Request, baseQuery *gorm.DB, out interface{}, spec *QuerySpec) (*Page, error) {
+	page, err := ParsePageRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sorts := spec.KeysetOrder()
+	signature := sortSignature(sorts)
+	base := baseQuery.Session(&gorm.Session{})
+
+	result := &Page{Limit: page.Limit}
+	if page.WithTotal {
+		var total int64
+		if err := base.Count(&total).Error; err != nil {
+			return nil, apperror.NewInternalError("failed to count records")
+		}
+		result.Total = &total
+	}
+
+	query := base
+	backward := false
+	if page.Cursor != nil {
+		if page.Cursor.Sort != signature || len(page.Cursor.Values) != len(sorts) {
+			return nil, apperror.NewValidationError("cursor", "does not match the requested sort")
+		}
+		backward = page.Cursor.Backward
+		condition, args, err := keysetCondition(sorts, page.Cursor.Values, backward)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(condition, args...)
+	}
+
+	for _, s := range sorts {
+		order := s.Column
+		if s.Descending != backward {
+			order += " DESC"
+		}
+		query = query.Order(order)
+	}
+
+	if err := query.Limit(page.Limit + 1).Find(out).Error; err != nil {
+		return nil, apperror.NewInternalError(fmt.Sprintf("failed to fetch records: %v", err))
+	}
+
+	rows := reflect.ValueOf(out).Elem()
+	hasMore := rows.Len() > page.Limit
+	if hasMore {
+		rows.Set(rows.Slice(0, page.Limit))
+	}
+	if backward {
+		reverse(rows)
+	}
+
+	if err := result.setCursors(r, rows, sorts, signature, page.Cursor != nil, backward, hasMore); err != nil {
+		return nil, err
+	}
+	result.Data = rows.Interface()
+	return result, nil
+}
+
+// PaginateSlice pages through items, a slice already sorted by sorts, the way
+// PaginateQuery pages through a query. It is used where ordering cannot be
+// expressed in SQL, such as search relevance.
+func PaginateSlice(r *http.Request, items interface{}, sorts []SortField) (*Page, error) {
+	page, err := ParsePageRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	signature := sortSignature(sorts)
+	all := reflect.ValueOf(items)
+
+	result := &Page{Limit: page.Limit}
+	if page.WithTotal {
+		total := int64(all.Len())
+		result.Total = &total
+	}
+
+	start, end := 0, all.Len()
+	backward := false
+	if page.Cursor != nil {
+		if page.Cursor.Sort != signature || len(page.Cursor.Values) != len(sorts) {
+			return nil, apperror.NewValidationError("cursor", "does not match the requested sort")
+		}
+		backward = page.Cursor.Backward
+		// position of the first row sorting after the cursor
+		pos := all.Len()
+		for i := 0; i < all.Len(); i++ {
+			values, err := rowValues(all.Index(i), sorts)
+			if err != nil {
+				return nil, err
+			}
+			if compareKeys(sorts, values, page.Cursor.Values) > 0 {
+				pos = i
+				break
+			}
+		}
+		if backward {
+			// rows before the cursor row itself
+			end = pos
+			for end > 0 {
+				values, err := rowValues(all.Index(end-1), sorts)
+				if err != nil {
+					return nil, err
+				}
+				if compareKeys(sorts, values, page.Cursor.Values) < 0 {
+					break
+				}
+				end--
+			}
+		} else {
+			start = pos
+		}
+	}
+
+	hasMore := false
+	if backward {
+		if end-start > page.Limit {
+			start = end - page.Limit
+			hasMore = true
+		}
+	} else if end-start > page.Limit {
+		end = start + page.Limit
+		hasMore = true
+	}
+
+	rows := reflect.MakeSlice(all.Type(), end-start, end-start)
+	reflect.Copy(rows, all.Slice(start, end))
+	if err := result.setCursors(r, rows, sorts, signature, page.Cursor != nil, backward, hasMore); err != nil {
+		return nil, err
+	}
+	result.Data = rows.Interface()
+	return result, nil
+}
+
+func (p *Page) setCursors(r *http.Request, rows reflect.Value, sorts []SortField, signature string, hadCursor, backward, hasMore bool) error {
+	if rows.Len() == 0 {
+		return nil
+	}
+
+	hasNext := (!backward && hasMore) || backward
+	hasPrev := (backward && hasMore) || (!backward && hadCursor)
+
+	if hasNext {
+		values, err := rowValues(rows.Index(rows.Len()-1), sorts)
+		if err != nil {
+			return err
+		}
+		p.NextCursor = EncodeCursor(Cursor{Values: values, Sort: signature})
+		p.Next = pageLink(r, p.NextCursor)
+	}
+	if hasPrev {
+		values, err := rowValues(rows.Index(0), sorts)
+		if err != nil {
+			return err
+		}
+		p.PrevCursor = EncodeCursor(Cursor{Values: values, Backward: true, Sort: signature})
+		p.Prev = pageLink(r, p.PrevCursor)
+	}
+	return nil
+}
+
+// KeysetOrder is the spec's sort followed by the primary key, which makes
+// every row's position unique.
+func (q *QuerySpec) KeysetOrder() []SortField {
+	sorts := append([]SortField{}, q.Sort...)
+	for _, s := range sorts {
+		if s.Column == q.primaryKey.Column {
+			return sorts
+		}
+	}
+	return append(sorts, SortField{
+		JSONName: q.primaryKey.JSONName,
+		Column:   q.primaryKey.Column,
+		Type:     q.primaryKey.Type,
+	})
+}
+
+func sortSignature(sorts []SortField) string {
+	parts := make([]string, 0, len(sorts))
+	for _, s := range sorts {
+		part := s.JSONName
+		if s.Descending {
+			part = "-" + part
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ",")
+}
+
+// keysetCondition builds "(a > ?) OR (a = ? AND b > ?) ..." selecting the rows
+// after (or, going backward, before) the cursor values.
+func keysetCondition(sorts []SortField, values []interface{}, backward bool) (string, []interface{}, error) {
+	var clauses []string
+	var args []interface{}
+	for i := range sorts {
+		var parts []string
+		for j := 0; j < i; j++ {
+			arg, err := cursorArg(sorts[j], values[j])
+			if err != nil {
+				return "", nil, err
+			}
+			parts = append(parts, sorts[j].Column+" = ?")
+			args = append(args, arg)
+		}
+		arg, err := cursorArg(sorts[i], values[i])
+		if err != nil {
+			return "", nil, err
+		}
+		op := " > ?"
+		if sorts[i].Descending != backward {
+			op = " < ?"
+		}
+		parts = append(parts, sorts[i].Column+op)
+		args = append(args, arg)
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")", args, nil
+}
+
+func cursorArg(field SortField, value interface{}) (interface{}, error) {
+	switch field.Type {
+	case FieldNumber:
+		if n, ok := value.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				return i, nil
+			}
+			return n.Float64()
+		}
+	case FieldTime:
+		if s, ok := value.(string); ok {
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, apperror.NewValidationError("cursor", "malformed cursor")
+			}
+			return t, nil
+		}
+	}
+	return value, nil
+}
+
+// rowValues extracts the sort key values of a row through its JSON form, so
+// the same code works for every model.
+func rowValues(row reflect.Value, sorts []SortField) ([]interface{}, error) {
+	raw, err := json.Marshal(row.Interface())
+	if err != nil {
+		return nil, apperror.NewInternalError("failed to build cursor")
 	}
+	var fields map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(raw)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, apperror.NewInternalError("failed to build cursor")
+	}
+
+	values := make([]interface{}, 0, len(sorts))
+	for _, s := range sorts {
+		values = append(values, lookupPath(fields, s.JSONName))
+	}
+	return values, nil
+}
+
+// lookupPath resolves a dotted JSON name such as custom_fields.tier; missing
+// members resolve to nil
+func lookupPath(fields map[string]interface{}, name string) interface{} {
+	parts := strings.Split(name, ".")
+	for _, part := range parts[:len(parts)-1] {
+		nested, ok := fields[part].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		fields = nested
+	}
+	return fields[parts[len(parts)-1]]
+}
+
+// SortSlice orders items, a slice, by sorts using the same comparison
+// PaginateSlice uses to locate a cursor, so the two always agree.
+func SortSlice(items interface{}, sorts []SortField) error {
+	rows := reflect.ValueOf(items)
+	keys := make([][]interface{}, rows.Len())
+	order := make([]int, rows.Len())
+	for i := range keys {
+		values, err := rowValues(rows.Index(i), sorts)
+		if err != nil {
+			return err
+		}
+		keys[i] = values
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return compareKeys(sorts, keys[order[a]], keys[order[b]]) < 0
+	})
+
+	sorted := reflect.MakeSlice(rows.Type(), rows.Len(), rows.Len())
+	for i, idx := range order {
+		sorted.Index(i).Set(rows.Index(idx))
+	}
+	reflect.Copy(rows, sorted)
+	return nil
+}
 
-	resp := map[string]interface{}{
-		"data":  out,
-		"total": total,
-		"page":  page,
-		"limit": limit,
+// compareKeys compares two rows' sort keys in sort order: negative when a
+// comes before b.
+func compareKeys(sorts []SortField, a, b []interface{}) int {
+	for i, s := range sorts {
+		c := compareValues(a[i], b[i])
+		if s.Descending {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareValues orders missing values first, like SQL orders NULL
+func compareValues(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		}
+		return 1
 	}
+	if na, ok := a.(json.Number); ok {
+		if nb, ok := b.(json.Number); ok {
+			fa, _ := na.Float64()
+			fb, _ := nb.Float64()
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func reverse(rows reflect.Value) {
+	swap := reflect.Swapper(rows.Interface())
+	for i, j := 0, rows.Len()-1; i < j; i, j = i+1, j-1 {
+		swap(i, j)
+	}
+}
 
-	RespondJSON(w, http.StatusOK, resp)
+func pageLink(r *http.Request, cursor string) string {
+	query := r.URL.Query()
+	query.Set("cursor", cursor)
+	return r.URL.Path + "?" + query.Encode()
 }
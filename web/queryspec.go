@@ -0,0 +1,314 @@
+package web
+
+import (
+	"Contact_App/apperror"
+	"Contact_App/repository"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type FieldType int
+
+const (
+	FieldString FieldType = iota
+	FieldNumber
+	FieldBool
+	FieldTime
+)
+
+// FieldSpec describes one field a client may sort, select or filter on.
+// Column is empty for fields that only exist in the response, such as
+// preloaded associations.
+type FieldSpec struct {
+	JSONName string
+	Column   string
+	Type     FieldType
+}
+
+// ResourceSpec is the per-resource whitelist used by ParseQuerySpec. Fields is
+// keyed by every name a client may use, so both "last_name" and "l_name" can
+// point at the same column.
+type ResourceSpec struct {
+	Fields     map[string]FieldSpec
+	PrimaryKey string
+}
+
+type SortField struct {
+	JSONName   string
+	Column     string
+	Type       FieldType
+	Descending bool
+}
+
+type FilterExpr struct {
+	Column   string
+	Operator string
+	Args     []interface{}
+}
+
+// QuerySpec is the parsed form of sort=, fields= and field[op]=value query parameters.
+type QuerySpec struct {
+	Sort    []SortField
+	Columns []string
+	Fields  []string
+	Filters []FilterExpr
+
+	primaryKey FieldSpec
+}
+
+var filterKeyPattern = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_.]*)\[([a-z_]+)\]$`)
+
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"}
+
+// ParseQuerySpec parses sort, fields and filter parameters against the
+// resource whitelist:
+//
+//	sort=-l_name,f_name
+//	fields=contact_id,first_name
+//	last_name[contains]=smi&contact_id[in]=1,2,3&created_at[between]=2024-01-01,2024-02-01
+//
+// Supported operators are eq, ne, in, contains, gt, gte, lt, lte and between.
+func ParseQuerySpec(values url.Values, resource ResourceSpec) (*QuerySpec, error) {
+	spec := &QuerySpec{primaryKey: resource.Fields[resource.PrimaryKey]}
+
+	if sortParam := strings.TrimSpace(values.Get("sort")); sortParam != "" {
+		for _, name := range strings.Split(sortParam, ",") {
+			name = strings.TrimSpace(name)
+			desc := strings.HasPrefix(name, "-")
+			name = strings.TrimPrefix(strings.TrimPrefix(name, "-"), "+")
+			field, ok := resource.Fields[name]
+			if !ok || field.Column == "" {
+				return nil, apperror.NewValidationError("sort", "cannot sort by "+name)
+			}
+			spec.Sort = append(spec.Sort, SortField{JSONName: field.JSONName, Column: field.Column, Type: field.Type, Descending: desc})
+		}
+	}
+
+	if fieldsParam := strings.TrimSpace(values.Get("fields")); fieldsParam != "" {
+		selected := make(map[string]bool)
+		for _, name := range strings.Split(fieldsParam, ",") {
+			name = strings.TrimSpace(name)
+			field, ok := resource.Fields[name]
+			if !ok {
+				return nil, apperror.NewValidationError("fields", "unknown field "+name)
+			}
+			if selected[field.JSONName] {
+				continue
+			}
+			selected[field.JSONName] = true
+			spec.Fields = append(spec.Fields, field.JSONName)
+			if field.Column != "" {
+				spec.Columns = append(spec.Columns, field.Column)
+			}
+		}
+		// the primary key is always loaded so associations can be preloaded,
+		// and sort columns are needed to build pagination cursors
+		loaded := make(map[string]bool, len(spec.Columns))
+		for _, column := range spec.Columns {
+			loaded[column] = true
+		}
+		extra := append([]string{spec.primaryKey.Column}, sortColumns(spec.Sort)...)
+		for _, column := range extra {
+			if column != "" && !loaded[column] {
+				loaded[column] = true
+				spec.Columns = append(spec.Columns, column)
+			}
+		}
+	}
+
+	for key, vals := range values {
+		match := filterKeyPattern.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+		name, op := match[1], match[2]
+		field, ok := resource.Fields[name]
+		if !ok || field.Column == "" {
+			return nil, apperror.NewValidationError(name, "filtering is not allowed on this field")
+		}
+		for _, raw := range vals {
+			expr, err := parseFilter(field, op, raw)
+			if err != nil {
+				return nil, err
+			}
+			spec.Filters = append(spec.Filters, expr)
+		}
+	}
+
+	return spec, nil
+}
+
+func sortColumns(sorts []SortField) []string {
+	columns := make([]string, 0, len(sorts))
+	for _, s := range sorts {
+		columns = append(columns, s.Column)
+	}
+	return columns
+}
+
+func parseFilter(field FieldSpec, op, raw string) (FilterExpr, error) {
+	expr := FilterExpr{Column: field.Column, Operator: op}
+	raw = strings.TrimSpace(raw)
+
+	switch op {
+	case "eq", "ne", "gt", "gte", "lt", "lte":
+		v, err := convertValue(field, raw)
+		if err != nil {
+			return expr, err
+		}
+		expr.Args = []interface{}{v}
+	case "contains":
+		if field.Type != FieldString {
+			return expr, apperror.NewValidationError(field.JSONName, "contains is only supported on text fields")
+		}
+		expr.Args = []interface{}{"%" + escapeLike(raw) + "%"}
+	case "in":
+		var list []interface{}
+		for _, part := range strings.Split(raw, ",") {
+			v, err := convertValue(field, strings.TrimSpace(part))
+			if err != nil {
+				return expr, err
+			}
+			list = append(list, v)
+		}
+		expr.Args = []interface{}{list}
+	case "between":
+		parts := strings.Split(raw, ",")
+		if len(parts) != 2 {
+			return expr, apperror.NewValidationError(field.JSONName, "between expects two comma separated values")
+		}
+		from, err := convertValue(field, strings.TrimSpace(parts[0]))
+		if err != nil {
+			return expr, err
+		}
+		to, err := convertValue(field, strings.TrimSpace(parts[1]))
+		if err != nil {
+			return expr, err
+		}
+		expr.Args = []interface{}{from, to}
+	default:
+		return expr, apperror.NewValidationError(field.JSONName, "unsupported operator "+op)
+	}
+	return expr, nil
+}
+
+func convertValue(field FieldSpec, raw string) (interface{}, error) {
+	switch field.Type {
+	case FieldNumber:
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, apperror.NewValidationError(field.JSONName, "must be a number")
+		}
+		return v, nil
+	case FieldBool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, apperror.NewValidationError(field.JSONName, "must be a boolean")
+		}
+		return v, nil
+	case FieldTime:
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, raw); err == nil {
+				return t, nil
+			}
+		}
+		return nil, apperror.NewValidationError(field.JSONName, "must be a date (YYYY-MM-DD) or RFC 3339 timestamp")
+	default:
+		return raw, nil
+	}
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+var filterConditions = map[string]string{
+	"eq":       "%s = ?",
+	"ne":       "%s <> ?",
+	"gt":       "%s > ?",
+	"gte":      "%s >= ?",
+	"lt":       "%s < ?",
+	"lte":      "%s <= ?",
+	"in":       "%s IN ?",
+	"contains": "%s LIKE ?",
+	"between":  "%s BETWEEN ? AND ?",
+}
+
+// Processors converts the spec into repository query processors.
+func (q *QuerySpec) Processors() []repository.QueryProcessor {
+	processors := q.FilterProcessors()
+	for _, s := range q.Sort {
+		order := s.Column
+		if s.Descending {
+			order += " DESC"
+		}
+		processors = append(processors, repository.OrderBy(order))
+	}
+	return processors
+}
+
+// FilterProcessors converts the filters and field selection into repository
+// query processors, leaving the ordering to the caller (see Paginate).
+func (q *QuerySpec) FilterProcessors() []repository.QueryProcessor {
+	var processors []repository.QueryProcessor
+	for _, f := range q.Filters {
+		condition := strings.Replace(filterConditions[f.Operator], "%s", f.Column, 1)
+		processors = append(processors, repository.Filter(condition, f.Args...))
+	}
+	if len(q.Columns) > 0 {
+		processors = append(processors, repository.Select(strings.Join(q.Columns, ", ")))
+	}
+	return processors
+}
+
+// HasSort reports whether the client asked for an explicit order.
+func (q *QuerySpec) HasSort() bool {
+	return len(q.Sort) > 0
+}
+
+// Project trims payload down to the requested fields. Payload may be a single
+// object or a list of objects; it is returned unchanged when no fields were requested.
+func (q *QuerySpec) Project(payload interface{}) interface{} {
+	if q == nil || len(q.Fields) == 0 {
+		return payload
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return payload
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return payload
+	}
+
+	// nested names such as custom_fields.tier keep their whole top-level member
+	keep := make(map[string]bool, len(q.Fields))
+	for _, f := range q.Fields {
+		keep[strings.SplitN(f, ".", 2)[0]] = true
+	}
+	project := func(v interface{}) interface{} {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		for k := range obj {
+			if !keep[k] {
+				delete(obj, k)
+			}
+		}
+		return obj
+	}
+
+	if list, ok := generic.([]interface{}); ok {
+		for i := range list {
+			list[i] = project(list[i])
+		}
+		return list
+	}
+	return project(generic)
+}
@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
 )
 
 type CreateDetailRequest struct {
@@ -39,3 +42,34 @@ func UnmarshalJSON(r *http.Request, out interface{}) error {
 
 	return nil
 }
+
+// MaxPatchBodySize caps PATCH documents so a runaway client cannot exhaust memory
+const MaxPatchBodySize = 1 << 20
+
+// ReadPatchBody returns the raw PATCH document for the patch package to interpret
+func ReadPatchBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, apperror.NewValidationError("body", "request body is empty")
+	}
+	defer r.Body.Close()
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxPatchBodySize))
+	if err != nil {
+		return nil, apperror.NewValidationError("body", "unable to read request body")
+	}
+	if len(body) == 0 {
+		return nil, apperror.NewValidationError("body", "request body is empty")
+	}
+	return body, nil
+}
+
+// ParseID reads the numeric path variable name. It writes a 400 response and
+// reports false when the variable is not a valid ID.
+func ParseID(w http.ResponseWriter, r *http.Request, name string) (uint, bool) {
+	id64, err := strconv.ParseUint(mux.Vars(r)[name], 10, 64)
+	if err != nil {
+		apperror.HandleBadRequest(w, "invalid "+name)
+		return 0, false
+	}
+	return uint(id64), true
+}